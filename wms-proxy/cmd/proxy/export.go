@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"wms-proxy/internal/config"
+	"wms-proxy/internal/server"
+	"wms-proxy/internal/tilefile"
+)
+
+// exportTiles runs the export-tiles command: it renders the tiles of a bbox
+// and zoom range through the proxy, using its caches, and writes them to an
+// MBTiles or GeoPackage file
+func exportTiles(args []string) error {
+	flags := flag.NewFlagSet("export-tiles", flag.ExitOnError)
+	output := flags.String("output", "", "output file (.mbtiles or .gpkg)")
+	packageFormat := flags.String("package", "", "package format: mbtiles or gpkg (default from the output extension)")
+	bbox := flags.String("bbox", "", "WGS84 bounds: minlon,minlat,maxlon,maxlat")
+	minZoom := flags.Int("min-zoom", 0, "lowest zoom level")
+	maxZoom := flags.Int("max-zoom", 14, "highest zoom level")
+	layers := flags.String("layers", "", "WMS layers of raster tiles, comma separated")
+	vectorLayer := flags.String("vector-layer", "", "layer of vector tiles (MBTiles only)")
+	backendName := flags.String("backend", "", "named backend (default: the ARCGIS_HOST backend)")
+	tileFormat := flags.String("format", tilefile.TilePNG, "raster tile format: png or jpg")
+	name := flags.String("name", "", "tileset name (default: the layers)")
+	description := flags.String("description", "", "tileset description")
+	attribution := flags.String("attribution", "", "tileset attribution")
+	concurrency := flags.Int("concurrency", 4, "tiles rendered in parallel")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export-tiles -output FILE -bbox BOUNDS (-layers LAYERS | -vector-layer LAYER) [options]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *output == "" {
+		return fmt.Errorf("-output is required")
+	}
+	bounds, err := parseBounds(*bbox)
+	if err != nil {
+		return err
+	}
+	if (*layers == "") == (*vectorLayer == "") {
+		return fmt.Errorf("exactly one of -layers and -vector-layer is required")
+	}
+	if *packageFormat == "" {
+		if *packageFormat, err = tilefile.FormatFromPath(*output); err != nil {
+			return err
+		}
+	}
+
+	mount := ""
+	if *backendName != "" {
+		mount = "/" + *backendName
+	}
+	metadata := tilefile.Metadata{
+		Name:        *name,
+		Description: *description,
+		Attribution: *attribution,
+		Bounds:      bounds,
+		MinZoom:     *minZoom,
+		MaxZoom:     *maxZoom,
+	}
+	opts := tilefile.ExportOptions{
+		Bounds:      bounds,
+		MinZoom:     *minZoom,
+		MaxZoom:     *maxZoom,
+		Concurrency: *concurrency,
+	}
+	if *vectorLayer != "" {
+		metadata.Format = tilefile.TilePBF
+		metadata.VectorLayers = []string{*vectorLayer}
+		opts.TileURL = "/vt" + mount + "/" + url.PathEscape(*vectorLayer) + "/{z}/{x}/{y}.mvt"
+	} else {
+		mimeType := "image/png"
+		switch *tileFormat {
+		case tilefile.TilePNG:
+		case tilefile.TileJPEG, "jpeg":
+			*tileFormat, mimeType = tilefile.TileJPEG, "image/jpeg"
+		default:
+			return fmt.Errorf("-format must be png or jpg")
+		}
+		metadata.Format = *tileFormat
+		query := url.Values{
+			"SERVICE": {"WMS"}, "VERSION": {"1.1.1"}, "REQUEST": {"GetMap"},
+			"LAYERS": {*layers}, "STYLES": {""}, "SRS": {"EPSG:3857"},
+			"WIDTH": {"256"}, "HEIGHT": {"256"}, "FORMAT": {mimeType},
+			"TRANSPARENT": {strconv.FormatBool(mimeType == "image/png")},
+		}
+		// The placeholder is added unescaped so that it can be replaced
+		opts.TileURL = "/wms" + mount + "?" + query.Encode() + "&BBOX={bbox}"
+	}
+	opts.Format = metadata.Format
+	if metadata.Name == "" {
+		metadata.Name = *layers + *vectorLayer
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	srv, err := server.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	writer, err := tilefile.Create(*output, *packageFormat, metadata)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	start := time.Now()
+	stats, err := tilefile.Export(ctx, srv.Handler(), writer, opts, logger)
+	if err != nil {
+		writer.Abort()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	logger.Info("Exported tiles",
+		"output", *output,
+		"tiles", stats.Written,
+		"empty", stats.Empty,
+		"duration", time.Since(start).Round(time.Second).String())
+	return nil
+}
+
+// parseBounds parses "minlon,minlat,maxlon,maxlat"
+func parseBounds(value string) ([4]float64, error) {
+	var bounds [4]float64
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return bounds, fmt.Errorf("-bbox must be minlon,minlat,maxlon,maxlat")
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return bounds, fmt.Errorf("-bbox: invalid number %q", part)
+		}
+		bounds[i] = n
+	}
+	if bounds[0] >= bounds[2] || bounds[1] >= bounds[3] || bounds[0] < -180 || bounds[2] > 180 || bounds[1] < -85.0511 || bounds[3] > 85.0511 {
+		return bounds, fmt.Errorf("-bbox must be minlon,minlat,maxlon,maxlat within the Web Mercator world")
+	}
+	return bounds, nil
+}
@@ -10,6 +10,21 @@ import (
 )
 
 func main() {
+	// Subcommands
+	commands := map[string]func([]string) error{
+		"export-tiles":    exportTiles,
+		"validate-config": validateConfig,
+	}
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -29,9 +44,15 @@ func main() {
 	fmt.Printf("ArcGIS Service: %s\n", cfg.ArcGISService)
 	fmt.Printf("Request Timeout: %s\n", cfg.RequestTimeout)
 	fmt.Printf("Log Level: %s\n", cfg.LogLevel)
+	if cfg.File != "" {
+		fmt.Printf("Config File: %s\n", cfg.File)
+	}
 
 	// Create and start server
-	srv := server.New(cfg)
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
 
 	if err := srv.Start(); err != nil {
 		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"wms-proxy/internal/config"
+)
+
+// validateConfig runs the validate-config command: it loads the configuration
+// file and environment like the server does and lists every invalid setting
+func validateConfig(args []string) error {
+	flags := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "configuration file (default: CONFIG_FILE)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s validate-config [-config FILE]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := config.LoadFile(*file)
+	if err != nil {
+		var invalid config.Errors
+		if !errors.As(err, &invalid) {
+			return err
+		}
+		for _, fieldErr := range invalid {
+			fmt.Fprintln(os.Stderr, fieldErr.Error())
+		}
+		return fmt.Errorf("%d invalid settings", len(invalid))
+	}
+
+	source := "environment"
+	if cfg.File != "" {
+		source = cfg.File + " and environment"
+	}
+	fmt.Printf("Configuration is valid (%s): %d named backends, %d tile files\n", source, len(cfg.Backends), len(cfg.TileFiles))
+	return nil
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStorage keeps serialized entries in a directory, one file per key, so
+// replicas mounting the same directory share them. Reading an entry touches
+// its file; when the total size exceeds the limit, the least recently used
+// files are removed. The size is tracked per process and rescanned on
+// eviction.
+type FileStorage struct {
+	dir      string
+	maxBytes int64
+	mutex    sync.Mutex // guards size and eviction
+	size     int64
+}
+
+// NewFileStorage creates a file storage in dir holding at most maxBytes; with
+// maxBytes 0 nothing is stored
+func NewFileStorage(dir string, maxBytes int64) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	s := &FileStorage{dir: dir, maxBytes: maxBytes}
+	s.size, _ = s.scan()
+	return s, nil
+}
+
+// path returns the file of a key: its hash, sharded by the first two digits
+func (s *FileStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, name[:2], name)
+}
+
+// Get reads an entry; expired files and files of another key are misses
+func (s *FileStorage) Get(key string) (Entry, bool, error) {
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	storedKey, entry, expires, err := decodeEntry(data)
+	if err != nil {
+		s.Delete(key)
+		return Entry{}, false, err
+	}
+	if storedKey != key {
+		return Entry{}, false, nil
+	}
+	now := time.Now()
+	if now.After(expires) {
+		s.Delete(key)
+		return Entry{}, false, nil
+	}
+	os.Chtimes(path, now, now)
+	return entry, true, nil
+}
+
+// Set writes an entry, replacing its file atomically
+func (s *FileStorage) Set(key string, entry Entry, retention time.Duration) error {
+	data := encodeEntry(key, entry, entry.StoredAt.Add(retention))
+	size := int64(len(data))
+	if s.maxBytes <= 0 || size > s.maxBytes {
+		return nil
+	}
+
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	var previous int64
+	if info, err := os.Stat(path); err == nil {
+		previous = info.Size()
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.size += size - previous
+	if s.size > s.maxBytes {
+		s.evict()
+	}
+	return nil
+}
+
+// Delete removes the file of an entry
+func (s *FileStorage) Delete(key string) error {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.size -= info.Size()
+	return nil
+}
+
+// cacheFile is a stored entry found by scan
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// scan returns the total size of the stored entries, and the entries
+func (s *FileStorage) scan() (int64, []cacheFile) {
+	var total int64
+	var files []cacheFile
+	filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		// Files being written are neither counted nor evicted
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return total, files
+}
+
+// evict removes the least recently used files until the storage is down to
+// 90% of its limit; the caller must hold the mutex
+func (s *FileStorage) evict() {
+	total, files := s.scan()
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, file := range files {
+		if total <= s.maxBytes*9/10 {
+			break
+		}
+		if err := os.Remove(file.path); err == nil {
+			total -= file.size
+		}
+	}
+	s.size = total
+}
+
+// Stats returns storage statistics for monitoring
+func (s *FileStorage) Stats() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return map[string]interface{}{
+		"storage":   "filesystem",
+		"directory": s.dir,
+		"size":      s.size,
+		"max_bytes": s.maxBytes,
+	}
+}
+
+// Close does nothing; the files stay for the next storage on the directory
+func (s *FileStorage) Close() error {
+	return nil
+}
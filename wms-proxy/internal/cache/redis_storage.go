@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RedisConfig configures a Redis storage
+type RedisConfig struct {
+	Address   string        // host:port
+	Password  string        // sent with AUTH when set
+	DB        int           // database selected on connect
+	KeyPrefix string        // prefix of every key, e.g. "wms-proxy:tiles:"
+	Timeout   time.Duration // dial and command timeout
+	PoolSize  int           // idle connections kept open
+}
+
+// RedisStorage keeps serialized entries in a server speaking the Redis
+// protocol (Redis, Valkey, KeyDB...), so every replica of the proxy shares
+// them. Entries expire on the server after their retention; size limits and
+// eviction are left to the server's maxmemory policy.
+type RedisStorage struct {
+	config RedisConfig
+	idle   chan *redisConn
+	dials  atomic.Int64
+	closed atomic.Bool
+}
+
+// redisConn is a connection with its buffered reader
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// errRedisClosed is returned by commands on a closed storage
+var errRedisClosed = errors.New("redis storage is closed")
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedisStorage creates a Redis storage; connections are opened on demand
+func NewRedisStorage(config RedisConfig) *RedisStorage {
+	if config.Timeout <= 0 {
+		config.Timeout = time.Second
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = 16
+	}
+	return &RedisStorage{
+		config: config,
+		idle:   make(chan *redisConn, config.PoolSize),
+	}
+}
+
+// Get reads an entry; entries stored under another key are misses
+func (s *RedisStorage) Get(key string) (Entry, bool, error) {
+	reply, err := s.do("GET", s.config.KeyPrefix+key)
+	if err != nil || reply == nil {
+		return Entry{}, false, err
+	}
+	storedKey, entry, _, err := decodeEntry(reply)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if storedKey != key {
+		return Entry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// Set writes an entry expiring after retention
+func (s *RedisStorage) Set(key string, entry Entry, retention time.Duration) error {
+	data := encodeEntry(key, entry, entry.StoredAt.Add(retention))
+	milliseconds := max(retention.Milliseconds(), 1)
+	_, err := s.do("SET", s.config.KeyPrefix+key, string(data), "PX", strconv.FormatInt(milliseconds, 10))
+	return err
+}
+
+// Delete removes an entry
+func (s *RedisStorage) Delete(key string) error {
+	_, err := s.do("DEL", s.config.KeyPrefix+key)
+	return err
+}
+
+// Stats returns storage statistics for monitoring
+func (s *RedisStorage) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"storage":     "redis",
+		"address":     s.config.Address,
+		"key_prefix":  s.config.KeyPrefix,
+		"connections": s.dials.Load(),
+	}
+}
+
+// Close closes the idle connections; connections in use are closed when their
+// command completes, and later commands fail
+func (s *RedisStorage) Close() error {
+	s.closed.Store(true)
+	s.closeIdle()
+	return nil
+}
+
+// closeIdle closes the pooled connections
+func (s *RedisStorage) closeIdle() {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// do runs a command on a pooled connection. Connections are discarded after
+// network and protocol errors; error replies leave them usable.
+func (s *RedisStorage) do(args ...string) ([]byte, error) {
+	if s.closed.Load() {
+		return nil, errRedisClosed
+	}
+	conn, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	conn.conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	reply, err := conn.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case s.idle <- conn:
+		// Close may have emptied the pool before the connection was returned
+		if s.closed.Load() {
+			s.closeIdle()
+		}
+	default:
+		conn.conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one
+func (s *RedisStorage) conn() (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", s.config.Address, s.config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	s.dials.Add(1)
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+	netConn.SetDeadline(time.Now().Add(s.config.Timeout))
+
+	if s.config.Password != "" {
+		if _, err := conn.command("AUTH", s.config.Password); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("failed to authenticate to redis: %w", err)
+		}
+	}
+	if s.config.DB != 0 {
+		if _, err := conn.command("SELECT", strconv.Itoa(s.config.DB)); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("failed to select redis database: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// command sends a command as an array of bulk strings and reads its reply
+func (c *redisConn) command(args ...string) ([]byte, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads a simple string, error, integer or bulk string reply; a
+// null bulk string is returned as nil
+func (c *redisConn) readReply() ([]byte, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return append([]byte(nil), line[1:]...), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		length, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:length], nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func (c *redisConn) readLine() ([]byte, error) {
+	line, err := c.reader.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	return line[:len(line)-2], nil
+}
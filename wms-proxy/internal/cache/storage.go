@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage holds cache entries for a TileCache. Freshness and the stale windows
+// are evaluated by the TileCache; a storage only keeps entries, for at most
+// the retention passed to Set.
+type Storage interface {
+	Get(key string) (Entry, bool, error)
+	Set(key string, entry Entry, retention time.Duration) error
+	Delete(key string) error
+	Stats() map[string]interface{}
+	Close() error // releases connections; the storage is not used afterwards
+}
+
+// errCorruptEntry is returned when a serialized entry cannot be decoded
+var errCorruptEntry = errors.New("corrupt cache entry")
+
+// entryMagic starts every serialized entry
+const entryMagic = "WMSCACHE/1"
+
+// encodeEntry serializes an entry for the shared storages: a line with the
+// format, the storage times and the key, the headers in MIME format with the
+// content type, a blank line and the body
+func encodeEntry(key string, entry Entry, expires time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %d %d %s\r\n", entryMagic, entry.StoredAt.UnixNano(), expires.UnixNano(), key)
+
+	header := entry.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.Write(entry.Body)
+	return buf.Bytes()
+}
+
+// decodeEntry parses an entry serialized by encodeEntry
+func decodeEntry(data []byte) (string, Entry, time.Time, error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	line, err := reader.ReadLine()
+	if err != nil {
+		return "", Entry{}, time.Time{}, errCorruptEntry
+	}
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 || fields[0] != entryMagic {
+		return "", Entry{}, time.Time{}, errCorruptEntry
+	}
+	storedAt, err1 := strconv.ParseInt(fields[1], 10, 64)
+	expires, err2 := strconv.ParseInt(fields[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return "", Entry{}, time.Time{}, errCorruptEntry
+	}
+
+	header, err := reader.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", Entry{}, time.Time{}, errCorruptEntry
+	}
+	body, err := io.ReadAll(reader.R)
+	if err != nil {
+		return "", Entry{}, time.Time{}, errCorruptEntry
+	}
+
+	entry := Entry{
+		Body:        body,
+		ContentType: header.Get("Content-Type"),
+		Header:      http.Header(header),
+		StoredAt:    time.Unix(0, storedAt),
+	}
+	return fields[3], entry, time.Unix(0, expires), nil
+}
+
+// MemoryStorage keeps entries in process memory, bounded by total body size.
+// The least recently used entries are evicted when the size limit is reached.
+type MemoryStorage struct {
+	mutex    sync.Mutex
+	maxBytes int64
+	size     int64
+	items    map[string]*list.Element
+	lru      *list.List // front is most recently used
+}
+
+type memoryItem struct {
+	key   string
+	entry Entry
+}
+
+// NewMemoryStorage creates a memory storage holding at most maxBytes of
+// entry bodies; with maxBytes 0 nothing is stored
+func NewMemoryStorage(maxBytes int64) *MemoryStorage {
+	return &MemoryStorage{
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns a stored entry, marking it as recently used
+func (s *MemoryStorage) Get(key string) (Entry, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, exists := s.items[key]
+	if !exists {
+		return Entry{}, false, nil
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*memoryItem).entry, true, nil
+}
+
+// Set stores an entry, evicting least recently used entries as needed.
+// Entries larger than the whole storage are not stored. Entries are kept until
+// evicted or deleted, whatever the retention.
+func (s *MemoryStorage) Set(key string, entry Entry, retention time.Duration) error {
+	size := int64(len(entry.Body))
+	if s.maxBytes <= 0 || size > s.maxBytes {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, exists := s.items[key]; exists {
+		s.remove(element)
+	}
+	s.items[key] = s.lru.PushFront(&memoryItem{key: key, entry: entry})
+	s.size += size
+
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete removes an entry
+func (s *MemoryStorage) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, exists := s.items[key]; exists {
+		s.remove(element)
+	}
+	return nil
+}
+
+// remove deletes an element; the caller must hold the mutex
+func (s *MemoryStorage) remove(element *list.Element) {
+	item := s.lru.Remove(element).(*memoryItem)
+	delete(s.items, item.key)
+	s.size -= int64(len(item.entry.Body))
+}
+
+// Stats returns storage statistics for monitoring
+func (s *MemoryStorage) Stats() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return map[string]interface{}{
+		"storage":   "memory",
+		"entries":   len(s.items),
+		"size":      s.size,
+		"max_bytes": s.maxBytes,
+	}
+}
+
+// Close does nothing; the entries are released with the storage
+func (s *MemoryStorage) Close() error {
+	return nil
+}
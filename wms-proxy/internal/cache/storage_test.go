@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEntryEncoding(t *testing.T) {
+	storedAt := time.Now()
+	entry := Entry{
+		Body:        []byte("\x89PNG\r\n\r\nbinary"),
+		ContentType: "image/png",
+		Header:      http.Header{"Etag": {`"abc"`}, "Cache-Control": {"max-age=60"}},
+		StoredAt:    storedAt,
+	}
+	key := "resp:https://gis.example.com/export?bbox=1 2"
+
+	data := encodeEntry(key, entry, storedAt.Add(time.Hour))
+	if string(encodeEntry(key, entry, storedAt.Add(time.Hour))) != string(data) {
+		t.Error("expected a stable serialization")
+	}
+
+	decodedKey, decoded, expires, err := decodeEntry(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decodedKey != key || string(decoded.Body) != string(entry.Body) || decoded.ContentType != "image/png" {
+		t.Errorf("unexpected entry %q: %+v", decodedKey, decoded)
+	}
+	if decoded.Header.Get("ETag") != `"abc"` || decoded.Header.Get("Cache-Control") != "max-age=60" || decoded.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("unexpected headers: %v", decoded.Header)
+	}
+	if !decoded.StoredAt.Equal(storedAt) || !expires.Equal(storedAt.Add(time.Hour)) {
+		t.Errorf("unexpected times: %v %v", decoded.StoredAt, expires)
+	}
+
+	if _, _, _, err := decodeEntry([]byte("garbage")); err == nil {
+		t.Error("expected an error for a corrupt entry")
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := Entry{Body: []byte("tile"), ContentType: "application/vnd.mapbox-vector-tile", StoredAt: time.Now()}
+	if err := storage.Set("a", entry, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Another replica sharing the directory sees the entry
+	other, err := NewFileStorage(dir, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, found, err := other.Get("a")
+	if err != nil || !found || string(got.Body) != "tile" || got.ContentType != entry.ContentType {
+		t.Errorf("unexpected result: %+v %v %v", got, found, err)
+	}
+	if _, found, _ := other.Get("b"); found {
+		t.Error("expected a miss")
+	}
+
+	// Entries past their retention are removed
+	if err := storage.Set("expired", entry, time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, found, _ := storage.Get("expired"); found {
+		t.Error("expected the expired entry to be removed")
+	}
+
+	// The least recently used entries are evicted above the size limit
+	large := Entry{Body: make([]byte, 400), StoredAt: time.Now()}
+	storage.Set("b", large, time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	storage.Get("a")
+	storage.Set("c", large, time.Hour)
+	storage.Set("d", large, time.Hour)
+	if _, found, _ := storage.Get("b"); found {
+		t.Error("expected b to be evicted")
+	}
+	if _, found, _ := storage.Get("d"); !found {
+		t.Error("expected d to remain stored")
+	}
+	if size := storage.Stats()["size"].(int64); size > 1024 {
+		t.Errorf("expected the size to be within the limit, got %d", size)
+	}
+}
+
+// fakeRedis is a stand-in for a Redis server supporting the commands used by
+// RedisStorage
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	mutex    sync.Mutex
+	values   map[string]string
+	expiry   map[string]time.Time
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &fakeRedis{listener: listener, password: password, values: map[string]string{}, expiry: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, count)
+		for i := range args {
+			line, _ := reader.ReadString('\n')
+			length, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			data := make([]byte, length+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+			args[i] = string(data[:length])
+		}
+
+		f.mutex.Lock()
+		var reply string
+		switch command := strings.ToUpper(args[0]); {
+		case command == "AUTH":
+			authenticated = args[1] == f.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case command == "SELECT":
+			reply = "+OK\r\n"
+		case command == "GET":
+			value, ok := f.values[args[1]]
+			if expiry, set := f.expiry[args[1]]; set && time.Now().After(expiry) {
+				ok = false
+			}
+			reply = "$-1\r\n"
+			if ok {
+				reply = "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+			}
+		case command == "SET":
+			f.values[args[1]] = args[2]
+			if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+				milliseconds, _ := strconv.Atoi(args[4])
+				f.expiry[args[1]] = time.Now().Add(time.Duration(milliseconds) * time.Millisecond)
+			}
+			reply = "+OK\r\n"
+		case command == "DEL":
+			_, ok := f.values[args[1]]
+			delete(f.values, args[1])
+			reply = ":0\r\n"
+			if ok {
+				reply = ":1\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mutex.Unlock()
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisStorage(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	storage := NewRedisStorage(RedisConfig{Address: server.listener.Addr().String(), Password: "secret", DB: 2, KeyPrefix: "wms-proxy:tiles:"})
+
+	entry := Entry{Body: []byte("tile\r\nbytes"), ContentType: "image/png", StoredAt: time.Now()}
+	if err := storage.Set("default:a", entry, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := server.values["wms-proxy:tiles:default:a"]; !ok {
+		t.Errorf("expected the key to be prefixed, got %v", server.values)
+	}
+
+	got, found, err := storage.Get("default:a")
+	if err != nil || !found || string(got.Body) != "tile\r\nbytes" || got.ContentType != "image/png" {
+		t.Errorf("unexpected result: %+v %v %v", got, found, err)
+	}
+	if _, found, err := storage.Get("default:b"); found || err != nil {
+		t.Errorf("expected a miss, got %v %v", found, err)
+	}
+
+	// Entries expire on the server after their retention
+	storage.Set("default:short", entry, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, found, _ := storage.Get("default:short"); found {
+		t.Error("expected the entry to expire")
+	}
+
+	if err := storage.Delete("default:a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, found, _ := storage.Get("default:a"); found {
+		t.Error("expected the entry to be deleted")
+	}
+	if dials := storage.Stats()["connections"]; dials != int64(1) {
+		t.Errorf("expected the connection to be reused, got %v dials", dials)
+	}
+
+	// Closing releases the pooled connection and fails later commands
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(storage.idle) != 0 {
+		t.Errorf("expected the idle connections to be closed, got %d", len(storage.idle))
+	}
+	if _, _, err := storage.Get("default:a"); err == nil {
+		t.Error("expected an error after Close")
+	}
+
+	// A wrong password fails every command
+	wrong := NewRedisStorage(RedisConfig{Address: server.listener.Addr().String(), Password: "wrong"})
+	if _, _, err := wrong.Get("default:a"); err == nil {
+		t.Error("expected an authentication error")
+	}
+}
+
+func TestTileCacheNamespace(t *testing.T) {
+	c := NewTileCache(100, time.Hour)
+	parcels := c.Namespace("parcels")
+	roads := c.Namespace("roads")
+
+	parcels.Set("0/0/0", []byte("parcels"), "image/png")
+	roads.Set("0/0/0", []byte("roads"), "image/png")
+	if entry, ok := parcels.Get("0/0/0"); !ok || string(entry.Body) != "parcels" {
+		t.Errorf("unexpected parcels entry: %+v", entry)
+	}
+	if entry, ok := roads.Get("0/0/0"); !ok || string(entry.Body) != "roads" {
+		t.Errorf("unexpected roads entry: %+v", entry)
+	}
+	if _, ok := c.Get("0/0/0"); ok {
+		t.Error("expected the root namespace to be separate")
+	}
+
+	// Namespaces share the statistics of the cache
+	if stats := c.Stats(); stats["hits"] != int64(2) || stats["entries"] != 2 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
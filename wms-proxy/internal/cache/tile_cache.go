@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response body with its content type
+type Entry struct {
+	Body        []byte
+	ContentType string
+	Header      http.Header // optional response headers to replay
+	StoredAt    time.Time
+}
+
+// Freshness of an entry returned by Lookup
+const (
+	Fresh           = iota // within the TTL
+	StaleRevalidate        // expired, within the stale-while-revalidate window
+	StaleIfError           // expired, only to be served when the upstream fails
+)
+
+// TileCache caches rendered tiles and upstream responses in a Storage.
+// Entries expire after the TTL and are kept for the stale windows set with
+// SetStale. Namespace returns views of the cache whose keys do not collide,
+// e.g. one per backend.
+type TileCache struct {
+	storage   Storage
+	ttl       time.Duration
+	namespace string
+	state     *tileCacheState // shared by the namespaces of a cache
+}
+
+// tileCacheState holds the stale windows and statistics of a cache
+type tileCacheState struct {
+	mutex           sync.Mutex
+	whileRevalidate time.Duration
+	ifError         time.Duration
+	hits            int64
+	misses          int64
+	staleServed     int64
+	errors          int64
+}
+
+// NewTileCache creates an in-memory tile cache holding at most maxBytes of tile data
+func NewTileCache(maxBytes int64, ttl time.Duration) *TileCache {
+	return NewTileCacheWithStorage(NewMemoryStorage(maxBytes), ttl)
+}
+
+// NewTileCacheWithStorage creates a tile cache keeping its entries in storage
+func NewTileCacheWithStorage(storage Storage, ttl time.Duration) *TileCache {
+	return &TileCache{
+		storage: storage,
+		ttl:     ttl,
+		state:   &tileCacheState{},
+	}
+}
+
+// Namespace returns a view of the cache prefixing its keys with name. The view
+// shares the storage, stale windows and statistics of the cache.
+func (c *TileCache) Namespace(name string) *TileCache {
+	view := *c
+	view.namespace = c.namespace + name + ":"
+	return &view
+}
+
+// TTL returns how long entries stay fresh
+func (c *TileCache) TTL() time.Duration {
+	return c.ttl
+}
+
+// SetStale keeps expired entries for serving while they are refreshed in the
+// background (whileRevalidate) and while the upstream fails (ifError)
+func (c *TileCache) SetStale(whileRevalidate, ifError time.Duration) {
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	c.state.whileRevalidate = whileRevalidate
+	c.state.ifError = ifError
+}
+
+// Get returns a fresh cached entry
+func (c *TileCache) Get(key string) (Entry, bool) {
+	entry, freshness, ok := c.Lookup(key)
+	if !ok || freshness != Fresh {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Lookup returns a cached entry and its freshness. Entries past every stale
+// window are removed. Storage errors count as misses.
+func (c *TileCache) Lookup(key string) (Entry, int, bool) {
+	entry, exists, err := c.storage.Get(c.namespace + key)
+	if err != nil || !exists {
+		c.record(&c.state.misses, err)
+		return Entry{}, 0, false
+	}
+
+	c.state.mutex.Lock()
+	whileRevalidate, ifError := c.state.whileRevalidate, c.state.ifError
+	c.state.mutex.Unlock()
+
+	age := time.Since(entry.StoredAt)
+	freshness := Fresh
+	switch {
+	case age <= c.ttl:
+	case age <= c.ttl+whileRevalidate:
+		freshness = StaleRevalidate
+	case age <= c.ttl+ifError:
+		freshness = StaleIfError
+	default:
+		c.record(&c.state.misses, c.storage.Delete(c.namespace+key))
+		return Entry{}, 0, false
+	}
+
+	if freshness == Fresh {
+		c.record(&c.state.hits, nil)
+	} else {
+		c.record(&c.state.misses, nil)
+	}
+	return entry, freshness, true
+}
+
+// RecordStale counts an expired entry served to a client
+func (c *TileCache) RecordStale() {
+	c.record(&c.state.staleServed, nil)
+}
+
+// Set stores an entry, evicting least recently used entries as needed.
+// Entries larger than the whole cache are not stored.
+func (c *TileCache) Set(key string, body []byte, contentType string) {
+	c.Store(key, Entry{Body: body, ContentType: contentType})
+}
+
+// Store stores an entry with its headers, stamping it with the current time.
+// The storage keeps it for the TTL and the longest stale window.
+func (c *TileCache) Store(key string, entry Entry) {
+	c.state.mutex.Lock()
+	retention := c.ttl + max(c.state.whileRevalidate, c.state.ifError)
+	c.state.mutex.Unlock()
+
+	entry.StoredAt = time.Now()
+	if err := c.storage.Set(c.namespace+key, entry, retention); err != nil {
+		c.record(nil, err)
+	}
+}
+
+// record increments counter, and the error count when err is set
+func (c *TileCache) record(counter *int64, err error) {
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	if counter != nil {
+		*counter++
+	}
+	if err != nil {
+		c.state.errors++
+	}
+}
+
+// Close closes the storage shared by the cache and its namespaces
+func (c *TileCache) Close() error {
+	return c.storage.Close()
+}
+
+// Stats returns cache statistics for monitoring
+func (c *TileCache) Stats() map[string]interface{} {
+	stats := c.storage.Stats()
+
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	stats["hits"] = c.state.hits
+	stats["misses"] = c.state.misses
+	stats["ttl"] = c.ttl.String()
+	stats["stale"] = c.state.staleServed
+	stats["errors"] = c.state.errors
+	return stats
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTileCacheEviction(t *testing.T) {
+	c := NewTileCache(10, time.Hour)
+
+	c.Set("a", []byte("12345"), "image/png")
+	c.Set("b", []byte("12345"), "image/png")
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+
+	// a was used most recently, so b is evicted
+	c.Set("c", []byte("12345"), "image/png")
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if entry, ok := c.Get("a"); !ok || string(entry.Body) != "12345" || entry.ContentType != "image/png" {
+		t.Errorf("Expected a to remain cached, got %+v", entry)
+	}
+
+	// Entries larger than the cache are not stored
+	c.Set("big", make([]byte, 11), "image/png")
+	if _, ok := c.Get("big"); ok {
+		t.Error("Expected oversized entry to be skipped")
+	}
+}
+
+func TestTileCacheExpiry(t *testing.T) {
+	c := NewTileCache(100, time.Millisecond)
+	c.Set("a", []byte("x"), "image/png")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected entry to expire")
+	}
+	if stats := c.Stats(); stats["entries"] != 0 {
+		t.Errorf("Expected expired entry to be removed, got %v", stats)
+	}
+}
+
+func TestTileCacheStale(t *testing.T) {
+	c := NewTileCache(100, 10*time.Millisecond)
+	c.SetStale(20*time.Millisecond, 40*time.Millisecond)
+	c.Set("a", []byte("x"), "image/png")
+
+	tests := []struct {
+		wait      time.Duration
+		freshness int
+		found     bool
+	}{
+		{0, Fresh, true},
+		{20 * time.Millisecond, StaleRevalidate, true},
+		{20 * time.Millisecond, StaleIfError, true},
+		{20 * time.Millisecond, 0, false},
+	}
+	for i, test := range tests {
+		time.Sleep(test.wait)
+		_, freshness, found := c.Lookup("a")
+		if found != test.found || freshness != test.freshness {
+			t.Errorf("step %d: expected freshness %d found %v, got %d %v", i, test.freshness, test.found, freshness, found)
+		}
+	}
+
+	// Get only returns fresh entries
+	c.Set("b", []byte("x"), "image/png")
+	time.Sleep(15 * time.Millisecond)
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected Get to skip an expired entry")
+	}
+	if _, _, found := c.Lookup("b"); !found {
+		t.Error("Expected the expired entry to be kept for the stale windows")
+	}
+}
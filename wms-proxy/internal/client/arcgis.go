@@ -1,16 +1,22 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// ServiceMetadata represents ArcGIS MapServer service metadata
+// ServiceMetadata represents ArcGIS MapServer, ImageServer or FeatureServer service metadata
 type ServiceMetadata struct {
+	Name             string `json:"name"`    // ImageServer
+	MapName          string `json:"mapName"` // MapServer
 	SpatialReference struct {
 		WKID       int    `json:"wkid"`
 		LatestWKID int    `json:"latestWkid"`
@@ -19,52 +25,303 @@ type ServiceMetadata struct {
 	SupportedQueryFormats interface{} `json:"supportedQueryFormats"` // Can be string or []string
 	MaxRecordCount        int         `json:"maxRecordCount"`
 	Capabilities          string      `json:"capabilities"`
+	TimeInfo              *TimeInfo   `json:"timeInfo,omitempty"`
+	RangeInfos            []RangeInfo `json:"rangeInfos,omitempty"`
+	Layers                []LayerInfo `json:"layers,omitempty"`
+
+	// Extents and export limits
+	FullExtent                *Extent `json:"fullExtent,omitempty"`
+	InitialExtent             *Extent `json:"initialExtent,omitempty"`
+	Extent                    *Extent `json:"extent,omitempty"` // ImageServer and FeatureServer
+	SupportedImageFormatTypes string  `json:"supportedImageFormatTypes,omitempty"`
+	MaxImageWidth             int     `json:"maxImageWidth,omitempty"`
+	MaxImageHeight            int     `json:"maxImageHeight,omitempty"`
+
+	// ImageServer properties
+	ServiceDataType     string               `json:"serviceDataType,omitempty"`
+	PixelType           string               `json:"pixelType,omitempty"`
+	BandCount           int                  `json:"bandCount,omitempty"`
+	RasterFunctionInfos []RasterFunctionInfo `json:"rasterFunctionInfos,omitempty"`
+}
+
+// RasterFunctionInfo describes a raster function template of an ImageServer,
+// applied to exportImage through renderingRule
+type RasterFunctionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// LayerInfo is an entry of the layer list in ArcGIS service metadata
+type LayerInfo struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	ParentLayerID int    `json:"parentLayerId"`
+	SubLayerIDs   []int  `json:"subLayerIds"`
+	GeometryType  string `json:"geometryType,omitempty"`
+}
+
+// LayerMetadata represents an ArcGIS MapServer/FeatureServer layer resource
+type LayerMetadata struct {
+	ID                 int     `json:"id"`
+	Name               string  `json:"name"`
+	Type               string  `json:"type"`
+	Description        string  `json:"description"`
+	GeometryType       string  `json:"geometryType"`
+	ObjectIDField      string  `json:"objectIdField"`
+	DisplayField       string  `json:"displayField"`
+	Fields             []Field `json:"fields"`
+	MaxRecordCount     int     `json:"maxRecordCount"`
+	SupportsPagination bool    `json:"supportsPagination"`
+	Extent             *Extent `json:"extent,omitempty"`
+}
+
+// Field describes a layer attribute
+type Field struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Alias  string `json:"alias"`
+	Length int    `json:"length,omitempty"`
+}
+
+// Extent is an ArcGIS envelope with its spatial reference
+type Extent struct {
+	XMin             float64 `json:"xmin"`
+	YMin             float64 `json:"ymin"`
+	XMax             float64 `json:"xmax"`
+	YMax             float64 `json:"ymax"`
+	SpatialReference struct {
+		WKID       int `json:"wkid"`
+		LatestWKID int `json:"latestWkid"`
+	} `json:"spatialReference"`
+}
+
+// ServiceExtent returns the full extent of the service, falling back to its
+// extent or initial extent, or nil when the metadata has none
+func (m *ServiceMetadata) ServiceExtent() *Extent {
+	for _, extent := range []*Extent{m.FullExtent, m.Extent, m.InitialExtent} {
+		if extent != nil && extent.XMax > extent.XMin && extent.YMax > extent.YMin {
+			return extent
+		}
+	}
+	return nil
+}
+
+// SupportsImageFormat reports whether the service exports the ArcGIS image
+// format (e.g. "png32", "jpg"); services that do not list their formats support all
+func (m *ServiceMetadata) SupportsImageFormat(format string) bool {
+	if strings.TrimSpace(m.SupportedImageFormatTypes) == "" {
+		return true
+	}
+	for _, supported := range strings.Split(m.SupportedImageFormatTypes, ",") {
+		if strings.EqualFold(strings.TrimSpace(supported), format) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectIDFieldName returns the name of the layer's object ID field
+func (l *LayerMetadata) ObjectIDFieldName() string {
+	if l.ObjectIDField != "" {
+		return l.ObjectIDField
+	}
+	for _, f := range l.Fields {
+		if f.Type == "esriFieldTypeOID" {
+			return f.Name
+		}
+	}
+	return "OBJECTID"
+}
+
+// TimeInfo describes the time extent of a time-enabled ArcGIS service
+type TimeInfo struct {
+	TimeExtent               []int64 `json:"timeExtent"` // [start, end] in epoch milliseconds
+	DefaultTimeInterval      float64 `json:"defaultTimeInterval"`
+	DefaultTimeIntervalUnits string  `json:"defaultTimeIntervalUnits"`
+	HasLiveData              bool    `json:"hasLiveData"`
+}
+
+// RangeInfo describes a range-enabled (e.g. elevation) dimension of an ArcGIS service
+type RangeInfo struct {
+	Name               string    `json:"name"`
+	CurrentRangeExtent []float64 `json:"currentRangeExtent"`
+	FullRangeExtent    []float64 `json:"fullRangeExtent"`
 }
 
 // ArcGISClientInterface defines the interface for ArcGIS client operations
 type ArcGISClientInterface interface {
 	Get(ctx context.Context, url string) (*http.Response, error)
+	Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error)
 	GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error)
 }
 
+// StatusError reports an unsuccessful upstream HTTP status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return strconv.Itoa(e.StatusCode)
+}
+
+// HealthChecker verifies connectivity to an upstream server
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
 // ArcGISClient handles HTTP requests to ArcGIS REST API
 type ArcGISClient struct {
 	httpClient *http.Client
 	baseURL    string
+	tokens     TokenProvider
 }
 
-// NewArcGISClient creates a new ArcGIS REST API client
+// NewArcGISClient creates a new ArcGIS REST API client with the default transport
 func NewArcGISClient(baseURL string, timeout time.Duration) *ArcGISClient {
+	// The default configuration loads no files and cannot fail
+	transport, _ := NewTransport(DefaultTransportConfig())
+	return NewArcGISClientWithTransport(baseURL, timeout, transport)
+}
+
+// NewArcGISClientWithTransport creates an ArcGIS REST API client on a custom
+// transport, e.g. one built by NewTransport with a CA bundle or client certificate
+func NewArcGISClientWithTransport(baseURL string, timeout time.Duration, transport http.RoundTripper) *ArcGISClient {
 	return &ArcGISClient{
 		httpClient: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   timeout,
+			Transport: transport,
 		},
 		baseURL: baseURL,
 	}
 }
 
-// Get performs a GET request to the ArcGIS server
-func (c *ArcGISClient) Get(ctx context.Context, url string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// SetToken sets a static ArcGIS token that is appended to every upstream request
+func (c *ArcGISClient) SetToken(token string) {
+	if token != "" {
+		c.tokens = StaticToken(token)
+	}
+}
+
+// SetTokenProvider sets the source of the token appended to every upstream request
+func (c *ArcGISClient) SetTokenProvider(tokens TokenProvider) {
+	c.tokens = tokens
+}
+
+// withToken adds the client's token to a request URL unless the URL already carries one
+func (c *ArcGISClient) withToken(ctx context.Context, rawURL string) (string, error) {
+	if c.tokens == nil {
+		return rawURL, nil
 	}
 
-	// Set appropriate headers
-	req.Header.Set("User-Agent", "WMS-Proxy/1.0")
-	req.Header.Set("Accept", "image/png,image/jpeg,image/gif,*/*")
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid request URL: %w", err)
+	}
+	query := u.Query()
+	if query.Get("token") != "" {
+		return rawURL, nil
+	}
 
-	resp, err := c.httpClient.Do(req)
+	token, err := c.tokens.Token(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return "", fmt.Errorf("failed to acquire token: %w", err)
+	}
+	if token == "" {
+		return rawURL, nil
+	}
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// do performs an authenticated GET request
+func (c *ArcGISClient) do(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	return c.send(ctx, "GET", rawURL, accept, "", nil)
+}
+
+// send performs an authenticated request with an optional body. When the server
+// rejects a renewable token (498/499), the token is invalidated and the request
+// repeated once; the rejected request was not executed, so this is safe for POST.
+func (c *ArcGISClient) send(ctx context.Context, method, rawURL, accept, contentType string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		target, err := c.withToken(ctx, rawURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		// Set appropriate headers
+		req.Header.Set("User-Agent", "WMS-Proxy/1.0")
+		req.Header.Set("Accept", accept)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request: %w", err)
+		}
+
+		if _, static := c.tokens.(StaticToken); c.tokens == nil || static || attempt > 0 {
+			return resp, nil
+		}
+		if rejected := tokenRejected(resp); !rejected {
+			return resp, nil
+		}
+		resp.Body.Close()
+		c.tokens.Invalidate()
+	}
+}
+
+// tokenRejected reports whether a response rejects the request's token. JSON
+// bodies of up to maxTokenErrorSize bytes are inspected for ArcGIS error codes
+// and restored for the caller.
+func tokenRejected(resp *http.Response) bool {
+	if resp.StatusCode == 498 || resp.StatusCode == 499 {
+		return true
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "json") && !strings.Contains(contentType, "text/plain") {
+		return false
 	}
 
-	return resp, nil
+	head := make([]byte, maxTokenErrorSize)
+	n, err := io.ReadFull(resp.Body, head)
+	head = head[:n]
+	if err == nil {
+		// Larger than any error response; not a token error
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), resp.Body), Closer: resp.Body}
+		return false
+	}
+	resp.Body = readCloser{Reader: bytes.NewReader(head), Closer: resp.Body}
+	return isInvalidToken(resp.StatusCode, head)
+}
+
+// maxTokenErrorSize bounds the response bodies inspected for token errors
+const maxTokenErrorSize = 4096
+
+// readCloser pairs a reader with the closer of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Get performs a GET request to the ArcGIS server
+func (c *ArcGISClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.do(ctx, url, "image/png,image/jpeg,image/gif,*/*")
+}
+
+// Post performs a POST request with a form or multipart body to the ArcGIS server
+func (c *ArcGISClient) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	return c.send(ctx, "POST", url, "*/*", contentType, body)
 }
 
 // HealthCheck verifies connectivity to the ArcGIS server
@@ -72,12 +329,7 @@ func (c *ArcGISClient) HealthCheck(ctx context.Context) error {
 	// Try to access the base ArcGIS REST services endpoint
 	healthURL := c.baseURL + "/arcgis/rest/services"
 
-	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create health check request: %w", err)
-	}
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, healthURL, "*/*")
 	if err != nil {
 		return fmt.Errorf("health check failed: %w", err)
 	}
@@ -90,30 +342,28 @@ func (c *ArcGISClient) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
-// GetServiceMetadata retrieves metadata for an ArcGIS MapServer service
+// GetServiceMetadata retrieves metadata for an ArcGIS service
 func (c *ArcGISClient) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
-	// Remove /export suffix if present to get the service root
-	serviceRoot := strings.TrimSuffix(servicePath, "/export")
+	// Remove the export operation if present to get the service root
+	serviceRoot := ServiceRoot(servicePath)
 
 	// Build metadata URL
 	metadataURL := c.baseURL + serviceRoot + "?f=json"
 
-	req, err := http.NewRequestWithContext(ctx, "GET", metadataURL, nil)
+	resp, err := c.do(ctx, metadataURL, "application/json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metadata request: %w", err)
+		return nil, fmt.Errorf("failed to execute metadata request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "WMS-Proxy/1.0")
-	req.Header.Set("Accept", "application/json")
+	return decodeServiceMetadata(resp)
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute metadata request: %w", err)
-	}
+// decodeServiceMetadata decodes and closes a service metadata response
+func decodeServiceMetadata(resp *http.Response) (*ServiceMetadata, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("metadata request failed with status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("metadata request failed with status: %w", &StatusError{StatusCode: resp.StatusCode})
 	}
 
 	var metadata ServiceMetadata
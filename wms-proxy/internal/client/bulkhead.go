@@ -0,0 +1,297 @@
+package client
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request priorities of the bulkhead queue; lower values are admitted first
+const (
+	PriorityMetadata = iota // capabilities and service metadata
+	PriorityQuery           // feature queries and other passthrough requests
+	PriorityRender          // map and image exports
+)
+
+// BulkheadConfig limits the concurrent upstream requests of a backend
+type BulkheadConfig struct {
+	MaxConcurrent int           // requests in flight (0 disables the bulkhead)
+	QueueSize     int           // requests waiting for a slot; further requests are rejected
+	MaxWait       time.Duration // time a request may wait in the queue
+}
+
+// OverloadError is returned when the bulkhead rejects a request because its
+// queue is full or the request waited longer than the queue-time budget
+type OverloadError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *OverloadError) Error() string {
+	return fmt.Sprintf("backend overloaded: %s", e.Reason)
+}
+
+// BulkheadStats reports the occupancy of a bulkhead
+type BulkheadStats struct {
+	Active        int   `json:"active"`
+	Queued        int   `json:"queued"`
+	MaxConcurrent int   `json:"max_concurrent"`
+	QueueSize     int   `json:"queue_size"`
+	Rejected      int64 `json:"rejected"`
+	TimedOut      int64 `json:"timed_out"`
+}
+
+// Bulkhead limits the concurrent requests to a backend. Requests beyond the
+// limit wait in a bounded queue ordered by priority, so that capabilities and
+// metadata requests are not starved by bursts of map renders.
+type Bulkhead struct {
+	inner  ArcGISClientInterface
+	config BulkheadConfig
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	active   int
+	queue    waitQueue
+	seq      uint64
+	rejected int64
+	timedOut int64
+}
+
+// Ensure Bulkhead implements ArcGISClientInterface
+var _ ArcGISClientInterface = (*Bulkhead)(nil)
+
+// NewBulkhead wraps inner with a concurrency limit and priority queue
+func NewBulkhead(inner ArcGISClientInterface, config BulkheadConfig, logger *slog.Logger) *Bulkhead {
+	return &Bulkhead{
+		inner:  inner,
+		config: config,
+		logger: logger,
+	}
+}
+
+// Get performs a GET request once a slot is free
+func (b *Bulkhead) Get(ctx context.Context, url string) (*http.Response, error) {
+	if err := b.acquire(ctx, requestPriority(url)); err != nil {
+		return nil, err
+	}
+	resp, err := b.inner.Get(ctx, url)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+	// The slot is held until the response body has been read
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: b.release}
+	return resp, nil
+}
+
+// Post performs a POST request once a slot is free
+func (b *Bulkhead) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	if err := b.acquire(ctx, PriorityQuery); err != nil {
+		return nil, err
+	}
+	resp, err := b.inner.Post(ctx, url, contentType, body)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: b.release}
+	return resp, nil
+}
+
+// GetServiceMetadata retrieves service metadata ahead of queued queries and renders
+func (b *Bulkhead) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	if err := b.acquire(ctx, PriorityMetadata); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.inner.GetServiceMetadata(ctx, servicePath)
+}
+
+// Stats returns the current occupancy of the bulkhead
+func (b *Bulkhead) Stats() BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BulkheadStats{
+		Active:        b.active,
+		Queued:        len(b.queue),
+		MaxConcurrent: b.config.MaxConcurrent,
+		QueueSize:     b.config.QueueSize,
+		Rejected:      b.rejected,
+		TimedOut:      b.timedOut,
+	}
+}
+
+// acquire takes a slot, waiting in the queue for at most MaxWait
+func (b *Bulkhead) acquire(ctx context.Context, priority int) error {
+	if b.config.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.active < b.config.MaxConcurrent && len(b.queue) == 0 {
+		b.active++
+		b.mu.Unlock()
+		return nil
+	}
+	if len(b.queue) >= b.config.QueueSize {
+		b.rejected++
+		b.mu.Unlock()
+		b.logger.Warn("Rejected upstream request, queue is full", "queue_size", b.config.QueueSize)
+		return &OverloadError{Reason: "request queue is full", RetryAfter: b.retryAfter()}
+	}
+	b.seq++
+	w := &waiter{priority: priority, seq: b.seq, ready: make(chan struct{})}
+	heap.Push(&b.queue, w)
+	b.logger.Debug("Queued upstream request", "priority", priority, "queue_depth", len(b.queue))
+	b.mu.Unlock()
+
+	timer := time.NewTimer(b.config.MaxWait)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		err = &OverloadError{Reason: "queue time budget exceeded", RetryAfter: b.retryAfter()}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w.index < 0 {
+		// The slot was handed over while giving up; keep it
+		return nil
+	}
+	heap.Remove(&b.queue, w.index)
+	if _, overloaded := err.(*OverloadError); overloaded {
+		b.timedOut++
+		b.logger.Warn("Upstream request timed out in queue", "max_wait_ms", b.config.MaxWait.Milliseconds(), "priority", priority)
+	}
+	return err
+}
+
+// release frees a slot, handing it to the first queued request
+func (b *Bulkhead) release() {
+	if b.config.MaxConcurrent <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.queue) > 0 {
+		w := heap.Pop(&b.queue).(*waiter)
+		close(w.ready)
+		return
+	}
+	b.active--
+}
+
+// retryAfter suggests when a rejected client should retry
+func (b *Bulkhead) retryAfter() time.Duration {
+	if b.config.MaxWait < time.Second {
+		return time.Second
+	}
+	return b.config.MaxWait.Round(time.Second)
+}
+
+// requestPriority classifies a GET request by its ArcGIS operation: exports
+// and tiles are renders; service roots, layers, legends and the services
+// directory are metadata
+func requestPriority(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return PriorityQuery
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	parent, last := path, ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parent, last = path[:i], path[i+1:]
+	}
+	switch {
+	case last == "export", last == "exportImage", strings.Contains(path, "/tile/"):
+		return PriorityRender
+	case ServiceTypeFromPath(path) != "", strings.Contains(path, "/rest/services") && !strings.Contains(path, "Server/"),
+		last == "legend", last == "layers":
+		return PriorityMetadata
+	case isLayerID(last) && ServiceTypeFromPath(parent) != "":
+		return PriorityMetadata
+	}
+	return PriorityQuery
+}
+
+// isLayerID reports whether a path segment is a numeric layer ID
+func isLayerID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// releasingBody frees the bulkhead slot of a response when its body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releasingBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// waiter is a request queued for a slot
+type waiter struct {
+	priority int
+	seq      uint64 // arrival order within a priority
+	ready    chan struct{}
+	index    int // position in the queue, -1 once admitted
+}
+
+// waitQueue orders waiters by priority, then arrival
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingClient records the order in which requests reach the backend
+type recordingClient struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (c *recordingClient) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	c.mu.Lock()
+	c.urls = append(c.urls, rawURL)
+	c.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func (c *recordingClient) Post(ctx context.Context, rawURL, contentType string, body []byte) (*http.Response, error) {
+	return c.Get(ctx, rawURL)
+}
+
+func (c *recordingClient) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	c.Get(ctx, servicePath)
+	return &ServiceMetadata{}, nil
+}
+
+func (c *recordingClient) recorded() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.urls...)
+}
+
+// waitForQueue waits until n requests are queued in the bulkhead
+func waitForQueue(t *testing.T, b *Bulkhead, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued requests, got %d", n, b.Stats().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkhead_PriorityOrder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	inner := &recordingClient{}
+	b := NewBulkhead(inner, BulkheadConfig{MaxConcurrent: 1, QueueSize: 10, MaxWait: 5 * time.Second}, logger)
+
+	// Hold the only slot until the queue is populated
+	held, err := b.Get(context.Background(), "https://gis.example.com/arcgis/rest/services/Parcels/MapServer/export?bbox=0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	requests := []string{
+		"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/export?bbox=1",
+		"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/0/query?where=1%3D1",
+		"/arcgis/rest/services/Parcels/MapServer",
+	}
+	for i, target := range requests {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			var resp *http.Response
+			var err error
+			if strings.HasPrefix(target, "/") {
+				_, err = b.GetServiceMetadata(context.Background(), target)
+			} else if resp, err = b.Get(context.Background(), target); err == nil {
+				resp.Body.Close()
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(target)
+		waitForQueue(t, b, i+1)
+	}
+
+	held.Body.Close()
+	wg.Wait()
+
+	expected := []string{
+		"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/export?bbox=0",
+		"/arcgis/rest/services/Parcels/MapServer",
+		"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/0/query?where=1%3D1",
+		"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/export?bbox=1",
+	}
+	got := inner.recorded()
+	for i := range expected {
+		if i >= len(got) || got[i] != expected[i] {
+			t.Fatalf("expected order %v, got %v", expected, got)
+		}
+	}
+	if stats := b.Stats(); stats.Active != 0 || stats.Queued != 0 {
+		t.Errorf("expected an idle bulkhead, got %+v", stats)
+	}
+}
+
+func TestBulkhead_Overload(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	b := NewBulkhead(&recordingClient{}, BulkheadConfig{MaxConcurrent: 1, QueueSize: 1, MaxWait: 50 * time.Millisecond}, logger)
+	target := "https://gis.example.com/arcgis/rest/services/Parcels/MapServer/export"
+
+	held, err := b.Get(context.Background(), target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer held.Body.Close()
+
+	// The queued request exceeds its queue-time budget
+	queued := make(chan error, 1)
+	go func() {
+		_, err := b.Get(context.Background(), target)
+		queued <- err
+	}()
+	waitForQueue(t, b, 1)
+
+	// A request beyond the queue size is rejected at once
+	_, err = b.Get(context.Background(), target)
+	var overload *OverloadError
+	if !errors.As(err, &overload) || overload.RetryAfter != time.Second {
+		t.Errorf("expected a full queue to reject the request with Retry-After 1s, got %v", err)
+	}
+
+	if err := <-queued; !errors.As(err, &overload) {
+		t.Errorf("expected the queued request to time out, got %v", err)
+	}
+
+	stats := b.Stats()
+	if stats.Rejected != 1 || stats.TimedOut != 1 || stats.Queued != 0 || stats.Active != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBulkhead_Disabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	b := NewBulkhead(&recordingClient{}, BulkheadConfig{}, logger)
+	for i := 0; i < 5; i++ {
+		if _, err := b.Get(context.Background(), "https://gis.example.com/arcgis/rest/services"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected int
+	}{
+		{"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/export?bbox=1,2,3,4", PriorityRender},
+		{"https://gis.example.com/arcgis/rest/services/Elevation/ImageServer/exportImage", PriorityRender},
+		{"https://gis.example.com/arcgis/rest/services/Basemap/MapServer/tile/3/2/1", PriorityRender},
+		{"https://gis.example.com/arcgis/rest/services/Parcels/MapServer?f=json", PriorityMetadata},
+		{"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/0?f=json", PriorityMetadata},
+		{"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/legend", PriorityMetadata},
+		{"https://gis.example.com/arcgis/rest/services/Hydro?f=json", PriorityMetadata},
+		{"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/0/query", PriorityQuery},
+		{"https://gis.example.com/arcgis/rest/services/Parcels/MapServer/identify", PriorityQuery},
+	}
+
+	for _, test := range tests {
+		if got := requestPriority(test.url); got != test.expected {
+			t.Errorf("requestPriority(%s) = %d, expected %d", test.url, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"wms-proxy/internal/coalesce"
+)
+
+// maxSharedBody bounds the response bodies buffered for coalesced callers;
+// larger responses are streamed to the caller that sent the request and
+// requested again by the callers that joined it
+const maxSharedBody = 32 << 20
+
+// sharedResponse is an upstream response buffered for every coalesced caller
+type sharedResponse struct {
+	status     string
+	statusCode int
+	header     http.Header
+	body       []byte
+	oversized  bool           // the body exceeded maxSharedBody and is not shared
+	stream     *http.Response // the oversized response, for the leader only
+}
+
+// CoalescingClient sends concurrent identical GET requests, and concurrent
+// metadata lookups of the same service, to the backend only once and shares
+// the result among the callers
+type CoalescingClient struct {
+	inner     ArcGISClientInterface
+	logger    *slog.Logger
+	responses coalesce.Group[*sharedResponse]
+	metadata  coalesce.Group[*ServiceMetadata]
+}
+
+// Ensure CoalescingClient implements ArcGISClientInterface
+var _ ArcGISClientInterface = (*CoalescingClient)(nil)
+
+// NewCoalescingClient wraps inner with request coalescing
+func NewCoalescingClient(inner ArcGISClientInterface, logger *slog.Logger) *CoalescingClient {
+	return &CoalescingClient{
+		inner:  inner,
+		logger: logger,
+	}
+}
+
+// Get performs a GET request, joining an identical request already in flight
+func (c *CoalescingClient) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	leaderCtx := ctx
+	shared, leader, err := c.responses.Do(ctx, normalizeURL(rawURL), func(ctx context.Context) (*sharedResponse, error) {
+		return c.fetch(ctx, leaderCtx, rawURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !leader {
+		c.logger.Debug("Coalesced upstream request", "url", rawURL)
+	}
+
+	if shared.oversized {
+		if leader {
+			return shared.stream, nil
+		}
+		return c.inner.Get(ctx, rawURL)
+	}
+
+	return &http.Response{
+		Status:        shared.status,
+		StatusCode:    shared.statusCode,
+		Header:        shared.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(shared.body)),
+		ContentLength: int64(len(shared.body)),
+	}, nil
+}
+
+// Post performs a POST request; POST requests are never coalesced
+func (c *CoalescingClient) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	return c.inner.Post(ctx, url, contentType, body)
+}
+
+// GetServiceMetadata retrieves service metadata, joining a lookup of the same service already in flight
+func (c *CoalescingClient) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	metadata, _, err := c.metadata.Do(ctx, ServiceRoot(servicePath), func(ctx context.Context) (*ServiceMetadata, error) {
+		return c.inner.GetServiceMetadata(ctx, servicePath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers get their own copy of the top-level fields
+	copied := *metadata
+	return &copied, nil
+}
+
+// fetch performs the upstream request and buffers its body for sharing. An
+// oversized response is kept as a stream for the leader, the caller whose
+// context is leaderCtx, and closed when that context is done.
+func (c *CoalescingClient) fetch(ctx, leaderCtx context.Context, rawURL string) (*sharedResponse, error) {
+	resp, err := c.inner.Get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSharedBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxSharedBody {
+		c.logger.Debug("Upstream response too large to share", "url", rawURL)
+		// The leader may have given up waiting; its request then releases the stream
+		context.AfterFunc(leaderCtx, func() { resp.Body.Close() })
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return &sharedResponse{oversized: true, stream: resp}, nil
+	}
+	resp.Body.Close()
+
+	return &sharedResponse{status: resp.Status, statusCode: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// normalizeURL returns the key identifying equivalent requests: the scheme and
+// host are lower-cased and the query parameters sorted
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = u.Query().Encode()
+	u.Fragment = ""
+	return u.String()
+}
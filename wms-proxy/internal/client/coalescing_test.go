@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescingClient_Get(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("tile " + r.URL.Query().Get("bbox")))
+	}))
+	defer server.Close()
+
+	c := NewCoalescingClient(NewArcGISClient(server.URL, 5*time.Second), logger)
+	urls := []string{
+		server.URL + "/arcgis/rest/services/Parcels/MapServer/export?bbox=1,2,3,4&size=256,256",
+		// Same request with the parameters in another order
+		server.URL + "/arcgis/rest/services/Parcels/MapServer/export?size=256,256&bbox=1,2,3,4",
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), urls[i%2])
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+				t.Errorf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+			bodies[i] = string(body)
+		}(i)
+	}
+
+	// Let every request join the one in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if hits.Load() != 1 {
+		t.Errorf("expected one upstream request, got %d", hits.Load())
+	}
+	for i, body := range bodies {
+		if body != "tile 1,2,3,4" {
+			t.Errorf("caller %d got body %q", i, body)
+		}
+	}
+
+	// A different request is not coalesced
+	resp, err := c.Get(context.Background(), server.URL+"/arcgis/rest/services/Parcels/MapServer/export?bbox=5,6,7,8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if hits.Load() != 2 {
+		t.Errorf("expected a second upstream request, got %d", hits.Load())
+	}
+}
+
+func TestCoalescingClient_GetOversized(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	body := strings.Repeat("x", maxSharedBody+1)
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	c := NewCoalescingClient(NewArcGISClient(server.URL, 5*time.Second), logger)
+	queryURL := server.URL + "/arcgis/rest/services/Parcels/MapServer/0/query?where=1%3D1"
+
+	var wg sync.WaitGroup
+	lengths := make([]int, 3)
+	for i := range lengths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), queryURL)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			lengths[i] = len(data)
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// The leader streams its response; only the two callers that joined it request again
+	if hits.Load() != 3 {
+		t.Errorf("expected 3 upstream requests, got %d", hits.Load())
+	}
+	for i, length := range lengths {
+		if length != len(body) {
+			t.Errorf("caller %d got %d bytes, expected %d", i, length, len(body))
+		}
+	}
+}
+
+func TestCoalescingClient_GetServiceMetadata(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte(`{"spatialReference":{"wkid":3857}}`))
+	}))
+	defer server.Close()
+
+	c := NewCoalescingClient(NewArcGISClient(server.URL, 5*time.Second), logger)
+
+	var wg sync.WaitGroup
+	for _, servicePath := range []string{"/arcgis/rest/services/Parcels/MapServer", "/arcgis/rest/services/Parcels/MapServer/export"} {
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(servicePath string) {
+				defer wg.Done()
+				metadata, err := c.GetServiceMetadata(context.Background(), servicePath)
+				if err != nil || metadata.SpatialReference.WKID != 3857 {
+					t.Errorf("unexpected result: %+v, %v", metadata, err)
+				}
+			}(servicePath)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if hits.Load() != 1 {
+		t.Errorf("expected one metadata request, got %d", hits.Load())
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"https://GIS.example.com/arcgis/rest/services?f=json", "https://gis.example.com/arcgis/rest/services?f=json", true},
+		{"https://gis.example.com/export?bbox=1&size=2", "https://gis.example.com/export?size=2&bbox=1", true},
+		{"https://gis.example.com/export?bbox=1", "https://gis.example.com/export?bbox=2", false},
+		{"https://gis.example.com/Export?bbox=1", "https://gis.example.com/export?bbox=1", false},
+	}
+
+	for _, test := range tests {
+		if got := normalizeURL(test.a) == normalizeURL(test.b); got != test.equal {
+			t.Errorf("normalizeURL(%s) == normalizeURL(%s) is %v, expected %v", test.a, test.b, got, test.equal)
+		}
+	}
+}
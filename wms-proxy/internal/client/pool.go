@@ -0,0 +1,303 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Load balancing strategies
+const (
+	RoundRobin       = "round-robin"
+	LeastOutstanding = "least-outstanding"
+)
+
+// healthCheckTimeout bounds each active replica probe
+const healthCheckTimeout = 10 * time.Second
+
+// replica is one upstream server of a pool
+type replica struct {
+	baseURL     string
+	client      *ArcGISClient
+	outstanding atomic.Int64
+	failures    atomic.Int32
+	healthy     atomic.Bool
+}
+
+// Pool distributes requests over the replicas of an ArcGIS backend. Replicas
+// are marked unhealthy after maxFails consecutive failures or a failed active
+// probe; a failed request is retried on the next replica.
+type Pool struct {
+	replicas []*replica
+	strategy string
+	maxFails int
+	next     atomic.Uint64
+	logger   *slog.Logger
+}
+
+// Ensure Pool implements ArcGISClientInterface
+var _ ArcGISClientInterface = (*Pool)(nil)
+
+// NewPool creates a pool over baseURLs. The first URL is the canonical base
+// URL that callers use to build request URLs. The replicas share transport;
+// nil selects the default transport.
+func NewPool(baseURLs []string, timeout time.Duration, transport http.RoundTripper, strategy string, maxFails int, logger *slog.Logger) *Pool {
+	if transport == nil {
+		transport, _ = NewTransport(DefaultTransportConfig())
+	}
+
+	p := &Pool{
+		strategy: strategy,
+		maxFails: maxFails,
+		logger:   logger,
+	}
+	for _, baseURL := range baseURLs {
+		r := &replica{baseURL: baseURL, client: NewArcGISClientWithTransport(baseURL, timeout, transport)}
+		r.healthy.Store(true)
+		p.replicas = append(p.replicas, r)
+	}
+	return p
+}
+
+// BaseURL returns the canonical base URL of the pool
+func (p *Pool) BaseURL() string {
+	return p.replicas[0].baseURL
+}
+
+// SetToken sets a static ArcGIS token on every replica
+func (p *Pool) SetToken(token string) {
+	for _, r := range p.replicas {
+		r.client.SetToken(token)
+	}
+}
+
+// SetTokenProvider shares a token provider between the replicas
+func (p *Pool) SetTokenProvider(tokens TokenProvider) {
+	for _, r := range p.replicas {
+		r.client.SetTokenProvider(tokens)
+	}
+}
+
+// Get performs a GET request on a replica, failing over to the others when the
+// request fails or the replica answers 502, 503 or 504. URLs outside the
+// canonical base URL are sent to the first replica as they are.
+func (p *Pool) Get(ctx context.Context, url string) (*http.Response, error) {
+	if !strings.HasPrefix(url, p.BaseURL()) {
+		return p.replicas[0].client.Get(ctx, url)
+	}
+	path := strings.TrimPrefix(url, p.BaseURL())
+
+	candidates := p.candidates()
+	var lastErr error
+	for i, r := range candidates {
+		r.outstanding.Add(1)
+		resp, err := r.client.Get(ctx, r.baseURL+path)
+		if err == nil && !isReplicaFailure(resp.StatusCode) {
+			r.failures.Store(0)
+			p.markHealthy(r)
+			resp.Body = &trackedBody{ReadCloser: resp.Body, done: func() { r.outstanding.Add(-1) }}
+			return resp, nil
+		}
+		r.outstanding.Add(-1)
+
+		// A cancelled caller or a failed token request is not a replica failure
+		if ctx.Err() != nil || (err != nil && !isConnectionError(err)) {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, err
+		}
+
+		p.recordFailure(r, err, resp)
+		if i == len(candidates)-1 {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("replica %s returned status: %d", r.baseURL, resp.StatusCode)
+		} else {
+			lastErr = err
+		}
+		p.logger.Warn("Failing over to next replica", "replica", r.baseURL, "error", lastErr)
+	}
+
+	return nil, lastErr
+}
+
+// Post performs a POST request on the first candidate replica. POST requests
+// such as applyEdits are not idempotent, so they are not failed over.
+func (p *Pool) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	if !strings.HasPrefix(url, p.BaseURL()) {
+		return p.replicas[0].client.Post(ctx, url, contentType, body)
+	}
+
+	r := p.candidates()[0]
+	r.outstanding.Add(1)
+	resp, err := r.client.Post(ctx, r.baseURL+strings.TrimPrefix(url, p.BaseURL()), contentType, body)
+	if err != nil {
+		r.outstanding.Add(-1)
+		if ctx.Err() == nil && isConnectionError(err) {
+			p.recordFailure(r, err, nil)
+		}
+		return nil, err
+	}
+	if isReplicaFailure(resp.StatusCode) {
+		p.recordFailure(r, nil, resp)
+	} else {
+		r.failures.Store(0)
+		p.markHealthy(r)
+	}
+	resp.Body = &trackedBody{ReadCloser: resp.Body, done: func() { r.outstanding.Add(-1) }}
+	return resp, nil
+}
+
+// GetServiceMetadata retrieves service metadata from a healthy replica
+func (p *Pool) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	serviceRoot := ServiceRoot(servicePath)
+	resp, err := p.Get(ctx, p.BaseURL()+serviceRoot+"?f=json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute metadata request: %w", err)
+	}
+	return decodeServiceMetadata(resp)
+}
+
+// HealthCheck probes every replica and succeeds when at least one is reachable
+func (p *Pool) HealthCheck(ctx context.Context) error {
+	var errs []error
+	for _, r := range p.replicas {
+		if err := p.probe(ctx, r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.baseURL, err))
+		}
+	}
+	if len(errs) == len(p.replicas) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// StartHealthChecks probes the replicas every interval until ctx is done
+func (p *Pool) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				var wg sync.WaitGroup
+				for _, r := range p.replicas {
+					wg.Add(1)
+					go func(r *replica) {
+						defer wg.Done()
+						p.probe(ctx, r)
+					}(r)
+				}
+				wg.Wait()
+			}
+		}
+	}()
+}
+
+// Status reports whether each replica is healthy
+func (p *Pool) Status() map[string]bool {
+	status := make(map[string]bool, len(p.replicas))
+	for _, r := range p.replicas {
+		status[r.baseURL] = r.healthy.Load()
+	}
+	return status
+}
+
+// probe runs an active health check on a replica and updates its state
+func (p *Pool) probe(ctx context.Context, r *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if err := r.client.HealthCheck(ctx); err != nil {
+		if r.healthy.Swap(false) {
+			p.logger.Warn("Replica marked unhealthy by health check", "replica", r.baseURL, "error", err)
+		}
+		return err
+	}
+	r.failures.Store(0)
+	p.markHealthy(r)
+	return nil
+}
+
+// candidates returns the replicas in the order to try: healthy replicas in
+// strategy order, then the unhealthy ones as a last resort
+func (p *Pool) candidates() []*replica {
+	n := len(p.replicas)
+	start := int(p.next.Add(1)-1) % n
+
+	var healthy, unhealthy []*replica
+	for i := 0; i < n; i++ {
+		r := p.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		} else {
+			unhealthy = append(unhealthy, r)
+		}
+	}
+
+	if p.strategy == LeastOutstanding && len(healthy) > 1 {
+		// Stable selection keeps the round-robin order among equally loaded replicas
+		best := 0
+		for i, r := range healthy {
+			if r.outstanding.Load() < healthy[best].outstanding.Load() {
+				best = i
+			}
+		}
+		healthy[0], healthy[best] = healthy[best], healthy[0]
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// recordFailure counts a failed request and marks the replica unhealthy at maxFails
+func (p *Pool) recordFailure(r *replica, err error, resp *http.Response) {
+	failures := int(r.failures.Add(1))
+	if failures >= p.maxFails && r.healthy.Swap(false) {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		p.logger.Warn("Replica marked unhealthy after consecutive failures",
+			"replica", r.baseURL,
+			"failures", failures,
+			"status", status,
+			"error", err)
+	}
+}
+
+// markHealthy returns a replica to rotation
+func (p *Pool) markHealthy(r *replica) {
+	if !r.healthy.Swap(true) {
+		p.logger.Info("Replica marked healthy", "replica", r.baseURL)
+	}
+}
+
+// isReplicaFailure reports whether a status indicates an unavailable replica
+func isReplicaFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// trackedBody calls done once when the response body is closed
+type trackedBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer answers with status and counts its requests
+func countingServer(t *testing.T, status *atomic.Int32, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(int(status.Load()))
+		io.WriteString(w, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	var status1, status2, hits1, hits2 atomic.Int32
+	status1.Store(http.StatusOK)
+	status2.Store(http.StatusOK)
+	server1 := countingServer(t, &status1, &hits1)
+	server2 := countingServer(t, &status2, &hits2)
+
+	pool := NewPool([]string{server1.URL, server2.URL}, 5*time.Second, nil, RoundRobin, 3, testLogger())
+	for i := 0; i < 4; i++ {
+		resp, err := pool.Get(context.Background(), pool.BaseURL()+"/arcgis/rest/services")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "/arcgis/rest/services" {
+			t.Errorf("expected the request path to be preserved, got %s", body)
+		}
+	}
+
+	if hits1.Load() != 2 || hits2.Load() != 2 {
+		t.Errorf("expected 2 requests per replica, got %d and %d", hits1.Load(), hits2.Load())
+	}
+}
+
+func TestPool_Failover(t *testing.T) {
+	var status1, status2, hits1, hits2 atomic.Int32
+	status1.Store(http.StatusServiceUnavailable)
+	status2.Store(http.StatusOK)
+	server1 := countingServer(t, &status1, &hits1)
+	server2 := countingServer(t, &status2, &hits2)
+
+	pool := NewPool([]string{server1.URL, server2.URL}, 5*time.Second, nil, RoundRobin, 2, testLogger())
+	for i := 0; i < 6; i++ {
+		resp, err := pool.Get(context.Background(), pool.BaseURL()+"/export")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("request %d: expected failover to a healthy replica, got status %d", i, resp.StatusCode)
+		}
+	}
+
+	// The failing replica is taken out of rotation after two failures
+	if hits1.Load() != 2 {
+		t.Errorf("expected 2 requests to the failing replica, got %d", hits1.Load())
+	}
+	if pool.Status()[server1.URL] {
+		t.Errorf("expected %s to be marked unhealthy", server1.URL)
+	}
+
+	// A successful probe returns it to rotation
+	status1.Store(http.StatusOK)
+	if err := pool.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("unexpected health check error: %v", err)
+	}
+	if !pool.Status()[server1.URL] {
+		t.Errorf("expected %s to be healthy after a successful probe", server1.URL)
+	}
+}
+
+func TestPool_PostNotFailedOver(t *testing.T) {
+	var status1, status2, hits1, hits2 atomic.Int32
+	status1.Store(http.StatusServiceUnavailable)
+	status2.Store(http.StatusOK)
+	server1 := countingServer(t, &status1, &hits1)
+	server2 := countingServer(t, &status2, &hits2)
+
+	pool := NewPool([]string{server1.URL, server2.URL}, 5*time.Second, nil, RoundRobin, 3, testLogger())
+	resp, err := pool.Post(context.Background(), pool.BaseURL()+"/applyEdits", "application/x-www-form-urlencoded", []byte("f=json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	// The edit may have been applied, so it must not be repeated on another replica
+	if resp.StatusCode != http.StatusServiceUnavailable || hits2.Load() != 0 {
+		t.Errorf("expected the 503 of the first replica only, got status %d and %d requests to the second", resp.StatusCode, hits2.Load())
+	}
+}
+
+func TestPool_AllReplicasFailing(t *testing.T) {
+	var status1, status2, hits1, hits2 atomic.Int32
+	status1.Store(http.StatusBadGateway)
+	status2.Store(http.StatusBadGateway)
+	server1 := countingServer(t, &status1, &hits1)
+	server2 := countingServer(t, &status2, &hits2)
+
+	pool := NewPool([]string{server1.URL, server2.URL}, 5*time.Second, nil, RoundRobin, 1, testLogger())
+	resp, err := pool.Get(context.Background(), pool.BaseURL()+"/export")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected the last replica's status, got %d", resp.StatusCode)
+	}
+
+	if err := pool.HealthCheck(context.Background()); err == nil {
+		t.Errorf("expected health check to fail when no replica is reachable")
+	}
+}
+
+func TestPool_LeastOutstanding(t *testing.T) {
+	pool := NewPool([]string{"http://a", "http://b", "http://c"}, time.Second, nil, LeastOutstanding, 3, testLogger())
+	pool.replicas[0].outstanding.Store(5)
+	pool.replicas[1].outstanding.Store(1)
+	pool.replicas[2].outstanding.Store(3)
+
+	for i := 0; i < 3; i++ {
+		if first := pool.candidates()[0]; first.baseURL != "http://b" {
+			t.Errorf("expected the least loaded replica first, got %s", first.baseURL)
+		}
+	}
+
+	pool.replicas[1].healthy.Store(false)
+	candidates := pool.candidates()
+	if candidates[0].baseURL != "http://c" || candidates[len(candidates)-1].baseURL != "http://b" {
+		t.Errorf("expected unhealthy replicas last, got %s first and %s last", candidates[0].baseURL, candidates[len(candidates)-1].baseURL)
+	}
+}
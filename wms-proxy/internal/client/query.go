@@ -0,0 +1,69 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// QueryParams represents ArcGIS REST layer query parameters
+type QueryParams struct {
+	Where             string
+	Geometry          string // envelope as "xmin,ymin,xmax,ymax"
+	InSR              string
+	OutFields         string
+	OutSR             string
+	ObjectIDs         string
+	ResultOffset      int
+	ResultRecordCount int
+	ReturnGeometry    bool
+}
+
+// BuildQueryURL constructs the ArcGIS REST query URL for a layer of a MapServer or FeatureServer
+func BuildQueryURL(baseURL, servicePath, layerID string, params *QueryParams) string {
+	serviceRoot := ServiceRoot(servicePath)
+
+	values := url.Values{}
+	where := params.Where
+	if where == "" {
+		where = "1=1"
+	}
+	values.Set("where", where)
+
+	outFields := params.OutFields
+	if outFields == "" {
+		outFields = "*"
+	}
+	values.Set("outFields", outFields)
+
+	if params.Geometry != "" {
+		values.Set("geometry", params.Geometry)
+		values.Set("geometryType", "esriGeometryEnvelope")
+		values.Set("spatialRel", "esriSpatialRelIntersects")
+		if params.InSR != "" {
+			values.Set("inSR", params.InSR)
+		}
+	}
+	if params.OutSR != "" {
+		values.Set("outSR", params.OutSR)
+	}
+	if params.ObjectIDs != "" {
+		values.Set("objectIds", params.ObjectIDs)
+	}
+	if params.ResultOffset > 0 {
+		values.Set("resultOffset", strconv.Itoa(params.ResultOffset))
+	}
+	if params.ResultRecordCount > 0 {
+		values.Set("resultRecordCount", strconv.Itoa(params.ResultRecordCount))
+	}
+	values.Set("returnGeometry", strconv.FormatBool(params.ReturnGeometry))
+	values.Set("f", "json")
+
+	return baseURL + serviceRoot + "/" + layerID + "/query?" + values.Encode()
+}
+
+// WKIDFromCRS extracts the numeric WKID from an EPSG code for ArcGIS SR parameters
+// (e.g. "EPSG:3424" -> "3424")
+func WKIDFromCRS(crs string) string {
+	return strings.TrimPrefix(strings.ToUpper(crs), "EPSG:")
+}
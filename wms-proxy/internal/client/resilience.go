@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned without contacting the backend while its circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ResilienceConfig configures retries and the circuit breaker of a ResilientClient
+type ResilienceConfig struct {
+	MaxRetries       int           // retries after the first attempt
+	BaseBackoff      time.Duration // backoff before the first retry, doubled for each further retry
+	MaxBackoff       time.Duration
+	FailureThreshold int           // consecutive failed requests that open the circuit (0 disables the breaker)
+	OpenTimeout      time.Duration // time the circuit stays open before a trial request
+}
+
+// ResilientClient retries failed GET requests with jittered exponential backoff
+// and fails fast through a circuit breaker while the backend is unavailable
+type ResilientClient struct {
+	inner  ArcGISClientInterface
+	config ResilienceConfig
+	logger *slog.Logger
+
+	mu            sync.Mutex
+	state         string
+	failures      int
+	openedAt      time.Time
+	trialInFlight bool
+	retries       int64
+	rejected      int64
+	opened        int64
+}
+
+// Ensure ResilientClient implements ArcGISClientInterface
+var _ ArcGISClientInterface = (*ResilientClient)(nil)
+
+// NewResilientClient wraps inner with retries and a circuit breaker
+func NewResilientClient(inner ArcGISClientInterface, config ResilienceConfig, logger *slog.Logger) *ResilientClient {
+	return &ResilientClient{
+		inner:  inner,
+		config: config,
+		logger: logger,
+		state:  CircuitClosed,
+	}
+}
+
+// Get performs a GET request, retrying connection errors and 502/503/504 responses
+func (c *ResilientClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.inner.Get(ctx, url)
+		retryable := (err != nil && isConnectionError(err)) || (err == nil && isReplicaFailure(resp.StatusCode))
+		if !retryable || attempt == c.config.MaxRetries || ctx.Err() != nil {
+			c.release(ctx, retryable)
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := c.backoff(ctx, attempt, url, err, resp); err != nil {
+			c.release(ctx, true)
+			return nil, err
+		}
+	}
+}
+
+// Post performs a POST request through the circuit breaker. It is never retried:
+// the request may have been executed even when the response was lost.
+func (c *ResilientClient) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.inner.Post(ctx, url, contentType, body)
+	c.release(ctx, (err != nil && isConnectionError(err)) || (err == nil && isReplicaFailure(resp.StatusCode)))
+	return resp, err
+}
+
+// GetServiceMetadata retrieves service metadata, retrying connection errors and 502/503/504 responses
+func (c *ResilientClient) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		metadata, err := c.inner.GetServiceMetadata(ctx, servicePath)
+		var statusErr *StatusError
+		retryable := err != nil && (isConnectionError(err) || (errors.As(err, &statusErr) && isReplicaFailure(statusErr.StatusCode)))
+		if !retryable || attempt == c.config.MaxRetries || ctx.Err() != nil {
+			c.release(ctx, retryable)
+			return metadata, err
+		}
+
+		if err := c.backoff(ctx, attempt, servicePath, err, nil); err != nil {
+			c.release(ctx, true)
+			return nil, err
+		}
+	}
+}
+
+// State returns the circuit breaker state
+func (c *ResilientClient) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Stats returns retry and circuit breaker statistics for monitoring
+func (c *ResilientClient) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return map[string]interface{}{
+		"state":                c.state,
+		"consecutive_failures": c.failures,
+		"retries":              c.retries,
+		"rejected":             c.rejected,
+		"opened":               c.opened,
+	}
+}
+
+// backoff waits before retry attempt+1 with full jitter
+func (c *ResilientClient) backoff(ctx context.Context, attempt int, target string, err error, resp *http.Response) error {
+	delay := c.config.BaseBackoff << uint(attempt)
+	if delay > c.config.MaxBackoff || delay <= 0 {
+		delay = c.config.MaxBackoff
+	}
+	if delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	}
+
+	c.mu.Lock()
+	c.retries++
+	c.mu.Unlock()
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	c.logger.Warn("Retrying upstream request",
+		"target", target,
+		"attempt", attempt+1,
+		"delay_ms", delay.Milliseconds(),
+		"status", status,
+		"error", err)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// acquire admits a request, rejecting it while the circuit is open. After the
+// open timeout a single trial request is admitted in the half-open state.
+func (c *ResilientClient) acquire() error {
+	if c.config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case CircuitOpen:
+		if time.Since(c.openedAt) < c.config.OpenTimeout {
+			c.rejected++
+			return ErrCircuitOpen
+		}
+		c.transition(CircuitHalfOpen)
+		c.trialInFlight = true
+	case CircuitHalfOpen:
+		if c.trialInFlight {
+			c.rejected++
+			return ErrCircuitOpen
+		}
+		c.trialInFlight = true
+	}
+	return nil
+}
+
+// release records the outcome of an admitted request
+func (c *ResilientClient) release(ctx context.Context, failed bool) {
+	if c.config.FailureThreshold <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A request cancelled by its caller says nothing about the backend
+	if ctx.Err() != nil && failed {
+		if c.state == CircuitHalfOpen {
+			c.trialInFlight = false
+		}
+		return
+	}
+
+	if !failed {
+		c.failures = 0
+		c.trialInFlight = false
+		if c.state != CircuitClosed {
+			c.transition(CircuitClosed)
+		}
+		return
+	}
+
+	c.failures++
+	switch {
+	case c.state == CircuitHalfOpen:
+		c.trialInFlight = false
+		c.open()
+	case c.state == CircuitClosed && c.failures >= c.config.FailureThreshold:
+		c.open()
+	}
+}
+
+// open opens the circuit; the caller holds mu
+func (c *ResilientClient) open() {
+	c.openedAt = time.Now()
+	c.opened++
+	c.transition(CircuitOpen)
+}
+
+// transition changes the breaker state and logs it; the caller holds mu
+func (c *ResilientClient) transition(state string) {
+	c.logger.Warn("Circuit breaker state changed",
+		"from", c.state,
+		"to", state,
+		"consecutive_failures", c.failures,
+		"opened", c.opened)
+	c.state = state
+}
+
+// isConnectionError reports whether err is a transport failure (as opposed to an HTTP status)
+func isConnectionError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedClient returns the next scripted status (0 for a connection error) on each call
+type scriptedClient struct {
+	statuses []int
+	calls    int
+}
+
+func (s *scriptedClient) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	status := s.statuses[len(s.statuses)-1]
+	if s.calls < len(s.statuses) {
+		status = s.statuses[s.calls]
+	}
+	s.calls++
+	if status == 0 {
+		return nil, &url.Error{Op: "Get", URL: rawURL, Err: errors.New("connection refused")}
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func (s *scriptedClient) Post(ctx context.Context, rawURL, contentType string, body []byte) (*http.Response, error) {
+	return s.Get(ctx, rawURL)
+}
+
+func (s *scriptedClient) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	resp, err := s.Get(ctx, servicePath)
+	if err != nil {
+		return nil, err
+	}
+	return decodeServiceMetadata(resp)
+}
+
+func TestResilientClient_Retry(t *testing.T) {
+	tests := []struct {
+		name           string
+		statuses       []int
+		expectedStatus int
+		expectedCalls  int
+		expectError    bool
+	}{
+		{"success", []int{200}, 200, 1, false},
+		{"retry after 503", []int{503, 200}, 200, 2, false},
+		{"retry after connection error", []int{0, 0, 200}, 200, 3, false},
+		{"retries exhausted", []int{502}, 502, 3, false},
+		{"connection errors exhausted", []int{0}, 0, 3, true},
+		{"no retry on 404", []int{404, 200}, 404, 1, false},
+		{"no retry on 500", []int{500, 200}, 500, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inner := &scriptedClient{statuses: test.statuses}
+			c := NewResilientClient(inner, ResilienceConfig{MaxRetries: 2, BaseBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}, testLogger())
+
+			resp, err := c.Get(context.Background(), "http://backend/export")
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if resp.StatusCode != test.expectedStatus {
+				t.Errorf("expected status %d, got %d", test.expectedStatus, resp.StatusCode)
+			}
+			if inner.calls != test.expectedCalls {
+				t.Errorf("expected %d calls, got %d", test.expectedCalls, inner.calls)
+			}
+		})
+	}
+}
+
+func TestResilientClient_PostNotRetried(t *testing.T) {
+	inner := &scriptedClient{statuses: []int{503, 200}}
+	c := NewResilientClient(inner, ResilienceConfig{MaxRetries: 2, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, testLogger())
+
+	resp, err := c.Post(context.Background(), "http://backend/applyEdits", "application/x-www-form-urlencoded", []byte("f=json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 503 || inner.calls != 1 {
+		t.Errorf("expected the 503 without a retry, got status %d after %d calls", resp.StatusCode, inner.calls)
+	}
+}
+
+func TestResilientClient_MetadataRetry(t *testing.T) {
+	inner := &scriptedClient{statuses: []int{504, 404}}
+	c := NewResilientClient(inner, ResilienceConfig{MaxRetries: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, testLogger())
+
+	_, err := c.GetServiceMetadata(context.Background(), "/arcgis/rest/services/test/MapServer")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 404 {
+		t.Errorf("expected the 404 status error, got %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected a retry after 504 only, got %d calls", inner.calls)
+	}
+}
+
+func TestResilientClient_CircuitBreaker(t *testing.T) {
+	inner := &scriptedClient{statuses: []int{503, 503, 200}}
+	c := NewResilientClient(inner, ResilienceConfig{FailureThreshold: 2, OpenTimeout: 20 * time.Millisecond}, testLogger())
+
+	for i := 0; i < 2; i++ {
+		if resp, err := c.Get(context.Background(), "http://backend/export"); err != nil || resp.StatusCode != 503 {
+			t.Fatalf("request %d: expected the upstream 503, got %v", i, err)
+		}
+	}
+	if c.State() != CircuitOpen {
+		t.Fatalf("expected the circuit to open after 2 failures, got %s", c.State())
+	}
+
+	// Open: fail fast without contacting the backend
+	if _, err := c.Get(context.Background(), "http://backend/export"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected no upstream call while open, got %d calls", inner.calls)
+	}
+
+	// After the open timeout a successful trial request closes the circuit
+	time.Sleep(30 * time.Millisecond)
+	if resp, err := c.Get(context.Background(), "http://backend/export"); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected the trial request to succeed, got %v", err)
+	}
+	if c.State() != CircuitClosed {
+		t.Errorf("expected the circuit to close, got %s", c.State())
+	}
+
+	stats := c.Stats()
+	if stats["opened"] != int64(1) || stats["rejected"] != int64(1) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestResilientClient_HalfOpenFailure(t *testing.T) {
+	inner := &scriptedClient{statuses: []int{0}}
+	c := NewResilientClient(inner, ResilienceConfig{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond}, testLogger())
+
+	c.Get(context.Background(), "http://backend/export")
+	if c.State() != CircuitOpen {
+		t.Fatalf("expected the circuit to open, got %s", c.State())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	c.Get(context.Background(), "http://backend/export")
+	if c.State() != CircuitOpen {
+		t.Errorf("expected a failed trial to reopen the circuit, got %s", c.State())
+	}
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/cache"
+	"wms-proxy/internal/coalesce"
+)
+
+// Warning headers marking responses served from expired cache entries
+const (
+	WarningStale              = `110 - "Response is Stale"`
+	WarningRevalidationFailed = `111 - "Revalidation Failed"`
+)
+
+// cachedHeaders are the upstream response headers replayed from the cache
+var cachedHeaders = []string{"Content-Type", "Cache-Control", "Expires", "Last-Modified", "ETag"}
+
+// CachingClient caches successful GET responses. Expired entries are served
+// while they are refreshed in the background (stale-while-revalidate) and when
+// the backend fails, answers with an ArcGIS error body or its circuit is open
+// (stale-if-error), marked with a Warning header.
+type CachingClient struct {
+	inner     ArcGISClientInterface
+	cache     *cache.TileCache
+	baseURL   string
+	logger    *slog.Logger
+	refreshes coalesce.Group[struct{}]
+}
+
+// Ensure CachingClient implements ArcGISClientInterface
+var _ ArcGISClientInterface = (*CachingClient)(nil)
+
+// NewCachingClient wraps inner with the response cache; baseURL is used for
+// service metadata requests
+func NewCachingClient(inner ArcGISClientInterface, responseCache *cache.TileCache, baseURL string, logger *slog.Logger) *CachingClient {
+	return &CachingClient{
+		inner:   inner,
+		cache:   responseCache,
+		baseURL: baseURL,
+		logger:  logger,
+	}
+}
+
+// Get returns a cached response when one is fresh and otherwise requests it from the backend
+func (c *CachingClient) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	key := "resp:" + normalizeURL(rawURL)
+	entry, freshness, found := c.cache.Lookup(key)
+	switch {
+	case found && freshness == cache.Fresh:
+		return entryResponse(entry, ""), nil
+	case found && freshness == cache.StaleRevalidate:
+		c.revalidate(key, rawURL)
+		c.cache.RecordStale()
+		return entryResponse(entry, WarningStale), nil
+	}
+
+	resp, failed, err := c.fetch(ctx, key, rawURL)
+	if found && ctx.Err() == nil && (err != nil || failed) {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		c.logger.Warn("Upstream request failed, serving stale response",
+			"url", rawURL,
+			"status", status,
+			"error", err,
+			"age_s", int(time.Since(entry.StoredAt).Seconds()))
+		c.cache.RecordStale()
+		return entryResponse(entry, WarningRevalidationFailed), nil
+	}
+	return resp, err
+}
+
+// Post performs a POST request; POST responses are never cached
+func (c *CachingClient) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	return c.inner.Post(ctx, url, contentType, body)
+}
+
+// GetServiceMetadata retrieves service metadata through the response cache
+func (c *CachingClient) GetServiceMetadata(ctx context.Context, servicePath string) (*ServiceMetadata, error) {
+	resp, err := c.Get(ctx, c.baseURL+ServiceRoot(servicePath)+"?f=json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute metadata request: %w", err)
+	}
+	return decodeServiceMetadata(resp)
+}
+
+// fetch requests rawURL from the backend and caches a successful response.
+// failed reports a server error or an ArcGIS error body, which is not cached.
+func (c *CachingClient) fetch(ctx context.Context, key, rawURL string) (*http.Response, bool, error) {
+	resp, err := c.inner.Get(ctx, rawURL)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK || !cacheable(resp.Header) {
+		return resp, resp.StatusCode >= http.StatusInternalServerError, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSharedBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, false, err
+	}
+	if len(body) > maxSharedBody {
+		// Too large to cache; return the response as it streams
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, false, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if isErrorBody(resp.Header, body) {
+		return resp, true, nil
+	}
+
+	header := make(http.Header)
+	for _, name := range cachedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	c.cache.Store(key, cache.Entry{Body: body, ContentType: header.Get("Content-Type"), Header: header})
+	return resp, false, nil
+}
+
+// revalidate refreshes an expired entry in the background
+func (c *CachingClient) revalidate(key, rawURL string) {
+	go c.refreshes.Do(context.Background(), key, func(ctx context.Context) (struct{}, error) {
+		resp, failed, err := c.fetch(ctx, key, rawURL)
+		if err != nil {
+			c.logger.Warn("Background revalidation failed", "url", rawURL, "error", err)
+			return struct{}{}, err
+		}
+		resp.Body.Close()
+		if failed {
+			c.logger.Warn("Background revalidation failed", "url", rawURL, "status", resp.StatusCode)
+		}
+		return struct{}{}, nil
+	})
+}
+
+// cacheable reports whether the upstream allows storing a response
+func cacheable(header http.Header) bool {
+	cacheControl := strings.ToLower(header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "private")
+}
+
+// isErrorBody reports whether a response body is an ArcGIS error, which is
+// sent with status 200 as JSON or text/plain: {"error":{"code":500,...}}
+func isErrorBody(header http.Header, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType != "text/plain" && !strings.HasSuffix(mediaType, "json") {
+		return false
+	}
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return false
+	}
+	return len(envelope.Error) > 0 && string(envelope.Error) != "null"
+}
+
+// entryResponse builds a response from a cached entry, adding warning when set
+func entryResponse(entry cache.Entry, warning string) *http.Response {
+	header := entry.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if warning != "" {
+		header.Set("Warning", warning)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/cache"
+)
+
+func TestCachingClient_Stale(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits, failing atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if failing.Load() == 1 {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{byte('0' + n)})
+	}))
+	defer server.Close()
+
+	responseCache := cache.NewTileCache(1<<20, 20*time.Millisecond)
+	responseCache.SetStale(20*time.Millisecond, time.Hour)
+	c := NewCachingClient(NewArcGISClient(server.URL, 5*time.Second), responseCache, server.URL, logger)
+	target := server.URL + "/arcgis/rest/services/Parcels/MapServer/export?bbox=1,2,3,4"
+
+	get := func() (string, string) {
+		t.Helper()
+		resp, err := c.Get(context.Background(), target)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+			t.Fatalf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		return string(body), resp.Header.Get("Warning")
+	}
+
+	if body, warning := get(); body != "1" || warning != "" {
+		t.Errorf("expected the upstream response, got %q %q", body, warning)
+	}
+	if body, _ := get(); body != "1" || hits.Load() != 1 {
+		t.Errorf("expected a cached response, got %q after %d requests", body, hits.Load())
+	}
+
+	// Within stale-while-revalidate the expired entry is served and refreshed in the background
+	time.Sleep(25 * time.Millisecond)
+	if body, warning := get(); body != "1" || warning != WarningStale {
+		t.Errorf("expected a stale response, got %q %q", body, warning)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for hits.Load() != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if body, warning := get(); body != "2" || warning != "" {
+		t.Errorf("expected the refreshed response, got %q %q", body, warning)
+	}
+
+	// Past stale-while-revalidate the expired entry is only served when the backend fails
+	failing.Store(1)
+	time.Sleep(45 * time.Millisecond)
+	if body, warning := get(); body != "2" || warning != WarningRevalidationFailed {
+		t.Errorf("expected a stale response after an upstream error, got %q %q", body, warning)
+	}
+	if stats := responseCache.Stats(); stats["stale"] != int64(2) {
+		t.Errorf("expected two stale responses, got %v", stats["stale"])
+	}
+}
+
+func TestCachingClient_NotCached(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	c := NewCachingClient(NewArcGISClient(server.URL, 5*time.Second), cache.NewTileCache(1<<20, time.Hour), server.URL, logger)
+	for _, path := range []string{"/private", "/missing"} {
+		for i := 0; i < 2; i++ {
+			resp, err := c.Get(context.Background(), server.URL+path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+		}
+	}
+	if hits.Load() != 4 {
+		t.Errorf("expected every request to reach the backend, got %d", hits.Load())
+	}
+}
+
+func TestCachingClient_ErrorBody(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits, failing atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch {
+		case failing.Load() == 1:
+			// ArcGIS reports errors with status 200
+			w.Header().Set("Content-Type", "text/plain;charset=utf-8")
+			w.Write([]byte(`{"error":{"code":500,"message":"Service Parcels/MapServer not started","details":[]}}`))
+		case r.URL.Path == "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"error":{"code":400,"message":"Invalid query"}}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"mapName":"Parcels","error":null}`))
+		}
+	}))
+	defer server.Close()
+
+	responseCache := cache.NewTileCache(1<<20, 20*time.Millisecond)
+	responseCache.SetStale(0, time.Hour)
+	c := NewCachingClient(NewArcGISClient(server.URL, 5*time.Second), responseCache, server.URL, logger)
+
+	get := func(path string) (string, string) {
+		t.Helper()
+		resp, err := c.Get(context.Background(), server.URL+path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get("Warning")
+	}
+
+	// Error bodies are returned but not cached
+	for i := 0; i < 2; i++ {
+		if body, _ := get("/json"); !strings.Contains(body, "Invalid query") {
+			t.Errorf("expected the error body, got %q", body)
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected error bodies not to be cached, got %d requests", hits.Load())
+	}
+
+	// An expired entry replaces an error body
+	if body, _ := get("/service"); !strings.Contains(body, "Parcels") {
+		t.Fatalf("expected the service metadata, got %q", body)
+	}
+	failing.Store(1)
+	time.Sleep(25 * time.Millisecond)
+	if body, warning := get("/service"); !strings.Contains(body, "Parcels") || warning != WarningRevalidationFailed {
+		t.Errorf("expected a stale response after an error body, got %q %q", body, warning)
+	}
+
+	failing.Store(0)
+	if body, warning := get("/service"); !strings.Contains(body, "Parcels") || warning != "" {
+		t.Errorf("expected the refreshed response, got %q %q", body, warning)
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import "strings"
+
+// ArcGIS service types
+const (
+	ServiceTypeMap     = "MapServer"
+	ServiceTypeImage   = "ImageServer"
+	ServiceTypeFeature = "FeatureServer"
+)
+
+// ServiceRoot returns a service path without its export or exportImage operation
+func ServiceRoot(servicePath string) string {
+	servicePath = strings.TrimSuffix(servicePath, "/")
+	for _, operation := range []string{"/export", "/exportImage"} {
+		if strings.HasSuffix(servicePath, operation) {
+			return strings.TrimSuffix(servicePath, operation)
+		}
+	}
+	return servicePath
+}
+
+// ServiceTypeFromPath returns the service type named by the last segment of
+// the service root (e.g. .../Aerials/ImageServer), or "" when it names none
+func ServiceTypeFromPath(servicePath string) string {
+	root := ServiceRoot(servicePath)
+	segment := root[strings.LastIndex(root, "/")+1:]
+	for _, serviceType := range []string{ServiceTypeMap, ServiceTypeImage, ServiceTypeFeature} {
+		if strings.EqualFold(segment, serviceType) {
+			return serviceType
+		}
+	}
+	return ""
+}
+
+// ServiceType determines the type of a service from its metadata: image
+// services describe their pixels, map services have the Map capability and
+// feature services list layers without it. The service path decides when the
+// metadata is inconclusive.
+func (m *ServiceMetadata) ServiceType(servicePath string) string {
+	switch {
+	case m.PixelType != "" || m.BandCount > 0 || strings.HasPrefix(m.ServiceDataType, "esriImageService"):
+		return ServiceTypeImage
+	case hasCapability(m.Capabilities, "Map"):
+		return ServiceTypeMap
+	case len(m.Layers) > 0 && m.Capabilities != "":
+		return ServiceTypeFeature
+	}
+	if serviceType := ServiceTypeFromPath(servicePath); serviceType != "" {
+		return serviceType
+	}
+	return ServiceTypeMap
+}
+
+// hasCapability reports whether a comma-separated capabilities list contains capability
+func hasCapability(capabilities, capability string) bool {
+	for _, c := range strings.Split(capabilities, ",") {
+		if strings.EqualFold(strings.TrimSpace(c), capability) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package client
+
+import "testing"
+
+func TestServiceMetadata_ServiceType(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    ServiceMetadata
+		servicePath string
+		expected    string
+	}{
+		{"image service", ServiceMetadata{PixelType: "F32", BandCount: 1}, "/arcgis/rest/services/Elevation/ImageServer", ServiceTypeImage},
+		{"image service behind a generic path", ServiceMetadata{ServiceDataType: "esriImageServiceDataTypeElevation"}, "/services/elevation", ServiceTypeImage},
+		{"map service", ServiceMetadata{Capabilities: "Map,Query,Data", Layers: []LayerInfo{{ID: 0}}}, "/arcgis/rest/services/Parcels/MapServer/export", ServiceTypeMap},
+		{"feature service", ServiceMetadata{Capabilities: "Query,Create", Layers: []LayerInfo{{ID: 0}}}, "/arcgis/rest/services/Parcels/FeatureServer", ServiceTypeFeature},
+		{"inconclusive metadata uses path", ServiceMetadata{}, "/arcgis/rest/services/Aerials/ImageServer/exportImage", ServiceTypeImage},
+		{"inconclusive metadata defaults to map", ServiceMetadata{}, "/services/unknown", ServiceTypeMap},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.metadata.ServiceType(test.servicePath); got != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestServiceRoot(t *testing.T) {
+	tests := map[string]string{
+		"/arcgis/rest/services/Parcels/MapServer/export":          "/arcgis/rest/services/Parcels/MapServer",
+		"/arcgis/rest/services/Elevation/ImageServer/exportImage": "/arcgis/rest/services/Elevation/ImageServer",
+		"/arcgis/rest/services/Parcels/FeatureServer/":            "/arcgis/rest/services/Parcels/FeatureServer",
+	}
+	for path, expected := range tests {
+		if got := ServiceRoot(path); got != expected {
+			t.Errorf("ServiceRoot(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry a cached token is renewed
+const tokenRefreshMargin = time.Minute
+
+// tokenExpiration is the lifetime requested from generateToken, in minutes
+const tokenExpiration = 60
+
+// TokenProvider supplies the ArcGIS token appended to upstream requests
+type TokenProvider interface {
+	// Token returns a valid token, acquiring a new one when needed
+	Token(ctx context.Context) (string, error)
+	// Invalidate discards the cached token after the server rejected it
+	Invalidate()
+}
+
+// StaticToken is a fixed, preconfigured token
+type StaticToken string
+
+// Token returns the static token
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Invalidate does nothing; a static token cannot be renewed
+func (t StaticToken) Invalidate() {}
+
+// tokenFetcher acquires a token and its expiry time
+type tokenFetcher func(ctx context.Context) (string, time.Time, error)
+
+// cachedToken caches a fetched token until shortly before it expires
+type cachedToken struct {
+	fetch   tokenFetcher
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token returns the cached token or fetches a new one
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires.Add(-tokenRefreshMargin)) {
+		return c.token, nil
+	}
+
+	token, expires, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	c.expires = expires
+	return token, nil
+}
+
+// Invalidate discards the cached token
+func (c *cachedToken) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
+
+// NewServerTokenProvider acquires tokens from an ArcGIS Server generateToken
+// endpoint (e.g. https://host/arcgis/tokens/generateToken) with a username and password
+func NewServerTokenProvider(httpClient *http.Client, tokenURL, username, password string) TokenProvider {
+	return &cachedToken{fetch: func(ctx context.Context) (string, time.Time, error) {
+		form := url.Values{}
+		form.Set("username", username)
+		form.Set("password", password)
+		form.Set("client", "requestip")
+		form.Set("expiration", fmt.Sprint(tokenExpiration))
+		form.Set("f", "json")
+
+		var response struct {
+			Token   string     `json:"token"`
+			Expires int64      `json:"expires"` // epoch milliseconds
+			Error   *arcgisErr `json:"error"`
+		}
+		if err := postForm(ctx, httpClient, tokenURL, form, &response); err != nil {
+			return "", time.Time{}, err
+		}
+		if response.Error != nil {
+			return "", time.Time{}, fmt.Errorf("generateToken failed: %s", response.Error)
+		}
+		if response.Token == "" {
+			return "", time.Time{}, fmt.Errorf("generateToken returned no token")
+		}
+
+		expires := time.UnixMilli(response.Expires)
+		if response.Expires == 0 {
+			expires = time.Now().Add(tokenExpiration * time.Minute)
+		}
+		return response.Token, expires, nil
+	}}
+}
+
+// NewOAuthTokenProvider acquires app tokens from an ArcGIS Portal with the
+// OAuth 2.0 client credentials grant
+func NewOAuthTokenProvider(httpClient *http.Client, portalURL, clientID, clientSecret string) TokenProvider {
+	tokenURL := strings.TrimSuffix(portalURL, "/") + "/sharing/rest/oauth2/token"
+	return &cachedToken{fetch: func(ctx context.Context) (string, time.Time, error) {
+		form := url.Values{}
+		form.Set("client_id", clientID)
+		form.Set("client_secret", clientSecret)
+		form.Set("grant_type", "client_credentials")
+		form.Set("f", "json")
+
+		var response struct {
+			AccessToken string     `json:"access_token"`
+			ExpiresIn   int64      `json:"expires_in"` // seconds
+			Error       *arcgisErr `json:"error"`
+		}
+		if err := postForm(ctx, httpClient, tokenURL, form, &response); err != nil {
+			return "", time.Time{}, err
+		}
+		if response.Error != nil {
+			return "", time.Time{}, fmt.Errorf("OAuth token request failed: %s", response.Error)
+		}
+		if response.AccessToken == "" {
+			return "", time.Time{}, fmt.Errorf("OAuth token request returned no token")
+		}
+
+		return response.AccessToken, time.Now().Add(time.Duration(response.ExpiresIn) * time.Second), nil
+	}}
+}
+
+// arcgisErr is the error object of ArcGIS JSON responses
+type arcgisErr struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *arcgisErr) String() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// postForm posts a form and decodes the JSON response into v
+func postForm(ctx context.Context, httpClient *http.Client, target string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "WMS-Proxy/1.0")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("token request failed with status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode token response: %w", err)
+	}
+	return nil
+}
+
+// isInvalidToken reports whether ArcGIS rejected the token: HTTP status 498
+// (invalid token) or 499 (token required), or a JSON error with those codes
+func isInvalidToken(status int, body []byte) bool {
+	if status == 498 || status == 499 {
+		return true
+	}
+
+	var response struct {
+		Error *arcgisErr `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil && response.Error != nil {
+		return response.Error.Code == 498 || response.Error.Code == 499
+	}
+	return false
+}
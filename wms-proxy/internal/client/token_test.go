@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// securedServer issues numbered tokens from generateToken and accepts only the latest one
+func securedServer(t *testing.T, issued *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/arcgis/tokens/generateToken":
+			if r.Method != http.MethodPost || r.FormValue("username") != "viewer" || r.FormValue("password") != "secret" {
+				fmt.Fprint(w, `{"error":{"code":400,"message":"Unable to generate token."}}`)
+				return
+			}
+			n := issued.Add(1)
+			fmt.Fprintf(w, `{"token":"token-%d","expires":%d}`, n, time.Now().Add(time.Hour).UnixMilli())
+		default:
+			if r.URL.Query().Get("token") != fmt.Sprintf("token-%d", issued.Load()) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"error":{"code":498,"message":"Invalid Token","details":[]}}`)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"spatialReference":{"wkid":3857}}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServerTokenProvider(t *testing.T) {
+	var issued atomic.Int32
+	server := securedServer(t, &issued)
+
+	c := NewArcGISClient(server.URL, 5*time.Second)
+	c.SetTokenProvider(NewServerTokenProvider(server.Client(), server.URL+"/arcgis/tokens/generateToken", "viewer", "secret"))
+
+	// The token is acquired once and reused
+	for i := 0; i < 3; i++ {
+		metadata, err := c.GetServiceMetadata(context.Background(), "/arcgis/rest/services/test/MapServer")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if metadata.SpatialReference.WKID != 3857 {
+			t.Errorf("expected the secured metadata, got WKID %d", metadata.SpatialReference.WKID)
+		}
+	}
+	if issued.Load() != 1 {
+		t.Errorf("expected 1 token request, got %d", issued.Load())
+	}
+
+	// Revoke the cached token: it is rejected with 498, renewed and the request repeated
+	issued.Add(1)
+	resp, err := c.Get(context.Background(), server.URL+"/arcgis/rest/services/test/MapServer?f=json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"spatialReference":{"wkid":3857}}` {
+		t.Errorf("expected the request to succeed with a renewed token, got %s", body)
+	}
+	if issued.Load() != 3 {
+		t.Errorf("expected the token to be renewed once, issued counter is %d", issued.Load())
+	}
+}
+
+func TestServerTokenProvider_InvalidCredentials(t *testing.T) {
+	var issued atomic.Int32
+	server := securedServer(t, &issued)
+
+	c := NewArcGISClient(server.URL, 5*time.Second)
+	c.SetTokenProvider(NewServerTokenProvider(server.Client(), server.URL+"/arcgis/tokens/generateToken", "viewer", "wrong"))
+
+	if _, err := c.Get(context.Background(), server.URL+"/arcgis/rest/services"); err == nil {
+		t.Errorf("expected an error for rejected credentials")
+	}
+}
+
+func TestOAuthTokenProvider(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/portal/sharing/rest/oauth2/token" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "app" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"app-token","expires_in":7200}`)
+	}))
+	defer server.Close()
+
+	provider := NewOAuthTokenProvider(server.Client(), server.URL+"/portal/", "app", "secret")
+	for i := 0; i < 2; i++ {
+		token, err := provider.Token(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != "app-token" {
+			t.Errorf("expected app-token, got %s", token)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected the token to be cached, got %d requests", requests.Load())
+	}
+
+	provider.Invalidate()
+	provider.Token(context.Background())
+	if requests.Load() != 2 {
+		t.Errorf("expected a new token request after Invalidate, got %d requests", requests.Load())
+	}
+}
+
+func TestArcGISClient_KeepsCallerToken(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query().Get("token")
+	}))
+	defer server.Close()
+
+	c := NewArcGISClient(server.URL, 5*time.Second)
+	c.SetToken("proxy-token")
+
+	resp, err := c.Get(context.Background(), server.URL+"/arcgis/rest/services?f=json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if received != "proxy-token" {
+		t.Errorf("expected the proxy token, got %q", received)
+	}
+
+	resp, err = c.Get(context.Background(), server.URL+"/arcgis/rest/services?f=json&token=caller-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if received != "caller-token" {
+		t.Errorf("expected the caller's token to be kept, got %q", received)
+	}
+}
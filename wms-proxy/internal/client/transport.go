@@ -0,0 +1,100 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TransportConfig configures TLS, the outbound proxy and connection pooling
+// of the upstream HTTP transport
+type TransportConfig struct {
+	CAFile              string // PEM bundle trusted in addition to the system roots
+	CertFile            string // client certificate for mutual TLS
+	KeyFile             string
+	MinTLSVersion       string // "1.0", "1.1", "1.2" or "1.3"
+	ServerName          string // SNI and certificate verification name, overriding the URL host
+	ProxyURL            string // forward proxy; empty uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 means unlimited
+	IdleConnTimeout     time.Duration
+}
+
+// DefaultTransportConfig returns the transport settings used when none are configured
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MinTLSVersion:       "1.2",
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTransport builds an HTTP transport from config, loading the CA bundle and
+// client certificate from disk
+func NewTransport(config TransportConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{ServerName: config.ServerName}
+
+	if config.MinTLSVersion != "" {
+		version, ok := tlsVersions[config.MinTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported minimum TLS version %q", config.MinTLSVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", config.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Transport{
+		Proxy:               proxy,
+		TLSClientConfig:     tlsConfig,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        config.MaxIdleConns,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.MaxConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}, nil
+}
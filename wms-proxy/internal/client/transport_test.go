@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientCert generates a self-signed client certificate and returns its files
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wms-proxy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	return writePEM(t, dir, "client.crt", "CERTIFICATE", der), writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER), cert
+}
+
+func TestNewTransport_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		name        string
+		config      TransportConfig
+		expectError bool
+	}{
+		{"untrusted certificate", DefaultTransportConfig(), true},
+		{"trusted CA bundle", TransportConfig{CAFile: caFile}, false},
+		// The test certificate is issued for example.com, so SNI must match it
+		{"matching server name", TransportConfig{CAFile: caFile, ServerName: "example.com"}, false},
+		{"mismatched server name", TransportConfig{CAFile: caFile, ServerName: "gis.example.org"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport, err := NewTransport(test.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			c := NewArcGISClientWithTransport(server.URL, 5*time.Second, transport)
+			err = c.HealthCheck(context.Background())
+			if test.expectError && err == nil {
+				t.Errorf("expected a TLS error")
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewTransport_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeClientCert(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	for _, withCert := range []bool{false, true} {
+		config := TransportConfig{CAFile: caFile}
+		if withCert {
+			config.CertFile, config.KeyFile = certFile, keyFile
+		}
+		transport, err := NewTransport(config)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = NewArcGISClientWithTransport(server.URL, 5*time.Second, transport).HealthCheck(context.Background())
+		if withCert && err != nil {
+			t.Errorf("expected mutual TLS to succeed, got %v", err)
+		}
+		if !withCert && err == nil {
+			t.Errorf("expected the server to require a client certificate")
+		}
+	}
+}
+
+func TestNewTransport_Proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(TransportConfig{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := NewArcGISClientWithTransport("http://gis.example.com", 5*time.Second, transport)
+	if err := c.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxied != "http://gis.example.com/arcgis/rest/services" {
+		t.Errorf("expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestNewTransport_InvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name   string
+		config TransportConfig
+	}{
+		{"unsupported TLS version", TransportConfig{MinTLSVersion: "1.4"}},
+		{"missing CA bundle", TransportConfig{CAFile: filepath.Join(dir, "missing.pem")}},
+		{"CA bundle without certificates", TransportConfig{CAFile: notPEM}},
+		{"certificate without key", TransportConfig{CertFile: notPEM}},
+		{"proxy without host", TransportConfig{ProxyURL: "proxy:3128"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewTransport(test.config); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Package coalesce shares one execution of a call among the concurrent callers
+// asking for the same key.
+package coalesce
+
+import (
+	"context"
+	"sync"
+)
+
+// call is an execution in flight and its result
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Group coalesces calls by key. The zero value is ready to use.
+type Group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+// Do runs fn for the first caller with key and makes concurrent callers with
+// the same key wait for its result. fn runs with a context detached from the
+// first caller's cancellation, so a caller giving up does not fail the others;
+// each caller stops waiting when its own ctx is done. leader reports whether
+// this caller started the execution.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(context.Context) (T, error)) (val T, leader bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+	c, inFlight := g.calls[key]
+	if !inFlight {
+		c = &call[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		go func() {
+			c.val, c.err = fn(context.WithoutCancel(ctx))
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, !inFlight, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, !inFlight, ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[string]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var leaders atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, leader, err := g.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+				calls.Add(1)
+				<-release
+				return "result", nil
+			})
+			if err != nil || val != "result" {
+				t.Errorf("unexpected result %q, %v", val, err)
+			}
+			if leader {
+				leaders.Add(1)
+			}
+		}()
+	}
+
+	// Let the callers join the call in flight before it completes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || leaders.Load() != 1 {
+		t.Errorf("expected one execution and one leader, got %d executions and %d leaders", calls.Load(), leaders.Load())
+	}
+
+	// Later calls start a new execution
+	g.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return "", nil
+	})
+	if calls.Load() != 2 {
+		t.Errorf("expected a new execution after the first completed, got %d", calls.Load())
+	}
+}
+
+func TestGroup_CallerCancellation(t *testing.T) {
+	var g Group[int]
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		<-release
+		return 42, ctx.Err()
+	}
+
+	// The first caller gives up; the execution continues for the second
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := g.Do(ctx, "key", fn)
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	second := make(chan int, 1)
+	go func() {
+		val, _, err := g.Do(context.Background(), "key", fn)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		second <- val
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancelled caller to stop waiting, got %v", err)
+	}
+	close(release)
+	if val := <-second; val != 42 {
+		t.Errorf("expected the remaining caller to get the result, got %d", val)
+	}
+}
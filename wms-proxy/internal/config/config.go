@@ -1,62 +1,456 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"os"
-	"strconv"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration for the proxy server
 type Config struct {
-	ArcGISHost     string
-	ArcGISScheme   string
-	ArcGISService  string
-	ProxyPort      int
-	RequestTimeout time.Duration
-	LogLevel       string
-	EnableHTTPS    bool
-	CertFile       string
-	KeyFile        string
-}
-
-// Load reads configuration from environment variables with sensible defaults
+	File              string        // configuration file the settings were read from, if any
+	WatchInterval     time.Duration // interval of checks of File for changes (0 disables)
+	ArcGISHost        string
+	ArcGISScheme      string
+	ArcGISService     string
+	ArcGISAuth        Credentials
+	ArcGISReplicas    []string // additional hosts serving the same services as ArcGISHost
+	ReadOnly          bool     // reject editing operations in the /arcgis passthrough
+	MaxFormSize       int      // form-encoded POST body limit in megabytes
+	MaxUploadSize     int      // multipart POST body limit in megabytes
+	ProxyPort         int
+	PublicURL         string // external base URL of the proxy; overrides the request host and X-Forwarded-* headers
+	RequestTimeout    time.Duration
+	LogLevel          string
+	EnableHTTPS       bool
+	CertFile          string
+	KeyFile           string
+	JPEGQuality       int // re-encode JPEG output at this quality (0 passes upstream JPEGs through)
+	TileCacheSize     int // tile cache size in megabytes (0 disables caching, ignored with redis)
+	TileCacheTTL      time.Duration
+	ResponseCacheSize int // upstream response cache size in megabytes (0 disables caching, ignored with redis)
+	ResponseCacheTTL  time.Duration
+	StaleRevalidate   time.Duration     // serve expired cache entries while refreshing them in the background
+	StaleIfError      time.Duration     // serve expired cache entries while the backend fails
+	CacheStorage      string            // storage of the tile, response and metadata caches: memory, filesystem or redis
+	CacheDir          string            // directory of the filesystem storage
+	Redis             Redis             // server of the redis storage
+	CacheControl      map[string]string // Cache-Control per endpoint: capabilities, map and tiles
+	LayerCacheControl map[string]string // Cache-Control per layer name, overriding the endpoint's
+	DefaultCRS        string            // spatial reference assumed when a backend's cannot be detected
+	SRCacheTTL        time.Duration
+	MetadataCacheTTL  time.Duration     // lifetime of cached service metadata (0 disables the cache)
+	SROverrides       map[string]string // service path -> CRS, skipping detection
+	SRCacheDir        string            // directory persisting detected spatial references (empty disables)
+	SRRefresh         bool              // re-detect spatial references in the background before they expire
+	Backends          []Backend         // named backends served under /wms/{name}, /arcgis/{name}/...
+	LoadBalancing     string            // default strategy across replicas: round-robin or least-outstanding
+	MaxFails          int               // consecutive failures before a replica is marked unhealthy
+	HealthInterval    time.Duration
+	Retries           int // retries of failed idempotent upstream requests
+	RetryBackoff      time.Duration
+	RetryMaxDelay     time.Duration
+	CircuitFails      int // consecutive failed requests that open a backend's circuit (0 disables)
+	CircuitTimeout    time.Duration
+	Upstream          Transport // TLS, outbound proxy and connection pooling towards the backends
+	Bulkhead          Bulkhead  // default concurrency limit and request queue of each backend
+	Coalesce          bool      // share identical in-flight upstream GET requests
+	Discovery         bool      // crawl the default backend's services directory and publish its map services
+	DiscoveryInterval time.Duration
+	DiscoveryInclude  []string // path.Match patterns on folder-qualified service names
+	DiscoveryExclude  []string
+	TileFiles         map[string]string // tile layer name -> MBTiles or GeoPackage file served under /tiles
+}
+
+// Backend is an upstream ArcGIS server exposed as a virtual service
+type Backend struct {
+	Name          string
+	URLs          []string // replica base URLs (scheme and host); the first is canonical
+	ServicePath   string
+	Auth          Credentials
+	Timeout       time.Duration
+	DefaultCRS    string
+	LoadBalancing string
+	Transport     Transport
+	ReadOnly      bool
+	Bulkhead      Bulkhead
+}
+
+// Transport holds the TLS, outbound proxy and connection pooling settings of
+// the connections to a backend
+type Transport struct {
+	CAFile              string // PEM bundle trusted in addition to the system roots
+	CertFile            string // client certificate for mutual TLS
+	KeyFile             string
+	MinTLSVersion       string // "1.0", "1.1", "1.2" or "1.3"
+	ServerName          string // SNI and certificate verification name, overriding the URL host
+	ProxyURL            string // forward proxy; empty uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 means unlimited
+	IdleConnTimeout     time.Duration
+}
+
+// Bulkhead holds the concurrency limit and request queue of a backend
+type Bulkhead struct {
+	MaxConcurrent int           // requests in flight (0 disables the limit)
+	QueueSize     int           // requests waiting for a slot
+	MaxWait       time.Duration // time a request may wait in the queue
+}
+
+// Redis holds the server of the redis cache storage
+type Redis struct {
+	Address   string // host:port
+	Password  string
+	DB        int
+	KeyPrefix string // prefix of every key, extended by the name of each cache
+	Timeout   time.Duration
+}
+
+// Credentials authenticate the proxy to a secured backend: a static token, a
+// username and password for generateToken, or Portal OAuth client credentials
+type Credentials struct {
+	Token        string
+	Username     string
+	Password     string
+	TokenURL     string // generateToken endpoint; defaults to <url>/arcgis/tokens/generateToken
+	ClientID     string
+	ClientSecret string
+	PortalURL    string // Portal for OAuth client credentials, e.g. https://portal.example.com/portal
+}
+
+// BaseURL returns the canonical base URL of the backend
+func (b Backend) BaseURL() string {
+	return b.URLs[0]
+}
+
+// DefaultBackendName names the backend built from ARCGIS_HOST and ARCGIS_SERVICE
+const DefaultBackendName = "default"
+
+// reservedBackendNames are the names a named backend cannot take: the default
+// backend, the top-level path segments of ArcGIS Server and Portal, which the
+// default backend proxies under /arcgis/, and the OGC API resources of the
+// default backend under /ogcapi/
+var reservedBackendNames = map[string]bool{
+	DefaultBackendName: true,
+	"rest":             true,
+	"admin":            true,
+	"tokens":           true,
+	"services":         true,
+	"sharing":          true,
+	"manager":          true,
+	"help":             true,
+	"login":            true,
+	"home":             true,
+	"apps":             true,
+	"portaladmin":      true,
+	"api":              true,
+	"conformance":      true,
+	"collections":      true,
+}
+
+// defaultTransport holds the upstream transport settings used without configuration
+var defaultTransport = Transport{
+	MinTLSVersion:       "1.2",
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 10,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+var (
+	backendNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	crsPattern         = regexp.MustCompile(`^EPSG:[0-9]+$`)
+	cacheDirective     = regexp.MustCompile(`^[A-Za-z-]+(=([A-Za-z0-9-]+|"[^"]*"))?$`)
+)
+
+// Load reads the configuration file named by CONFIG_FILE, if any, and the
+// environment variables, which override the file's settings
 func Load() (*Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile reads configuration from the YAML or JSON file filename (none when
+// empty) and from environment variables, with sensible defaults. Every invalid
+// setting is reported: the error is an Errors listing them by file path or
+// environment variable.
+func LoadFile(filename string) (*Config, error) {
+	l := &loader{}
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration file: %w", err)
+		}
+		if l.file, l.paths, err = parseFile(data); err != nil {
+			return nil, err
+		}
+	}
+
 	cfg := &Config{
-		ArcGISHost:     getEnvString("ARCGIS_HOST", "localhost"),
-		ArcGISScheme:   getEnvString("ARCGIS_SCHEME", "https"),
-		ArcGISService:  getEnvString("ARCGIS_SERVICE", "/arcgis/rest/services/Features/Environmental_admin/MapServer/export"),
-		ProxyPort:      getEnvInt("PROXY_PORT", 8080),
-		RequestTimeout: time.Duration(getEnvInt("REQUEST_TIMEOUT", 30)) * time.Second,
-		LogLevel:       getEnvString("LOG_LEVEL", "info"),
-		EnableHTTPS:    getEnvBool("ENABLE_HTTPS", false),
-		CertFile:       getEnvString("CERT_FILE", "/app/certs/server.crt"),
-		KeyFile:        getEnvString("KEY_FILE", "/app/certs/server.key"),
+		File:           filename,
+		ArcGISHost:     l.string("ARCGIS_HOST", "localhost"),
+		ArcGISScheme:   l.string("ARCGIS_SCHEME", "https"),
+		ArcGISService:  l.string("ARCGIS_SERVICE", "/arcgis/rest/services/Features/Environmental_admin/MapServer/export"),
+		ArcGISAuth:     l.credentials("ARCGIS_"),
+		ReadOnly:       l.bool("ARCGIS_READ_ONLY", true),
+		MaxFormSize:    l.int("MAX_FORM_SIZE", 10),
+		MaxUploadSize:  l.int("MAX_UPLOAD_SIZE", 100),
+		ProxyPort:      l.int("PROXY_PORT", 8080),
+		PublicURL:      strings.TrimSuffix(l.string("PUBLIC_URL", ""), "/"),
+		RequestTimeout: time.Duration(l.int("REQUEST_TIMEOUT", 30)) * time.Second,
+		LogLevel:       l.string("LOG_LEVEL", "info"),
+		EnableHTTPS:    l.bool("ENABLE_HTTPS", false),
+		CertFile:       l.string("CERT_FILE", "/app/certs/server.crt"),
+		KeyFile:        l.string("KEY_FILE", "/app/certs/server.key"),
+		JPEGQuality:    l.int("JPEG_QUALITY", 0),
+		TileCacheSize:  l.int("TILE_CACHE_SIZE", 256),
+		TileCacheTTL:   time.Duration(l.int("TILE_CACHE_TTL", 3600)) * time.Second,
+		DefaultCRS:     l.string("DEFAULT_CRS", "EPSG:3424"),
+		LoadBalancing:  l.string("LOAD_BALANCING", "round-robin"),
+		MaxFails:       l.int("MAX_FAILS", 3),
+		HealthInterval: time.Duration(l.int("HEALTH_CHECK_INTERVAL", 30)) * time.Second,
+		Retries:        l.int("UPSTREAM_RETRIES", 2),
+		RetryBackoff:   time.Duration(l.int("RETRY_BACKOFF_MS", 200)) * time.Millisecond,
+		RetryMaxDelay:  time.Duration(l.int("RETRY_MAX_BACKOFF_MS", 2000)) * time.Millisecond,
+		CircuitFails:   l.int("CIRCUIT_FAILURE_THRESHOLD", 5),
+		CircuitTimeout: time.Duration(l.int("CIRCUIT_OPEN_TIMEOUT", 30)) * time.Second,
+	}
+	if filename != "" {
+		cfg.WatchInterval = time.Duration(l.int("CONFIG_WATCH_INTERVAL", 5)) * time.Second
 	}
+	cfg.Upstream = l.transport("UPSTREAM_", defaultTransport)
+	cfg.Upstream.MaxIdleConns = l.int("UPSTREAM_MAX_IDLE_CONNS", cfg.Upstream.MaxIdleConns)
+	cfg.Upstream.MaxIdleConnsPerHost = l.int("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", cfg.Upstream.MaxIdleConnsPerHost)
+	cfg.Upstream.MaxConnsPerHost = l.int("UPSTREAM_MAX_CONNS_PER_HOST", cfg.Upstream.MaxConnsPerHost)
+	cfg.Upstream.IdleConnTimeout = time.Duration(l.int("UPSTREAM_IDLE_CONN_TIMEOUT", int(cfg.Upstream.IdleConnTimeout.Seconds()))) * time.Second
+	cfg.ArcGISReplicas = l.list("ARCGIS_REPLICAS")
+	cfg.Coalesce = l.bool("UPSTREAM_COALESCE", true)
+	cfg.ResponseCacheSize = l.int("RESPONSE_CACHE_SIZE", 0)
+	cfg.ResponseCacheTTL = time.Duration(l.int("RESPONSE_CACHE_TTL", 300)) * time.Second
+	cfg.StaleRevalidate = time.Duration(l.int("STALE_WHILE_REVALIDATE", 30)) * time.Second
+	cfg.StaleIfError = time.Duration(l.int("STALE_IF_ERROR", 86400)) * time.Second
+	cfg.CacheStorage = l.string("CACHE_STORAGE", "memory")
+	cfg.CacheDir = l.string("CACHE_DIR", "")
+	cfg.Redis = Redis{
+		Address:   l.string("REDIS_ADDR", ""),
+		Password:  l.string("REDIS_PASSWORD", ""),
+		DB:        l.int("REDIS_DB", 0),
+		KeyPrefix: l.string("REDIS_KEY_PREFIX", "wms-proxy:"),
+		Timeout:   time.Duration(l.int("REDIS_TIMEOUT_MS", 1000)) * time.Millisecond,
+	}
+	cfg.Bulkhead = l.bulkhead("UPSTREAM_", Bulkhead{QueueSize: 100, MaxWait: 10 * time.Second})
+	cfg.Discovery = l.bool("DISCOVERY_ENABLED", false)
+	cfg.DiscoveryInterval = time.Duration(l.int("DISCOVERY_INTERVAL", 3600)) * time.Second
+	cfg.DiscoveryInclude = l.list("DISCOVERY_INCLUDE")
+	cfg.DiscoveryExclude = l.list("DISCOVERY_EXCLUDE")
+	cfg.CacheControl = l.cacheControl()
+	cfg.SRCacheTTL = time.Duration(l.int("SR_CACHE_TTL", 900)) * time.Second
+	cfg.MetadataCacheTTL = time.Duration(l.int("METADATA_CACHE_TTL", 900)) * time.Second
+	cfg.SROverrides = loadSROverrides(l.list("SR_OVERRIDES"))
+	cfg.SRCacheDir = l.string("SR_CACHE_DIR", "")
+	cfg.SRRefresh = l.bool("SR_REFRESH", true)
+	cfg.LayerCacheControl = loadLayerCacheControl(l.string("CACHE_CONTROL_LAYERS", ""))
+	cfg.TileFiles = loadTileFiles(l.list("TILE_FILES"))
 
 	// Validate required configuration
 	if cfg.ArcGISHost == "" {
-		return nil, fmt.Errorf("ARCGIS_HOST is required")
+		l.fail("ARCGIS_HOST", "is required")
 	}
 
 	if cfg.ArcGISScheme != "http" && cfg.ArcGISScheme != "https" {
-		return nil, fmt.Errorf("ARCGIS_SCHEME must be 'http' or 'https'")
+		l.fail("ARCGIS_SCHEME", "must be 'http' or 'https'")
 	}
 
 	if cfg.ProxyPort <= 0 || cfg.ProxyPort > 65535 {
-		return nil, fmt.Errorf("PROXY_PORT must be between 1 and 65535")
+		l.fail("PROXY_PORT", "must be between 1 and 65535")
+	}
+
+	if cfg.PublicURL != "" {
+		u, err := url.Parse(cfg.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			l.fail("PUBLIC_URL", "must be an http or https URL without query, e.g. https://maps.example.com/gis")
+		}
+	}
+
+	if cfg.RequestTimeout <= 0 {
+		l.fail("REQUEST_TIMEOUT", "must be positive")
+	}
+
+	if !validLogLevel(cfg.LogLevel) {
+		l.fail("LOG_LEVEL", "must be 'debug', 'info', 'warn' or 'error'")
+	}
+
+	if cfg.WatchInterval < 0 {
+		l.fail("CONFIG_WATCH_INTERVAL", "must not be negative")
+	}
+
+	if cfg.MaxFormSize <= 0 {
+		l.fail("MAX_FORM_SIZE", "must be positive")
+	}
+	if cfg.MaxUploadSize <= 0 {
+		l.fail("MAX_UPLOAD_SIZE", "must be positive")
+	}
+
+	if cfg.JPEGQuality < 0 || cfg.JPEGQuality > 100 {
+		l.fail("JPEG_QUALITY", "must be between 0 and 100")
+	}
+
+	if cfg.TileCacheSize < 0 {
+		l.fail("TILE_CACHE_SIZE", "must not be negative")
+	}
+
+	if cfg.ResponseCacheSize < 0 {
+		l.fail("RESPONSE_CACHE_SIZE", "must not be negative")
+	}
+	if cfg.ResponseCacheTTL <= 0 {
+		l.fail("RESPONSE_CACHE_TTL", "must be positive")
+	}
+
+	if cfg.StaleRevalidate < 0 {
+		l.fail("STALE_WHILE_REVALIDATE", "must not be negative")
+	}
+	if cfg.StaleIfError < cfg.StaleRevalidate {
+		l.fail("STALE_IF_ERROR", "must not be shorter than STALE_WHILE_REVALIDATE")
+	}
+
+	switch cfg.CacheStorage {
+	case "memory":
+	case "filesystem":
+		if cfg.CacheDir == "" {
+			l.fail("CACHE_DIR", "is required with CACHE_STORAGE=filesystem")
+		}
+	case "redis":
+		if cfg.Redis.Address == "" {
+			l.fail("REDIS_ADDR", "is required with CACHE_STORAGE=redis")
+		}
+		if cfg.Redis.DB < 0 {
+			l.fail("REDIS_DB", "must not be negative")
+		}
+		if cfg.Redis.Timeout <= 0 {
+			l.fail("REDIS_TIMEOUT_MS", "must be positive")
+		}
+	default:
+		l.fail("CACHE_STORAGE", "must be 'memory', 'filesystem' or 'redis'")
+	}
+
+	if !crsPattern.MatchString(cfg.DefaultCRS) {
+		l.fail("DEFAULT_CRS", "must have the form EPSG:<code>")
+	}
+
+	if cfg.SRCacheTTL <= 0 {
+		l.fail("SR_CACHE_TTL", "must be positive")
+	}
+
+	if cfg.MetadataCacheTTL < 0 {
+		l.fail("METADATA_CACHE_TTL", "must not be negative")
+	}
+
+	for servicePath, crs := range cfg.SROverrides {
+		if !strings.HasPrefix(servicePath, "/") || !crsPattern.MatchString(crs) {
+			l.fail("SR_OVERRIDES", "invalid entry %q, expected <service path>=EPSG:<code>", servicePath+"="+crs)
+		}
+	}
+
+	if !validLoadBalancing(cfg.LoadBalancing) {
+		l.fail("LOAD_BALANCING", "must be 'round-robin' or 'least-outstanding'")
+	}
+
+	if cfg.MaxFails <= 0 {
+		l.fail("MAX_FAILS", "must be positive")
+	}
+
+	if cfg.HealthInterval < 0 {
+		l.fail("HEALTH_CHECK_INTERVAL", "must not be negative")
+	}
+
+	if cfg.Retries < 0 || cfg.Retries > 10 {
+		l.fail("UPSTREAM_RETRIES", "must be between 0 and 10")
+	}
+
+	if cfg.RetryBackoff < 0 {
+		l.fail("RETRY_BACKOFF_MS", "must not be negative")
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBackoff {
+		l.fail("RETRY_MAX_BACKOFF_MS", "must not be shorter than RETRY_BACKOFF_MS")
+	}
+
+	if cfg.CircuitFails < 0 {
+		l.fail("CIRCUIT_FAILURE_THRESHOLD", "must not be negative")
+	}
+
+	if cfg.CircuitFails > 0 && cfg.CircuitTimeout <= 0 {
+		l.fail("CIRCUIT_OPEN_TIMEOUT", "must be positive")
+	}
+
+	for key, value := range map[string]int{
+		"UPSTREAM_MAX_IDLE_CONNS":          cfg.Upstream.MaxIdleConns,
+		"UPSTREAM_MAX_IDLE_CONNS_PER_HOST": cfg.Upstream.MaxIdleConnsPerHost,
+		"UPSTREAM_MAX_CONNS_PER_HOST":      cfg.Upstream.MaxConnsPerHost,
+		"UPSTREAM_IDLE_CONN_TIMEOUT":       int(cfg.Upstream.IdleConnTimeout),
+	} {
+		if value < 0 {
+			l.fail(key, "must not be negative")
+		}
+	}
+
+	l.validateTransport("UPSTREAM_", cfg.Upstream)
+	l.validateBulkhead("UPSTREAM_", cfg.Bulkhead)
+
+	if cfg.Discovery && cfg.DiscoveryInterval <= 0 {
+		l.fail("DISCOVERY_INTERVAL", "must be positive")
 	}
 
+	for key, patterns := range map[string][]string{"DISCOVERY_INCLUDE": cfg.DiscoveryInclude, "DISCOVERY_EXCLUDE": cfg.DiscoveryExclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				l.fail(key, "invalid pattern %q", pattern)
+			}
+		}
+	}
+
+	for endpoint, cacheControl := range cfg.CacheControl {
+		if !validCacheControl(cacheControl) {
+			l.fail("CACHE_CONTROL_"+strings.ToUpper(endpoint), "invalid Cache-Control value %q", cacheControl)
+		}
+	}
+	for layer, cacheControl := range cfg.LayerCacheControl {
+		if layer == "" || !validCacheControl(cacheControl) {
+			l.fail("CACHE_CONTROL_LAYERS", "invalid entry %q, expected <layer>:<Cache-Control>", layer+":"+cacheControl)
+		}
+	}
+
+	for name, file := range cfg.TileFiles {
+		ext := strings.ToLower(filepath.Ext(file))
+		if !backendNamePattern.MatchString(name) || (ext != ".mbtiles" && ext != ".gpkg") {
+			l.fail("TILE_FILES", "invalid entry %q, expected <name>=<path>.mbtiles or <name>=<path>.gpkg", name+"="+file)
+		}
+	}
+
+	l.validateCredentials("ARCGIS_", cfg.ArcGISAuth)
+
+	cfg.Backends = l.backends(cfg)
+
 	// Validate HTTPS configuration
 	if cfg.EnableHTTPS {
 		if cfg.CertFile == "" {
-			return nil, fmt.Errorf("CERT_FILE is required when HTTPS is enabled")
+			l.fail("CERT_FILE", "is required when HTTPS is enabled")
 		}
 		if cfg.KeyFile == "" {
-			return nil, fmt.Errorf("KEY_FILE is required when HTTPS is enabled")
+			l.fail("KEY_FILE", "is required when HTTPS is enabled")
 		}
 	}
 
+	if len(l.errs) > 0 {
+		sort.SliceStable(l.errs, func(i, j int) bool { return l.errs[i].Path < l.errs[j].Path })
+		return nil, l.errs
+	}
 	return cfg, nil
 }
 
@@ -65,35 +459,272 @@ func (c *Config) GetArcGISBaseURL() string {
 	return fmt.Sprintf("%s://%s", c.ArcGISScheme, c.ArcGISHost)
 }
 
-// GetProxyAddress returns the address the proxy should listen on
-func (c *Config) GetProxyAddress() string {
-	return fmt.Sprintf(":%d", c.ProxyPort)
+// DefaultBackend returns the backend configured by ARCGIS_HOST and ARCGIS_SERVICE,
+// served on the unprefixed routes
+func (c *Config) DefaultBackend() Backend {
+	urls := []string{c.GetArcGISBaseURL()}
+	for _, host := range c.ArcGISReplicas {
+		urls = append(urls, fmt.Sprintf("%s://%s", c.ArcGISScheme, host))
+	}
+
+	return Backend{
+		Name:          DefaultBackendName,
+		URLs:          urls,
+		ServicePath:   c.ArcGISService,
+		Auth:          c.ArcGISAuth,
+		Timeout:       c.RequestTimeout,
+		DefaultCRS:    c.DefaultCRS,
+		LoadBalancing: c.LoadBalancing,
+		Transport:     c.Upstream,
+		ReadOnly:      c.ReadOnly,
+		Bulkhead:      c.Bulkhead,
+	}
 }
 
-func getEnvString(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// cacheControl reads the Cache-Control value of each endpoint from
+// CACHE_CONTROL_<ENDPOINT>; endpoints without one keep their default
+func (l *loader) cacheControl() map[string]string {
+	cacheControl := map[string]string{"capabilities": "max-age=3600"}
+	for _, endpoint := range []string{"capabilities", "map", "tiles"} {
+		if value := l.string("CACHE_CONTROL_"+strings.ToUpper(endpoint), ""); value != "" {
+			cacheControl[endpoint] = value
+		}
 	}
-	return defaultValue
+	return cacheControl
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// loadLayerCacheControl parses "<layer>:<Cache-Control>;..." entries
+func loadLayerCacheControl(value string) map[string]string {
+	layers := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		layer, cacheControl := entry, ""
+		if i := strings.LastIndex(entry, ":"); i >= 0 {
+			layer, cacheControl = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
 		}
+		layers[layer] = cacheControl
 	}
-	return defaultValue
+	return layers
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		switch value {
-		case "true", "1", "yes", "on":
-			return true
-		case "false", "0", "no", "off":
+// validCacheControl checks that a Cache-Control value is a list of directives
+func validCacheControl(value string) bool {
+	for _, directive := range strings.Split(value, ",") {
+		if !cacheDirective.MatchString(strings.TrimSpace(directive)) {
 			return false
 		}
 	}
-	return defaultValue
+	return true
+}
+
+// loadSROverrides parses "<service path>=EPSG:<code>" entries
+func loadSROverrides(entries []string) map[string]string {
+	overrides := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		servicePath, crs, _ := strings.Cut(entry, "=")
+		overrides[strings.TrimSpace(servicePath)] = strings.ToUpper(strings.TrimSpace(crs))
+	}
+	return overrides
+}
+
+// loadTileFiles parses "<name>=<path>" entries
+func loadTileFiles(entries []string) map[string]string {
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, file, _ := strings.Cut(entry, "=")
+		files[strings.TrimSpace(name)] = strings.TrimSpace(file)
+	}
+	return files
+}
+
+// transport reads <prefix>CA_FILE, _CLIENT_CERT, _CLIENT_KEY, _TLS_MIN_VERSION,
+// _SERVER_NAME and _PROXY, defaulting to the settings of base
+func (l *loader) transport(prefix string, base Transport) Transport {
+	t := base
+	t.CAFile = l.string(prefix+"CA_FILE", base.CAFile)
+	t.CertFile = l.string(prefix+"CLIENT_CERT", base.CertFile)
+	t.KeyFile = l.string(prefix+"CLIENT_KEY", base.KeyFile)
+	t.MinTLSVersion = l.string(prefix+"TLS_MIN_VERSION", base.MinTLSVersion)
+	t.ServerName = l.string(prefix+"SERVER_NAME", base.ServerName)
+	t.ProxyURL = l.string(prefix+"PROXY", base.ProxyURL)
+	return t
+}
+
+// validateTransport checks the TLS and proxy settings, loading the CA bundle
+// and client certificate
+func (l *loader) validateTransport(prefix string, t Transport) {
+	switch t.MinTLSVersion {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		l.fail(prefix+"TLS_MIN_VERSION", "unsupported minimum TLS version %q", t.MinTLSVersion)
+	}
+	if t.CAFile != "" {
+		if pem, err := os.ReadFile(t.CAFile); err != nil {
+			l.fail(prefix+"CA_FILE", "failed to read CA bundle: %v", err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			l.fail(prefix+"CA_FILE", "no certificates found in CA bundle %s", t.CAFile)
+		}
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		l.fail(prefix+"CLIENT_KEY", "must be set together with %sCLIENT_CERT", prefix)
+	} else if t.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			l.fail(prefix+"CLIENT_CERT", "failed to load client certificate: %v", err)
+		}
+	}
+	if t.ProxyURL != "" {
+		if u, err := url.Parse(t.ProxyURL); err != nil || u.Host == "" {
+			l.fail(prefix+"PROXY", "invalid proxy URL %q", t.ProxyURL)
+		}
+	}
+}
+
+// bulkhead reads <prefix>MAX_CONCURRENT, _QUEUE_SIZE and _QUEUE_TIMEOUT_MS,
+// defaulting to the settings of base
+func (l *loader) bulkhead(prefix string, base Bulkhead) Bulkhead {
+	return Bulkhead{
+		MaxConcurrent: l.int(prefix+"MAX_CONCURRENT", base.MaxConcurrent),
+		QueueSize:     l.int(prefix+"QUEUE_SIZE", base.QueueSize),
+		MaxWait:       time.Duration(l.int(prefix+"QUEUE_TIMEOUT_MS", int(base.MaxWait.Milliseconds()))) * time.Millisecond,
+	}
+}
+
+// validateBulkhead checks the concurrency limit and queue settings
+func (l *loader) validateBulkhead(prefix string, b Bulkhead) {
+	if b.MaxConcurrent < 0 {
+		l.fail(prefix+"MAX_CONCURRENT", "must not be negative")
+	}
+	if b.QueueSize < 0 {
+		l.fail(prefix+"QUEUE_SIZE", "must not be negative")
+	}
+	if b.MaxConcurrent > 0 && b.MaxWait <= 0 {
+		l.fail(prefix+"QUEUE_TIMEOUT_MS", "must be positive")
+	}
+}
+
+// credentials reads <prefix>TOKEN, _USERNAME, _PASSWORD, _TOKEN_URL,
+// _CLIENT_ID, _CLIENT_SECRET and _PORTAL_URL
+func (l *loader) credentials(prefix string) Credentials {
+	return Credentials{
+		Token:        l.string(prefix+"TOKEN", ""),
+		Username:     l.string(prefix+"USERNAME", ""),
+		Password:     l.string(prefix+"PASSWORD", ""),
+		TokenURL:     l.string(prefix+"TOKEN_URL", ""),
+		ClientID:     l.string(prefix+"CLIENT_ID", ""),
+		ClientSecret: l.string(prefix+"CLIENT_SECRET", ""),
+		PortalURL:    strings.TrimSuffix(l.string(prefix+"PORTAL_URL", ""), "/"),
+	}
+}
+
+// validateCredentials checks that at most one authentication method is fully configured
+func (l *loader) validateCredentials(prefix string, c Credentials) {
+	methods := 0
+	if c.Token != "" {
+		methods++
+	}
+	if c.Username != "" || c.Password != "" {
+		methods++
+		if c.Username == "" || c.Password == "" {
+			l.fail(prefix+"PASSWORD", "must be set together with %sUSERNAME", prefix)
+		}
+	}
+	if c.ClientID != "" || c.ClientSecret != "" {
+		methods++
+		if c.ClientID == "" || c.ClientSecret == "" || c.PortalURL == "" {
+			l.fail(prefix+"CLIENT_ID", "%sCLIENT_ID, %sCLIENT_SECRET and %sPORTAL_URL must be set together", prefix, prefix, prefix)
+		}
+	}
+	if methods > 1 {
+		l.fail(prefix+"TOKEN", "configure only one of TOKEN, USERNAME/PASSWORD and CLIENT_ID/CLIENT_SECRET")
+	}
+}
+
+// backends reads the routing table: BACKENDS lists the names, and each backend
+// is configured by BACKEND_<NAME>_URL (comma-separated replicas), _SERVICE,
+// _TIMEOUT, _DEFAULT_CRS, _LOAD_BALANCING, _READ_ONLY, the credentials of
+// credentials, the TLS and proxy settings of transport and the limits of bulkhead
+func (l *loader) backends(cfg *Config) []Backend {
+	var backends []Backend
+	seen := make(map[string]bool)
+	for _, name := range l.list("BACKENDS") {
+		if !backendNamePattern.MatchString(name) {
+			l.fail("BACKENDS", "invalid name %q (use lower-case letters, digits, '-' and '_')", name)
+			continue
+		}
+		if reservedBackendNames[name] {
+			l.fail("BACKENDS", "name %q is reserved", name)
+			continue
+		}
+		if seen[name] {
+			l.fail("BACKENDS", "duplicate name %q", name)
+			continue
+		}
+		seen[name] = true
+
+		prefix := backendPrefix(name)
+		backend := Backend{
+			Name:          name,
+			ServicePath:   l.string(prefix+"SERVICE", ""),
+			Auth:          l.credentials(prefix),
+			Timeout:       time.Duration(l.int(prefix+"TIMEOUT", int(cfg.RequestTimeout.Seconds()))) * time.Second,
+			DefaultCRS:    l.string(prefix+"DEFAULT_CRS", cfg.DefaultCRS),
+			LoadBalancing: l.string(prefix+"LOAD_BALANCING", cfg.LoadBalancing),
+			Transport:     l.transport(prefix, cfg.Upstream),
+			ReadOnly:      l.bool(prefix+"READ_ONLY", cfg.ReadOnly),
+			Bulkhead:      l.bulkhead(prefix, cfg.Bulkhead),
+		}
+
+		urls := l.list(prefix + "URL")
+		if len(urls) == 0 {
+			l.fail(prefix+"URL", "is required")
+		}
+		for _, rawURL := range urls {
+			rawURL = strings.TrimSuffix(rawURL, "/")
+			u, err := url.Parse(rawURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				l.fail(prefix+"URL", "must list http or https URLs")
+				continue
+			}
+			backend.URLs = append(backend.URLs, rawURL)
+		}
+		if !strings.HasPrefix(backend.ServicePath, "/") {
+			l.fail(prefix+"SERVICE", "is required and must start with '/'")
+		}
+		if backend.Timeout <= 0 {
+			l.fail(prefix+"TIMEOUT", "must be positive")
+		}
+		if !crsPattern.MatchString(backend.DefaultCRS) {
+			l.fail(prefix+"DEFAULT_CRS", "must have the form EPSG:<code>")
+		}
+		if !validLoadBalancing(backend.LoadBalancing) {
+			l.fail(prefix+"LOAD_BALANCING", "must be 'round-robin' or 'least-outstanding'")
+		}
+		l.validateCredentials(prefix, backend.Auth)
+		l.validateTransport(prefix, backend.Transport)
+		l.validateBulkhead(prefix, backend.Bulkhead)
+
+		backends = append(backends, backend)
+	}
+
+	return backends
+}
+
+// backendPrefix returns the prefix of the environment variables of a backend
+func backendPrefix(name string) string {
+	return "BACKEND_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+func validLoadBalancing(strategy string) bool {
+	return strategy == "round-robin" || strategy == "least-outstanding"
+}
+
+func validLogLevel(level string) bool {
+	return level == "debug" || level == "info" || level == "warn" || level == "error"
+}
+
+// GetProxyAddress returns the address the proxy should listen on
+func (c *Config) GetProxyAddress() string {
+	return fmt.Sprintf(":%d", c.ProxyPort)
 }
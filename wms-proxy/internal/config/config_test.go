@@ -0,0 +1,239 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoad_Backends(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "20")
+	t.Setenv("BACKENDS", "parcels, wet-lands")
+	t.Setenv("BACKEND_PARCELS_URL", "https://parcels.example.com/, https://parcels2.example.com")
+	t.Setenv("BACKEND_PARCELS_LOAD_BALANCING", "least-outstanding")
+	t.Setenv("BACKEND_PARCELS_SERVICE", "/arcgis/rest/services/Parcels/MapServer")
+	t.Setenv("BACKEND_PARCELS_TOKEN", "secret")
+	t.Setenv("BACKEND_PARCELS_DEFAULT_CRS", "EPSG:3857")
+	t.Setenv("BACKEND_WET_LANDS_URL", "http://wetlands.example.com")
+	t.Setenv("BACKEND_WET_LANDS_SERVICE", "/arcgis/rest/services/Wetlands/MapServer")
+	t.Setenv("BACKEND_WET_LANDS_TIMEOUT", "60")
+	t.Setenv("BACKEND_WET_LANDS_READ_ONLY", "false")
+	t.Setenv("UPSTREAM_MAX_CONCURRENT", "8")
+	t.Setenv("BACKEND_PARCELS_MAX_CONCURRENT", "4")
+	t.Setenv("BACKEND_PARCELS_QUEUE_TIMEOUT_MS", "2500")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Backend{
+		{
+			Name:          "parcels",
+			URLs:          []string{"https://parcels.example.com", "https://parcels2.example.com"},
+			ServicePath:   "/arcgis/rest/services/Parcels/MapServer",
+			Auth:          Credentials{Token: "secret"},
+			Timeout:       20 * time.Second,
+			DefaultCRS:    "EPSG:3857",
+			LoadBalancing: "least-outstanding",
+			Transport:     defaultTransport,
+			ReadOnly:      true,
+			Bulkhead:      Bulkhead{MaxConcurrent: 4, QueueSize: 100, MaxWait: 2500 * time.Millisecond},
+		},
+		{
+			Name:          "wet-lands",
+			URLs:          []string{"http://wetlands.example.com"},
+			ServicePath:   "/arcgis/rest/services/Wetlands/MapServer",
+			Timeout:       60 * time.Second,
+			DefaultCRS:    "EPSG:3424",
+			LoadBalancing: "round-robin",
+			Transport:     defaultTransport,
+			ReadOnly:      false,
+			Bulkhead:      Bulkhead{MaxConcurrent: 8, QueueSize: 100, MaxWait: 10 * time.Second},
+		},
+	}
+	if len(cfg.Backends) != len(expected) {
+		t.Fatalf("expected %d backends, got %d", len(expected), len(cfg.Backends))
+	}
+	for i, backend := range cfg.Backends {
+		if !reflect.DeepEqual(backend, expected[i]) {
+			t.Errorf("backend %d: expected %+v, got %+v", i, expected[i], backend)
+		}
+	}
+
+	if name := cfg.DefaultBackend().Name; name != DefaultBackendName {
+		t.Errorf("expected default backend name %s, got %s", DefaultBackendName, name)
+	}
+}
+
+func TestConfig_DefaultBackendReplicas(t *testing.T) {
+	t.Setenv("ARCGIS_HOST", "gis1.example.com")
+	t.Setenv("ARCGIS_REPLICAS", "gis2.example.com, gis3.example.com")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"https://gis1.example.com", "https://gis2.example.com", "https://gis3.example.com"}
+	backend := cfg.DefaultBackend()
+	if !reflect.DeepEqual(backend.URLs, expected) {
+		t.Errorf("expected URLs %v, got %v", expected, backend.URLs)
+	}
+	if backend.BaseURL() != expected[0] {
+		t.Errorf("expected base URL %s, got %s", expected[0], backend.BaseURL())
+	}
+}
+
+func TestLoad_Transport(t *testing.T) {
+	t.Setenv("UPSTREAM_TLS_MIN_VERSION", "1.3")
+	t.Setenv("UPSTREAM_PROXY", "http://proxy.example.com:3128")
+	t.Setenv("UPSTREAM_MAX_CONNS_PER_HOST", "32")
+	t.Setenv("BACKENDS", "internal")
+	t.Setenv("BACKEND_INTERNAL_URL", "https://10.0.0.5")
+	t.Setenv("BACKEND_INTERNAL_SERVICE", "/arcgis/rest/services/Internal/MapServer")
+	t.Setenv("BACKEND_INTERNAL_SERVER_NAME", "gis.internal.example.com")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if transport := cfg.DefaultBackend().Transport; transport.MinTLSVersion != "1.3" || transport.MaxConnsPerHost != 32 || transport.ServerName != "" {
+		t.Errorf("unexpected default transport: %+v", transport)
+	}
+	// Named backends inherit the upstream settings and override their own
+	transport := cfg.Backends[0].Transport
+	if transport.ServerName != "gis.internal.example.com" || transport.ProxyURL != "http://proxy.example.com:3128" || transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("unexpected backend transport: %+v", transport)
+	}
+}
+
+func TestLoad_InvalidBackends(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"invalid name", map[string]string{"BACKENDS": "Parcels"}},
+		{"reserved name", map[string]string{"BACKENDS": "default"}},
+		{"ArcGIS path segment", map[string]string{
+			"BACKENDS":               "tokens",
+			"BACKEND_TOKENS_URL":     "https://a.example.com",
+			"BACKEND_TOKENS_SERVICE": "/arcgis/rest/services/A/MapServer",
+		}},
+		{"OGC API resource", map[string]string{
+			"BACKENDS":                    "collections",
+			"BACKEND_COLLECTIONS_URL":     "https://a.example.com",
+			"BACKEND_COLLECTIONS_SERVICE": "/arcgis/rest/services/A/MapServer",
+		}},
+		{"duplicate name", map[string]string{
+			"BACKENDS":          "a,a",
+			"BACKEND_A_URL":     "https://a.example.com",
+			"BACKEND_A_SERVICE": "/arcgis/rest/services/A/MapServer",
+		}},
+		{"missing URL", map[string]string{
+			"BACKENDS":          "a",
+			"BACKEND_A_SERVICE": "/arcgis/rest/services/A/MapServer",
+		}},
+		{"unsupported scheme", map[string]string{
+			"BACKENDS":          "a",
+			"BACKEND_A_URL":     "ftp://a.example.com",
+			"BACKEND_A_SERVICE": "/arcgis/rest/services/A/MapServer",
+		}},
+		{"missing service", map[string]string{
+			"BACKENDS":      "a",
+			"BACKEND_A_URL": "https://a.example.com",
+		}},
+		{"invalid default CRS", map[string]string{
+			"BACKENDS":              "a",
+			"BACKEND_A_URL":         "https://a.example.com",
+			"BACKEND_A_SERVICE":     "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_DEFAULT_CRS": "3857",
+		}},
+		{"invalid replica URL", map[string]string{
+			"BACKENDS":          "a",
+			"BACKEND_A_URL":     "https://a.example.com,a2.example.com",
+			"BACKEND_A_SERVICE": "/arcgis/rest/services/A/MapServer",
+		}},
+		{"unknown load balancing", map[string]string{
+			"BACKENDS":                 "a",
+			"BACKEND_A_URL":            "https://a.example.com",
+			"BACKEND_A_SERVICE":        "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_LOAD_BALANCING": "random",
+		}},
+		{"username without password", map[string]string{
+			"BACKENDS":           "a",
+			"BACKEND_A_URL":      "https://a.example.com",
+			"BACKEND_A_SERVICE":  "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_USERNAME": "viewer",
+		}},
+		{"client credentials without portal", map[string]string{
+			"BACKENDS":                "a",
+			"BACKEND_A_URL":           "https://a.example.com",
+			"BACKEND_A_SERVICE":       "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_CLIENT_ID":     "id",
+			"BACKEND_A_CLIENT_SECRET": "secret",
+		}},
+		{"several authentication methods", map[string]string{
+			"BACKENDS":           "a",
+			"BACKEND_A_URL":      "https://a.example.com",
+			"BACKEND_A_SERVICE":  "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_TOKEN":    "token",
+			"BACKEND_A_USERNAME": "viewer",
+			"BACKEND_A_PASSWORD": "secret",
+		}},
+		{"negative timeout", map[string]string{
+			"BACKENDS":          "a",
+			"BACKEND_A_URL":     "https://a.example.com",
+			"BACKEND_A_SERVICE": "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_TIMEOUT": "-5",
+		}},
+		{"client certificate without key", map[string]string{
+			"BACKENDS":              "a",
+			"BACKEND_A_URL":         "https://a.example.com",
+			"BACKEND_A_SERVICE":     "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_CLIENT_CERT": "/certs/client.crt",
+		}},
+		{"missing CA bundle", map[string]string{
+			"BACKENDS":          "a",
+			"BACKEND_A_URL":     "https://a.example.com",
+			"BACKEND_A_SERVICE": "/arcgis/rest/services/A/MapServer",
+			"BACKEND_A_CA_FILE": "/nonexistent/ca.pem",
+		}},
+		{"unsupported TLS version", map[string]string{"UPSTREAM_TLS_MIN_VERSION": "1.4"}},
+		{"invalid proxy", map[string]string{"UPSTREAM_PROXY": "proxy.example.com:3128"}},
+		{"negative pool size", map[string]string{"UPSTREAM_MAX_CONNS_PER_HOST": "-1"}},
+		{"relative public URL", map[string]string{"PUBLIC_URL": "/gis"}},
+		{"zero form size", map[string]string{"MAX_FORM_SIZE": "0"}},
+		{"zero discovery interval", map[string]string{"DISCOVERY_ENABLED": "true", "DISCOVERY_INTERVAL": "0"}},
+		{"negative response cache", map[string]string{"RESPONSE_CACHE_SIZE": "-1"}},
+		{"malformed Cache-Control", map[string]string{"CACHE_CONTROL_MAP": "max-age 60"}},
+		{"layer Cache-Control without layer", map[string]string{"CACHE_CONTROL_LAYERS": "max-age=60"}},
+		{"zero SR cache TTL", map[string]string{"SR_CACHE_TTL": "0"}},
+		{"negative metadata cache TTL", map[string]string{"METADATA_CACHE_TTL": "-1"}},
+		{"unknown cache storage", map[string]string{"CACHE_STORAGE": "memcached"}},
+		{"filesystem cache without directory", map[string]string{"CACHE_STORAGE": "filesystem"}},
+		{"redis cache without address", map[string]string{"CACHE_STORAGE": "redis"}},
+		{"tile file without extension", map[string]string{"TILE_FILES": "basemap=/data/basemap"}},
+		{"tile file without name", map[string]string{"TILE_FILES": "/data/basemap.mbtiles"}},
+		{"malformed integer", map[string]string{"PROXY_PORT": "80a"}},
+		{"malformed boolean", map[string]string{"ENABLE_HTTPS": "maybe"}},
+		{"unknown log level", map[string]string{"LOG_LEVEL": "verbose"}},
+		{"invalid SR override", map[string]string{"SR_OVERRIDES": "/arcgis/rest/services/Parcels/MapServer=3857"}},
+		{"stale-if-error shorter than stale-while-revalidate", map[string]string{"STALE_WHILE_REVALIDATE": "60", "STALE_IF_ERROR": "30"}},
+		{"negative concurrency limit", map[string]string{"UPSTREAM_MAX_CONCURRENT": "-1"}},
+		{"zero queue timeout", map[string]string{"UPSTREAM_MAX_CONCURRENT": "4", "UPSTREAM_QUEUE_TIMEOUT_MS": "0"}},
+		{"invalid discovery pattern", map[string]string{"DISCOVERY_EXCLUDE": "Hydro/[a-"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
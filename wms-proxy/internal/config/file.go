@@ -0,0 +1,518 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is an invalid setting, located by its path in the configuration
+// file (with the line) or by its environment variable
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Errors lists every invalid setting of a configuration
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// loader reads settings from the environment, falling back to the
+// configuration file, and collects the invalid ones
+type loader struct {
+	file  map[string]fileValue // settings of the configuration file by environment variable
+	paths map[string]string    // file path of every environment variable the file can set
+	errs  Errors
+}
+
+// fileValue is a setting of the configuration file, converted to the format
+// of its environment variable
+type fileValue struct {
+	value string
+	path  string
+	line  int
+}
+
+// lookup returns the non-empty value of a setting
+func (l *loader) lookup(key string) (string, bool) {
+	if value := os.Getenv(key); value != "" {
+		return value, true
+	}
+	if v, ok := l.file[key]; ok && v.value != "" {
+		return v.value, true
+	}
+	return "", false
+}
+
+// location names where the setting of key comes from or belongs
+func (l *loader) location(key string) string {
+	if os.Getenv(key) != "" {
+		return key
+	}
+	if v, ok := l.file[key]; ok {
+		return fmt.Sprintf("%s (line %d)", v.path, v.line)
+	}
+	if path, ok := l.paths[key]; ok {
+		return path
+	}
+	return key
+}
+
+// fail records an invalid setting
+func (l *loader) fail(key, format string, args ...interface{}) {
+	l.errs = append(l.errs, FieldError{Path: l.location(key), Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	if value, ok := l.lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// int reads an integer; a malformed value is reported and the default used
+func (l *loader) int(key string, defaultValue int) int {
+	value, ok := l.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		l.fail(key, "invalid integer %q", value)
+		return defaultValue
+	}
+	return intValue
+}
+
+// list reads a comma-separated list, dropping empty entries
+func (l *loader) list(key string) []string {
+	var list []string
+	for _, value := range strings.Split(l.string(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// bool reads a boolean; a malformed value is reported and the default used
+func (l *loader) bool(key string, defaultValue bool) bool {
+	value, ok := l.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	switch value {
+	case "true", "1", "yes", "on":
+		return true
+	case "false", "0", "no", "off":
+		return false
+	}
+	l.fail(key, "invalid boolean %q", value)
+	return defaultValue
+}
+
+// valueKind is the type of a file setting
+type valueKind int
+
+const (
+	kindString  valueKind = iota
+	kindInt               // integer
+	kindBool              // boolean
+	kindList              // sequence, or comma-separated string
+	kindSeconds           // integer seconds, or a duration such as "90s"
+	kindMillis            // integer milliseconds, or a duration such as "1.5s"
+	kindMap               // mapping, stored as "<key>=<value>,..."
+)
+
+// setting maps a file setting to its environment variable
+type setting struct {
+	env  string
+	kind valueKind
+}
+
+var (
+	credentialSettings = map[string]setting{
+		"auth.token":         {"TOKEN", kindString},
+		"auth.username":      {"USERNAME", kindString},
+		"auth.password":      {"PASSWORD", kindString},
+		"auth.token_url":     {"TOKEN_URL", kindString},
+		"auth.client_id":     {"CLIENT_ID", kindString},
+		"auth.client_secret": {"CLIENT_SECRET", kindString},
+		"auth.portal_url":    {"PORTAL_URL", kindString},
+	}
+	transportSettings = map[string]setting{
+		"tls.ca_file":     {"CA_FILE", kindString},
+		"tls.client_cert": {"CLIENT_CERT", kindString},
+		"tls.client_key":  {"CLIENT_KEY", kindString},
+		"tls.min_version": {"TLS_MIN_VERSION", kindString},
+		"tls.server_name": {"SERVER_NAME", kindString},
+		"proxy":           {"PROXY", kindString},
+	}
+	bulkheadSettings = map[string]setting{
+		"max_concurrent": {"MAX_CONCURRENT", kindInt},
+		"queue_size":     {"QUEUE_SIZE", kindInt},
+		"queue_timeout":  {"QUEUE_TIMEOUT_MS", kindMillis},
+	}
+
+	// fileSchema holds the settings of the configuration file outside the
+	// backends and layers lists
+	fileSchema = newSchema(map[string]setting{
+		"logging.level":                     {"LOG_LEVEL", kindString},
+		"server.port":                       {"PROXY_PORT", kindInt},
+		"server.public_url":                 {"PUBLIC_URL", kindString},
+		"server.jpeg_quality":               {"JPEG_QUALITY", kindInt},
+		"server.https.enabled":              {"ENABLE_HTTPS", kindBool},
+		"server.https.cert_file":            {"CERT_FILE", kindString},
+		"server.https.key_file":             {"KEY_FILE", kindString},
+		"server.watch_interval":             {"CONFIG_WATCH_INTERVAL", kindSeconds},
+		"security.read_only":                {"ARCGIS_READ_ONLY", kindBool},
+		"security.max_form_size":            {"MAX_FORM_SIZE", kindInt},
+		"security.max_upload_size":          {"MAX_UPLOAD_SIZE", kindInt},
+		"arcgis.host":                       {"ARCGIS_HOST", kindString},
+		"arcgis.scheme":                     {"ARCGIS_SCHEME", kindString},
+		"arcgis.service":                    {"ARCGIS_SERVICE", kindString},
+		"arcgis.replicas":                   {"ARCGIS_REPLICAS", kindList},
+		"upstream.timeout":                  {"REQUEST_TIMEOUT", kindSeconds},
+		"upstream.load_balancing":           {"LOAD_BALANCING", kindString},
+		"upstream.max_fails":                {"MAX_FAILS", kindInt},
+		"upstream.health_check_interval":    {"HEALTH_CHECK_INTERVAL", kindSeconds},
+		"upstream.retries":                  {"UPSTREAM_RETRIES", kindInt},
+		"upstream.retry_backoff":            {"RETRY_BACKOFF_MS", kindMillis},
+		"upstream.retry_max_backoff":        {"RETRY_MAX_BACKOFF_MS", kindMillis},
+		"upstream.circuit_failures":         {"CIRCUIT_FAILURE_THRESHOLD", kindInt},
+		"upstream.circuit_open_timeout":     {"CIRCUIT_OPEN_TIMEOUT", kindSeconds},
+		"upstream.coalesce":                 {"UPSTREAM_COALESCE", kindBool},
+		"upstream.max_idle_conns":           {"UPSTREAM_MAX_IDLE_CONNS", kindInt},
+		"upstream.max_idle_conns_per_host":  {"UPSTREAM_MAX_IDLE_CONNS_PER_HOST", kindInt},
+		"upstream.max_conns_per_host":       {"UPSTREAM_MAX_CONNS_PER_HOST", kindInt},
+		"upstream.idle_conn_timeout":        {"UPSTREAM_IDLE_CONN_TIMEOUT", kindSeconds},
+		"caches.storage":                    {"CACHE_STORAGE", kindString},
+		"caches.dir":                        {"CACHE_DIR", kindString},
+		"caches.redis.address":              {"REDIS_ADDR", kindString},
+		"caches.redis.password":             {"REDIS_PASSWORD", kindString},
+		"caches.redis.db":                   {"REDIS_DB", kindInt},
+		"caches.redis.key_prefix":           {"REDIS_KEY_PREFIX", kindString},
+		"caches.redis.timeout":              {"REDIS_TIMEOUT_MS", kindMillis},
+		"caches.tiles.size":                 {"TILE_CACHE_SIZE", kindInt},
+		"caches.tiles.ttl":                  {"TILE_CACHE_TTL", kindSeconds},
+		"caches.responses.size":             {"RESPONSE_CACHE_SIZE", kindInt},
+		"caches.responses.ttl":              {"RESPONSE_CACHE_TTL", kindSeconds},
+		"caches.stale_while_revalidate":     {"STALE_WHILE_REVALIDATE", kindSeconds},
+		"caches.stale_if_error":             {"STALE_IF_ERROR", kindSeconds},
+		"caches.metadata_ttl":               {"METADATA_CACHE_TTL", kindSeconds},
+		"caches.cache_control.capabilities": {"CACHE_CONTROL_CAPABILITIES", kindString},
+		"caches.cache_control.map":          {"CACHE_CONTROL_MAP", kindString},
+		"caches.cache_control.tiles":        {"CACHE_CONTROL_TILES", kindString},
+		"spatial_reference.default_crs":     {"DEFAULT_CRS", kindString},
+		"spatial_reference.cache_ttl":       {"SR_CACHE_TTL", kindSeconds},
+		"spatial_reference.cache_dir":       {"SR_CACHE_DIR", kindString},
+		"spatial_reference.refresh":         {"SR_REFRESH", kindBool},
+		"spatial_reference.overrides":       {"SR_OVERRIDES", kindMap},
+		"discovery.enabled":                 {"DISCOVERY_ENABLED", kindBool},
+		"discovery.interval":                {"DISCOVERY_INTERVAL", kindSeconds},
+		"discovery.include":                 {"DISCOVERY_INCLUDE", kindList},
+		"discovery.exclude":                 {"DISCOVERY_EXCLUDE", kindList},
+		"tile_files":                        {"TILE_FILES", kindMap},
+	},
+		prefixed("arcgis.", "ARCGIS_", credentialSettings),
+		prefixed("upstream.", "UPSTREAM_", transportSettings),
+		prefixed("upstream.", "UPSTREAM_", bulkheadSettings),
+	)
+
+	// backendSchema holds the settings of an entry of the backends list; the
+	// environment variables are prefixed with BACKEND_<NAME>_
+	backendSchema = newSchema(map[string]setting{
+		"url":            {"URL", kindList},
+		"service":        {"SERVICE", kindString},
+		"timeout":        {"TIMEOUT", kindSeconds},
+		"default_crs":    {"DEFAULT_CRS", kindString},
+		"load_balancing": {"LOAD_BALANCING", kindString},
+		"read_only":      {"READ_ONLY", kindBool},
+	}, credentialSettings, transportSettings, bulkheadSettings)
+)
+
+// schema is a set of file settings by dotted path
+type schema struct {
+	settings map[string]setting
+	sections map[string]bool // paths of the mappings holding settings
+}
+
+func newSchema(tables ...map[string]setting) schema {
+	s := schema{settings: make(map[string]setting), sections: make(map[string]bool)}
+	for _, table := range tables {
+		for path, setting := range table {
+			s.settings[path] = setting
+			parts := strings.Split(path, ".")
+			for i := 1; i < len(parts); i++ {
+				s.sections[strings.Join(parts[:i], ".")] = true
+			}
+		}
+	}
+	return s
+}
+
+// prefixed prepends a path and environment variable prefix to settings
+func prefixed(path, env string, settings map[string]setting) map[string]setting {
+	result := make(map[string]setting, len(settings))
+	for key, s := range settings {
+		result[path+key] = setting{env: env + s.env, kind: s.kind}
+	}
+	return result
+}
+
+// fileParser converts a configuration file to environment variable values
+type fileParser struct {
+	values map[string]fileValue
+	paths  map[string]string
+	errs   Errors
+}
+
+// parseFile reads a YAML or JSON configuration file. Unknown settings and
+// values of the wrong type are reported with their path and line.
+func parseFile(data []byte) (map[string]fileValue, map[string]string, error) {
+	p := &fileParser{values: make(map[string]fileValue), paths: make(map[string]string)}
+	for path, s := range fileSchema.settings {
+		p.paths[s.env] = path
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, nil, Errors{{Path: "configuration file", Message: err.Error()}}
+	}
+	if len(document.Content) > 0 {
+		root := resolve(document.Content[0])
+		if root.Kind == yaml.MappingNode {
+			p.walk(root, "", "", fileSchema, "")
+		} else if root.Tag != "!!null" {
+			p.fail("configuration file", root, "must be a mapping of settings")
+		}
+	}
+
+	if len(p.errs) > 0 {
+		return nil, nil, p.errs
+	}
+	return p.values, p.paths, nil
+}
+
+func (p *fileParser) fail(path string, node *yaml.Node, format string, args ...interface{}) {
+	p.errs = append(p.errs, FieldError{Path: fmt.Sprintf("%s (line %d)", path, node.Line), Message: fmt.Sprintf(format, args...)})
+}
+
+// walk reads the settings of a mapping at relative path rel of s, displayed
+// as display; envPrefix is prepended to their environment variables
+func (p *fileParser) walk(node *yaml.Node, rel, display string, s schema, envPrefix string) {
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolve(node.Content[i+1])
+		key := keyNode.Value
+		path := joinPath(rel, key)
+		shown := joinPath(display, key)
+		if seen[key] {
+			p.fail(shown, keyNode, "duplicate setting")
+			continue
+		}
+		seen[key] = true
+
+		setting, isSetting := s.settings[path]
+		switch {
+		case rel == "" && display == "" && key == "backends":
+			p.backends(valueNode, shown)
+		case rel == "" && display == "" && key == "layers":
+			p.layers(valueNode, shown)
+		case isSetting:
+			if value, ok := p.convert(valueNode, shown, setting.kind); ok {
+				p.values[envPrefix+setting.env] = fileValue{value: value, path: shown, line: valueNode.Line}
+			}
+		case s.sections[path]:
+			if valueNode.Kind != yaml.MappingNode {
+				if valueNode.Tag != "!!null" {
+					p.fail(shown, valueNode, "must be a mapping")
+				}
+				continue
+			}
+			p.walk(valueNode, path, shown, s, envPrefix)
+		default:
+			p.fail(shown, keyNode, "unknown setting")
+		}
+	}
+}
+
+// backends reads the backends list; each entry is named by its name setting
+func (p *fileParser) backends(node *yaml.Node, display string) {
+	if node.Kind != yaml.SequenceNode {
+		if node.Tag != "!!null" {
+			p.fail(display, node, "must be a list")
+		}
+		return
+	}
+	var names []string
+	for i, item := range node.Content {
+		item = resolve(item)
+		shown := fmt.Sprintf("%s[%d]", display, i)
+		if item.Kind != yaml.MappingNode {
+			p.fail(shown, item, "must be a mapping")
+			continue
+		}
+		var nameNode *yaml.Node
+		fields := &yaml.Node{Kind: yaml.MappingNode}
+		for j := 0; j+1 < len(item.Content); j += 2 {
+			if item.Content[j].Value == "name" {
+				nameNode = resolve(item.Content[j+1])
+				continue
+			}
+			fields.Content = append(fields.Content, item.Content[j], item.Content[j+1])
+		}
+		if nameNode == nil || nameNode.Kind != yaml.ScalarNode || nameNode.Value == "" {
+			p.fail(shown+".name", item, "is required")
+			continue
+		}
+
+		name := nameNode.Value
+		names = append(names, name)
+		prefix := backendPrefix(name)
+		for path, s := range backendSchema.settings {
+			p.paths[prefix+s.env] = shown + "." + path
+		}
+		p.walk(fields, "", shown, backendSchema, prefix)
+	}
+	p.values["BACKENDS"] = fileValue{value: strings.Join(names, ","), path: display, line: node.Line}
+}
+
+// layers reads the per-layer settings: "<layer>: {cache_control: <value>}"
+func (p *fileParser) layers(node *yaml.Node, display string) {
+	if node.Kind != yaml.MappingNode {
+		if node.Tag != "!!null" {
+			p.fail(display, node, "must be a mapping of layer names")
+		}
+		return
+	}
+	var entries []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		layer, settings := node.Content[i].Value, resolve(node.Content[i+1])
+		shown := display + "." + layer
+		if settings.Kind != yaml.MappingNode {
+			p.fail(shown, settings, "must be a mapping")
+			continue
+		}
+		for j := 0; j+1 < len(settings.Content); j += 2 {
+			key, value := settings.Content[j], resolve(settings.Content[j+1])
+			if key.Value != "cache_control" {
+				p.fail(shown+"."+key.Value, key, "unknown setting")
+				continue
+			}
+			if cacheControl, ok := p.convert(value, shown+".cache_control", kindString); ok {
+				entries = append(entries, layer+":"+cacheControl)
+			}
+		}
+	}
+	p.values["CACHE_CONTROL_LAYERS"] = fileValue{value: strings.Join(entries, ";"), path: display, line: node.Line}
+}
+
+// convert formats a value as its environment variable would hold it
+func (p *fileParser) convert(node *yaml.Node, display string, kind valueKind) (string, bool) {
+	if node.Tag == "!!null" {
+		return "", true
+	}
+	switch kind {
+	case kindList:
+		if node.Kind == yaml.SequenceNode {
+			items := make([]string, 0, len(node.Content))
+			for _, item := range node.Content {
+				item = resolve(item)
+				if item.Kind != yaml.ScalarNode {
+					p.fail(display, item, "must be a list of strings")
+					return "", false
+				}
+				items = append(items, item.Value)
+			}
+			return strings.Join(items, ","), true
+		}
+	case kindMap:
+		if node.Kind != yaml.MappingNode {
+			p.fail(display, node, "must be a mapping")
+			return "", false
+		}
+		entries := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := resolve(node.Content[i+1])
+			if value.Kind != yaml.ScalarNode {
+				p.fail(display+"."+node.Content[i].Value, value, "must be a string")
+				return "", false
+			}
+			entries = append(entries, node.Content[i].Value+"="+value.Value)
+		}
+		return strings.Join(entries, ","), true
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		p.fail(display, node, "must be a single value")
+		return "", false
+	}
+	value := node.Value
+	switch kind {
+	case kindInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			p.fail(display, node, "must be an integer, not %q", value)
+			return "", false
+		}
+	case kindBool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			switch value {
+			case "yes", "on":
+				parsed = true
+			case "no", "off":
+				parsed = false
+			default:
+				p.fail(display, node, "must be true or false, not %q", value)
+				return "", false
+			}
+		}
+		value = strconv.FormatBool(parsed)
+	case kindSeconds, kindMillis:
+		unit, unitName := time.Second, "seconds"
+		if kind == kindMillis {
+			unit, unitName = time.Millisecond, "milliseconds"
+		}
+		if _, err := strconv.Atoi(value); err == nil {
+			return value, true
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration%unit != 0 {
+			p.fail(display, node, "must be a whole number of %s or a duration such as \"30s\", not %q", unitName, value)
+			return "", false
+		}
+		value = strconv.FormatInt(int64(duration/unit), 10)
+	}
+	return value, true
+}
+
+// resolve follows YAML aliases to their anchored node
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
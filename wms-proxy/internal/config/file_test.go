@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+logging:
+  level: debug
+server:
+  port: 9090
+  public_url: https://maps.example.com/gis
+security:
+  read_only: false
+  max_form_size: 5
+arcgis:
+  host: gis.example.com
+  service: /arcgis/rest/services/Base/MapServer
+  replicas: [gis2.example.com]
+  auth:
+    token: base-token
+upstream:
+  timeout: 1m
+  retry_backoff: 0.5s
+  max_concurrent: 8
+  tls:
+    min_version: "1.3"
+backends:
+  - name: parcels
+    url:
+      - https://parcels.example.com
+      - https://parcels2.example.com
+    service: /arcgis/rest/services/Parcels/MapServer
+    timeout: 45
+    auth:
+      username: proxy
+      password: secret
+    queue_timeout: 2s
+caches:
+  tiles:
+    size: 64
+    ttl: 2h
+  cache_control:
+    map: max-age=300
+spatial_reference:
+  overrides:
+    /arcgis/rest/services/Parcels/MapServer: EPSG:3857
+layers:
+  Parcels:
+    cache_control: max-age=60, public
+tile_files:
+  basemap: /data/basemap.mbtiles
+`
+
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfig(t, "config.yaml", testConfig)
+	// Environment variables override the file
+	t.Setenv("TILE_CACHE_SIZE", "128")
+	t.Setenv("BACKEND_PARCELS_TIMEOUT", "50")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checks := []struct {
+		name          string
+		got, expected interface{}
+	}{
+		{"file", cfg.File, path},
+		{"watch interval", cfg.WatchInterval, 5 * time.Second},
+		{"log level", cfg.LogLevel, "debug"},
+		{"port", cfg.ProxyPort, 9090},
+		{"public URL", cfg.PublicURL, "https://maps.example.com/gis"},
+		{"read only", cfg.ReadOnly, false},
+		{"form size", cfg.MaxFormSize, 5},
+		{"upload size default", cfg.MaxUploadSize, 100},
+		{"default backend URLs", cfg.DefaultBackend().URLs, []string{"https://gis.example.com", "https://gis2.example.com"}},
+		{"default backend token", cfg.ArcGISAuth.Token, "base-token"},
+		{"request timeout", cfg.RequestTimeout, time.Minute},
+		{"retry backoff", cfg.RetryBackoff, 500 * time.Millisecond},
+		{"TLS version", cfg.Upstream.MinTLSVersion, "1.3"},
+		{"tile cache size", cfg.TileCacheSize, 128},
+		{"tile cache TTL", cfg.TileCacheTTL, 2 * time.Hour},
+		{"map Cache-Control", cfg.CacheControl["map"], "max-age=300"},
+		{"SR overrides", cfg.SROverrides, map[string]string{"/arcgis/rest/services/Parcels/MapServer": "EPSG:3857"}},
+		{"layer Cache-Control", cfg.LayerCacheControl, map[string]string{"Parcels": "max-age=60, public"}},
+		{"tile files", cfg.TileFiles, map[string]string{"basemap": "/data/basemap.mbtiles"}},
+	}
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.expected) {
+			t.Errorf("%s: expected %v, got %v", check.name, check.expected, check.got)
+		}
+	}
+
+	expected := Backend{
+		Name:          "parcels",
+		URLs:          []string{"https://parcels.example.com", "https://parcels2.example.com"},
+		ServicePath:   "/arcgis/rest/services/Parcels/MapServer",
+		Auth:          Credentials{Username: "proxy", Password: "secret"},
+		Timeout:       50 * time.Second,
+		DefaultCRS:    "EPSG:3424",
+		LoadBalancing: "round-robin",
+		Transport:     cfg.Upstream,
+		ReadOnly:      false,
+		Bulkhead:      Bulkhead{MaxConcurrent: 8, QueueSize: 100, MaxWait: 2 * time.Second},
+	}
+	if len(cfg.Backends) != 1 || !reflect.DeepEqual(cfg.Backends[0], expected) {
+		t.Errorf("expected backends [%+v], got %+v", expected, cfg.Backends)
+	}
+}
+
+func TestLoadFile_JSON(t *testing.T) {
+	path := writeConfig(t, "config.json", `{
+		"server": {"port": 8081},
+		"backends": [{"name": "wetlands", "url": "https://wetlands.example.com", "service": "/arcgis/rest/services/Wetlands/MapServer"}],
+		"discovery": {"enabled": true, "include": ["Hydro/*", "Parcels"]}
+	}`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProxyPort != 8081 || !cfg.Discovery || !reflect.DeepEqual(cfg.DiscoveryInclude, []string{"Hydro/*", "Parcels"}) {
+		t.Errorf("unexpected configuration: %+v", cfg)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].BaseURL() != "https://wetlands.example.com" {
+		t.Errorf("unexpected backends: %+v", cfg.Backends)
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			name: "file errors",
+			contents: `
+server:
+  port: eighty
+  colour: blue
+caches:
+  tiles: 64
+  responses:
+    ttl: 1.5s
+backends:
+  - url: https://a.example.com
+  - name: b
+    timeout: [1]
+    bogus: true
+`,
+			expected: []string{
+				"backends[0].name (line 10): is required",
+				"backends[1].bogus (line 13): unknown setting",
+				"backends[1].timeout (line 12): must be a single value",
+				"caches.responses.ttl (line 8): must be a whole number of seconds",
+				"caches.tiles (line 6): must be a mapping",
+				"server.colour (line 4): unknown setting",
+				"server.port (line 3): must be an integer",
+			},
+		},
+		{
+			name: "validation errors",
+			contents: `
+server:
+  port: 70000
+caches:
+  storage: filesystem
+  tiles:
+    size: -1
+backends:
+  - name: parcels
+    url: parcels.example.com
+`,
+			env: map[string]string{"UPSTREAM_RETRIES": "lots"},
+			expected: []string{
+				"UPSTREAM_RETRIES: invalid integer",
+				"backends[0].service: is required",
+				"backends[0].url (line 10): must list http or https URLs",
+				"caches.dir: is required with CACHE_STORAGE=filesystem",
+				"caches.tiles.size (line 7): must not be negative",
+				"server.port (line 3): must be between 1 and 65535",
+			},
+		},
+		{
+			name:     "syntax error",
+			contents: "server:\n  port: [8080\n",
+			expected: []string{"configuration file: yaml: line"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			_, err := LoadFile(writeConfig(t, "config.yaml", test.contents))
+			var invalid Errors
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected configuration errors, got %v", err)
+			}
+			if len(invalid) != len(test.expected) {
+				t.Errorf("expected %d errors, got %d: %v", len(test.expected), len(invalid), err)
+			}
+			for _, expected := range test.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error %q in %v", expected, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cql
+
+// Expr is a node of a parsed CQL filter expression
+type Expr interface {
+	exprNode()
+}
+
+// LiteralKind identifies the type of a literal value
+type LiteralKind int
+
+const (
+	LiteralNumber LiteralKind = iota
+	LiteralString
+	LiteralBool
+)
+
+// Literal is a constant value in a CQL expression
+type Literal struct {
+	Kind   LiteralKind
+	Number float64
+	String string
+	Bool   bool
+}
+
+// And is the logical conjunction of two expressions
+type And struct {
+	Left, Right Expr
+}
+
+// Or is the logical disjunction of two expressions
+type Or struct {
+	Left, Right Expr
+}
+
+// Not negates an expression
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares an attribute with a literal using =, <>, <, <=, > or >=
+type Comparison struct {
+	Attribute string
+	Operator  string
+	Value     Literal
+}
+
+// Like matches a string attribute against a pattern using % and _ wildcards
+type Like struct {
+	Attribute       string
+	Pattern         string
+	Negated         bool
+	CaseInsensitive bool
+}
+
+// Between tests that an attribute lies within an inclusive range
+type Between struct {
+	Attribute    string
+	Lower, Upper Literal
+	Negated      bool
+}
+
+// In tests that an attribute equals one of a list of values
+type In struct {
+	Attribute string
+	Values    []Literal
+	Negated   bool
+}
+
+// IsNull tests whether an attribute is null
+type IsNull struct {
+	Attribute string
+	Negated   bool
+}
+
+// Constant is the INCLUDE (true) or EXCLUDE (false) filter
+type Constant struct {
+	Value bool
+}
+
+func (*And) exprNode()        {}
+func (*Or) exprNode()         {}
+func (*Not) exprNode()        {}
+func (*Comparison) exprNode() {}
+func (*Like) exprNode()       {}
+func (*Between) exprNode()    {}
+func (*In) exprNode()         {}
+func (*IsNull) exprNode()     {}
+func (*Constant) exprNode()   {}
@@ -0,0 +1,103 @@
+package cql
+
+import (
+	"strings"
+	"testing"
+)
+
+func testSchema() *Schema {
+	return NewSchema([]Field{
+		{Name: "COUNTY", Type: "esriFieldTypeString"},
+		{Name: "POP_2020", Type: "esriFieldTypeInteger"},
+		{Name: "AREA_SQMI", Type: "esriFieldTypeDouble"},
+		{Name: "UPDATED", Type: "esriFieldTypeDate"},
+		{Name: "Shape", Type: "esriFieldTypeGeometry"},
+	})
+}
+
+func TestToSQL(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected string
+	}{
+		{"COUNTY = 'Mercer'", "COUNTY = 'Mercer'"},
+		{"county='Mercer'", "COUNTY = 'Mercer'"},
+		{`"COUNTY" <> 'Mercer'`, "COUNTY <> 'Mercer'"},
+		{"POP_2020 != 100", "POP_2020 <> 100"},
+		{"POP_2020 >= 1e5", "POP_2020 >= 100000"},
+		{"AREA_SQMI < -1.5", "AREA_SQMI < -1.5"},
+		{"COUNTY = 'O''Brien'", "COUNTY = 'O''Brien'"},
+		{"COUNTY LIKE 'Mer%'", "COUNTY LIKE 'Mer%'"},
+		{"COUNTY NOT ILIKE 'mer%'", "UPPER(COUNTY) NOT LIKE UPPER('mer%')"},
+		{"POP_2020 BETWEEN 10 AND 20", "POP_2020 BETWEEN 10 AND 20"},
+		{"COUNTY IN ('Mercer', 'Ocean')", "COUNTY IN ('Mercer', 'Ocean')"},
+		{"COUNTY NOT IN ('Mercer')", "COUNTY NOT IN ('Mercer')"},
+		{"COUNTY IS NOT NULL", "COUNTY IS NOT NULL"},
+		{"UPDATED > '2024-01-01'", "UPDATED > timestamp '2024-01-01 00:00:00'"},
+		{"COUNTY = 'Mercer' AND (POP_2020 > 5 OR NOT AREA_SQMI < 2)", "(COUNTY = 'Mercer') AND ((POP_2020 > 5) OR (NOT (AREA_SQMI < 2)))"},
+		{"INCLUDE", "1=1"},
+		{"EXCLUDE", "1=0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			expr, err := Parse(test.filter)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.filter, err)
+			}
+			result, err := ToSQL(expr, testSchema())
+			if err != nil {
+				t.Fatalf("ToSQL(%q) failed: %v", test.filter, err)
+			}
+			if result != test.expected {
+				t.Errorf("ToSQL(%q) = %q, expected %q", test.filter, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestRejectsUnsafeOrInvalidFilters(t *testing.T) {
+	tests := []string{
+		"COUNTY = 'x' OR 1=1",               // literal on left-hand side
+		"COUNTY = 'x'; DROP TABLE counties", // trailing statement
+		"COUNTY = 'x' -- comment",           // SQL comment
+		"COUNTY = 'unterminated",            // unterminated string
+		"COUNTY = POP_2020",                 // attribute comparison
+		"BBOX(Shape, 0, 0, 1, 1)",           // spatial predicate
+		"UPPER(COUNTY) = 'X'",               // function call
+		"MISSING = 1",                       // unknown attribute
+		"Shape IS NULL",                     // geometry attribute
+		"POP_2020 = 'many'",                 // type mismatch
+		"POP_2020 LIKE '1%'",                // LIKE on numeric
+		"UPDATED > 'last week'",             // invalid date
+		"COUNTY = 'a\x00b'",                 // control character
+		"COUNTY IN ()",                      // empty list
+		"COUNTY == 'x'",                     // not a CQL operator
+		strings.Repeat("(", 40) + "COUNTY = 'x'" + strings.Repeat(")", 40), // nesting
+		"",
+	}
+
+	for _, filter := range tests {
+		expr, err := Parse(filter)
+		if err == nil {
+			_, err = ToSQL(expr, testSchema())
+		}
+		if err == nil {
+			t.Errorf("Expected filter %q to be rejected", filter)
+		}
+	}
+}
+
+func TestSplitFilters(t *testing.T) {
+	filters := SplitFilters("COUNTY = 'a;b';POP_2020 > 1; INCLUDE")
+	expected := []string{"COUNTY = 'a;b'", "POP_2020 > 1", "INCLUDE"}
+
+	if len(filters) != len(expected) {
+		t.Fatalf("Expected %d filters, got %d: %q", len(expected), len(filters), filters)
+	}
+	for i := range expected {
+		if filters[i] != expected[i] {
+			t.Errorf("filter %d = %q, expected %q", i, filters[i], expected[i])
+		}
+	}
+}
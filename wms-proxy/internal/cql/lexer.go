@@ -0,0 +1,121 @@
+package cql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the lexical class of a token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+// token is a single lexical unit of a CQL expression
+type token struct {
+	kind   tokenKind
+	text   string
+	quoted bool // identifier was written as "name"
+	pos    int
+}
+
+// lex splits a CQL expression into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case r == '\'':
+			// String literal, with '' as an escaped quote
+			var sb strings.Builder
+			start := i
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string literal at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: start})
+		case r == '"':
+			// Quoted attribute name
+			start := i
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted identifier at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i+1 : end]), quoted: true, pos: start})
+			i = end + 1
+		case r == '=' || r == '<' || r == '>' || r == '!':
+			start := i
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				op += string(runes[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected character '!' at position %d", start)
+			}
+			if op == "==" {
+				return nil, fmt.Errorf("unsupported operator '==' at position %d, use '='", start)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: start})
+			i += len(op)
+		case unicode.IsDigit(r) || ((r == '-' || r == '+' || r == '.') && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
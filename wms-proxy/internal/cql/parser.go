@@ -0,0 +1,297 @@
+package cql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds expression nesting to keep parsing cheap for hostile input
+const maxDepth = 32
+
+// parser is a recursive-descent parser over a token stream
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// Parse parses an ECQL attribute filter into an expression tree.
+// Spatial predicates, functions and arithmetic are not supported.
+func Parse(input string) (Expr, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, fmt.Errorf("empty filter")
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return expr, nil
+}
+
+// SplitFilters splits a CQL_FILTER value into per-layer filters separated by ';'
+// (semicolons inside string literals are preserved)
+func SplitFilters(value string) []string {
+	var filters []string
+	var current strings.Builder
+	inString := false
+
+	for _, r := range value {
+		switch {
+		case r == '\'':
+			inString = !inString
+			current.WriteRune(r)
+		case r == ';' && !inString:
+			filters = append(filters, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	filters = append(filters, strings.TrimSpace(current.String()))
+
+	return filters
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword reports whether the current token is the given unquoted keyword
+func (p *parser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == tokenIdent && !tok.quoted && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.isKeyword(keyword) {
+		tok := p.peek()
+		return fmt.Errorf("expected %s at position %d, got %q", keyword, tok.pos, tok.text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.isKeyword("NOT") {
+		p.depth++
+		if p.depth > maxDepth {
+			return nil, fmt.Errorf("filter is nested too deeply")
+		}
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		return &Not{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+
+	if tok.kind == tokenLParen {
+		p.depth++
+		if p.depth > maxDepth {
+			return nil, fmt.Errorf("filter is nested too deeply")
+		}
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, fmt.Errorf("expected ')' at position %d", p.peek().pos)
+		}
+		p.next()
+		p.depth--
+		return expr, nil
+	}
+
+	if p.isKeyword("INCLUDE") {
+		p.next()
+		return &Constant{Value: true}, nil
+	}
+	if p.isKeyword("EXCLUDE") {
+		p.next()
+		return &Constant{Value: false}, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (Expr, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return nil, fmt.Errorf("expected attribute name at position %d, got %q", tok.pos, tok.text)
+	}
+	if p.peek().kind == tokenLParen {
+		return nil, fmt.Errorf("function or spatial predicate %q is not supported", tok.text)
+	}
+	attribute := tok.text
+
+	// Comparison operators
+	if op := p.peek(); op.kind == tokenOperator {
+		p.next()
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		operator := op.text
+		if operator == "!=" {
+			operator = "<>"
+		}
+		return &Comparison{Attribute: attribute, Operator: operator, Value: value}, nil
+	}
+
+	if p.isKeyword("IS") {
+		p.next()
+		negated := false
+		if p.isKeyword("NOT") {
+			p.next()
+			negated = true
+		}
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNull{Attribute: attribute, Negated: negated}, nil
+	}
+
+	negated := false
+	if p.isKeyword("NOT") {
+		p.next()
+		negated = true
+	}
+
+	switch {
+	case p.isKeyword("LIKE"), p.isKeyword("ILIKE"):
+		caseInsensitive := p.isKeyword("ILIKE")
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, fmt.Errorf("LIKE requires a string pattern at position %d", pattern.pos)
+		}
+		return &Like{Attribute: attribute, Pattern: pattern.text, Negated: negated, CaseInsensitive: caseInsensitive}, nil
+
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		lower, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		upper, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &Between{Attribute: attribute, Lower: lower, Upper: upper, Negated: negated}, nil
+
+	case p.isKeyword("IN"):
+		p.next()
+		if p.peek().kind != tokenLParen {
+			return nil, fmt.Errorf("expected '(' after IN at position %d", p.peek().pos)
+		}
+		p.next()
+		var values []Literal
+		for {
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if p.peek().kind == tokenComma {
+				p.next()
+				continue
+			}
+			break
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, fmt.Errorf("expected ')' at position %d", p.peek().pos)
+		}
+		p.next()
+		return &In{Attribute: attribute, Values: values, Negated: negated}, nil
+	}
+
+	next := p.peek()
+	return nil, fmt.Errorf("expected operator after %q at position %d, got %q", attribute, next.pos, next.text)
+}
+
+func (p *parser) parseLiteral() (Literal, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return Literal{}, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return Literal{Kind: LiteralNumber, Number: n}, nil
+	case tokenString:
+		return Literal{Kind: LiteralString, String: tok.text}, nil
+	case tokenIdent:
+		if !tok.quoted && strings.EqualFold(tok.text, "TRUE") {
+			return Literal{Kind: LiteralBool, Bool: true}, nil
+		}
+		if !tok.quoted && strings.EqualFold(tok.text, "FALSE") {
+			return Literal{Kind: LiteralBool, Bool: false}, nil
+		}
+		return Literal{}, fmt.Errorf("attribute-to-attribute comparisons are not supported (%q at position %d)", tok.text, tok.pos)
+	default:
+		return Literal{}, fmt.Errorf("expected literal value at position %d, got %q", tok.pos, tok.text)
+	}
+}
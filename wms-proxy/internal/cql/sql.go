@@ -0,0 +1,253 @@
+package cql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FieldType is the broad type class of a layer field
+type FieldType int
+
+const (
+	FieldUnsupported FieldType = iota
+	FieldString
+	FieldNumber
+	FieldDate
+)
+
+// Field describes a layer attribute as reported by ArcGIS layer metadata
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // ArcGIS type, e.g. esriFieldTypeString
+}
+
+// Schema maps attribute names (case-insensitively) to their fields
+type Schema struct {
+	fields map[string]schemaField
+}
+
+type schemaField struct {
+	name      string
+	fieldType FieldType
+}
+
+// identifierPattern restricts field names emitted into SQL
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// NewSchema builds a schema from ArcGIS layer fields
+func NewSchema(fields []Field) *Schema {
+	s := &Schema{fields: make(map[string]schemaField, len(fields))}
+	for _, f := range fields {
+		s.fields[strings.ToLower(f.Name)] = schemaField{name: f.Name, fieldType: esriFieldType(f.Type)}
+	}
+	return s
+}
+
+// esriFieldType maps an ArcGIS field type to a FieldType
+func esriFieldType(t string) FieldType {
+	switch t {
+	case "esriFieldTypeString", "esriFieldTypeGUID", "esriFieldTypeGlobalID":
+		return FieldString
+	case "esriFieldTypeSmallInteger", "esriFieldTypeInteger", "esriFieldTypeBigInteger",
+		"esriFieldTypeSingle", "esriFieldTypeDouble", "esriFieldTypeOID":
+		return FieldNumber
+	case "esriFieldTypeDate", "esriFieldTypeDateOnly", "esriFieldTypeTimestampOffset":
+		return FieldDate
+	default:
+		return FieldUnsupported
+	}
+}
+
+// dateLayouts lists accepted forms for literals compared against date fields
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ToSQL validates an expression against a layer schema and renders it as an
+// ArcGIS where clause. Field names must exist in the schema and literal types
+// must match the field types; every literal is re-encoded, so the output never
+// contains text copied verbatim from the filter.
+func ToSQL(expr Expr, schema *Schema) (string, error) {
+	if schema == nil {
+		return "", fmt.Errorf("layer schema is required to validate filters")
+	}
+	return schema.render(expr)
+}
+
+func (s *Schema) render(expr Expr) (string, error) {
+	switch e := expr.(type) {
+	case *And:
+		return s.renderBinary(e.Left, e.Right, "AND")
+	case *Or:
+		return s.renderBinary(e.Left, e.Right, "OR")
+	case *Not:
+		inner, err := s.render(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	case *Constant:
+		if e.Value {
+			return "1=1", nil
+		}
+		return "1=0", nil
+	case *Comparison:
+		field, err := s.lookup(e.Attribute)
+		if err != nil {
+			return "", err
+		}
+		value, err := renderLiteral(field, e.Value)
+		if err != nil {
+			return "", err
+		}
+		return field.name + " " + e.Operator + " " + value, nil
+	case *Like:
+		field, err := s.lookup(e.Attribute)
+		if err != nil {
+			return "", err
+		}
+		if field.fieldType != FieldString {
+			return "", fmt.Errorf("LIKE requires a string attribute, %s is not", field.name)
+		}
+		pattern, err := quoteString(e.Pattern)
+		if err != nil {
+			return "", err
+		}
+		operator := " LIKE "
+		if e.Negated {
+			operator = " NOT LIKE "
+		}
+		if e.CaseInsensitive {
+			return "UPPER(" + field.name + ")" + operator + "UPPER(" + pattern + ")", nil
+		}
+		return field.name + operator + pattern, nil
+	case *Between:
+		field, err := s.lookup(e.Attribute)
+		if err != nil {
+			return "", err
+		}
+		lower, err := renderLiteral(field, e.Lower)
+		if err != nil {
+			return "", err
+		}
+		upper, err := renderLiteral(field, e.Upper)
+		if err != nil {
+			return "", err
+		}
+		operator := " BETWEEN "
+		if e.Negated {
+			operator = " NOT BETWEEN "
+		}
+		return field.name + operator + lower + " AND " + upper, nil
+	case *In:
+		field, err := s.lookup(e.Attribute)
+		if err != nil {
+			return "", err
+		}
+		values := make([]string, 0, len(e.Values))
+		for _, v := range e.Values {
+			rendered, err := renderLiteral(field, v)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, rendered)
+		}
+		operator := " IN ("
+		if e.Negated {
+			operator = " NOT IN ("
+		}
+		return field.name + operator + strings.Join(values, ", ") + ")", nil
+	case *IsNull:
+		field, err := s.lookup(e.Attribute)
+		if err != nil {
+			return "", err
+		}
+		if e.Negated {
+			return field.name + " IS NOT NULL", nil
+		}
+		return field.name + " IS NULL", nil
+	default:
+		return "", fmt.Errorf("unsupported filter expression %T", expr)
+	}
+}
+
+func (s *Schema) renderBinary(left, right Expr, operator string) (string, error) {
+	l, err := s.render(left)
+	if err != nil {
+		return "", err
+	}
+	r, err := s.render(right)
+	if err != nil {
+		return "", err
+	}
+	return "(" + l + ") " + operator + " (" + r + ")", nil
+}
+
+// lookup resolves an attribute name to a known, queryable field
+func (s *Schema) lookup(attribute string) (schemaField, error) {
+	field, ok := s.fields[strings.ToLower(attribute)]
+	if !ok {
+		return schemaField{}, fmt.Errorf("unknown attribute %q", attribute)
+	}
+	if field.fieldType == FieldUnsupported {
+		return schemaField{}, fmt.Errorf("attribute %q cannot be used in filters", field.name)
+	}
+	if !identifierPattern.MatchString(field.name) {
+		return schemaField{}, fmt.Errorf("attribute %q has an unsupported name", field.name)
+	}
+	return field, nil
+}
+
+// renderLiteral renders a literal for comparison against a field, enforcing type compatibility
+func renderLiteral(field schemaField, lit Literal) (string, error) {
+	switch field.fieldType {
+	case FieldNumber:
+		switch lit.Kind {
+		case LiteralNumber:
+			return strconv.FormatFloat(lit.Number, 'f', -1, 64), nil
+		case LiteralBool:
+			if lit.Bool {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		return "", fmt.Errorf("attribute %s is numeric and cannot be compared with a string", field.name)
+	case FieldString:
+		switch lit.Kind {
+		case LiteralString:
+			return quoteString(lit.String)
+		case LiteralNumber:
+			return quoteString(strconv.FormatFloat(lit.Number, 'f', -1, 64))
+		}
+		return "", fmt.Errorf("attribute %s is a string and cannot be compared with a boolean", field.name)
+	case FieldDate:
+		if lit.Kind != LiteralString {
+			return "", fmt.Errorf("attribute %s is a date and must be compared with an ISO 8601 string", field.name)
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, lit.String); err == nil {
+				return "timestamp '" + t.UTC().Format("2006-01-02 15:04:05") + "'", nil
+			}
+		}
+		return "", fmt.Errorf("invalid date %q for attribute %s", lit.String, field.name)
+	default:
+		return "", fmt.Errorf("attribute %q cannot be used in filters", field.name)
+	}
+}
+
+// quoteString renders a SQL string literal, doubling embedded quotes
+func quoteString(s string) (string, error) {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("control characters are not allowed in string literals")
+		}
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+}
@@ -0,0 +1,157 @@
+package geometry
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// MarshalJSON encodes the geometry as a GeoJSON geometry object
+func (g *Geometry) MarshalJSON() ([]byte, error) {
+	if g == nil {
+		return []byte("null"), nil
+	}
+
+	var coordinates interface{}
+	switch g.Type {
+	case TypePoint:
+		coordinates = g.Points[0]
+	case TypeMultiPoint:
+		coordinates = g.Points
+	case TypeLineString:
+		coordinates = g.Lines[0]
+	case TypeMultiLineString:
+		coordinates = g.Lines
+	case TypePolygon:
+		coordinates = g.Polygons[0]
+	default:
+		coordinates = g.Polygons
+	}
+
+	return json.Marshal(struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}{g.Type, coordinates})
+}
+
+// GeoJSONFeature is the GeoJSON encoding of a Feature
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// ToGeoJSON converts a feature into its GeoJSON representation
+func (f *Feature) ToGeoJSON() GeoJSONFeature {
+	properties := f.Properties
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	return GeoJSONFeature{Type: "Feature", ID: f.ID, Geometry: f.Geometry, Properties: properties}
+}
+
+// GML encodes the geometry as GML 3.2. When swapAxes is set, coordinates are
+// written in y/x (latitude/longitude) order as required by EPSG:4326 URNs.
+func GML(g *Geometry, srsName, gmlID string, swapAxes bool) string {
+	if g.IsEmpty() {
+		return ""
+	}
+
+	w := &gmlWriter{swapAxes: swapAxes, idPrefix: gmlID}
+	attrs := ` gml:id="` + escapeAttr(w.nextID()) + `" srsName="` + escapeAttr(srsName) + `"`
+
+	switch g.Type {
+	case TypePoint:
+		w.point(g.Points[0], attrs)
+	case TypeMultiPoint:
+		w.sb.WriteString("<gml:MultiPoint" + attrs + ">")
+		for _, p := range g.Points {
+			w.sb.WriteString("<gml:pointMember>")
+			w.point(p, ` gml:id="`+escapeAttr(w.nextID())+`"`)
+			w.sb.WriteString("</gml:pointMember>")
+		}
+		w.sb.WriteString("</gml:MultiPoint>")
+	case TypeLineString:
+		w.lineString(g.Lines[0], attrs)
+	case TypeMultiLineString:
+		w.sb.WriteString("<gml:MultiCurve" + attrs + ">")
+		for _, line := range g.Lines {
+			w.sb.WriteString("<gml:curveMember>")
+			w.lineString(line, ` gml:id="`+escapeAttr(w.nextID())+`"`)
+			w.sb.WriteString("</gml:curveMember>")
+		}
+		w.sb.WriteString("</gml:MultiCurve>")
+	case TypePolygon:
+		w.polygon(g.Polygons[0], attrs)
+	default:
+		w.sb.WriteString("<gml:MultiSurface" + attrs + ">")
+		for _, polygon := range g.Polygons {
+			w.sb.WriteString("<gml:surfaceMember>")
+			w.polygon(polygon, ` gml:id="`+escapeAttr(w.nextID())+`"`)
+			w.sb.WriteString("</gml:surfaceMember>")
+		}
+		w.sb.WriteString("</gml:MultiSurface>")
+	}
+
+	return w.sb.String()
+}
+
+// gmlWriter accumulates GML output and hands out unique gml:id values
+type gmlWriter struct {
+	sb       strings.Builder
+	swapAxes bool
+	idPrefix string
+	counter  int
+}
+
+func (w *gmlWriter) nextID() string {
+	w.counter++
+	return w.idPrefix + ".g" + strconv.Itoa(w.counter)
+}
+
+func (w *gmlWriter) point(p Point, attrs string) {
+	w.sb.WriteString("<gml:Point" + attrs + "><gml:pos>")
+	w.coords([]Point{p})
+	w.sb.WriteString("</gml:pos></gml:Point>")
+}
+
+func (w *gmlWriter) lineString(line []Point, attrs string) {
+	w.sb.WriteString("<gml:LineString" + attrs + "><gml:posList>")
+	w.coords(line)
+	w.sb.WriteString("</gml:posList></gml:LineString>")
+}
+
+func (w *gmlWriter) polygon(rings [][]Point, attrs string) {
+	w.sb.WriteString("<gml:Polygon" + attrs + ">")
+	for i, ring := range rings {
+		element := "gml:interior"
+		if i == 0 {
+			element = "gml:exterior"
+		}
+		w.sb.WriteString("<" + element + "><gml:LinearRing><gml:posList>")
+		w.coords(ring)
+		w.sb.WriteString("</gml:posList></gml:LinearRing></" + element + ">")
+	}
+	w.sb.WriteString("</gml:Polygon>")
+}
+
+func (w *gmlWriter) coords(points []Point) {
+	for i, p := range points {
+		if i > 0 {
+			w.sb.WriteByte(' ')
+		}
+		x, y := p[0], p[1]
+		if w.swapAxes {
+			x, y = y, x
+		}
+		w.sb.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+		w.sb.WriteByte(' ')
+		w.sb.WriteString(strconv.FormatFloat(y, 'f', -1, 64))
+	}
+}
+
+// escapeAttr escapes a value for use inside a double-quoted XML attribute
+func escapeAttr(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
@@ -0,0 +1,156 @@
+package geometry
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// esriGeometry covers the point, multipoint, polyline and polygon forms of ArcGIS JSON geometry
+type esriGeometry struct {
+	X      *float64      `json:"x"`
+	Y      *float64      `json:"y"`
+	Points [][]float64   `json:"points"`
+	Paths  [][][]float64 `json:"paths"`
+	Rings  [][][]float64 `json:"rings"`
+}
+
+// FromEsriJSON converts an ArcGIS JSON geometry into a Geometry.
+// It returns nil for null or empty geometries.
+func FromEsriJSON(raw json.RawMessage) (*Geometry, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var eg esriGeometry
+	if err := json.Unmarshal(raw, &eg); err != nil {
+		return nil, fmt.Errorf("invalid ArcGIS geometry: %w", err)
+	}
+
+	switch {
+	case eg.X != nil && eg.Y != nil:
+		return &Geometry{Type: TypePoint, Points: []Point{{*eg.X, *eg.Y}}}, nil
+
+	case eg.Points != nil:
+		points, err := toPoints(eg.Points)
+		if err != nil || len(points) == 0 {
+			return nil, err
+		}
+		if len(points) == 1 {
+			return &Geometry{Type: TypePoint, Points: points}, nil
+		}
+		return &Geometry{Type: TypeMultiPoint, Points: points}, nil
+
+	case eg.Paths != nil:
+		var lines [][]Point
+		for _, path := range eg.Paths {
+			line, err := toPoints(path)
+			if err != nil {
+				return nil, err
+			}
+			if len(line) >= 2 {
+				lines = append(lines, line)
+			}
+		}
+		switch len(lines) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &Geometry{Type: TypeLineString, Lines: lines}, nil
+		default:
+			return &Geometry{Type: TypeMultiLineString, Lines: lines}, nil
+		}
+
+	case eg.Rings != nil:
+		polygons, err := assemblePolygons(eg.Rings)
+		if err != nil {
+			return nil, err
+		}
+		switch len(polygons) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &Geometry{Type: TypePolygon, Polygons: polygons}, nil
+		default:
+			return &Geometry{Type: TypeMultiPolygon, Polygons: polygons}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// toPoints converts ArcGIS coordinate arrays, ignoring any z/m values
+func toPoints(coords [][]float64) ([]Point, error) {
+	points := make([]Point, 0, len(coords))
+	for _, c := range coords {
+		if len(c) < 2 {
+			return nil, fmt.Errorf("invalid coordinate with %d values", len(c))
+		}
+		points = append(points, Point{c[0], c[1]})
+	}
+	return points, nil
+}
+
+// assemblePolygons groups ArcGIS rings into polygons. ArcGIS exterior rings are
+// clockwise and holes counter-clockwise; each hole is attached to the exterior
+// that contains it. Output rings follow RFC 7946 orientation (exterior
+// counter-clockwise, holes clockwise).
+func assemblePolygons(rings [][][]float64) ([][][]Point, error) {
+	var exteriors [][]Point
+	var holes [][]Point
+
+	for _, coords := range rings {
+		ring, err := toPoints(coords)
+		if err != nil {
+			return nil, err
+		}
+		if len(ring) < 4 {
+			continue
+		}
+		area := signedArea(ring)
+		if area == 0 {
+			continue
+		}
+		if area < 0 {
+			exteriors = append(exteriors, reverse(ring))
+		} else {
+			holes = append(holes, reverse(ring))
+		}
+	}
+
+	// A lone counter-clockwise ring is treated as an exterior
+	if len(exteriors) == 0 {
+		for _, hole := range holes {
+			exteriors = append(exteriors, reverse(hole))
+		}
+		holes = nil
+	}
+
+	polygons := make([][][]Point, len(exteriors))
+	for i, exterior := range exteriors {
+		polygons[i] = [][]Point{exterior}
+	}
+
+	for _, hole := range holes {
+		owner := 0
+		for i, exterior := range exteriors {
+			if ringContains(exterior, hole[0]) {
+				owner = i
+				break
+			}
+		}
+		if len(polygons) > 0 {
+			polygons[owner] = append(polygons[owner], hole)
+		}
+	}
+
+	return polygons, nil
+}
+
+// reverse returns a ring with its vertex order reversed
+func reverse(ring []Point) []Point {
+	out := make([]Point, len(ring))
+	for i, p := range ring {
+		out[len(ring)-1-i] = p
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+
+	"wms-proxy/internal/transform"
+)
+
+// Geometry types, named as in GeoJSON
+const (
+	TypePoint           = "Point"
+	TypeMultiPoint      = "MultiPoint"
+	TypeLineString      = "LineString"
+	TypeMultiLineString = "MultiLineString"
+	TypePolygon         = "Polygon"
+	TypeMultiPolygon    = "MultiPolygon"
+)
+
+// Point is an x/y coordinate pair
+type Point [2]float64
+
+// Geometry is a simple-features geometry. Only the member matching Type is set:
+// Points for (Multi)Point, Lines for (Multi)LineString and Polygons for
+// (Multi)Polygon, where each polygon is a list of rings with the exterior first.
+type Geometry struct {
+	Type     string
+	Points   []Point
+	Lines    [][]Point
+	Polygons [][][]Point
+}
+
+// Feature is a geometry with its attributes
+type Feature struct {
+	ID         interface{}
+	Properties map[string]interface{}
+	Geometry   *Geometry
+}
+
+// Transform reprojects every coordinate of the geometry in place
+func (g *Geometry) Transform(fn transform.TransformFunc) error {
+	return g.eachPoint(func(p *Point) error {
+		x, y, err := fn(p[0], p[1])
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+			return fmt.Errorf("coordinate (%f, %f) cannot be transformed", p[0], p[1])
+		}
+		p[0], p[1] = x, y
+		return nil
+	})
+}
+
+// Bounds returns the geometry's envelope
+func (g *Geometry) Bounds() transform.BBox {
+	b := transform.BBox{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	_ = g.eachPoint(func(p *Point) error {
+		b.MinX = math.Min(b.MinX, p[0])
+		b.MinY = math.Min(b.MinY, p[1])
+		b.MaxX = math.Max(b.MaxX, p[0])
+		b.MaxY = math.Max(b.MaxY, p[1])
+		return nil
+	})
+	return b
+}
+
+// IsEmpty reports whether the geometry has no coordinates
+func (g *Geometry) IsEmpty() bool {
+	return g == nil || (len(g.Points) == 0 && len(g.Lines) == 0 && len(g.Polygons) == 0)
+}
+
+// eachPoint calls fn for every coordinate of the geometry
+func (g *Geometry) eachPoint(fn func(p *Point) error) error {
+	for i := range g.Points {
+		if err := fn(&g.Points[i]); err != nil {
+			return err
+		}
+	}
+	for _, line := range g.Lines {
+		for i := range line {
+			if err := fn(&line[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, polygon := range g.Polygons {
+		for _, ring := range polygon {
+			for i := range ring {
+				if err := fn(&ring[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// signedArea returns twice the signed area of a ring (positive when counter-clockwise)
+func signedArea(ring []Point) float64 {
+	area := 0.0
+	for i := 0; i < len(ring); i++ {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return area
+}
+
+// ringContains reports whether a point lies inside a ring (even-odd rule)
+func ringContains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if (ring[i][1] > p[1]) != (ring[j][1] > p[1]) &&
+			p[0] < (ring[j][0]-ring[i][0])*(p[1]-ring[i][1])/(ring[j][1]-ring[i][1])+ring[i][0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,107 @@
+package geometry
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFromEsriJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		expectedType string
+	}{
+		{"point", `{"x": 1, "y": 2}`, TypePoint},
+		{"single multipoint", `{"points": [[1, 2]]}`, TypePoint},
+		{"multipoint", `{"points": [[1, 2], [3, 4]]}`, TypeMultiPoint},
+		{"polyline", `{"paths": [[[0, 0], [1, 1]]]}`, TypeLineString},
+		{"multi polyline", `{"paths": [[[0, 0], [1, 1]], [[2, 2], [3, 3, 9]]]}`, TypeMultiLineString},
+		{"polygon", `{"rings": [[[0, 0], [0, 1], [1, 1], [1, 0], [0, 0]]]}`, TypePolygon},
+		{"multipolygon", `{"rings": [[[0, 0], [0, 1], [1, 1], [1, 0], [0, 0]], [[5, 5], [5, 6], [6, 6], [6, 5], [5, 5]]]}`, TypeMultiPolygon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := FromEsriJSON(json.RawMessage(tt.input))
+			if err != nil {
+				t.Fatalf("FromEsriJSON failed: %v", err)
+			}
+			if g == nil || g.Type != tt.expectedType {
+				t.Errorf("Expected %s, got %+v", tt.expectedType, g)
+			}
+		})
+	}
+
+	for _, empty := range []string{``, `null`, `{}`, `{"rings": []}`} {
+		g, err := FromEsriJSON(json.RawMessage(empty))
+		if err != nil || g != nil {
+			t.Errorf("Expected nil geometry for %q, got %+v (err %v)", empty, g, err)
+		}
+	}
+}
+
+func TestPolygonHolesAndOrientation(t *testing.T) {
+	// Clockwise exterior with a counter-clockwise hole, as ArcGIS writes them
+	input := `{"rings": [
+		[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]],
+		[[2, 2], [4, 2], [4, 4], [2, 4], [2, 2]]
+	]}`
+
+	g, err := FromEsriJSON(json.RawMessage(input))
+	if err != nil {
+		t.Fatalf("FromEsriJSON failed: %v", err)
+	}
+	if g.Type != TypePolygon || len(g.Polygons[0]) != 2 {
+		t.Fatalf("Expected one polygon with a hole, got %+v", g)
+	}
+	if signedArea(g.Polygons[0][0]) <= 0 {
+		t.Error("Expected counter-clockwise exterior ring")
+	}
+	if signedArea(g.Polygons[0][1]) >= 0 {
+		t.Error("Expected clockwise interior ring")
+	}
+}
+
+func TestGeoJSONEncoding(t *testing.T) {
+	f := Feature{ID: 7, Properties: map[string]interface{}{"NAME": "A"}, Geometry: &Geometry{Type: TypePoint, Points: []Point{{1.5, 2}}}}
+
+	data, err := json.Marshal(f.ToGeoJSON())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `{"type":"Feature","id":7,"geometry":{"type":"Point","coordinates":[1.5,2]},"properties":{"NAME":"A"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
+
+func TestGML(t *testing.T) {
+	point := &Geometry{Type: TypePoint, Points: []Point{{-74.5, 40.2}}}
+
+	gml := GML(point, "urn:ogc:def:crs:EPSG::4326", "Roads.1", true)
+	if !strings.Contains(gml, "<gml:pos>40.2 -74.5</gml:pos>") {
+		t.Errorf("Expected swapped axes, got %s", gml)
+	}
+	if !strings.Contains(gml, `gml:id="Roads.1.g1"`) {
+		t.Errorf("Expected gml:id, got %s", gml)
+	}
+
+	gml = GML(point, "EPSG:3857", "Roads.1", false)
+	if !strings.Contains(gml, "<gml:pos>-74.5 40.2</gml:pos>") {
+		t.Errorf("Expected x/y axis order, got %s", gml)
+	}
+
+	polygon := &Geometry{Type: TypeMultiPolygon, Polygons: [][][]Point{
+		{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		{{{5, 5}, {6, 5}, {6, 6}, {5, 5}}},
+	}}
+	gml = GML(polygon, "EPSG:3857", "Parcels.2", false)
+	if strings.Count(gml, "<gml:surfaceMember>") != 2 || !strings.HasPrefix(gml, "<gml:MultiSurface") {
+		t.Errorf("Unexpected MultiSurface encoding: %s", gml)
+	}
+
+	if GML(nil, "EPSG:4326", "x", false) != "" {
+		t.Error("Expected empty output for nil geometry")
+	}
+}
@@ -1,9 +1,16 @@
 package handlers
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,21 +25,127 @@ type ArcGISProxyHandler struct {
 	arcgisClient client.ArcGISClientInterface
 	logger       *slog.Logger
 	baseURL      string
+	pathPrefix   string   // where the backend's /arcgis tree is served, e.g. /arcgis/parcels
+	origins      []string // backend base URLs rewritten to the proxy's in responses
 	transformer  *transform.CoordinateTransformer
 	srDetector   *services.BackendSRDetector
+	readOnly     bool  // reject editing operations
+	maxFormSize  int64 // bytes of a form-encoded POST body
+	maxUpload    int64 // bytes of a multipart POST body
 }
 
-// NewArcGISProxyHandler creates a new ArcGIS proxy handler
-func NewArcGISProxyHandler(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, baseURL string) *ArcGISProxyHandler {
+// Default limits of POST bodies
+const (
+	DefaultMaxFormSize = 10 << 20
+	DefaultMaxUpload   = 100 << 20
+)
+
+// editOperations are the ArcGIS REST operations that modify data
+var editOperations = map[string]bool{
+	"applyedits":           true,
+	"addfeatures":          true,
+	"updatefeatures":       true,
+	"deletefeatures":       true,
+	"calculate":            true,
+	"append":               true,
+	"truncate":             true,
+	"addattachment":        true,
+	"updateattachment":     true,
+	"deleteattachments":    true,
+	"synchronizereplica":   true,
+	"unregisterreplica":    true,
+	"addtodefinition":      true,
+	"updatedefinition":     true,
+	"deletefromdefinition": true,
+}
+
+// readOperations are the operations that may be POSTed in read-only mode,
+// which clients do when their parameters are too long for a URL
+var readOperations = map[string]bool{
+	// Map, feature and image services
+	"query":                       true,
+	"queryrelatedrecords":         true,
+	"querytopfeatures":            true,
+	"queryattachments":            true,
+	"querydomains":                true,
+	"identify":                    true,
+	"find":                        true,
+	"export":                      true,
+	"exportimage":                 true,
+	"generaterenderer":            true,
+	"getsamples":                  true,
+	"computehistograms":           true,
+	"computestatisticshistograms": true,
+	"legend":                      true,
+	// Geometry services
+	"project":         true,
+	"buffer":          true,
+	"simplify":        true,
+	"union":           true,
+	"intersect":       true,
+	"difference":      true,
+	"areasandlengths": true,
+	"lengths":         true,
+	"distance":        true,
+	"densify":         true,
+	"generalize":      true,
+	"offset":          true,
+	"labelpoints":     true,
+	"convexhull":      true,
+	"relation":        true,
+	// Geocode services
+	"findaddresscandidates": true,
+	"reversegeocode":        true,
+	"suggest":               true,
+	// Token requests send credentials in the body
+	"generatetoken": true,
+}
+
+// uploadOperations modify the uploads resource of a service
+var uploadOperations = map[string]bool{
+	"upload":     true,
+	"register":   true,
+	"uploadpart": true,
+	"commit":     true,
+	"delete":     true,
+}
+
+// NewArcGISProxyHandler creates a new ArcGIS proxy handler serving the backend's
+// /arcgis tree under pathPrefix
+func NewArcGISProxyHandler(arcgisClient client.ArcGISClientInterface, srDetector *services.BackendSRDetector, logger *slog.Logger, baseURL, pathPrefix string) *ArcGISProxyHandler {
 	return &ArcGISProxyHandler{
 		arcgisClient: arcgisClient,
 		logger:       logger,
 		baseURL:      baseURL,
+		pathPrefix:   pathPrefix,
+		origins:      []string{baseURL},
 		transformer:  transform.NewCoordinateTransformer(),
-		srDetector:   services.NewBackendSRDetector(arcgisClient, logger),
+		srDetector:   srDetector,
+		readOnly:     true,
+		maxFormSize:  DefaultMaxFormSize,
+		maxUpload:    DefaultMaxUpload,
 	}
 }
 
+// SetReadOnly enables or disables the rejection of editing operations
+// (applyEdits, feature and attachment edits, replica synchronization, uploads,
+// the admin API and POSTs to anything but a read operation)
+func (h *ArcGISProxyHandler) SetReadOnly(readOnly bool) {
+	h.readOnly = readOnly
+}
+
+// SetBodyLimits sets the maximum size of form-encoded and multipart POST bodies
+func (h *ArcGISProxyHandler) SetBodyLimits(maxFormSize, maxUpload int64) {
+	h.maxFormSize = maxFormSize
+	h.maxUpload = maxUpload
+}
+
+// SetBackendOrigins sets the base URLs whose absolute links are rewritten to
+// the proxy in passthrough responses, e.g. every replica of the backend
+func (h *ArcGISProxyHandler) SetBackendOrigins(origins []string) {
+	h.origins = origins
+}
+
 // ServeHTTP handles ArcGIS REST API requests and proxies them directly
 func (h *ArcGISProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
@@ -45,9 +158,15 @@ func (h *ArcGISProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"remote_addr", r.RemoteAddr,
 	)
 
-	// Only handle GET requests
-	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Only GET and POST methods are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.readOnly && (isEditOperation(r.URL.Path) || (r.Method == http.MethodPost && !isReadOperation(r.URL.Path))) {
+		h.logger.Warn("Rejected editing operation in read-only mode", "path", r.URL.Path)
+		http.Error(w, "Editing operations are disabled on this proxy", http.StatusForbidden)
 		return
 	}
 
@@ -60,21 +179,43 @@ func (h *ArcGISProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info("Proxying to ArcGIS",
+		"method", r.Method,
 		"target_url", targetURL,
 	)
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	// The backend's client applies its configured request timeout
+	ctx := r.Context()
 
 	// Make request to ArcGIS server
-	arcgisResp, err := h.arcgisClient.Get(ctx, targetURL)
+	var arcgisResp *http.Response
+	if r.Method == http.MethodPost {
+		contentType, body, status, err := h.readBody(w, r)
+		if err != nil {
+			h.logger.Warn("Rejected POST body", "error", err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		arcgisResp, err = h.arcgisClient.Post(ctx, targetURL, contentType, body)
+	} else {
+		arcgisResp, err = h.arcgisClient.Get(ctx, targetURL)
+	}
 	if err != nil {
 		h.logger.Error("Failed to request from ArcGIS server", "error", err)
-		http.Error(w, "Upstream server error", http.StatusBadGateway)
+		http.Error(w, "Upstream server error", upstreamErrorStatus(w, err))
 		return
 	}
 
+	// Point links to the backend (service directories, layer info, pagination)
+	// at the proxy; the body is rewritten while it streams through
+	if isRewritableType(arcgisResp.Header.Get("Content-Type")) {
+		arcgisResp.Body = rewriteBackendURLs(arcgisResp.Body, h.origins, baseRequestURL(r), h.pathPrefix)
+		arcgisResp.Header.Del("Content-Length")
+		if etag := arcgisResp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// The rewritten body is equivalent to, not identical with, the upstream one
+			arcgisResp.Header.Set("ETag", "W/"+etag)
+		}
+	}
+
 	// Copy the response directly (no translation needed for direct proxy)
 	if err := translator.TranslateArcGISResponse(arcgisResp, w); err != nil {
 		h.logger.Error("Failed to copy ArcGIS response", "error", err)
@@ -90,14 +231,162 @@ func (h *ArcGISProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
+// readBody reads a POST body within the size limit of its content type and
+// applies the coordinate transformation to form fields. It returns the HTTP
+// status to answer with when the body is rejected.
+func (h *ArcGISProxyHandler) readBody(w http.ResponseWriter, r *http.Request) (string, []byte, int, error) {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", nil, http.StatusUnsupportedMediaType, fmt.Errorf("POST requires a form or multipart body")
+	}
+
+	var limit int64
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		limit = h.maxFormSize
+	case "multipart/form-data":
+		limit = h.maxUpload
+	default:
+		return "", nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported POST content type %s", mediaType)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", nil, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", limit)
+		}
+		return "", nil, http.StatusBadRequest, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	// Multipart bodies (uploads, attachments) are forwarded untouched
+	if mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", nil, http.StatusBadRequest, fmt.Errorf("invalid form body: %w", err)
+		}
+		if h.transformParams(r, form) {
+			body = []byte(form.Encode())
+		}
+	}
+
+	return contentType, body, http.StatusOK, nil
+}
+
 // buildTransformedURL builds the target URL with coordinate transformation if needed
 func (h *ArcGISProxyHandler) buildTransformedURL(r *http.Request) (string, error) {
 	// Parse the query parameters
 	queryParams := r.URL.Query()
+	h.transformParams(r, queryParams)
 
-	// Check if we need to transform coordinates
+	// Build the target URL
+	targetURL := h.baseURL + r.URL.Path
+	if len(queryParams) > 0 {
+		targetURL += "?" + queryParams.Encode()
+	}
+
+	return targetURL, nil
+}
+
+// transformParams transforms bbox (with bboxSR) and envelope geometry (with
+// inSR) parameters into the backend's spatial reference, reporting whether
+// any parameter changed
+func (h *ArcGISProxyHandler) transformParams(r *http.Request, queryParams url.Values) bool {
+	transformed := h.transformBBoxParam(r, queryParams)
+	if queryParams.Get("geometry") != "" && queryParams.Get("inSR") != "" {
+		transformed = h.transformGeometryParam(r, queryParams) || transformed
+	}
+	return transformed
+}
+
+// backendCRS detects the spatial reference of the requested service
+func (h *ArcGISProxyHandler) backendCRS(r *http.Request) string {
+	toCRS, err := h.srDetector.GetBackendSR(r.Context(), r.URL.Path)
+	if err != nil {
+		h.logger.Warn("Failed to detect backend SR, using fallback",
+			"error", err,
+			"service_path", r.URL.Path,
+			"fallback_sr", h.srDetector.FallbackSR())
+		return h.srDetector.FallbackSR()
+	}
+	return toCRS
+}
+
+// srParam formats a CRS as an ArcGIS spatial reference parameter (e.g. "EPSG:3424" -> "3424")
+func srParam(crs string) string {
+	return strings.TrimPrefix(crs, "EPSG:")
+}
+
+// transformGeometryParam transforms an envelope geometry, given as
+// "xmin,ymin,xmax,ymax" or as an ArcGIS JSON envelope. Other geometry types
+// are left to the backend, which projects them from inSR itself.
+func (h *ArcGISProxyHandler) transformGeometryParam(r *http.Request, queryParams url.Values) bool {
+	geometryType := queryParams.Get("geometryType")
+	if geometryType != "" && geometryType != "esriGeometryEnvelope" {
+		return false
+	}
+
+	fromCRS := h.transformer.NormalizeCRS(queryParams.Get("inSR"))
+	toCRS := h.backendCRS(r)
+	if fromCRS == toCRS {
+		return false
+	}
+
+	geometry := queryParams.Get("geometry")
+	var transformed string
+	if strings.HasPrefix(strings.TrimSpace(geometry), "{") {
+		var envelope struct {
+			XMin float64 `json:"xmin"`
+			YMin float64 `json:"ymin"`
+			XMax float64 `json:"xmax"`
+			YMax float64 `json:"ymax"`
+		}
+		if err := json.Unmarshal([]byte(geometry), &envelope); err != nil {
+			return false
+		}
+		bbox, err := h.transformer.TransformEnvelope(transform.BBox{MinX: envelope.XMin, MinY: envelope.YMin, MaxX: envelope.XMax, MaxY: envelope.YMax}, fromCRS, toCRS)
+		if err != nil {
+			h.logger.Warn("Geometry transformation failed, using original geometry", "error", err, "from_crs", fromCRS, "to_crs", toCRS)
+			return false
+		}
+		data, _ := json.Marshal(map[string]interface{}{
+			"xmin": bbox.MinX, "ymin": bbox.MinY, "xmax": bbox.MaxX, "ymax": bbox.MaxY,
+			"spatialReference": map[string]interface{}{"wkid": srWKID(toCRS)},
+		})
+		transformed = string(data)
+	} else {
+		var err error
+		if transformed, err = h.transformer.TransformBBox(geometry, fromCRS, toCRS); err != nil {
+			h.logger.Warn("Geometry transformation failed, using original geometry", "error", err, "from_crs", fromCRS, "to_crs", toCRS)
+			return false
+		}
+	}
+
+	queryParams.Set("geometry", transformed)
+	queryParams.Set("inSR", srParam(toCRS))
+	h.logger.Info("Transformed geometry",
+		"original_geometry", geometry,
+		"transformed_geometry", transformed,
+		"from_crs", fromCRS,
+		"to_crs", toCRS,
+	)
+	return true
+}
+
+// srWKID returns the numeric code of an EPSG CRS for ArcGIS JSON
+func srWKID(crs string) interface{} {
+	if wkid, err := strconv.Atoi(srParam(crs)); err == nil {
+		return wkid
+	}
+	return srParam(crs)
+}
+
+// transformBBoxParam transforms the bbox parameter from bboxSR into the backend's spatial reference
+func (h *ArcGISProxyHandler) transformBBoxParam(r *http.Request, queryParams url.Values) bool {
 	bbox := queryParams.Get("bbox")
 	bboxSR := queryParams.Get("bboxSR")
+	transformed := false
 
 	// If we have both bbox and bboxSR, check if transformation is needed
 	if bbox != "" && bboxSR != "" {
@@ -105,14 +394,7 @@ func (h *ArcGISProxyHandler) buildTransformedURL(r *http.Request) (string, error
 		fromCRS := h.transformer.NormalizeCRS(bboxSR)
 
 		// Detect what spatial reference system the backend service expects
-		toCRS, err := h.srDetector.GetBackendSR(r.Context(), r.URL.Path)
-		if err != nil {
-			h.logger.Warn("Failed to detect backend SR, using fallback",
-				"error", err,
-				"service_path", r.URL.Path,
-				"fallback_sr", "EPSG:3424")
-			toCRS = "EPSG:3424" // Fallback to New Jersey State Plane
-		}
+		toCRS := h.backendCRS(r)
 
 		// Only transform if the CRS are different
 		if fromCRS != toCRS {
@@ -130,11 +412,8 @@ func (h *ArcGISProxyHandler) buildTransformedURL(r *http.Request) (string, error
 				// Use the transformed coordinates and update bboxSR to match backend expectation
 				queryParams.Set("bbox", transformedBBox)
 				// Extract just the numeric part for bboxSR (e.g., "EPSG:3424" -> "3424")
-				if strings.HasPrefix(toCRS, "EPSG:") {
-					queryParams.Set("bboxSR", strings.TrimPrefix(toCRS, "EPSG:"))
-				} else {
-					queryParams.Set("bboxSR", toCRS)
-				}
+				queryParams.Set("bboxSR", srParam(toCRS))
+				transformed = true
 				h.logger.Info("Transformed coordinates",
 					"original_bbox", bbox,
 					"transformed_bbox", transformedBBox,
@@ -145,13 +424,50 @@ func (h *ArcGISProxyHandler) buildTransformedURL(r *http.Request) (string, error
 		}
 	}
 
-	// Build the target URL
-	targetURL := h.baseURL + r.URL.Path
-	if len(queryParams) > 0 {
-		targetURL += "?" + queryParams.Encode()
+	return transformed
+}
+
+// isEditOperation reports whether a passthrough path modifies data: an edit
+// operation of a feature service, an upload, or the ArcGIS Server admin API
+func isEditOperation(path string) bool {
+	segments := pathSegments(path)
+	if len(segments) >= 2 && segments[0] == "arcgis" && (segments[1] == "admin" ||
+		(segments[1] == "rest" && len(segments) >= 3 && segments[2] == "admin")) {
+		return true
 	}
 
-	return targetURL, nil
+	operation := segments[len(segments)-1]
+	if editOperations[operation] {
+		return true
+	}
+	// /uploads/upload, /uploads/register, /uploads/{itemID}/delete, ...
+	for _, segment := range segments[:len(segments)-1] {
+		if segment == "uploads" {
+			return uploadOperations[operation]
+		}
+	}
+	return false
+}
+
+// isReadOperation reports whether a passthrough path is an operation that
+// only reads data, such as a query, identify or export
+func isReadOperation(path string) bool {
+	segments := pathSegments(path)
+	return readOperations[segments[len(segments)-1]]
+}
+
+// pathSegments returns the lower-case segments of a path without their path
+// parameters (";x"), which the backend ignores when routing, after resolving
+// "." and ".." segments
+func pathSegments(urlPath string) []string {
+	segments := strings.Split(urlPath, "/")
+	for i, segment := range segments {
+		if j := strings.IndexByte(segment, ';'); j >= 0 {
+			segments[i] = segment[:j]
+		}
+	}
+	cleaned := path.Clean("/" + strings.Join(segments, "/"))
+	return strings.Split(strings.ToLower(cleaned), "/")[1:]
 }
 
 // isArcGISPath checks if the request path is for ArcGIS REST API
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
@@ -10,15 +11,20 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
 )
 
 // mockArcGISClient is a mock implementation of ArcGISClientInterface for testing
 type mockArcGISClient struct {
-	lastRequestURL string
-	response       *http.Response
-	err            error
+	lastRequestURL  string
+	lastMethod      string
+	lastContentType string
+	lastBody        []byte
+	response        *http.Response
+	err             error
 }
 
 // Ensure mockArcGISClient implements client.ArcGISClientInterface
@@ -26,6 +32,15 @@ var _ client.ArcGISClientInterface = (*mockArcGISClient)(nil)
 
 func (m *mockArcGISClient) Get(ctx context.Context, url string) (*http.Response, error) {
 	m.lastRequestURL = url
+	m.lastMethod = http.MethodGet
+	return m.response, m.err
+}
+
+func (m *mockArcGISClient) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	m.lastRequestURL = url
+	m.lastMethod = http.MethodPost
+	m.lastContentType = contentType
+	m.lastBody = body
 	return m.response, m.err
 }
 
@@ -45,7 +60,7 @@ func (m *mockArcGISClient) GetServiceMetadata(ctx context.Context, servicePath s
 func TestArcGISProxyHandler_buildTransformedURL(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
 	mockClient := &mockArcGISClient{}
-	handler := NewArcGISProxyHandler(mockClient, logger, "https://example.com")
+	handler := NewArcGISProxyHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis")
 
 	tests := []struct {
 		name            string
@@ -147,8 +162,8 @@ func TestArcGISProxyHandler_ServeHTTP(t *testing.T) {
 			expectedStatus: 200,
 		},
 		{
-			name:           "POST method not allowed",
-			method:         "POST",
+			name:           "DELETE method not allowed",
+			method:         "DELETE",
 			requestURL:     "/arcgis/rest/services/test/MapServer/export",
 			expectedStatus: 405,
 		},
@@ -159,6 +174,20 @@ func TestArcGISProxyHandler_ServeHTTP(t *testing.T) {
 			mockResponse:   &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("mock image data")), Header: make(http.Header)},
 			expectedStatus: 200,
 		},
+		{
+			name:           "Upstream failure",
+			method:         "GET",
+			requestURL:     "/arcgis/rest/services/test/MapServer/export?size=256,256&f=image",
+			mockError:      errors.New("connection refused"),
+			expectedStatus: 502,
+		},
+		{
+			name:           "Backend overloaded",
+			method:         "GET",
+			requestURL:     "/arcgis/rest/services/test/MapServer/export?size=256,256&f=image",
+			mockError:      &client.OverloadError{Reason: "request queue is full", RetryAfter: 10 * time.Second},
+			expectedStatus: 503,
+		},
 	}
 
 	for _, test := range tests {
@@ -167,7 +196,7 @@ func TestArcGISProxyHandler_ServeHTTP(t *testing.T) {
 				response: test.mockResponse,
 				err:      test.mockError,
 			}
-			handler := NewArcGISProxyHandler(mockClient, logger, "https://example.com")
+			handler := NewArcGISProxyHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis")
 
 			req := httptest.NewRequest(test.method, test.requestURL, nil)
 			w := httptest.NewRecorder()
@@ -177,6 +206,9 @@ func TestArcGISProxyHandler_ServeHTTP(t *testing.T) {
 			if w.Code != test.expectedStatus {
 				t.Errorf("expected status %d, got %d", test.expectedStatus, w.Code)
 			}
+			if test.expectedStatus == 503 && w.Header().Get("Retry-After") != "10" {
+				t.Errorf("expected Retry-After 10, got %q", w.Header().Get("Retry-After"))
+			}
 
 			// For successful requests, verify that the mock client was called
 			if test.expectedStatus == 200 && mockClient.lastRequestURL == "" {
@@ -208,7 +240,7 @@ func TestArcGISProxyHandler_CoordinateTransformationIntegration(t *testing.T) {
 	mockClient := &mockArcGISClient{
 		response: mockResponse,
 	}
-	handler := NewArcGISProxyHandler(mockClient, logger, "https://mapserver.example.com")
+	handler := NewArcGISProxyHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://mapserver.example.com", "/arcgis")
 
 	// Test request with coordinate transformation
 	// Client sends EPSG:3857 coordinates, backend expects EPSG:3424 (from mock)
@@ -277,7 +309,7 @@ func TestArcGISProxyHandler_TransformationErrorHandling(t *testing.T) {
 	mockClient := &mockArcGISClient{
 		response: mockResponse,
 	}
-	handler := NewArcGISProxyHandler(mockClient, logger, "https://example.com")
+	handler := NewArcGISProxyHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis")
 
 	// Test with invalid bbox format - should not cause server error
 	requestURL := "/arcgis/rest/services/test/MapServer/export?" +
@@ -301,3 +333,252 @@ func TestArcGISProxyHandler_TransformationErrorHandling(t *testing.T) {
 		t.Error("expected request to be made to upstream server despite transformation error")
 	}
 }
+
+func TestArcGISProxyHandler_RewritesBackendURLs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	tests := []struct {
+		name        string
+		pathPrefix  string
+		contentType string
+		headers     map[string]string
+		body        string
+		expected    string
+	}{
+		{
+			name:        "JSON service directory",
+			pathPrefix:  "/arcgis",
+			contentType: "text/plain;charset=utf-8",
+			body:        `{"services":[{"url":"https://gis.internal/arcgis/rest/services/Parcels/MapServer"}]}`,
+			expected:    `{"services":[{"url":"http://example.com/arcgis/rest/services/Parcels/MapServer"}]}`,
+		},
+		{
+			name:        "replica over http behind a reverse proxy",
+			pathPrefix:  "/arcgis/parcels",
+			contentType: "application/json",
+			headers:     map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "maps.example.com, edge.internal", "X-Forwarded-Prefix": "/gis"},
+			body:        `{"next":"http://gis2.internal/arcgis/rest/services/Parcels/MapServer/0/query?resultOffset=1000"}`,
+			expected:    `{"next":"https://maps.example.com/gis/arcgis/parcels/rest/services/Parcels/MapServer/0/query?resultOffset=1000"}`,
+		},
+		{
+			name:        "relative HTML links of a named backend",
+			pathPrefix:  "/arcgis/parcels",
+			contentType: "text/html",
+			body:        `<a href="/arcgis/rest/services/Parcels/MapServer">Parcels</a>`,
+			expected:    `<a href="/arcgis/parcels/rest/services/Parcels/MapServer">Parcels</a>`,
+		},
+		{
+			name:        "images are not rewritten",
+			pathPrefix:  "/arcgis/parcels",
+			contentType: "image/png",
+			body:        `https://gis.internal/arcgis/`,
+			expected:    `https://gis.internal/arcgis/`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockResponse := &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(test.body)),
+				Header:     make(http.Header),
+			}
+			mockResponse.Header.Set("Content-Type", test.contentType)
+			mockClient := &mockArcGISClient{response: mockResponse}
+
+			handler := NewArcGISProxyHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://gis.internal", test.pathPrefix)
+			handler.SetBackendOrigins([]string{"https://gis.internal", "https://gis2.internal"})
+
+			req := httptest.NewRequest("GET", "/arcgis/rest/services?f=json", nil)
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Body.String() != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestArcGISProxyHandler_Post(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	newHandler := func() (*ArcGISProxyHandler, *mockArcGISClient) {
+		mockClient := &mockArcGISClient{response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"features":[]}`)),
+			Header:     http.Header{"Content-Type": {"application/json"}},
+		}}
+		handler := NewArcGISProxyHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis")
+		handler.SetBodyLimits(1024, 4096)
+		return handler, mockClient
+	}
+
+	t.Run("form query with envelope geometry", func(t *testing.T) {
+		handler, mockClient := newHandler()
+		form := url.Values{
+			"geometry":     {`{"xmin":-74.006,"ymin":40.710974,"xmax":-74.003364,"ymax":40.712972}`},
+			"geometryType": {"esriGeometryEnvelope"},
+			"inSR":         {"4326"},
+			"where":        {"1=1"},
+			"f":            {"json"},
+		}
+		req := httptest.NewRequest("POST", "/arcgis/rest/services/test/MapServer/0/query", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || mockClient.lastMethod != http.MethodPost {
+			t.Fatalf("expected a forwarded POST, got status %d and method %q", w.Code, mockClient.lastMethod)
+		}
+		forwarded, err := url.ParseQuery(string(mockClient.lastBody))
+		if err != nil {
+			t.Fatalf("forwarded body is not a form: %v", err)
+		}
+		if forwarded.Get("inSR") != "3424" || !strings.Contains(forwarded.Get("geometry"), `"wkid":3424`) {
+			t.Errorf("expected the geometry transformed to EPSG:3424, got inSR=%s geometry=%s", forwarded.Get("inSR"), forwarded.Get("geometry"))
+		}
+		if forwarded.Get("where") != "1=1" {
+			t.Errorf("expected the other fields to be preserved, got %v", forwarded)
+		}
+	})
+
+	t.Run("multipart upload forwarded untouched", func(t *testing.T) {
+		handler, mockClient := newHandler()
+		handler.SetReadOnly(false)
+		body := "--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.zip\"\r\n\r\nPK\r\n--b--\r\n"
+		req := httptest.NewRequest("POST", "/arcgis/rest/services/test/FeatureServer/uploads/upload", strings.NewReader(body))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || string(mockClient.lastBody) != body || mockClient.lastContentType != "multipart/form-data; boundary=b" {
+			t.Errorf("expected the multipart body to be forwarded as is, got status %d", w.Code)
+		}
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{"method not allowed", "PUT", "/arcgis/rest/services", "", "", http.StatusMethodNotAllowed},
+		{"unsupported content type", "POST", "/arcgis/rest/services/test/MapServer/0/query", "application/xml", "<query/>", http.StatusUnsupportedMediaType},
+		{"form too large", "POST", "/arcgis/rest/services/test/MapServer/0/query", "application/x-www-form-urlencoded", "where=" + strings.Repeat("x", 2048), http.StatusRequestEntityTooLarge},
+		{"applyEdits in read-only mode", "POST", "/arcgis/rest/services/test/FeatureServer/0/applyEdits", "application/x-www-form-urlencoded", "f=json", http.StatusForbidden},
+		{"edit via GET in read-only mode", "GET", "/arcgis/rest/services/test/FeatureServer/0/deleteFeatures?where=1%3D1", "", "", http.StatusForbidden},
+		{"admin API in read-only mode", "POST", "/arcgis/admin/services/test.MapServer/stop", "application/x-www-form-urlencoded", "f=json", http.StatusForbidden},
+		{"REST admin API in read-only mode", "POST", "/arcgis/rest/admin/services/test/FeatureServer/addToDefinition", "application/x-www-form-urlencoded", "f=json", http.StatusForbidden},
+		{"synchronizeReplica in read-only mode", "POST", "/arcgis/rest/services/test/FeatureServer/synchronizeReplica", "application/x-www-form-urlencoded", "f=json", http.StatusForbidden},
+		{"path parameter in read-only mode", "POST", "/arcgis/rest/services/test/FeatureServer/0/applyEdits;x", "application/x-www-form-urlencoded", "f=json", http.StatusForbidden},
+		{"unlisted POST operation in read-only mode", "POST", "/arcgis/rest/services/test/GPServer/task/execute", "application/x-www-form-urlencoded", "f=json", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, mockClient := newHandler()
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+			if mockClient.lastRequestURL != "" {
+				t.Errorf("expected the request not to be forwarded, got %s", mockClient.lastRequestURL)
+			}
+		})
+	}
+
+	t.Run("applyEdits with editing enabled", func(t *testing.T) {
+		handler, mockClient := newHandler()
+		handler.SetReadOnly(false)
+		req := httptest.NewRequest("POST", "/arcgis/rest/services/test/FeatureServer/0/applyEdits", strings.NewReader("adds=%5B%5D&f=json"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || string(mockClient.lastBody) != "adds=%5B%5D&f=json" {
+			t.Errorf("expected the edit to be forwarded unchanged, got status %d and body %q", w.Code, mockClient.lastBody)
+		}
+	})
+
+	for _, path := range []string{"/arcgis/rest/services/test/FeatureServer/0/query", "/arcgis/tokens/generateToken"} {
+		t.Run("read operation POST in read-only mode "+path, func(t *testing.T) {
+			handler, mockClient := newHandler()
+			req := httptest.NewRequest("POST", path, strings.NewReader("f=json"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || mockClient.lastRequestURL != "https://example.com"+path {
+				t.Errorf("expected the request to be forwarded, got status %d and URL %q", w.Code, mockClient.lastRequestURL)
+			}
+		})
+	}
+}
+
+func TestIsEditOperation(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/arcgis/rest/services/test/MapServer/export", false},
+		{"/arcgis/rest/services/test/FeatureServer/0/query", false},
+		{"/arcgis/rest/services/test/FeatureServer/0/applyEdits", true},
+		{"/arcgis/rest/services/test/FeatureServer/applyEdits/", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/12/addAttachment", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/12/attachments", false},
+		{"/arcgis/rest/services/test/GPServer/uploads/upload", true},
+		{"/arcgis/rest/services/test/GPServer/uploads/info", false},
+		{"/arcgis/rest/services/test/GPServer/uploads/i1234/delete", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/deleteFeatures", true},
+		{"/arcgis/admin/services", true},
+		{"/arcgis/rest/admin/services/test/FeatureServer/addToDefinition", true},
+		{"/arcgis/rest/admin/services/test/FeatureServer/0/updateDefinition", true},
+		{"/arcgis/rest/services/test/FeatureServer/deleteFromDefinition", true},
+		{"/arcgis/rest/services/test/FeatureServer/synchronizeReplica", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/applyEdits;x", true},
+		{"/arcgis/rest/services/test/FeatureServer/0;x/applyEdits;jsessionid=1", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/applyEdits/.", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/query/../applyEdits", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/query;x", false},
+		{"/arcgis/rest/info", false},
+	}
+
+	for _, test := range tests {
+		if got := isEditOperation(test.path); got != test.expected {
+			t.Errorf("isEditOperation(%q) = %v, expected %v", test.path, got, test.expected)
+		}
+	}
+}
+
+func TestIsReadOperation(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/arcgis/rest/services/test/FeatureServer/0/query", true},
+		{"/arcgis/rest/services/test/MapServer/identify", true},
+		{"/arcgis/rest/services/test/MapServer/export;x", true},
+		{"/arcgis/rest/services/test/ImageServer/exportImage/", true},
+		{"/arcgis/rest/services/test/FeatureServer/0/applyEdits", false},
+		{"/arcgis/rest/services/test/GPServer/task/submitJob", false},
+		{"/arcgis/rest/services/test/MapServer/query/../applyEdits", false},
+	}
+
+	for _, test := range tests {
+		if got := isReadOperation(test.path); got != test.expected {
+			t.Errorf("isReadOperation(%q) = %v, expected %v", test.path, got, test.expected)
+		}
+	}
+}
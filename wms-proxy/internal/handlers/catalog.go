@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
+	"wms-proxy/internal/translator"
+)
+
+// CatalogHandler publishes the map services found by service discovery as WMS
+// layer sets under {pathPrefix}/{folder}/{service}/MapServer/wms and lists the
+// catalog at {pathPrefix}
+type CatalogHandler struct {
+	crawler      *services.ServiceCrawler
+	arcgisClient client.ArcGISClientInterface
+	srDetector   *services.BackendSRDetector
+	logger       *slog.Logger
+	baseURL      string
+	pathPrefix   string
+	jpegQuality  int
+	cachePolicy  *CachePolicy
+
+	mu          sync.Mutex
+	wmsHandlers map[string]*WMSHandler // service root -> WMS handler
+}
+
+// NewCatalogHandler creates a handler publishing the services of crawler
+func NewCatalogHandler(crawler *services.ServiceCrawler, arcgisClient client.ArcGISClientInterface, srDetector *services.BackendSRDetector, logger *slog.Logger, baseURL, pathPrefix string, jpegQuality int) *CatalogHandler {
+	return &CatalogHandler{
+		crawler:      crawler,
+		arcgisClient: arcgisClient,
+		srDetector:   srDetector,
+		logger:       logger,
+		baseURL:      baseURL,
+		pathPrefix:   pathPrefix,
+		jpegQuality:  jpegQuality,
+		wmsHandlers:  make(map[string]*WMSHandler),
+	}
+}
+
+// SetCachePolicy sets the Cache-Control policy of the published WMS services
+func (h *CatalogHandler) SetCachePolicy(policy *CachePolicy) {
+	h.cachePolicy = policy
+}
+
+// catalogResponse lists the discovered services
+type catalogResponse struct {
+	LastCrawl string           `json:"lastCrawl,omitempty"`
+	Services  []catalogService `json:"services"`
+}
+
+type catalogService struct {
+	Name   string         `json:"name"`
+	Type   string         `json:"type"`
+	Title  string         `json:"title,omitempty"`
+	WMS    string         `json:"wms,omitempty"`
+	Layers []catalogLayer `json:"layers,omitempty"`
+}
+
+type catalogLayer struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+// ServeHTTP handles catalog listing and published WMS requests
+func (h *CatalogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, h.pathPrefix)
+	if rest == "" || rest == "/" {
+		h.handleList(w, r)
+		return
+	}
+
+	name, ok := strings.CutSuffix(strings.TrimPrefix(rest, "/"), "/"+client.ServiceTypeMap+"/wms")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	service, found := h.crawler.FindService(name, client.ServiceTypeMap)
+	if !found {
+		translator.GenerateWMSError(w, "Unknown service: "+name, http.StatusNotFound)
+		return
+	}
+
+	h.wmsHandler(service).ServeHTTP(w, r)
+}
+
+// handleList writes the catalog as JSON
+func (h *CatalogHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	response := catalogResponse{Services: []catalogService{}}
+	if lastCrawl := h.crawler.LastCrawl(); !lastCrawl.IsZero() {
+		response.LastCrawl = lastCrawl.UTC().Format(time.RFC3339)
+	}
+
+	base := baseRequestURL(r) + h.pathPrefix
+	for _, s := range h.crawler.Services() {
+		service := catalogService{Name: s.Name, Type: s.Type, Title: s.Title}
+		if s.Type == client.ServiceTypeMap {
+			service.WMS = base + "/" + s.Name + "/" + s.Type + "/wms"
+		}
+		for _, layer := range s.Layers {
+			service.Layers = append(service.Layers, catalogLayer{ID: layer.ID, Name: layer.Name, Title: layer.Title})
+		}
+		response.Services = append(response.Services, service)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode service catalog", "error", err)
+	}
+}
+
+// wmsHandler returns the WMS handler of a discovered map service, creating it on first use
+func (h *CatalogHandler) wmsHandler(service services.DiscoveredService) *WMSHandler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	handler, exists := h.wmsHandlers[service.Path]
+	if !exists {
+		handler = NewWMSHandler(h.arcgisClient, h.srDetector, h.logger, h.baseURL, service.Path+"/export", h.jpegQuality)
+		handler.SetCachePolicy(h.cachePolicy)
+		h.wmsHandlers[service.Path] = handler
+	}
+	return handler
+}
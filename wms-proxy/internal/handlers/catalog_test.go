@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
+)
+
+// newDirectoryServer serves a services directory with one root service and
+// a folder holding a map service, an image service and an excluded service
+func newDirectoryServer(t *testing.T, exported *string) *httptest.Server {
+	t.Helper()
+	responses := map[string]string{
+		"/arcgis/rest/services":                             `{"folders":["Hydro"],"services":[{"name":"Parcels","type":"MapServer"}]}`,
+		"/arcgis/rest/services/Hydro":                       `{"folders":[],"services":[{"name":"Hydro/Wetlands","type":"MapServer"},{"name":"Hydro/Elevation","type":"ImageServer"},{"name":"Hydro/Scratch","type":"MapServer"}]}`,
+		"/arcgis/rest/services/Parcels/MapServer":           `{"mapName":"Parcels","capabilities":"Map,Query","layers":[{"id":0,"name":"Parcels","parentLayerId":-1}]}`,
+		"/arcgis/rest/services/Hydro/Wetlands/MapServer":    `{"mapName":"Wetlands","capabilities":"Map,Query","layers":[{"id":0,"name":"Wetlands","parentLayerId":-1},{"id":1,"name":"Streams","parentLayerId":-1}]}`,
+		"/arcgis/rest/services/Hydro/Scratch/MapServer":     `{"mapName":"Scratch","capabilities":"Map","layers":[]}`,
+		"/arcgis/rest/services/Hydro/Elevation/ImageServer": `{"name":"Elevation","pixelType":"F32","bandCount":1}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := responses[r.URL.Path]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+			return
+		}
+		if r.URL.Path == "/arcgis/rest/services/Hydro/Wetlands/MapServer/export" {
+			*exported = r.URL.Path
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServiceCrawler_Crawl(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var exported string
+	server := newDirectoryServer(t, &exported)
+	arcgisClient := client.NewArcGISClient(server.URL, 5*time.Second)
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{"all services", nil, nil, []string{"Hydro/Elevation", "Hydro/Scratch", "Hydro/Wetlands", "Parcels"}},
+		{"include folder", []string{"Hydro/*"}, nil, []string{"Hydro/Elevation", "Hydro/Scratch", "Hydro/Wetlands"}},
+		{"exclude wins", []string{"Hydro/*"}, []string{"*/Scratch"}, []string{"Hydro/Elevation", "Hydro/Wetlands"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			crawler := services.NewServiceCrawler(arcgisClient, logger, server.URL, "/arcgis/rest/services", test.include, test.exclude)
+			if err := crawler.Crawl(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, s := range crawler.Services() {
+				names = append(names, s.Name)
+			}
+			if len(names) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, names)
+			}
+			for i := range names {
+				if names[i] != test.expected[i] {
+					t.Errorf("expected %v, got %v", test.expected, names)
+				}
+			}
+		})
+	}
+}
+
+func TestCatalogHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var exported string
+	server := newDirectoryServer(t, &exported)
+	arcgisClient := client.NewArcGISClient(server.URL, 5*time.Second)
+	crawler := services.NewServiceCrawler(arcgisClient, logger, server.URL, "/arcgis/rest/services", []string{"Hydro/*"}, nil)
+	if err := crawler.Crawl(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := NewCatalogHandler(crawler, arcgisClient, services.NewBackendSRDetector(arcgisClient, logger), logger, server.URL, "/services", 0)
+
+	t.Run("list", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://proxy.example.com/services", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		var response catalogResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(response.Services) != 3 || response.LastCrawl == "" {
+			t.Fatalf("unexpected catalog: %+v", response)
+		}
+		wetlands := response.Services[2]
+		if wetlands.WMS != "http://proxy.example.com/services/Hydro/Wetlands/MapServer/wms" || len(wetlands.Layers) != 2 {
+			t.Errorf("unexpected map service entry: %+v", wetlands)
+		}
+		if elevation := response.Services[0]; elevation.WMS != "" {
+			t.Errorf("image services should not be published as WMS, got %s", elevation.WMS)
+		}
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"published map service", "/services/Hydro/Wetlands/MapServer/wms?SERVICE=WMS&REQUEST=GetMap&LAYERS=0&FORMAT=image/png&SRS=EPSG:4326&BBOX=-75,40,-74,41&WIDTH=256&HEIGHT=256", http.StatusOK},
+		{"service excluded from discovery", "/services/Parcels/MapServer/wms?SERVICE=WMS&REQUEST=GetCapabilities", http.StatusNotFound},
+		{"image service", "/services/Hydro/Elevation/ImageServer/wms?SERVICE=WMS&REQUEST=GetCapabilities", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+			if rr.Code != test.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if exported != "/arcgis/rest/services/Hydro/Wetlands/MapServer/export" {
+		t.Errorf("expected GetMap to be sent to the discovered service, got %q", exported)
+	}
+}
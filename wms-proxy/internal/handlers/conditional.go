@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Endpoints with a configurable Cache-Control policy
+const (
+	EndpointCapabilities = "capabilities" // WMS and WFS GetCapabilities
+	EndpointMap          = "map"          // WMS GetMap images
+	EndpointTiles        = "tiles"        // vector tiles
+)
+
+// CachePolicy holds the Cache-Control header sent per endpoint and per layer
+type CachePolicy struct {
+	endpoints map[string]string
+	layers    map[string]string
+}
+
+// NewCachePolicy creates a cache policy from Cache-Control values keyed by
+// endpoint and by layer name
+func NewCachePolicy(endpoints, layers map[string]string) *CachePolicy {
+	return &CachePolicy{endpoints: endpoints, layers: layers}
+}
+
+// For returns the Cache-Control value of a response of endpoint showing layers.
+// Layers without a policy of their own use the endpoint's, or fallback when the
+// endpoint has none. When the layers differ the most restrictive value wins.
+func (p *CachePolicy) For(endpoint string, layers []string, fallback string) string {
+	if p == nil {
+		return fallback
+	}
+	endpointValue := fallback
+	if value, ok := p.endpoints[endpoint]; ok {
+		endpointValue = value
+	}
+	if len(layers) == 0 {
+		return endpointValue
+	}
+
+	result := ""
+	for _, layer := range layers {
+		value, ok := p.layers[strings.TrimSpace(layer)]
+		if !ok {
+			value = endpointValue
+		}
+		if value != "" && (result == "" || cacheLifetime(value) < cacheLifetime(result)) {
+			result = value
+		}
+	}
+	return result
+}
+
+// cacheLifetime orders Cache-Control values by how long they allow caching
+func cacheLifetime(cacheControl string) int {
+	lifetime := int(^uint(0) >> 1)
+	for _, directive := range strings.Split(strings.ToLower(cacheControl), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch name {
+		case "no-store":
+			return -2
+		case "no-cache":
+			return -1
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds < lifetime {
+				lifetime = seconds
+			}
+		}
+	}
+	return lifetime
+}
+
+// serveConditional writes a successful response body with a strong ETag,
+// answering 304 Not Modified when the client's copy is current. The other
+// headers, such as Content-Type and Cache-Control, must already be set.
+func serveConditional(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if notModified(r, etag, w.Header().Get("Last-Modified")) {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the request
+// has no If-None-Match, against the response's validators
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// If-None-Match uses the weak comparison
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	return err == nil && !modified.After(since)
+}
+
+// writeBuffered sends a buffered response to the client: successful responses
+// get a Cache-Control value from the policy and are served conditionally, others
+// are passed through
+func writeBuffered(w http.ResponseWriter, r *http.Request, buffered *bufferedResponse, cacheControl string) {
+	for name, values := range buffered.header {
+		w.Header()[name] = values
+	}
+	if buffered.status != http.StatusOK {
+		w.WriteHeader(buffered.status)
+		w.Write(buffered.body.Bytes())
+		return
+	}
+
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	serveConditional(w, r, buffered.body.Bytes())
+}
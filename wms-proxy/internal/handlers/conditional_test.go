@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
+)
+
+func TestCachePolicy_For(t *testing.T) {
+	policy := NewCachePolicy(
+		map[string]string{EndpointCapabilities: "max-age=600", EndpointMap: "public, max-age=300"},
+		map[string]string{"0": "max-age=86400", "1": "no-cache", "Roads": "max-age=60"},
+	)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		layers   []string
+		fallback string
+		expected string
+	}{
+		{"endpoint policy", EndpointCapabilities, nil, "max-age=3600", "max-age=600"},
+		{"fallback", EndpointTiles, nil, "max-age=3600", "max-age=3600"},
+		{"layer policy", EndpointMap, []string{"0"}, "", "max-age=86400"},
+		{"layer without policy", EndpointMap, []string{"7"}, "", "public, max-age=300"},
+		{"most restrictive of layers", EndpointMap, []string{"0", "7"}, "", "public, max-age=300"},
+		{"no-cache layer", EndpointMap, []string{"0", "1"}, "", "no-cache"},
+		{"tile layer", EndpointTiles, []string{"Roads"}, "max-age=3600", "max-age=60"},
+		{"no policy", EndpointTiles, []string{"Parcels"}, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := policy.For(test.endpoint, test.layers, test.fallback); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+
+	var unset *CachePolicy
+	if got := unset.For(EndpointMap, []string{"0"}, "max-age=1"); got != "max-age=1" {
+		t.Errorf("expected a nil policy to return the fallback, got %q", got)
+	}
+}
+
+func TestWMSHandler_ConditionalGetMap(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	lastModified := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/export") {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Cache-Control", "max-age=10")
+			w.Header().Set("Last-Modified", lastModified)
+			w.Write([]byte("png data"))
+			return
+		}
+		w.Write([]byte(`{"spatialReference":{"wkid":3857}}`))
+	}))
+	defer server.Close()
+
+	arcgisClient := client.NewArcGISClient(server.URL, 5*time.Second)
+	handler := NewWMSHandler(arcgisClient, services.NewBackendSRDetector(arcgisClient, logger), logger, server.URL, "/arcgis/rest/services/test/MapServer/export", 0)
+	handler.SetCachePolicy(NewCachePolicy(nil, map[string]string{"0": "max-age=86400"}))
+	getMap := "/wms?SERVICE=WMS&REQUEST=GetMap&VERSION=1.1.1&LAYERS=%s&SRS=EPSG:3857&BBOX=0,0,1000,1000&WIDTH=256&HEIGHT=256&FORMAT=image/png"
+
+	serve := func(layers string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://proxy.local"+strings.ReplaceAll(getMap, "%s", layers), nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := serve("0", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Body.String() != "png data" || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("unexpected response: %d %q ETag %q", first.Code, first.Body.String(), etag)
+	}
+	if cacheControl := first.Header().Get("Cache-Control"); cacheControl != "max-age=86400" {
+		t.Errorf("expected the layer's Cache-Control, got %q", cacheControl)
+	}
+	if cacheControl := serve("1", nil).Header().Get("Cache-Control"); cacheControl != "max-age=10" {
+		t.Errorf("expected the upstream Cache-Control for a layer without policy, got %q", cacheControl)
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected int
+	}{
+		{"matching ETag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"weak matching ETag", http.Header{"If-None-Match": {`"other", W/` + etag}}, http.StatusNotModified},
+		{"other ETag", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified},
+		{"modified since", http.Header{"If-Modified-Since": {time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve("0", test.header)
+			if w.Code != test.expected {
+				t.Fatalf("expected status %d, got %d", test.expected, w.Code)
+			}
+			if w.Code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != etag) {
+				t.Errorf("expected an empty 304 with the ETag, got %q %q", w.Body.String(), w.Header().Get("ETag"))
+			}
+		})
+	}
+}
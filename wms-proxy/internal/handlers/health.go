@@ -6,27 +6,72 @@ import (
 	"net/http"
 	"time"
 
+	"wms-proxy/internal/cache"
 	"wms-proxy/internal/client"
 )
 
 // HealthHandler provides health check functionality
 type HealthHandler struct {
-	arcgisClient *client.ArcGISClient
+	arcgisClient client.HealthChecker
+	backends     []namedBackend
+	circuits     map[string]*client.ResilientClient
+	queues       map[string]*client.Bulkhead
+	caches       map[string]*cache.TileCache
+}
+
+// namedBackend is an additional upstream checked by the health endpoint
+type namedBackend struct {
+	name   string
+	client client.HealthChecker
 }
 
 // NewHealthHandler creates a new health check handler
-func NewHealthHandler(arcgisClient *client.ArcGISClient) *HealthHandler {
+func NewHealthHandler(arcgisClient client.HealthChecker) *HealthHandler {
 	return &HealthHandler{
 		arcgisClient: arcgisClient,
 	}
 }
 
+// AddBackend adds a named backend to the health check. A failing named
+// backend degrades the status without failing the check.
+func (h *HealthHandler) AddBackend(name string, arcgisClient client.HealthChecker) {
+	h.backends = append(h.backends, namedBackend{name: name, client: arcgisClient})
+}
+
+// AddCircuit reports the circuit breaker state of a backend
+func (h *HealthHandler) AddCircuit(name string, resilientClient *client.ResilientClient) {
+	if h.circuits == nil {
+		h.circuits = make(map[string]*client.ResilientClient)
+	}
+	h.circuits[name] = resilientClient
+}
+
+// AddQueue reports the occupancy of a backend's bulkhead
+func (h *HealthHandler) AddQueue(name string, bulkhead *client.Bulkhead) {
+	if h.queues == nil {
+		h.queues = make(map[string]*client.Bulkhead)
+	}
+	h.queues[name] = bulkhead
+}
+
+// AddCache reports the statistics of a cache, including the stale entries served
+func (h *HealthHandler) AddCache(name string, c *cache.TileCache) {
+	if h.caches == nil {
+		h.caches = make(map[string]*cache.TileCache)
+	}
+	h.caches[name] = c
+}
+
 // HealthResponse represents the health check response
 type HealthResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
-	Upstream  string `json:"upstream"`
-	Message   string `json:"message,omitempty"`
+	Status    string                            `json:"status"`
+	Timestamp string                            `json:"timestamp"`
+	Upstream  string                            `json:"upstream"`
+	Message   string                            `json:"message,omitempty"`
+	Backends  map[string]string                 `json:"backends,omitempty"`
+	Circuits  map[string]string                 `json:"circuits,omitempty"`
+	Queues    map[string]client.BulkheadStats   `json:"queues,omitempty"`
+	Caches    map[string]map[string]interface{} `json:"caches,omitempty"`
 }
 
 // ServeHTTP handles health check requests
@@ -40,6 +85,45 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Upstream:  "ok",
 	}
 
+	// Report circuit breaker states
+	if len(h.circuits) > 0 {
+		response.Circuits = make(map[string]string, len(h.circuits))
+		for name, resilientClient := range h.circuits {
+			response.Circuits[name] = resilientClient.State()
+		}
+	}
+
+	// Report queue depths
+	if len(h.queues) > 0 {
+		response.Queues = make(map[string]client.BulkheadStats, len(h.queues))
+		for name, bulkhead := range h.queues {
+			response.Queues[name] = bulkhead.Stats()
+		}
+	}
+
+	// Report cache statistics
+	if len(h.caches) > 0 {
+		response.Caches = make(map[string]map[string]interface{}, len(h.caches))
+		for name, c := range h.caches {
+			response.Caches[name] = c.Stats()
+		}
+	}
+
+	// Check named backends
+	if len(h.backends) > 0 {
+		response.Backends = make(map[string]string, len(h.backends))
+		for _, backend := range h.backends {
+			if err := backend.client.HealthCheck(ctx); err != nil {
+				response.Backends[backend.name] = "error: " + err.Error()
+				response.Status = "degraded"
+			} else {
+				response.Backends[backend.name] = "ok"
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
 	// Check upstream ArcGIS server connectivity
 	if err := h.arcgisClient.HealthCheck(ctx); err != nil {
 		response.Status = "unhealthy"
@@ -50,8 +134,6 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode health response", http.StatusInternalServerError)
 		return
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/transform"
+	"wms-proxy/internal/translator"
+	"wms-proxy/pkg/kml"
+	"wms-proxy/pkg/wms"
+)
+
+// KML super-overlay limits
+const (
+	defaultKMLMaxLevel = 12
+	maxKMLLevel        = 20
+	kmlTileSize        = 256
+	defaultKMZWidth    = 2048
+	maxKMZWidth        = 4096
+)
+
+// kmlOwnParams are consumed by the KML handler and not forwarded to GetMap
+var kmlOwnParams = map[string]bool{
+	"SERVICE": true, "VERSION": true, "REQUEST": true, "SRS": true, "CRS": true,
+	"BBOX": true, "WIDTH": true, "HEIGHT": true, "LEVEL": true, "MAXLEVEL": true,
+}
+
+// KMLHandler serves Google Earth super-overlays and KMZ overlays rendered
+// through the WMS GetMap path
+type KMLHandler struct {
+	wms         *WMSHandler
+	logger      *slog.Logger
+	wmsPath     string
+	transformer *transform.CoordinateTransformer
+}
+
+// NewKMLHandler creates a KML handler whose overlays call GetMap on wmsPath
+func NewKMLHandler(wmsHandler *WMSHandler, logger *slog.Logger, wmsPath string) *KMLHandler {
+	return &KMLHandler{
+		wms:         wmsHandler,
+		logger:      logger,
+		wmsPath:     wmsPath,
+		transformer: transform.NewCoordinateTransformer(),
+	}
+}
+
+// ServeHTTP handles /kml requests, returning a tile of the super-overlay
+func (h *KMLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params, bbox, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+	h.handleSuperOverlay(w, r, params, bbox)
+}
+
+// ServeKMZ handles /kmz requests, returning a single overlay
+func (h *KMLHandler) ServeKMZ(w http.ResponseWriter, r *http.Request) {
+	params, bbox, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+	h.handleKMZ(w, r, params, bbox)
+}
+
+// parseRequest reads the layers and bounding box of a request, writing an
+// error response when they are invalid
+func (h *KMLHandler) parseRequest(w http.ResponseWriter, r *http.Request) (url.Values, kml.BBox, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return nil, kml.BBox{}, false
+	}
+
+	params := translator.NormalizeWFSParams(r.URL.Query())
+	if params.Get("LAYERS") == "" {
+		http.Error(w, "Missing required parameter: LAYERS", http.StatusBadRequest)
+		return nil, kml.BBox{}, false
+	}
+
+	bbox, err := h.resolveBBox(r, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, kml.BBox{}, false
+	}
+	return params, bbox, true
+}
+
+// handleSuperOverlay returns one tile of the Region-based super-overlay hierarchy
+func (h *KMLHandler) handleSuperOverlay(w http.ResponseWriter, r *http.Request, params url.Values, bbox kml.BBox) {
+	level, err := intParam(params, "LEVEL", 0, maxKMLLevel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxLevel, err := intParam(params, "MAXLEVEL", defaultKMLMaxLevel, maxKMLLevel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	forwarded := forwardedParams(params)
+	baseURL := baseRequestURL(r)
+
+	getMap := url.Values{}
+	for key, values := range forwarded {
+		getMap[key] = values
+	}
+	getMap.Set("SERVICE", "WMS")
+	getMap.Set("VERSION", "1.1.1")
+	getMap.Set("REQUEST", "GetMap")
+	getMap.Set("SRS", "EPSG:4326")
+	getMap.Set("BBOX", bbox.String())
+	getMap.Set("WIDTH", strconv.Itoa(kmlTileSize))
+	getMap.Set("HEIGHT", strconv.Itoa(kmlTileSize))
+	if getMap.Get("FORMAT") == "" {
+		getMap.Set("FORMAT", "image/png")
+	}
+	if getMap.Get("TRANSPARENT") == "" {
+		getMap.Set("TRANSPARENT", "true")
+	}
+
+	tile := kml.SuperOverlayTile{
+		Name:      fmt.Sprintf("Level %d", level),
+		BBox:      bbox,
+		Level:     level,
+		ImageHref: baseURL + h.wmsPath + "?" + getMap.Encode(),
+	}
+
+	if level < maxLevel {
+		for _, quadrant := range bbox.Quadrants() {
+			child := url.Values{}
+			for key, values := range forwarded {
+				child[key] = values
+			}
+			child.Set("BBOX", quadrant.String())
+			child.Set("LEVEL", strconv.Itoa(level+1))
+			child.Set("MAXLEVEL", strconv.Itoa(maxLevel))
+			tile.Children = append(tile.Children, baseURL+r.URL.Path+"?"+child.Encode())
+		}
+	}
+
+	document, err := kml.GenerateSuperOverlay(tile)
+	if err != nil {
+		h.logger.Error("Failed to generate KML", "error", err)
+		http.Error(w, "Failed to generate KML", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", kml.ContentTypeKML)
+	w.WriteHeader(http.StatusOK)
+	w.Write(document)
+}
+
+// handleKMZ renders a single overlay image through GetMap and bundles it with its KML
+func (h *KMLHandler) handleKMZ(w http.ResponseWriter, r *http.Request, params url.Values, bbox kml.BBox) {
+	width, err := intParam(params, "WIDTH", defaultKMZWidth, maxKMZWidth)
+	if err != nil || width == 0 {
+		http.Error(w, "WIDTH must be between 1 and "+strconv.Itoa(maxKMZWidth), http.StatusBadRequest)
+		return
+	}
+	// Keep ground distances square at the centre latitude
+	midLat := (bbox.South + bbox.North) / 2 * math.Pi / 180
+	height := int(math.Round(float64(width) * (bbox.North - bbox.South) / ((bbox.East - bbox.West) * math.Cos(midLat))))
+	if height < 1 {
+		height = 1
+	}
+	if height > maxKMZWidth {
+		height = maxKMZWidth
+	}
+
+	getMap := forwardedParams(params)
+	getMap.Set("SERVICE", "WMS")
+	getMap.Set("VERSION", "1.1.1")
+	getMap.Set("REQUEST", "GetMap")
+	getMap.Set("SRS", "EPSG:4326")
+	getMap.Set("BBOX", bbox.String())
+	getMap.Set("WIDTH", strconv.Itoa(width))
+	getMap.Set("HEIGHT", strconv.Itoa(height))
+	if getMap.Get("FORMAT") == "" {
+		getMap.Set("FORMAT", "image/png")
+	}
+
+	wmsParams, err := wms.ParseWMSParams(getMap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Render through the regular GetMap path so filters, dimensions and image processing apply
+	rendered := newBufferedResponse()
+	h.wms.handleGetMap(rendered, r, wmsParams)
+	contentType := rendered.Header().Get("Content-Type")
+	if rendered.status != http.StatusOK || !strings.HasPrefix(contentType, "image/") {
+		h.logger.Error("KMZ overlay rendering failed", "status", rendered.status, "content_type", contentType)
+		http.Error(w, "Failed to render overlay image", http.StatusBadGateway)
+		return
+	}
+
+	imageName := "overlay.png"
+	if strings.Contains(contentType, "jpeg") {
+		imageName = "overlay.jpg"
+	} else if strings.Contains(contentType, "gif") {
+		imageName = "overlay.gif"
+	}
+
+	document, err := kml.GenerateOverlay("Layers "+params.Get("LAYERS"), bbox, imageName)
+	if err != nil {
+		h.logger.Error("Failed to generate KML", "error", err)
+		http.Error(w, "Failed to generate KML", http.StatusInternalServerError)
+		return
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{"doc.kml", document}, {imageName, rendered.body.Bytes()}} {
+		fw, err := zw.Create(file.name)
+		if err == nil {
+			_, err = fw.Write(file.data)
+		}
+		if err != nil {
+			h.logger.Error("Failed to build KMZ", "error", err)
+			http.Error(w, "Failed to build KMZ", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		h.logger.Error("Failed to build KMZ", "error", err)
+		http.Error(w, "Failed to build KMZ", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", kml.ContentTypeKMZ)
+	w.Header().Set("Content-Disposition", `attachment; filename="overlay.kmz"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive.Bytes())
+}
+
+// resolveBBox returns the requested EPSG:4326 BBOX or the extent of the first requested layer
+func (h *KMLHandler) resolveBBox(r *http.Request, params url.Values) (kml.BBox, error) {
+	if value := params.Get("BBOX"); value != "" {
+		parts := strings.Split(value, ",")
+		if len(parts) != 4 {
+			return kml.BBox{}, fmt.Errorf("invalid BBOX format, expected west,south,east,north")
+		}
+		var coords [4]float64
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return kml.BBox{}, fmt.Errorf("invalid BBOX coordinate: %s", part)
+			}
+			coords[i] = v
+		}
+		bbox := kml.BBox{West: coords[0], South: coords[1], East: coords[2], North: coords[3]}
+		if bbox.West >= bbox.East || bbox.South >= bbox.North || bbox.West < -180 || bbox.East > 180 || bbox.South < -90 || bbox.North > 90 {
+			return kml.BBox{}, fmt.Errorf("invalid BBOX: expected west,south,east,north in degrees")
+		}
+		return bbox, nil
+	}
+
+	layerID := strings.TrimSpace(strings.Split(params.Get("LAYERS"), ",")[0])
+	metadata, err := h.wms.schemas.GetLayerMetadata(r.Context(), h.wms.servicePath, layerID)
+	if err != nil || metadata.Extent == nil {
+		return kml.BBox{}, fmt.Errorf("BBOX is required when the layer extent is unavailable")
+	}
+
+	extent := metadata.Extent
+	wkid := extent.SpatialReference.LatestWKID
+	if wkid == 0 {
+		wkid = extent.SpatialReference.WKID
+	}
+	wgs84, err := h.transformer.TransformEnvelope(
+		transform.BBox{MinX: extent.XMin, MinY: extent.YMin, MaxX: extent.XMax, MaxY: extent.YMax},
+		"EPSG:"+strconv.Itoa(wkid), "EPSG:4326")
+	if err != nil {
+		return kml.BBox{}, fmt.Errorf("BBOX is required: %w", err)
+	}
+	return kml.BBox{West: wgs84.MinX, South: wgs84.MinY, East: wgs84.MaxX, North: wgs84.MaxY}, nil
+}
+
+// forwardedParams returns the request parameters passed on to GetMap (LAYERS, FORMAT, CQL_FILTER, TIME, ...)
+func forwardedParams(params url.Values) url.Values {
+	forwarded := url.Values{}
+	for key, values := range params {
+		if !kmlOwnParams[key] {
+			forwarded[key] = values
+		}
+	}
+	return forwarded
+}
+
+// intParam parses an optional integer parameter between 0 and max
+func intParam(params url.Values, key string, defaultValue, max int) (int, error) {
+	value := params.Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("%s must be between 0 and %d", key, max)
+	}
+	return n, nil
+}
+
+// bufferedResponse captures a handler's response in memory
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// reset discards everything written so far
+func (b *bufferedResponse) reset() {
+	b.header = make(http.Header)
+	b.status = http.StatusOK
+	b.body.Reset()
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"wms-proxy/internal/services"
+	"wms-proxy/pkg/kml"
+)
+
+func TestKMLHandler_SuperOverlay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	// Layer metadata is unavailable, so requests without BBOX cannot resolve an extent
+	mockClient := &mockArcGISClient{err: errors.New("upstream unavailable")}
+	wmsHandler := NewWMSHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis/rest/services/test/MapServer", 85)
+	handler := NewKMLHandler(wmsHandler, logger, "/wms")
+
+	tests := []struct {
+		name             string
+		requestURL       string
+		expectedStatus   int
+		expectedChildren int
+	}{
+		{"root tile", "/kml?LAYERS=0&BBOX=-10,40,-8,42&MAXLEVEL=2", 200, 4},
+		{"deepest tile", "/kml?LAYERS=0&BBOX=-10,40,-8,42&LEVEL=2&MAXLEVEL=2", 200, 0},
+		{"missing layers", "/kml?BBOX=-10,40,-8,42", 400, 0},
+		{"inverted bbox", "/kml?LAYERS=0&BBOX=-8,40,-10,42", 400, 0},
+		{"bbox outside world", "/kml?LAYERS=0&BBOX=-190,40,-8,42", 400, 0},
+		{"no bbox without layer extent", "/kml?LAYERS=0", 400, 0},
+		{"level out of range", "/kml?LAYERS=0&BBOX=-10,40,-8,42&LEVEL=99", 400, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://proxy.local"+test.requestURL, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedStatus != 200 {
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != kml.ContentTypeKML {
+				t.Errorf("expected content type %s, got %s", kml.ContentTypeKML, ct)
+			}
+
+			var document kml.KML
+			if err := xml.Unmarshal(w.Body.Bytes(), &document); err != nil {
+				t.Fatalf("failed to parse KML: %v", err)
+			}
+			if len(document.Document.NetworkLinks) != test.expectedChildren {
+				t.Errorf("expected %d network links, got %d", test.expectedChildren, len(document.Document.NetworkLinks))
+			}
+			if len(document.Document.GroundOverlays) != 1 {
+				t.Fatalf("expected 1 ground overlay, got %d", len(document.Document.GroundOverlays))
+			}
+
+			href, err := url.Parse(document.Document.GroundOverlays[0].Icon.Href)
+			if err != nil {
+				t.Fatalf("invalid overlay href: %v", err)
+			}
+			if href.Host != "proxy.local" || href.Path != "/wms" {
+				t.Errorf("expected overlay to call the proxy's /wms, got %s", href)
+			}
+			query := href.Query()
+			if query.Get("REQUEST") != "GetMap" || query.Get("SRS") != "EPSG:4326" || query.Get("LAYERS") != "0" {
+				t.Errorf("unexpected GetMap parameters: %s", href.RawQuery)
+			}
+		})
+	}
+}
+
+func TestKMLHandler_ChildLinks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	mockClient := &mockArcGISClient{}
+	wmsHandler := NewWMSHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis/rest/services/test/MapServer", 85)
+	handler := NewKMLHandler(wmsHandler, logger, "/wms")
+
+	req := httptest.NewRequest("GET", "http://proxy.local/kml?LAYERS=0&BBOX=0,0,2,2&CQL_FILTER=STATUS%3D1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var document kml.KML
+	if err := xml.Unmarshal(w.Body.Bytes(), &document); err != nil {
+		t.Fatalf("failed to parse KML: %v", err)
+	}
+
+	expectedBBoxes := []string{"0,1,1,2", "1,1,2,2", "0,0,1,1", "1,0,2,1"}
+	if len(document.Document.NetworkLinks) != len(expectedBBoxes) {
+		t.Fatalf("expected %d network links, got %d", len(expectedBBoxes), len(document.Document.NetworkLinks))
+	}
+	for i, link := range document.Document.NetworkLinks {
+		href, err := url.Parse(link.Link.Href)
+		if err != nil {
+			t.Fatalf("invalid child href: %v", err)
+		}
+		query := href.Query()
+		if query.Get("BBOX") != expectedBBoxes[i] {
+			t.Errorf("child %d: expected BBOX %s, got %s", i, expectedBBoxes[i], query.Get("BBOX"))
+		}
+		if query.Get("LEVEL") != "1" {
+			t.Errorf("child %d: expected LEVEL 1, got %s", i, query.Get("LEVEL"))
+		}
+		if query.Get("CQL_FILTER") != "STATUS=1" {
+			t.Errorf("child %d: expected CQL_FILTER to be forwarded, got %q", i, query.Get("CQL_FILTER"))
+		}
+		if link.Link.ViewRefresh != "onRegion" {
+			t.Errorf("child %d: expected onRegion refresh, got %s", i, link.Link.ViewRefresh)
+		}
+		if !strings.HasPrefix(link.Link.Href, "http://proxy.local/kml?") {
+			t.Errorf("child %d: expected link back to /kml, got %s", i, link.Link.Href)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/geometry"
+	"wms-proxy/internal/services"
+	"wms-proxy/internal/transform"
+	"wms-proxy/internal/translator"
+	"wms-proxy/pkg/ogcapi"
+)
+
+// OGCAPIHandler serves OGC API - Features resources from ArcGIS layer queries
+type OGCAPIHandler struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	baseURL      string
+	servicePath  string
+	pathPrefix   string
+	transformer  *transform.CoordinateTransformer
+	srDetector   *services.BackendSRDetector
+	catalog      *services.LayerCatalog
+	schemas      *services.LayerSchemaService
+	features     *services.FeatureService
+}
+
+// NewOGCAPIHandler creates a new OGC API - Features handler mounted at pathPrefix
+func NewOGCAPIHandler(arcgisClient client.ArcGISClientInterface, srDetector *services.BackendSRDetector, logger *slog.Logger, baseURL, servicePath, pathPrefix string) *OGCAPIHandler {
+	transformer := transform.NewCoordinateTransformer()
+
+	return &OGCAPIHandler{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		baseURL:      baseURL,
+		servicePath:  servicePath,
+		pathPrefix:   strings.TrimSuffix(pathPrefix, "/"),
+		transformer:  transformer,
+		srDetector:   srDetector,
+		catalog:      services.NewLayerCatalog(arcgisClient, logger),
+		schemas:      services.NewLayerSchemaService(arcgisClient, logger, baseURL),
+		features:     services.NewFeatureService(arcgisClient, logger, baseURL, transformer, srDetector),
+	}
+}
+
+// ServeHTTP routes OGC API requests by path
+func (h *OGCAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	h.logger.Info("Incoming OGC API request",
+		"method", r.Method,
+		"url", r.URL.String(),
+		"remote_addr", r.RemoteAddr,
+	)
+
+	if r.Method != http.MethodGet {
+		translator.GenerateOGCAPIError(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if f := r.URL.Query().Get("f"); f != "" && f != "json" {
+		translator.GenerateOGCAPIError(w, "Unsupported format: "+f, http.StatusNotAcceptable)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, h.pathPrefix), "/")
+	var segments []string
+	if path != "" {
+		segments = strings.Split(path, "/")
+	}
+
+	switch {
+	case len(segments) == 0:
+		h.handleLandingPage(w, r)
+	case len(segments) == 1 && segments[0] == "conformance":
+		writeJSON(w, ogcapi.MediaTypeJSON, ogcapi.Conformance{ConformsTo: ogcapi.ConformanceClasses})
+	case len(segments) == 1 && segments[0] == "api":
+		h.handleAPI(w, r)
+	case len(segments) == 1 && segments[0] == "collections":
+		h.handleCollections(w, r)
+	case len(segments) == 2 && segments[0] == "collections":
+		h.handleCollection(w, r, segments[1])
+	case len(segments) == 3 && segments[0] == "collections" && segments[2] == "items":
+		h.handleItems(w, r, segments[1])
+	case len(segments) == 4 && segments[0] == "collections" && segments[2] == "items":
+		h.handleItem(w, r, segments[1], segments[3])
+	default:
+		translator.GenerateOGCAPIError(w, "Resource not found: "+r.URL.Path, http.StatusNotFound)
+	}
+
+	h.logger.Info("Request completed",
+		"duration_ms", time.Since(startTime).Milliseconds(),
+		"path", r.URL.Path,
+	)
+}
+
+// handleLandingPage returns the API root document
+func (h *OGCAPIHandler) handleLandingPage(w http.ResponseWriter, r *http.Request) {
+	root := h.rootURL(r)
+	writeJSON(w, ogcapi.MediaTypeJSON, ogcapi.LandingPage{
+		Title:       "ArcGIS REST to OGC API - Features Proxy",
+		Description: "Feature access to the layers of " + h.servicePath,
+		Links: []ogcapi.Link{
+			{Href: root, Rel: "self", Type: ogcapi.MediaTypeJSON, Title: "This document"},
+			{Href: root + "/api", Rel: "service-desc", Type: ogcapi.MediaTypeOpenAPI, Title: "API definition"},
+			{Href: root + "/conformance", Rel: "conformance", Type: ogcapi.MediaTypeJSON, Title: "Conformance classes"},
+			{Href: root + "/collections", Rel: "data", Type: ogcapi.MediaTypeJSON, Title: "Feature collections"},
+		},
+	})
+}
+
+// handleAPI returns the OpenAPI definition
+func (h *OGCAPIHandler) handleAPI(w http.ResponseWriter, r *http.Request) {
+	document, err := ogcapi.GenerateOpenAPI(h.rootURL(r))
+	if err != nil {
+		h.logger.Error("Failed to generate OpenAPI document", "error", err)
+		translator.GenerateOGCAPIError(w, "Failed to generate API definition", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ogcapi.MediaTypeOpenAPI)
+	w.WriteHeader(http.StatusOK)
+	w.Write(document)
+}
+
+// handleCollections lists the feature layers of the service
+func (h *OGCAPIHandler) handleCollections(w http.ResponseWriter, r *http.Request) {
+	layers, err := h.catalog.GetFeatureLayers(r.Context(), h.servicePath)
+	if err != nil {
+		h.logger.Error("Failed to load layer catalog", "error", err)
+		translator.GenerateOGCAPIError(w, "Failed to load service layers", upstreamErrorStatus(w, err))
+		return
+	}
+
+	root := h.rootURL(r)
+	collections := ogcapi.Collections{
+		Links: []ogcapi.Link{
+			{Href: root + "/collections", Rel: "self", Type: ogcapi.MediaTypeJSON, Title: "This document"},
+		},
+		Collections: make([]ogcapi.Collection, 0, len(layers)),
+		CRS:         ogcapi.SupportedCRS,
+	}
+	for i := range layers {
+		collections.Collections = append(collections.Collections, h.describeCollection(r, &layers[i]))
+	}
+
+	writeJSON(w, ogcapi.MediaTypeJSON, collections)
+}
+
+// handleCollection describes a single feature layer
+func (h *OGCAPIHandler) handleCollection(w http.ResponseWriter, r *http.Request, collectionID string) {
+	layer, err := h.catalog.FindLayer(r.Context(), h.servicePath, collectionID)
+	if err != nil {
+		translator.GenerateOGCAPIError(w, "Collection not found: "+collectionID, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, ogcapi.MediaTypeJSON, h.describeCollection(r, layer))
+}
+
+// describeCollection builds collection metadata, including the layer extent when available
+func (h *OGCAPIHandler) describeCollection(r *http.Request, layer *services.CatalogLayer) ogcapi.Collection {
+	itemsURL := h.rootURL(r) + "/collections/" + layer.Name
+	collection := ogcapi.Collection{
+		ID:       layer.Name,
+		Title:    layer.Title,
+		ItemType: "feature",
+		CRS:      ogcapi.SupportedCRS,
+		Links: []ogcapi.Link{
+			{Href: itemsURL, Rel: "self", Type: ogcapi.MediaTypeJSON, Title: "This collection"},
+			{Href: itemsURL + "/items", Rel: "items", Type: ogcapi.MediaTypeGeoJSON, Title: "Features"},
+		},
+	}
+
+	if backendSR, err := h.srDetector.GetBackendSR(r.Context(), h.servicePath); err == nil {
+		collection.StorageCRS = ogcapi.CRSURI(backendSR)
+	}
+
+	metadata, err := h.schemas.GetLayerMetadata(r.Context(), h.servicePath, strconv.Itoa(layer.ID))
+	if err != nil {
+		h.logger.Warn("Failed to load layer metadata, omitting extent", "error", err, "layer", layer.ID)
+		return collection
+	}
+	collection.Description = metadata.Description
+
+	if extent := metadata.Extent; extent != nil {
+		wkid := extent.SpatialReference.LatestWKID
+		if wkid == 0 {
+			wkid = extent.SpatialReference.WKID
+		}
+		bbox := transform.BBox{MinX: extent.XMin, MinY: extent.YMin, MaxX: extent.XMax, MaxY: extent.YMax}
+		if wgs84, err := h.transformer.TransformEnvelope(bbox, "EPSG:"+strconv.Itoa(wkid), "EPSG:4326"); err == nil {
+			collection.Extent = &ogcapi.Extent{Spatial: ogcapi.SpatialExtent{
+				BBox: [][]float64{{wgs84.MinX, wgs84.MinY, wgs84.MaxX, wgs84.MaxY}},
+				CRS:  ogcapi.CRS84,
+			}}
+		}
+	}
+
+	return collection
+}
+
+// handleItems returns a page of features of a collection
+func (h *OGCAPIHandler) handleItems(w http.ResponseWriter, r *http.Request, collectionID string) {
+	layer, err := h.catalog.FindLayer(r.Context(), h.servicePath, collectionID)
+	if err != nil {
+		translator.GenerateOGCAPIError(w, "Collection not found: "+collectionID, http.StatusNotFound)
+		return
+	}
+
+	params, err := translator.ParseOGCAPIItemsParams(r.URL.Query())
+	if err != nil {
+		translator.GenerateOGCAPIError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.features.Query(r.Context(), h.servicePath, &services.FeatureQuery{
+		LayerID: strconv.Itoa(layer.ID),
+		BBox:    params.BBox,
+		BBoxCRS: params.BBoxCRS,
+		Offset:  params.Offset,
+		Limit:   params.Limit,
+		OutCRS:  params.OutCRS,
+	})
+	if err != nil {
+		h.logger.Error("Feature query failed", "error", err, "layer", layer.ID)
+		translator.GenerateOGCAPIError(w, "Upstream feature query failed", upstreamErrorStatus(w, err))
+		return
+	}
+	if params.SwapAxes {
+		swapFeatureAxes(result.Features)
+	}
+
+	itemsURL := h.rootURL(r) + "/collections/" + layer.Name + "/items"
+	links := []translator.GeoJSONLink{
+		{Href: pageURL(itemsURL, r, params.Offset), Rel: "self", Type: ogcapi.MediaTypeGeoJSON, Title: "This page"},
+		{Href: h.rootURL(r) + "/collections/" + layer.Name, Rel: "collection", Type: ogcapi.MediaTypeJSON, Title: "The collection"},
+	}
+	returned := len(result.Features)
+	if returned > 0 && (result.ExceededTransferLimit || returned == params.Limit) {
+		links = append(links, translator.GeoJSONLink{Href: pageURL(itemsURL, r, params.Offset+returned), Rel: "next", Type: ogcapi.MediaTypeGeoJSON, Title: "Next page"})
+	}
+	if params.Offset > 0 {
+		prev := params.Offset - params.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, translator.GeoJSONLink{Href: pageURL(itemsURL, r, prev), Rel: "prev", Type: ogcapi.MediaTypeGeoJSON, Title: "Previous page"})
+	}
+
+	data, err := translator.EncodeGeoJSONFeatureCollection(result.Features, nil, links)
+	if err != nil {
+		h.logger.Error("Failed to encode features", "error", err)
+		translator.GenerateOGCAPIError(w, "Failed to encode features", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ogcapi.MediaTypeGeoJSON)
+	w.Header().Set("Content-Crs", "<"+params.OutCRSURI+">")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// handleItem returns a single feature by object ID
+func (h *OGCAPIHandler) handleItem(w http.ResponseWriter, r *http.Request, collectionID, featureID string) {
+	layer, err := h.catalog.FindLayer(r.Context(), h.servicePath, collectionID)
+	if err != nil {
+		translator.GenerateOGCAPIError(w, "Collection not found: "+collectionID, http.StatusNotFound)
+		return
+	}
+	if _, err := strconv.ParseInt(featureID, 10, 64); err != nil {
+		translator.GenerateOGCAPIError(w, "Feature not found: "+featureID, http.StatusNotFound)
+		return
+	}
+
+	crsURI := r.URL.Query().Get("crs")
+	if crsURI == "" {
+		crsURI = ogcapi.CRS84
+	}
+	outCRS, swapAxes, err := translator.ParseOGCAPICRS(crsURI)
+	if err != nil {
+		translator.GenerateOGCAPIError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.features.Query(r.Context(), h.servicePath, &services.FeatureQuery{
+		LayerID:   strconv.Itoa(layer.ID),
+		ObjectIDs: []string{featureID},
+		OutCRS:    outCRS,
+	})
+	if err != nil {
+		h.logger.Error("Feature query failed", "error", err, "layer", layer.ID)
+		translator.GenerateOGCAPIError(w, "Upstream feature query failed", upstreamErrorStatus(w, err))
+		return
+	}
+	if len(result.Features) == 0 {
+		translator.GenerateOGCAPIError(w, "Feature not found: "+featureID, http.StatusNotFound)
+		return
+	}
+	if swapAxes {
+		swapFeatureAxes(result.Features)
+	}
+
+	collectionURL := h.rootURL(r) + "/collections/" + layer.Name
+	feature := struct {
+		geometry.GeoJSONFeature
+		Links []translator.GeoJSONLink `json:"links"`
+	}{
+		GeoJSONFeature: result.Features[0].ToGeoJSON(),
+		Links: []translator.GeoJSONLink{
+			{Href: collectionURL + "/items/" + featureID, Rel: "self", Type: ogcapi.MediaTypeGeoJSON},
+			{Href: collectionURL, Rel: "collection", Type: ogcapi.MediaTypeJSON},
+		},
+	}
+
+	w.Header().Set("Content-Crs", "<"+crsURI+">")
+	writeJSON(w, ogcapi.MediaTypeGeoJSON, feature)
+}
+
+// rootURL returns the absolute URL of the API landing page
+func (h *OGCAPIHandler) rootURL(r *http.Request) string {
+	return baseRequestURL(r) + h.pathPrefix
+}
+
+// pageURL returns the items URL with the request's parameters and the given offset
+func pageURL(itemsURL string, r *http.Request, offset int) string {
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	return itemsURL + "?" + query.Encode()
+}
+
+// swapFeatureAxes switches coordinates to latitude/longitude order
+func swapFeatureAxes(features []geometry.Feature) {
+	for i := range features {
+		if features[i].Geometry != nil {
+			features[i].Geometry.Transform(func(x, y float64) (float64, float64, error) {
+				return y, x, nil
+			})
+		}
+	}
+}
+
+// writeJSON writes a JSON document with the given content type
+func writeJSON(w http.ResponseWriter, contentType string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		translator.GenerateOGCAPIError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
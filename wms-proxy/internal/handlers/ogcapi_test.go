@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
+	"wms-proxy/pkg/ogcapi"
+)
+
+func TestOGCAPIHandler_Routing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	mockClient := &mockArcGISClient{}
+	handler := NewOGCAPIHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis/rest/services/test/MapServer", "/ogcapi")
+
+	tests := []struct {
+		name                string
+		requestURL          string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{"landing page", "/ogcapi", 200, ogcapi.MediaTypeJSON},
+		{"landing page with slash", "/ogcapi/", 200, ogcapi.MediaTypeJSON},
+		{"conformance", "/ogcapi/conformance", 200, ogcapi.MediaTypeJSON},
+		{"api definition", "/ogcapi/api", 200, ogcapi.MediaTypeOpenAPI},
+		{"collections", "/ogcapi/collections", 200, ogcapi.MediaTypeJSON},
+		{"unknown collection", "/ogcapi/collections/Nope", 404, ogcapi.MediaTypeJSON},
+		{"unknown collection items", "/ogcapi/collections/Nope/items", 404, ogcapi.MediaTypeJSON},
+		{"unknown resource", "/ogcapi/tiles", 404, ogcapi.MediaTypeJSON},
+		{"unsupported format", "/ogcapi?f=html", 406, ogcapi.MediaTypeJSON},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.requestURL, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != test.expectedContentType {
+				t.Errorf("expected content type %s, got %s", test.expectedContentType, ct)
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("expected JSON body, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOGCAPIHandler_LandingPageLinks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	mockClient := &mockArcGISClient{}
+	handler := NewOGCAPIHandler(mockClient, services.NewBackendSRDetector(mockClient, logger), logger, "https://example.com", "/arcgis/rest/services/test/MapServer", "/ogcapi")
+
+	req := httptest.NewRequest("GET", "http://proxy.local/ogcapi", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var page ogcapi.LandingPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("invalid landing page: %v", err)
+	}
+
+	rels := make(map[string]string)
+	for _, link := range page.Links {
+		rels[link.Rel] = link.Href
+	}
+	for _, rel := range []string{"self", "service-desc", "conformance", "data"} {
+		if !strings.HasPrefix(rels[rel], "http://proxy.local/ogcapi") {
+			t.Errorf("expected %s link under the proxy URL, got %q", rel, rels[rel])
+		}
+	}
+}
+
+func newTestOGCAPIHandler(t *testing.T) (*OGCAPIHandler, *featureUpstream) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	upstream := newFeatureUpstream(t)
+	arcgisClient := client.NewArcGISClient(upstream.server.URL, 5*time.Second)
+	handler := NewOGCAPIHandler(arcgisClient, services.NewBackendSRDetector(arcgisClient, logger), logger, upstream.server.URL, featureServicePath, "/ogcapi")
+	return handler, upstream
+}
+
+// itemsResponse is the part of an items response the tests inspect
+type itemsResponse struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+	Links []struct {
+		Href string `json:"href"`
+		Rel  string `json:"rel"`
+	} `json:"links"`
+}
+
+// offsetOf returns the offset parameter of the link with the given rel, or
+// "" when the response has no such link
+func (r itemsResponse) offsetOf(t *testing.T, rel string) string {
+	t.Helper()
+	for _, link := range r.Links {
+		if link.Rel == rel {
+			u, err := url.Parse(link.Href)
+			if err != nil {
+				t.Fatalf("invalid %s link %q: %v", rel, link.Href, err)
+			}
+			return u.Query().Get("offset")
+		}
+	}
+	return ""
+}
+
+func TestOGCAPIHandler_ItemsPaging(t *testing.T) {
+	handler, upstream := newTestOGCAPIHandler(t)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedCount  int
+		expectedOffset string
+		expectedNext   string
+		expectedPrev   string
+	}{
+		{"first page", "limit=2", 2, "", "2", ""},
+		{"last page", "limit=2&offset=2", 1, "2", "", "0"},
+		{"middle page", "limit=1&offset=1", 1, "1", "2", "0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://proxy.local/ogcapi/collections/Parcels/items?"+test.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != 200 {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ogcapi.MediaTypeGeoJSON {
+				t.Errorf("expected content type %s, got %s", ogcapi.MediaTypeGeoJSON, ct)
+			}
+
+			var items itemsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+				t.Fatalf("invalid items response: %v", err)
+			}
+			if len(items.Features) != test.expectedCount {
+				t.Errorf("expected %d features, got %d", test.expectedCount, len(items.Features))
+			}
+			if next := items.offsetOf(t, "next"); next != test.expectedNext {
+				t.Errorf("expected next offset %q, got %q", test.expectedNext, next)
+			}
+			if prev := items.offsetOf(t, "prev"); prev != test.expectedPrev {
+				t.Errorf("expected prev offset %q, got %q", test.expectedPrev, prev)
+			}
+			for _, link := range items.Links {
+				if link.Rel == "self" && !strings.HasPrefix(link.Href, "http://proxy.local/ogcapi/collections/Parcels/items") {
+					t.Errorf("expected self link under the proxy URL, got %q", link.Href)
+				}
+			}
+			if offset := upstream.lastQuery(t).Get("resultOffset"); offset != test.expectedOffset {
+				t.Errorf("expected upstream resultOffset %q, got %q", test.expectedOffset, offset)
+			}
+		})
+	}
+}
+
+func TestOGCAPIHandler_ItemsBBox(t *testing.T) {
+	handler, upstream := newTestOGCAPIHandler(t)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       string
+	}{
+		{"CRS84 bbox", "bbox=-75,40,-74,41", 200, "-75.000000,40.000000,-74.000000,41.000000"},
+		{"EPSG:4326 bbox is lat/lon", "bbox=40,-75,41,-74&bbox-crs=" + url.QueryEscape("http://www.opengis.net/def/crs/EPSG/0/4326"), 200, "-75.000000,40.000000,-74.000000,41.000000"},
+		{"too few coordinates", "bbox=-75,40,-74", 400, ""},
+		{"inverted bbox", "bbox=-74,40,-75,41", 400, ""},
+		{"bbox-crs without bbox", "bbox-crs=" + url.QueryEscape(ogcapi.CRS84), 400, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ogcapi/collections/Parcels/items?"+test.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedStatus != 200 {
+				return
+			}
+			if got := upstream.lastQuery(t).Get("geometry"); got != test.expected {
+				t.Errorf("expected query envelope %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestOGCAPIHandler_ItemsCRS(t *testing.T) {
+	handler, _ := newTestOGCAPIHandler(t)
+
+	tests := []struct {
+		name           string
+		crs            string
+		expectedStatus int
+		expectedCRS    string
+		expected       []float64
+	}{
+		{"default CRS84", "", 200, ogcapi.CRS84, []float64{-74.5, 40.2}},
+		{"EPSG:4326 is lat/lon", "http://www.opengis.net/def/crs/EPSG/0/4326", 200, "http://www.opengis.net/def/crs/EPSG/0/4326", []float64{40.2, -74.5}},
+		{"unsupported CRS", "urn:example:crs", 400, "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := "/ogcapi/collections/Parcels/items?limit=1"
+			if test.crs != "" {
+				target += "&crs=" + url.QueryEscape(test.crs)
+			}
+			req := httptest.NewRequest("GET", target, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedStatus != 200 {
+				return
+			}
+			if got := w.Header().Get("Content-Crs"); got != "<"+test.expectedCRS+">" {
+				t.Errorf("expected Content-Crs <%s>, got %s", test.expectedCRS, got)
+			}
+
+			var items itemsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+				t.Fatalf("invalid items response: %v", err)
+			}
+			if len(items.Features) != 1 {
+				t.Fatalf("expected 1 feature, got %d", len(items.Features))
+			}
+			coords := items.Features[0].Geometry.Coordinates
+			if len(coords) != 2 || coords[0] != test.expected[0] || coords[1] != test.expected[1] {
+				t.Errorf("expected coordinates %v, got %v", test.expected, coords)
+			}
+		})
+	}
+}
+
+func TestOGCAPIHandler_Item(t *testing.T) {
+	handler, upstream := newTestOGCAPIHandler(t)
+
+	tests := []struct {
+		name           string
+		featureID      string
+		expectedStatus int
+	}{
+		{"existing feature", "2", 200},
+		{"missing feature", "9", 404},
+		{"non-numeric feature ID", "abc", 404},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ogcapi/collections/Parcels/items/"+test.featureID, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("expected JSON body, got %s", w.Body.String())
+			}
+			if test.expectedStatus != 200 {
+				return
+			}
+			if ids := upstream.lastQuery(t).Get("objectIds"); ids != test.featureID {
+				t.Errorf("expected objectIds %s, got %s", test.featureID, ids)
+			}
+
+			var feature struct {
+				ID interface{} `json:"id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &feature); err != nil {
+				t.Fatalf("invalid feature: %v", err)
+			}
+			if fmt.Sprint(feature.ID) != test.featureID {
+				t.Errorf("expected feature %s, got %v", test.featureID, feature.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"wms-proxy/internal/rewrite"
+)
+
+// baseRequestURL returns the external base URL of the proxy (scheme, host and
+// path prefix) as seen by the client. Behind a reverse proxy it is taken from
+// X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix.
+func baseRequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := forwardedValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	host := r.Host
+	if forwardedHost := forwardedValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+
+	prefix := strings.TrimSuffix(forwardedValue(r, "X-Forwarded-Prefix"), "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+
+	return scheme + "://" + host + prefix
+}
+
+// forwardedValue returns the first value of a forwarding header; proxies
+// chained in front of each other append their values
+func forwardedValue(r *http.Request, header string) string {
+	value, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(value)
+}
+
+// isRewritableType reports whether a passthrough response is text that may
+// contain backend URLs: JSON (ArcGIS often labels it text/plain), JSONP and HTML
+func isRewritableType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "application/json", "text/plain", "text/html", "application/javascript", "text/javascript":
+		return true
+	}
+	return false
+}
+
+// rewriteBackendURLs replaces the backend's ArcGIS URLs in body with the
+// proxy's: absolute URLs on any backend origin (over http or https) and
+// quoted root-relative /arcgis/ paths. publicURL is the proxy's base URL and
+// pathPrefix where the backend's /arcgis tree is mounted (e.g. /arcgis/parcels).
+func rewriteBackendURLs(body io.ReadCloser, origins []string, publicURL, pathPrefix string) io.ReadCloser {
+	target := publicURL + pathPrefix + "/"
+
+	var oldnew []string
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		oldnew = append(oldnew,
+			"https://"+u.Host+"/arcgis/", target,
+			"http://"+u.Host+"/arcgis/", target)
+	}
+
+	// Root-relative links (e.g. in the HTML service directory) only change when
+	// the proxy serves the backend under another path
+	var publicPath string
+	if u, err := url.Parse(publicURL); err == nil {
+		publicPath = u.Path
+	}
+	if relative := publicPath + pathPrefix + "/"; relative != "/arcgis/" {
+		oldnew = append(oldnew,
+			`"/arcgis/`, `"`+relative,
+			`'/arcgis/`, `'`+relative)
+	}
+
+	return rewrite.NewReader(body, oldnew...)
+}
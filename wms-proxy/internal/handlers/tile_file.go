@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/tilefile"
+)
+
+// TileFileHandler serves read-only tile layers from MBTiles and GeoPackage
+// files: /{name} returns the layer's TileJSON and /{name}/{z}/{x}/{y}.{ext}
+// its XYZ tiles
+type TileFileHandler struct {
+	files       map[string]*tilefile.Reader
+	logger      *slog.Logger
+	pathPrefix  string
+	cachePolicy *CachePolicy
+}
+
+// NewTileFileHandler creates a tile file handler mounted at pathPrefix serving
+// files by layer name
+func NewTileFileHandler(files map[string]*tilefile.Reader, logger *slog.Logger, pathPrefix string) *TileFileHandler {
+	return &TileFileHandler{
+		files:      files,
+		logger:     logger,
+		pathPrefix: strings.TrimSuffix(pathPrefix, "/"),
+	}
+}
+
+// SetCachePolicy sets the Cache-Control policy of tiles
+func (h *TileFileHandler) SetCachePolicy(policy *CachePolicy) {
+	h.cachePolicy = policy
+}
+
+// ServeHTTP handles TileJSON and tile requests
+func (h *TileFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, h.pathPrefix), "/"), "/")
+	file, ok := h.files[segments[0]]
+	if !ok {
+		http.Error(w, "Tile layer not found", http.StatusNotFound)
+		return
+	}
+	switch len(segments) {
+	case 1:
+		h.handleTileJSON(w, r, segments[0], file)
+	case 4:
+		h.handleTile(w, r, segments[0], file, segments[1:])
+	default:
+		http.Error(w, "expected /{name} or /{name}/{z}/{x}/{y}.{ext}", http.StatusNotFound)
+	}
+}
+
+// handleTileJSON describes a tile layer in TileJSON 3.0
+func (h *TileFileHandler) handleTileJSON(w http.ResponseWriter, r *http.Request, name string, file *tilefile.Reader) {
+	metadata := file.Metadata()
+	tileJSON := map[string]interface{}{
+		"tilejson":    "3.0.0",
+		"name":        metadata.Name,
+		"description": metadata.Description,
+		"attribution": metadata.Attribution,
+		"scheme":      "xyz",
+		"format":      metadata.Format,
+		"tiles":       []string{fmt.Sprintf("%s%s/%s/{z}/{x}/{y}.%s", baseRequestURL(r), h.pathPrefix, name, metadata.Format)},
+		"minzoom":     metadata.MinZoom,
+		"maxzoom":     metadata.MaxZoom,
+		"bounds":      metadata.Bounds,
+	}
+	if metadata.Format == tilefile.TilePBF {
+		layers := make([]map[string]interface{}, len(metadata.VectorLayers))
+		for i, layer := range metadata.VectorLayers {
+			layers[i] = map[string]interface{}{"id": layer, "fields": map[string]string{}}
+		}
+		tileJSON["vector_layers"] = layers
+	}
+
+	body, err := json.Marshal(tileJSON)
+	if err != nil {
+		http.Error(w, "Failed to encode TileJSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if cacheControl := h.cachePolicy.For(EndpointTiles, []string{name}, "max-age=3600"); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	serveConditional(w, r, body)
+}
+
+// handleTile serves the tile at "{z}/{x}/{y}.{ext}"; vector tiles are sent
+// gzip compressed to clients that accept it
+func (h *TileFileHandler) handleTile(w http.ResponseWriter, r *http.Request, name string, file *tilefile.Reader, coordinates []string) {
+	metadata := file.Metadata()
+	last, ext, _ := strings.Cut(coordinates[2], ".")
+	if ext != metadata.Format && !(ext == "mvt" && metadata.Format == tilefile.TilePBF) && !(ext == "jpeg" && metadata.Format == tilefile.TileJPEG) {
+		http.Error(w, fmt.Sprintf("tiles of %s are %s", name, metadata.Format), http.StatusNotFound)
+		return
+	}
+	var coords [3]int
+	for i, s := range []string{coordinates[0], coordinates[1], last} {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "invalid tile coordinate: "+s, http.StatusNotFound)
+			return
+		}
+		coords[i] = n
+	}
+
+	tile, found, err := file.Tile(coords[0], coords[1], coords[2])
+	if err != nil {
+		h.logger.Error("Failed to read tile file", "error", err, "layer", name, "tile", fmt.Sprintf("%d/%d/%d", coords[0], coords[1], coords[2]))
+		http.Error(w, "Failed to read tile", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Tile not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", tilefile.ContentType(metadata.Format))
+	if metadata.Format == tilefile.TilePBF {
+		w.Header().Set("Vary", "Accept-Encoding")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") && bytes.HasPrefix(tile, []byte("\x1f\x8b")) {
+			w.Header().Set("Content-Encoding", "gzip")
+		} else if tile, err = gunzip(tile); err != nil {
+			h.logger.Error("Failed to decompress vector tile", "error", err, "layer", name)
+			http.Error(w, "Failed to read tile", http.StatusInternalServerError)
+			return
+		}
+	}
+	if cacheControl := h.cachePolicy.For(EndpointTiles, []string{name}, "max-age=3600"); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	serveConditional(w, r, tile)
+}
+
+// gunzip decompresses gzip data; other data is returned as is
+func gunzip(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("\x1f\x8b")) {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
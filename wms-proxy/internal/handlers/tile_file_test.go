@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wms-proxy/internal/tilefile"
+)
+
+func TestTileFileHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	png := []byte("\x89PNG\r\n\x1a\ntile")
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("mvt"))
+	gz.Close()
+
+	files := make(map[string]*tilefile.Reader)
+	for name, file := range map[string]struct {
+		path     string
+		metadata tilefile.Metadata
+		tile     []byte
+	}{
+		"basemap": {"basemap.gpkg", tilefile.Metadata{Name: "Basemap", Format: tilefile.TilePNG, Bounds: [4]float64{-75, 39, -74, 40}}, png},
+		"parcels": {"parcels.mbtiles", tilefile.Metadata{Name: "Parcels", Format: tilefile.TilePBF, VectorLayers: []string{"parcels"}}, compressed.Bytes()},
+	} {
+		path := filepath.Join(t.TempDir(), file.path)
+		packageFormat, _ := tilefile.FormatFromPath(path)
+		writer, err := tilefile.Create(path, packageFormat, file.metadata)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		writer.WriteTile(10, 301, 385, file.tile)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		reader, err := tilefile.Open(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer reader.Close()
+		files[name] = reader
+	}
+	handler := NewTileFileHandler(files, logger, "/tiles")
+
+	tests := []struct {
+		name            string
+		path            string
+		acceptEncoding  string
+		expectedStatus  int
+		expectedType    string
+		expectedEncoded string
+		expectedBody    []byte
+	}{
+		{"raster tile", "/tiles/basemap/10/301/385.png", "", http.StatusOK, "image/png", "", png},
+		{"missing tile", "/tiles/basemap/10/0/0.png", "", http.StatusNotFound, "", "", nil},
+		{"wrong extension", "/tiles/basemap/10/301/385.jpg", "", http.StatusNotFound, "", "", nil},
+		{"unknown layer", "/tiles/roads/10/301/385.png", "", http.StatusNotFound, "", "", nil},
+		{"invalid coordinate", "/tiles/basemap/10/a/385.png", "", http.StatusNotFound, "", "", nil},
+		{"compressed vector tile", "/tiles/parcels/10/301/385.pbf", "gzip, deflate", http.StatusOK, "application/vnd.mapbox-vector-tile", "gzip", compressed.Bytes()},
+		{"decompressed vector tile", "/tiles/parcels/10/301/385.mvt", "", http.StatusOK, "application/vnd.mapbox-vector-tile", "", []byte("mvt")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.path, nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != test.expectedType {
+				t.Errorf("expected Content-Type %s, got %s", test.expectedType, got)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != test.expectedEncoded {
+				t.Errorf("expected Content-Encoding %q, got %q", test.expectedEncoded, got)
+			}
+			if !bytes.Equal(w.Body.Bytes(), test.expectedBody) {
+				t.Errorf("expected body %q, got %q", test.expectedBody, w.Body.Bytes())
+			}
+		})
+	}
+
+	t.Run("TileJSON", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://maps.example.com/tiles/basemap", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var tileJSON struct {
+			Name    string   `json:"name"`
+			Format  string   `json:"format"`
+			Tiles   []string `json:"tiles"`
+			MinZoom int      `json:"minzoom"`
+			MaxZoom int      `json:"maxzoom"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &tileJSON); err != nil {
+			t.Fatalf("invalid TileJSON: %v", err)
+		}
+		if tileJSON.Name != "Basemap" || tileJSON.Format != "png" || tileJSON.MinZoom != 10 || tileJSON.MaxZoom != 10 ||
+			len(tileJSON.Tiles) != 1 || tileJSON.Tiles[0] != "http://maps.example.com/tiles/basemap/{z}/{x}/{y}.png" {
+			t.Errorf("unexpected TileJSON: %s", w.Body.String())
+		}
+	})
+}
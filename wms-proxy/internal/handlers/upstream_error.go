@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wms-proxy/internal/client"
+)
+
+// upstreamErrorStatus returns the status answering a failed upstream request:
+// 503 with a Retry-After header when the backend's bulkhead shed the request,
+// 502 otherwise
+func upstreamErrorStatus(w http.ResponseWriter, err error) int {
+	var overload *client.OverloadError
+	if errors.As(err, &overload) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(overload.RetryAfter.Seconds())))
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/cache"
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/coalesce"
+	"wms-proxy/internal/mvt"
+	"wms-proxy/internal/services"
+	"wms-proxy/internal/transform"
+)
+
+// VectorTileHandler serves Mapbox Vector Tiles built from ArcGIS layer queries
+type VectorTileHandler struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	baseURL      string
+	servicePath  string
+	pathPrefix   string
+	catalog      *services.LayerCatalog
+	features     *services.FeatureService
+	tileCache    *cache.TileCache
+	refreshes    coalesce.Group[struct{}]
+	cachePolicy  *CachePolicy
+}
+
+// NewVectorTileHandler creates a new vector tile handler mounted at pathPrefix
+func NewVectorTileHandler(arcgisClient client.ArcGISClientInterface, srDetector *services.BackendSRDetector, logger *slog.Logger, baseURL, servicePath, pathPrefix string, tileCache *cache.TileCache) *VectorTileHandler {
+	transformer := transform.NewCoordinateTransformer()
+
+	return &VectorTileHandler{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		baseURL:      baseURL,
+		servicePath:  servicePath,
+		pathPrefix:   strings.TrimSuffix(pathPrefix, "/"),
+		catalog:      services.NewLayerCatalog(arcgisClient, logger),
+		features:     services.NewFeatureService(arcgisClient, logger, baseURL, transformer, srDetector),
+		tileCache:    tileCache,
+	}
+}
+
+// SetCachePolicy sets the Cache-Control policy of tiles
+func (h *VectorTileHandler) SetCachePolicy(policy *CachePolicy) {
+	h.cachePolicy = policy
+}
+
+// ServeHTTP handles /{layer}/{z}/{x}/{y}.mvt requests
+func (h *VectorTileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	layerName, z, x, y, err := parseTilePath(strings.TrimPrefix(r.URL.Path, h.pathPrefix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	bounds, err := mvt.TileBounds(z, x, y)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	layer, err := h.catalog.FindLayer(r.Context(), h.servicePath, layerName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("mvt:%s%s:%d/%d/%d/%d", h.baseURL, h.servicePath, layer.ID, z, x, y)
+	entry, freshness, cached := h.tileCache.Lookup(cacheKey)
+	switch {
+	case cached && freshness == cache.Fresh:
+		h.writeTile(w, r, layerName, entry.Body, "HIT", "")
+		return
+	case cached && freshness == cache.StaleRevalidate:
+		// Serve the expired tile and render a fresh one in the background
+		go h.refreshes.Do(context.Background(), cacheKey, func(ctx context.Context) (struct{}, error) {
+			_, _, err := h.render(ctx, cacheKey, layer, z, x, y, bounds)
+			if err != nil {
+				h.logger.Warn("Background vector tile refresh failed", "error", err, "layer", layer.ID, "tile", fmt.Sprintf("%d/%d/%d", z, x, y))
+			}
+			return struct{}{}, err
+		})
+		h.tileCache.RecordStale()
+		h.writeTile(w, r, layerName, entry.Body, "STALE", client.WarningStale)
+		return
+	}
+
+	tile, features, err := h.render(r.Context(), cacheKey, layer, z, x, y, bounds)
+	if err != nil {
+		h.logger.Error("Feature query for vector tile failed", "error", err, "layer", layer.ID, "tile", fmt.Sprintf("%d/%d/%d", z, x, y))
+		if cached && r.Context().Err() == nil {
+			h.tileCache.RecordStale()
+			h.writeTile(w, r, layerName, entry.Body, "STALE", client.WarningRevalidationFailed)
+			return
+		}
+		http.Error(w, "Upstream feature query failed", upstreamErrorStatus(w, err))
+		return
+	}
+	h.writeTile(w, r, layerName, tile, "MISS", "")
+
+	h.logger.Info("Rendered vector tile",
+		"layer", layer.Name,
+		"tile", fmt.Sprintf("%d/%d/%d", z, x, y),
+		"features", features,
+		"bytes", len(tile),
+		"duration_ms", time.Since(startTime).Milliseconds(),
+	)
+}
+
+// render queries the features of a tile, encodes it and stores it in the cache
+func (h *VectorTileHandler) render(ctx context.Context, cacheKey string, layer *services.CatalogLayer, z, x, y int, bounds transform.BBox) ([]byte, int, error) {
+	// Query features intersecting the buffered tile; the feature service
+	// transforms the bounds into the backend SR and the results back to EPSG:3857
+	queryBounds := mvt.BufferedBounds(bounds)
+	result, err := h.features.Query(ctx, h.servicePath, &services.FeatureQuery{
+		LayerID: strconv.Itoa(layer.ID),
+		BBox:    &queryBounds,
+		BBoxCRS: "EPSG:3857",
+		OutCRS:  "EPSG:3857",
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if result.ExceededTransferLimit {
+		h.logger.Warn("Vector tile truncated by the layer's maxRecordCount",
+			"layer", layer.ID,
+			"tile", fmt.Sprintf("%d/%d/%d", z, x, y),
+			"features", len(result.Features))
+	}
+
+	tileLayer := mvt.NewLayer(layer.Name, bounds)
+	for i := range result.Features {
+		tileLayer.AddFeature(&result.Features[i])
+	}
+	tile := mvt.Marshal(tileLayer)
+
+	h.tileCache.Set(cacheKey, tile, mvt.ContentType)
+	return tile, tileLayer.Len(), nil
+}
+
+// writeTile writes an encoded tile of layerName; warning marks tiles served stale
+func (h *VectorTileHandler) writeTile(w http.ResponseWriter, r *http.Request, layerName string, tile []byte, cacheStatus, warning string) {
+	w.Header().Set("Content-Type", mvt.ContentType)
+	if warning != "" {
+		w.Header().Set("Warning", warning)
+	}
+	if cacheControl := h.cachePolicy.For(EndpointTiles, []string{layerName}, fmt.Sprintf("max-age=%d", int(h.tileCache.TTL().Seconds()))); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	w.Header().Set("X-Cache", cacheStatus)
+	serveConditional(w, r, tile)
+}
+
+// parseTilePath parses "/{layer}/{z}/{x}/{y}.mvt"
+func parseTilePath(path string) (string, int, int, int, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != 4 || !strings.HasSuffix(segments[3], ".mvt") {
+		return "", 0, 0, 0, fmt.Errorf("expected /{layer}/{z}/{x}/{y}.mvt")
+	}
+
+	var coords [3]int
+	for i, s := range []string{segments[1], segments[2], strings.TrimSuffix(segments[3], ".mvt")} {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return "", 0, 0, 0, fmt.Errorf("invalid tile coordinate: %s", s)
+		}
+		coords[i] = n
+	}
+	return segments[0], coords[0], coords[1], coords[2], nil
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
+	"wms-proxy/internal/transform"
+	"wms-proxy/internal/translator"
+	"wms-proxy/pkg/wfs"
+)
+
+// WFSHandler serves WFS 2.0 requests from ArcGIS layer queries
+type WFSHandler struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	baseURL      string
+	servicePath  string
+	catalog      *services.LayerCatalog
+	schemas      *services.LayerSchemaService
+	features     *services.FeatureService
+	cachePolicy  *CachePolicy
+}
+
+// NewWFSHandler creates a new WFS handler
+func NewWFSHandler(arcgisClient client.ArcGISClientInterface, srDetector *services.BackendSRDetector, logger *slog.Logger, baseURL, servicePath string) *WFSHandler {
+	transformer := transform.NewCoordinateTransformer()
+
+	return &WFSHandler{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		baseURL:      baseURL,
+		servicePath:  servicePath,
+		catalog:      services.NewLayerCatalog(arcgisClient, logger),
+		schemas:      services.NewLayerSchemaService(arcgisClient, logger, baseURL),
+		features:     services.NewFeatureService(arcgisClient, logger, baseURL, transformer, srDetector),
+	}
+}
+
+// SetCachePolicy sets the Cache-Control policy of capabilities
+func (h *WFSHandler) SetCachePolicy(policy *CachePolicy) {
+	h.cachePolicy = policy
+}
+
+// ServeHTTP handles WFS requests
+func (h *WFSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	h.logger.Info("Incoming WFS request",
+		"method", r.Method,
+		"url", r.URL.String(),
+		"remote_addr", r.RemoteAddr,
+	)
+
+	if r.Method != http.MethodGet {
+		translator.GenerateOWSException(w, "OperationNotSupported", "", "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := translator.NormalizeWFSParams(r.URL.Query())
+
+	if service := params.Get("SERVICE"); service != "" && !strings.EqualFold(service, "WFS") {
+		translator.GenerateOWSException(w, "InvalidParameterValue", "service", "Unsupported service: "+service, http.StatusBadRequest)
+		return
+	}
+
+	request := params.Get("REQUEST")
+	switch strings.ToUpper(request) {
+	case "GETCAPABILITIES":
+		h.handleGetCapabilities(w, r)
+	case "DESCRIBEFEATURETYPE":
+		h.handleDescribeFeatureType(w, r, params)
+	case "GETFEATURE":
+		h.handleGetFeature(w, r, params)
+	case "":
+		translator.GenerateOWSException(w, "MissingParameterValue", "request", "Missing required parameter: REQUEST", http.StatusBadRequest)
+	default:
+		translator.GenerateOWSException(w, "OperationNotSupported", "request", "Unsupported request type: "+request, http.StatusBadRequest)
+	}
+
+	h.logger.Info("Request completed",
+		"duration_ms", time.Since(startTime).Milliseconds(),
+		"request_type", request,
+	)
+}
+
+// handleGetCapabilities lists the feature layers of the service as feature types
+func (h *WFSHandler) handleGetCapabilities(w http.ResponseWriter, r *http.Request) {
+	layers, err := h.catalog.GetFeatureLayers(r.Context(), h.servicePath)
+	if err != nil {
+		h.logger.Error("Failed to load layer catalog", "error", err)
+		translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to load service layers", upstreamErrorStatus(w, err))
+		return
+	}
+
+	featureTypes := make([]wfs.FeatureTypeInfo, 0, len(layers))
+	for _, layer := range layers {
+		featureTypes = append(featureTypes, wfs.FeatureTypeInfo{Name: layer.Name, Title: layer.Title})
+	}
+
+	capabilitiesXML, err := wfs.GenerateCapabilities(requestURL(r), featureTypes)
+	if err != nil {
+		h.logger.Error("Failed to generate WFS capabilities", "error", err)
+		translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to generate capabilities", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	if cacheControl := h.cachePolicy.For(EndpointCapabilities, nil, "max-age=3600"); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	serveConditional(w, r, capabilitiesXML)
+}
+
+// handleDescribeFeatureType returns the application schema of the requested feature types
+func (h *WFSHandler) handleDescribeFeatureType(w http.ResponseWriter, r *http.Request, params url.Values) {
+	typeNames := firstValue(params, "TYPENAMES", "TYPENAME")
+
+	var layers []services.CatalogLayer
+	if typeNames == "" {
+		all, err := h.catalog.GetFeatureLayers(r.Context(), h.servicePath)
+		if err != nil {
+			h.logger.Error("Failed to load layer catalog", "error", err)
+			translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to load service layers", upstreamErrorStatus(w, err))
+			return
+		}
+		layers = all
+	} else {
+		for _, name := range strings.Split(typeNames, ",") {
+			layer, err := h.catalog.FindLayer(r.Context(), h.servicePath, strings.TrimSpace(name))
+			if err != nil {
+				translator.GenerateOWSException(w, "InvalidParameterValue", "typeNames", err.Error(), http.StatusBadRequest)
+				return
+			}
+			layers = append(layers, *layer)
+		}
+	}
+
+	featureTypes := make([]wfs.FeatureTypeSchema, 0, len(layers))
+	for _, layer := range layers {
+		metadata, err := h.schemas.GetLayerMetadata(r.Context(), h.servicePath, strconv.Itoa(layer.ID))
+		if err != nil {
+			h.logger.Error("Failed to load layer metadata", "error", err, "layer", layer.ID)
+			translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to load layer metadata", upstreamErrorStatus(w, err))
+			return
+		}
+
+		featureType := wfs.FeatureTypeSchema{Name: layer.Name}
+		for _, field := range attributeFields(metadata) {
+			featureType.Properties = append(featureType.Properties, wfs.PropertyInfo{
+				Name: field.Name,
+				Type: translator.XSDTypeForEsriField(field.Type),
+			})
+		}
+		featureType.Properties = append(featureType.Properties, wfs.PropertyInfo{Name: "shape", Type: "gml:GeometryPropertyType"})
+		featureTypes = append(featureTypes, featureType)
+	}
+
+	schemaXML, err := wfs.GenerateFeatureTypeSchema(featureTypes)
+	if err != nil {
+		h.logger.Error("Failed to generate feature type schema", "error", err)
+		translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to generate schema", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gml+xml; version=3.2")
+	w.WriteHeader(http.StatusOK)
+	w.Write(schemaXML)
+}
+
+// handleGetFeature queries the ArcGIS layer behind a feature type
+func (h *WFSHandler) handleGetFeature(w http.ResponseWriter, r *http.Request, params url.Values) {
+	req, err := translator.ParseWFSGetFeature(params)
+	if err != nil {
+		translator.GenerateOWSException(w, "InvalidParameterValue", "", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	layer, err := h.catalog.FindLayer(r.Context(), h.servicePath, req.TypeName)
+	if err != nil {
+		translator.GenerateOWSException(w, "InvalidParameterValue", "typeNames", err.Error(), http.StatusBadRequest)
+		return
+	}
+	layerID := strconv.Itoa(layer.ID)
+
+	metadata, err := h.schemas.GetLayerMetadata(r.Context(), h.servicePath, layerID)
+	if err != nil {
+		h.logger.Error("Failed to load layer metadata", "error", err, "layer", layerID)
+		translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to load layer metadata", upstreamErrorStatus(w, err))
+		return
+	}
+
+	schema, err := h.schemas.GetLayerSchema(r.Context(), h.servicePath, layerID)
+	if err != nil {
+		translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to load layer metadata", upstreamErrorStatus(w, err))
+		return
+	}
+	where, err := translator.TranslateFilterToWhere(req.Filter, schema)
+	if err != nil {
+		h.logger.Warn("Rejected CQL filter", "error", err, "filter", req.Filter)
+		translator.GenerateOWSException(w, "InvalidParameterValue", "filter", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	properties, err := selectProperties(metadata, req.PropertyNames)
+	if err != nil {
+		translator.GenerateOWSException(w, "InvalidParameterValue", "propertyName", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The object ID is always queried since it identifies the returned features
+	outFields := properties
+	if oid := metadata.ObjectIDFieldName(); !containsFold(outFields, oid) {
+		outFields = append(append([]string{}, properties...), oid)
+	}
+
+	result := &services.FeatureResult{}
+	if req.Count > 0 {
+		result, err = h.features.Query(r.Context(), h.servicePath, &services.FeatureQuery{
+			LayerID:   layerID,
+			Where:     where,
+			BBox:      req.BBox,
+			BBoxCRS:   req.BBoxCRS,
+			OutFields: outFields,
+			ObjectIDs: req.ResourceIDs,
+			Offset:    req.StartIndex,
+			Limit:     req.Count,
+			OutCRS:    req.OutCRS,
+		})
+		if err != nil {
+			h.logger.Error("Feature query failed", "error", err, "layer", layerID)
+			translator.GenerateOWSException(w, "NoApplicableCode", "", "Upstream feature query failed", upstreamErrorStatus(w, err))
+			return
+		}
+	}
+
+	// Offer a next page when ArcGIS truncated the result or the page is full
+	next := ""
+	returned := len(result.Features)
+	if returned > 0 && (result.ExceededTransferLimit || returned == req.Count) {
+		query := r.URL.Query()
+		for key := range query {
+			if strings.EqualFold(key, "STARTINDEX") {
+				query.Del(key)
+			}
+		}
+		query.Set("STARTINDEX", strconv.Itoa(req.StartIndex+returned))
+		next = requestURL(r) + "?" + query.Encode()
+	}
+
+	if req.OutputFormat == wfs.FormatGeoJSON {
+		var links []translator.GeoJSONLink
+		if next != "" {
+			links = append(links, translator.GeoJSONLink{Href: next, Rel: "next", Type: "application/geo+json"})
+		}
+		data, err := translator.EncodeGeoJSONFeatureCollection(result.Features, properties, links)
+		if err != nil {
+			h.logger.Error("Failed to encode features", "error", err)
+			translator.GenerateOWSException(w, "NoApplicableCode", "", "Failed to encode features", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", wfs.FormatGML32)
+	w.WriteHeader(http.StatusOK)
+	w.Write(translator.EncodeGMLFeatureCollection(layer.Name, result.Features, properties, req.SRSName, req.SwapAxes, next))
+}
+
+// attributeFields returns the non-geometry fields of a layer
+func attributeFields(metadata *client.LayerMetadata) []client.Field {
+	fields := make([]client.Field, 0, len(metadata.Fields))
+	for _, field := range metadata.Fields {
+		if field.Type != "esriFieldTypeGeometry" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// selectProperties resolves requested property names against the layer fields,
+// returning all attribute fields when none were requested
+func selectProperties(metadata *client.LayerMetadata, requested []string) ([]string, error) {
+	fields := attributeFields(metadata)
+
+	var properties []string
+	if len(requested) == 0 {
+		for _, field := range fields {
+			properties = append(properties, field.Name)
+		}
+		return properties, nil
+	}
+
+	for _, name := range requested {
+		if strings.EqualFold(name, "shape") {
+			continue
+		}
+		found := false
+		for _, field := range fields {
+			if strings.EqualFold(field.Name, name) {
+				properties = append(properties, field.Name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown property: %s", name)
+		}
+	}
+	return properties, nil
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstValue returns the first non-empty value among the given parameter keys
+func firstValue(params url.Values, keys ...string) string {
+	for _, key := range keys {
+		if values := params[key]; len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// requestURL returns the absolute URL of the request without its query string
+func requestURL(r *http.Request) string {
+	return baseRequestURL(r) + r.URL.Path
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/services"
+)
+
+const featureServicePath = "/arcgis/rest/services/test/MapServer"
+
+// featureUpstream is an ArcGIS map service in EPSG:4326 with one point layer
+// of three features, recording the parameters of each layer query
+type featureUpstream struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+func newFeatureUpstream(t *testing.T) *featureUpstream {
+	t.Helper()
+	u := &featureUpstream{}
+	points := [][2]float64{{-74.5, 40.2}, {-74.0, 40.0}, {-74.2, 41.0}}
+
+	u.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case featureServicePath:
+			w.Write([]byte(`{"spatialReference":{"wkid":4326},"layers":[{"id":0,"name":"Parcels","parentLayerId":-1,"geometryType":"esriGeometryPoint"}]}`))
+		case featureServicePath + "/0":
+			w.Write([]byte(`{"id":0,"name":"Parcels","type":"Feature Layer","geometryType":"esriGeometryPoint","objectIdField":"OBJECTID",` +
+				`"fields":[{"name":"OBJECTID","type":"esriFieldTypeOID"},{"name":"NAME","type":"esriFieldTypeString"},{"name":"Shape","type":"esriFieldTypeGeometry"}]}`))
+		case featureServicePath + "/0/query":
+			query := r.URL.Query()
+			u.mu.Lock()
+			u.queries = append(u.queries, query)
+			u.mu.Unlock()
+
+			offset, _ := strconv.Atoi(query.Get("resultOffset"))
+			count, _ := strconv.Atoi(query.Get("resultRecordCount"))
+			var features []map[string]interface{}
+			for i, p := range points {
+				id := strconv.Itoa(i + 1)
+				if ids := query.Get("objectIds"); ids != "" && ids != id {
+					continue
+				}
+				features = append(features, map[string]interface{}{
+					"attributes": map[string]interface{}{"OBJECTID": i + 1, "NAME": "Parcel " + id},
+					"geometry":   map[string]float64{"x": p[0], "y": p[1]},
+				})
+			}
+			if offset > len(features) {
+				offset = len(features)
+			}
+			features = features[offset:]
+			if count > 0 && count < len(features) {
+				features = features[:count]
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"objectIdFieldName": "OBJECTID",
+				"geometryType":      "esriGeometryPoint",
+				"features":          features,
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(u.server.Close)
+	return u
+}
+
+// lastQuery returns the parameters of the most recent layer query
+func (u *featureUpstream) lastQuery(t *testing.T) url.Values {
+	t.Helper()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.queries) == 0 {
+		t.Fatal("expected a layer query")
+	}
+	return u.queries[len(u.queries)-1]
+}
+
+func newTestWFSHandler(t *testing.T) (*WFSHandler, *featureUpstream) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	upstream := newFeatureUpstream(t)
+	arcgisClient := client.NewArcGISClient(upstream.server.URL, 5*time.Second)
+	handler := NewWFSHandler(arcgisClient, services.NewBackendSRDetector(arcgisClient, logger), logger, upstream.server.URL, featureServicePath)
+	return handler, upstream
+}
+
+func TestWFSHandler_GetCapabilities(t *testing.T) {
+	handler, _ := newTestWFSHandler(t)
+
+	req := httptest.NewRequest("GET", "/wfs?SERVICE=WFS&REQUEST=GetCapabilities", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("expected application/xml, got %s", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"Parcels", "GetFeature", "DescribeFeatureType"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected capabilities to contain %q", want)
+		}
+	}
+}
+
+func TestWFSHandler_DescribeFeatureType(t *testing.T) {
+	handler, _ := newTestWFSHandler(t)
+
+	tests := []struct {
+		name           string
+		typeNames      string
+		expectedStatus int
+	}{
+		{"all feature types", "", http.StatusOK},
+		{"named feature type", "esri:Parcels", http.StatusOK},
+		{"unknown feature type", "Roads", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := "/wfs?SERVICE=WFS&REQUEST=DescribeFeatureType"
+			if test.typeNames != "" {
+				target += "&TYPENAMES=" + url.QueryEscape(test.typeNames)
+			}
+			req := httptest.NewRequest("GET", target, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/gml+xml; version=3.2" {
+				t.Errorf("expected GML schema content type, got %s", ct)
+			}
+			body := w.Body.String()
+			for _, want := range []string{`"Parcels"`, `"OBJECTID"`, `"NAME"`, `"shape"`, "gml:GeometryPropertyType"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected schema to contain %s, got %s", want, body)
+				}
+			}
+			if strings.Contains(body, `"Shape"`) {
+				t.Errorf("expected the geometry field to be published as shape only, got %s", body)
+			}
+		})
+	}
+}
+
+func TestWFSHandler_GetFeature(t *testing.T) {
+	handler, upstream := newTestWFSHandler(t)
+
+	req := httptest.NewRequest("GET", "http://proxy.local/wfs?SERVICE=WFS&REQUEST=GetFeature&TYPENAMES=Parcels&COUNT=2&OUTPUTFORMAT=application/json", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("expected application/geo+json, got %s", ct)
+	}
+
+	var collection struct {
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   struct {
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+		Links []struct {
+			Href string `json:"href"`
+			Rel  string `json:"rel"`
+		} `json:"links"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+	if len(collection.Features) != 2 {
+		t.Fatalf("expected 2 features, got %d", len(collection.Features))
+	}
+	if got := collection.Features[0].Properties["NAME"]; got != "Parcel 1" {
+		t.Errorf("expected NAME Parcel 1, got %v", got)
+	}
+	if coords := collection.Features[0].Geometry.Coordinates; len(coords) != 2 || coords[0] != -74.5 || coords[1] != 40.2 {
+		t.Errorf("expected GeoJSON coordinates in lon/lat, got %v", coords)
+	}
+
+	next := ""
+	for _, link := range collection.Links {
+		if link.Rel == "next" {
+			next = link.Href
+		}
+	}
+	nextURL, err := url.Parse(next)
+	if err != nil || nextURL.Host != "proxy.local" || nextURL.Query().Get("STARTINDEX") != "2" {
+		t.Errorf("expected a next link at STARTINDEX=2, got %q", next)
+	}
+
+	query := upstream.lastQuery(t)
+	if query.Get("resultRecordCount") != "2" || query.Get("resultOffset") != "" {
+		t.Errorf("expected the first page of 2 to be queried, got %v", query)
+	}
+}
+
+func TestWFSHandler_GetFeatureGML(t *testing.T) {
+	handler, _ := newTestWFSHandler(t)
+
+	req := httptest.NewRequest("GET", "/wfs?SERVICE=WFS&REQUEST=GetFeature&TYPENAMES=Parcels&RESOURCEID=1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "urn:ogc:def:crs:EPSG::4326") {
+		t.Errorf("expected the default srsName, got %s", body)
+	}
+	// The default srsName is latitude/longitude
+	if !strings.Contains(body, "40.2 -74.5") {
+		t.Errorf("expected lat/lon coordinates, got %s", body)
+	}
+	if strings.Contains(body, "Parcel 2") {
+		t.Errorf("expected only the requested feature, got %s", body)
+	}
+}
+
+func TestWFSHandler_GetFeatureBBoxAxisOrder(t *testing.T) {
+	handler, upstream := newTestWFSHandler(t)
+
+	tests := []struct {
+		name     string
+		params   string
+		expected string
+	}{
+		{"default srsName is lat/lon", "BBOX=40,-75,41,-74", "-75.000000,40.000000,-74.000000,41.000000"},
+		{"EPSG code srsName is lon/lat", "SRSNAME=EPSG:4326&BBOX=-75,40,-74,41", "-75.000000,40.000000,-74.000000,41.000000"},
+		{"explicit CRS overrides srsName", "SRSNAME=EPSG:4326&BBOX=40,-75,41,-74,urn:ogc:def:crs:EPSG::4326", "-75.000000,40.000000,-74.000000,41.000000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/wfs?SERVICE=WFS&REQUEST=GetFeature&TYPENAMES=Parcels&"+test.params, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := upstream.lastQuery(t).Get("geometry"); got != test.expected {
+				t.Errorf("expected query envelope %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
@@ -1,13 +1,15 @@
 package handlers
 
 import (
-	"context"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"wms-proxy/internal/client"
+	"wms-proxy/internal/cql"
+	"wms-proxy/internal/imaging"
 	"wms-proxy/internal/services"
 	"wms-proxy/internal/transform"
 	"wms-proxy/internal/translator"
@@ -22,20 +24,34 @@ type WMSHandler struct {
 	servicePath  string
 	transformer  *transform.CoordinateTransformer
 	srDetector   *services.BackendSRDetector
+	schemas      *services.LayerSchemaService
+	catalog      *services.LayerCatalog
+	serviceTypes *services.ServiceTypeDetector
+	jpegQuality  int
+	cachePolicy  *CachePolicy
 }
 
 // NewWMSHandler creates a new WMS handler
-func NewWMSHandler(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, baseURL, servicePath string) *WMSHandler {
+func NewWMSHandler(arcgisClient client.ArcGISClientInterface, srDetector *services.BackendSRDetector, logger *slog.Logger, baseURL, servicePath string, jpegQuality int) *WMSHandler {
 	return &WMSHandler{
 		arcgisClient: arcgisClient,
 		logger:       logger,
 		baseURL:      baseURL,
 		servicePath:  servicePath,
 		transformer:  transform.NewCoordinateTransformer(),
-		srDetector:   services.NewBackendSRDetector(arcgisClient, logger),
+		srDetector:   srDetector,
+		schemas:      services.NewLayerSchemaService(arcgisClient, logger, baseURL),
+		catalog:      services.NewLayerCatalog(arcgisClient, logger),
+		serviceTypes: services.NewServiceTypeDetector(arcgisClient, logger),
+		jpegQuality:  jpegQuality,
 	}
 }
 
+// SetCachePolicy sets the Cache-Control policy of capabilities and maps
+func (h *WMSHandler) SetCachePolicy(policy *CachePolicy) {
+	h.cachePolicy = policy
+}
+
 // ServeHTTP handles WMS requests
 func (h *WMSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
@@ -75,7 +91,10 @@ func (h *WMSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "GETCAPABILITIES":
 		h.handleGetCapabilities(w, r)
 	case "GETMAP":
-		h.handleGetMap(w, r, wmsParams)
+		// The image is buffered so that conditional requests can be answered
+		rendered := newBufferedResponse()
+		h.handleGetMap(rendered, r, wmsParams)
+		writeBuffered(w, r, rendered, h.cachePolicy.For(EndpointMap, strings.Split(wmsParams.Layers, ","), rendered.Header().Get("Cache-Control")))
 	default:
 		translator.GenerateWMSError(w, "Unsupported request type: "+wmsParams.Request, http.StatusBadRequest)
 	}
@@ -90,7 +109,47 @@ func (h *WMSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // handleGetCapabilities processes WMS GetCapabilities requests
 func (h *WMSHandler) handleGetCapabilities(w http.ResponseWriter, r *http.Request) {
-	capabilitiesXML, err := wms.GenerateCapabilities(h.baseURL)
+	// Advertise TIME/ELEVATION dimensions when the backend service exposes them
+	var dimensions []wms.DimensionInfo
+	metadata, err := h.arcgisClient.GetServiceMetadata(r.Context(), h.servicePath)
+	if err != nil {
+		h.logger.Warn("Failed to get service metadata for capabilities, omitting dimensions",
+			"error", err,
+			"service_path", h.servicePath)
+	} else {
+		dimensions = translator.DimensionsFromMetadata(metadata)
+	}
+
+	var layers []wms.LayerInfo
+	switch h.serviceTypes.GetServiceType(r.Context(), h.servicePath) {
+	case client.ServiceTypeImage:
+		// An image service is a single layer whose raster functions are offered as styles
+		if metadata != nil {
+			layer := wms.LayerInfo{Name: "0", Title: metadata.Name}
+			if layer.Title == "" {
+				layer.Title = "Image"
+			}
+			for _, rasterFunction := range metadata.RasterFunctionInfos {
+				layer.Styles = append(layer.Styles, rasterFunction.Name)
+			}
+			layers = append(layers, layer)
+		}
+	case client.ServiceTypeFeature:
+		// Feature services cannot render maps; their layers are served by WFS, OGC API and vector tiles
+	default:
+		// Layers are named by ArcGIS layer ID, as used in GetMap LAYERS and CQL_FILTER
+		catalogLayers, err := h.catalog.GetLayers(r.Context(), h.servicePath)
+		if err != nil {
+			h.logger.Warn("Failed to load layer catalog for capabilities, omitting layers",
+				"error", err,
+				"service_path", h.servicePath)
+		}
+		for _, layer := range catalogLayers {
+			layers = append(layers, wms.LayerInfo{Name: strconv.Itoa(layer.ID), Title: layer.Title})
+		}
+	}
+
+	capabilitiesXML, err := wms.GenerateCapabilitiesWithExtent(h.baseURL, layers, dimensions, translator.ExtentFromMetadata(metadata, h.transformer))
 	if err != nil {
 		h.logger.Error("Failed to generate capabilities", "error", err)
 		translator.GenerateWMSError(w, "Failed to generate capabilities", http.StatusInternalServerError)
@@ -98,13 +157,20 @@ func (h *WMSHandler) handleGetCapabilities(w http.ResponseWriter, r *http.Reques
 	}
 
 	w.Header().Set("Content-Type", "application/vnd.ogc.wms_xml")
-	w.Header().Set("Cache-Control", "max-age=3600")
-	w.WriteHeader(http.StatusOK)
-	w.Write(capabilitiesXML)
+	if cacheControl := h.cachePolicy.For(EndpointCapabilities, nil, "max-age=3600"); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	serveConditional(w, r, capabilitiesXML)
 }
 
-// handleGetMap processes WMS GetMap requests
-func (h *WMSHandler) handleGetMap(w http.ResponseWriter, r *http.Request, wmsParams *wms.WMSParams) {
+// handleGetMap processes WMS GetMap requests into a buffered response
+func (h *WMSHandler) handleGetMap(w *bufferedResponse, r *http.Request, wmsParams *wms.WMSParams) {
+	serviceType := h.serviceTypes.GetServiceType(r.Context(), h.servicePath)
+	if serviceType == client.ServiceTypeFeature {
+		translator.GenerateWMSError(w, "GetMap is not supported for feature services; use the WFS, OGC API - Features or vector tile endpoints", http.StatusBadRequest)
+		return
+	}
+
 	// Translate WMS parameters to ArcGIS parameters with coordinate transformation
 	arcgisParams, err := translator.TranslateWMSToArcGISWithTransformAndBackendSR(wmsParams, h.transformer, h.srDetector, r.Context(), h.servicePath)
 	if err != nil {
@@ -113,31 +179,87 @@ func (h *WMSHandler) handleGetMap(w http.ResponseWriter, r *http.Request, wmsPar
 		return
 	}
 
-	// Build ArcGIS URL
-	arcgisURL := translator.BuildArcGISURL(h.baseURL, h.servicePath, arcgisParams)
+	// Decide whether the upstream image needs post-processing (BGCOLOR, opaque output, PNG8, JPEG quality)
+	imageOpts, err := imaging.NewOptions(wmsParams.Format, wmsParams.Transparent, wmsParams.BGColor, wmsParams.FormatOptions, h.jpegQuality)
+	if err != nil {
+		translator.GenerateWMSError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if imageOpts.NeedsProcessing() {
+		// Request an image with alpha so the background can be composed locally
+		arcgisParams.Format = imageOpts.UpstreamFormat()
+		arcgisParams.Transparent = "true"
+	}
+
+	if serviceType == client.ServiceTypeImage {
+		// Image services have no sublayers; rendering rules, interpolation and compression apply instead
+		if err := translator.TranslateImageServerParams(wmsParams, arcgisParams); err != nil {
+			translator.GenerateWMSError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if wmsParams.Filter != "" {
+		// Translate attribute filters into layer definitions validated against the layer schemas
+		layerDefs, err := translator.TranslateFilterToLayerDefs(wmsParams.Layers, wmsParams.Filter, func(layerID string) (*cql.Schema, error) {
+			return h.schemas.GetLayerSchema(r.Context(), h.servicePath, layerID)
+		})
+		if err != nil {
+			h.logger.Warn("Rejected CQL filter", "error", err, "filter", wmsParams.Filter)
+			translator.GenerateWMSError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		arcgisParams.LayerDefs = layerDefs
+	}
+
+	// Reject sizes and formats the service cannot export, and name dimensions as the service does
+	if metadata, err := h.arcgisClient.GetServiceMetadata(r.Context(), h.servicePath); err == nil {
+		if err := translator.ValidateExportLimits(metadata, wmsParams.Width, wmsParams.Height, arcgisParams.Format); err != nil {
+			translator.GenerateWMSError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := translator.MatchRangeNames(metadata, arcgisParams); err != nil {
+			h.logger.Error("Failed to match dimension names", "error", err)
+			translator.GenerateWMSError(w, "Parameter translation failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Build ArcGIS export or exportImage URL
+	var arcgisURL string
+	if serviceType == client.ServiceTypeImage {
+		arcgisURL = translator.BuildExportImageURL(h.baseURL, h.servicePath, arcgisParams)
+	} else {
+		arcgisURL = translator.BuildArcGISURL(h.baseURL, client.ServiceRoot(h.servicePath)+"/export", arcgisParams)
+	}
 
 	h.logger.Info("Proxying to ArcGIS",
 		"arcgis_url", arcgisURL,
 		"bbox", arcgisParams.BBOX,
 		"size", arcgisParams.Size,
+		"time", arcgisParams.Time,
 	)
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	// The backend's client applies its configured request timeout
+	ctx := r.Context()
 
 	// Make request to ArcGIS server
 	arcgisResp, err := h.arcgisClient.Get(ctx, arcgisURL)
 	if err != nil {
 		h.logger.Error("Failed to request from ArcGIS server", "error", err)
-		translator.GenerateWMSError(w, "Upstream server error", http.StatusBadGateway)
+		translator.GenerateWMSError(w, "Upstream server error", upstreamErrorStatus(w, err))
 		return
 	}
 
 	// Translate and return response
-	if err := translator.TranslateArcGISResponse(arcgisResp, w); err != nil {
+	if imageOpts.NeedsProcessing() {
+		err = translator.TranslateArcGISImageResponse(arcgisResp, w, imageOpts)
+	} else {
+		err = translator.TranslateArcGISResponse(arcgisResp, w)
+	}
+	if err != nil {
 		h.logger.Error("Failed to translate ArcGIS response", "error", err)
-		// Response may have already been written, so we can't send another error
+		// Nothing has been sent yet, so the partial image is replaced by an error
+		w.reset()
+		translator.GenerateWMSError(w, "Failed to read upstream response", http.StatusBadGateway)
 		return
 	}
 
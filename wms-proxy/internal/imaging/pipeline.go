@@ -0,0 +1,244 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Output formats produced by the pipeline
+const (
+	FormatPNG  = "image/png"
+	FormatPNG8 = "image/png8"
+	FormatJPEG = "image/jpeg"
+	FormatGIF  = "image/gif"
+)
+
+// DefaultJPEGQuality is used when neither configuration nor FORMAT_OPTIONS set a quality
+const DefaultJPEGQuality = 85
+
+// Options controls post-processing of an upstream image
+type Options struct {
+	Format      string     // requested output format (one of the Format constants)
+	Opaque      bool       // TRANSPARENT=FALSE: flatten alpha onto Background
+	Background  color.RGBA // BGCOLOR, white by default
+	JPEGQuality int        // 1-100
+	qualitySet  bool       // quality was configured or requested via FORMAT_OPTIONS
+}
+
+// NewOptions builds pipeline options from WMS FORMAT, TRANSPARENT, BGCOLOR and
+// FORMAT_OPTIONS values. defaultQuality is the configured JPEG quality; when it
+// is zero, upstream JPEGs are passed through unless FORMAT_OPTIONS asks for a quality.
+func NewOptions(format, transparent, bgColor, formatOptions string, defaultQuality int) (*Options, error) {
+	opts := &Options{
+		Format:      normalizeFormat(format),
+		Background:  color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		JPEGQuality: DefaultJPEGQuality,
+	}
+	if defaultQuality > 0 && defaultQuality <= 100 {
+		opts.JPEGQuality = defaultQuality
+		opts.qualitySet = true
+	}
+
+	switch strings.ToLower(strings.TrimSpace(transparent)) {
+	case "false", "0", "no":
+		opts.Opaque = true
+	}
+
+	if bgColor != "" {
+		c, err := ParseBGColor(bgColor)
+		if err != nil {
+			return nil, err
+		}
+		opts.Background = c
+	}
+
+	for key, value := range ParseFormatOptions(formatOptions) {
+		switch key {
+		case "quality":
+			q, err := parseQuality(value)
+			if err != nil {
+				return nil, err
+			}
+			opts.JPEGQuality = q
+			opts.qualitySet = true
+		}
+	}
+
+	return opts, nil
+}
+
+// NeedsProcessing reports whether the upstream image has to be decoded and re-encoded
+func (o *Options) NeedsProcessing() bool {
+	if o.Opaque || o.Format == FormatPNG8 {
+		return true
+	}
+	return o.Format == FormatJPEG && o.qualitySet
+}
+
+// UpstreamFormat returns the ArcGIS export format to request when processing is
+// needed. png32 keeps the alpha channel so the background can be filled here.
+func (o *Options) UpstreamFormat() string {
+	return "png32"
+}
+
+// Process decodes an upstream image and applies background filling, alpha
+// flattening, palette conversion and re-encoding according to the options.
+// It returns the encoded image and its content type.
+func Process(data []byte, opts *Options) ([]byte, string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode upstream image: %w", err)
+	}
+
+	img := src
+	if opts.Opaque || opts.Format == FormatJPEG {
+		img = flatten(src, opts.Background)
+	}
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case FormatJPEG:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.JPEGQuality})
+		return buf.Bytes(), FormatJPEG, wrapEncodeErr(err)
+	case FormatGIF:
+		err = gif.Encode(&buf, img, nil)
+		return buf.Bytes(), FormatGIF, wrapEncodeErr(err)
+	case FormatPNG8:
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		err = encoder.Encode(&buf, toPaletted(img))
+		return buf.Bytes(), FormatPNG, wrapEncodeErr(err)
+	default:
+		err = png.Encode(&buf, img)
+		return buf.Bytes(), FormatPNG, wrapEncodeErr(err)
+	}
+}
+
+func wrapEncodeErr(err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return nil
+}
+
+// flatten composites an image over a solid background, removing transparency
+func flatten(src image.Image, bg color.RGBA) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Over)
+	return dst
+}
+
+// toPaletted converts an image to an 8-bit palette. Images with at most 256
+// distinct colors keep them exactly; others are dithered to the web-safe
+// palette plus a fully transparent entry.
+func toPaletted(src image.Image) *image.Paletted {
+	if p, ok := src.(*image.Paletted); ok {
+		return p
+	}
+
+	bounds := src.Bounds()
+	if exact := exactPalette(src); exact != nil {
+		dst := image.NewPaletted(bounds, exact)
+		draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+		return dst
+	}
+
+	pal := make(color.Palette, 0, len(palette.WebSafe)+1)
+	pal = append(pal, color.RGBA{})
+	pal = append(pal, palette.WebSafe...)
+	dst := image.NewPaletted(bounds, pal)
+	draw.FloydSteinberg.Draw(dst, bounds, src, bounds.Min)
+	return dst
+}
+
+// exactPalette returns the image's colors when there are at most 256 of them
+func exactPalette(src image.Image) color.Palette {
+	bounds := src.Bounds()
+	seen := make(map[color.RGBA]struct{})
+	var pal color.Palette
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(src.At(x, y)).(color.RGBA)
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			if len(pal) == 256 {
+				return nil
+			}
+			seen[c] = struct{}{}
+			pal = append(pal, c)
+		}
+	}
+	return pal
+}
+
+// ParseBGColor parses a WMS BGCOLOR value in 0xRRGGBB (or #RRGGBB) form
+func ParseBGColor(value string) (color.RGBA, error) {
+	hex := strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(hex, "0x"), strings.HasPrefix(hex, "0X"):
+		hex = hex[2:]
+	case strings.HasPrefix(hex, "#"):
+		hex = hex[1:]
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid BGCOLOR %q: expected 0xRRGGBB", value)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid BGCOLOR %q: expected 0xRRGGBB", value)
+	}
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xff}, nil
+}
+
+// ParseFormatOptions parses a FORMAT_OPTIONS value of the form "key:value;key:value"
+func ParseFormatOptions(value string) map[string]string {
+	options := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(pair, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !found || key == "" {
+			continue
+		}
+		options[key] = strings.TrimSpace(val)
+	}
+	return options
+}
+
+// parseQuality accepts a JPEG quality as 1-100 or as a 0-1 fraction
+func parseQuality(value string) (int, error) {
+	q, err := strconv.ParseFloat(value, 64)
+	if err != nil || q <= 0 || q > 100 {
+		return 0, fmt.Errorf("invalid FORMAT_OPTIONS quality %q: expected 1-100", value)
+	}
+	if q <= 1 {
+		q *= 100
+	}
+	return int(q + 0.5), nil
+}
+
+// normalizeFormat maps WMS FORMAT values onto the pipeline's output formats
+func normalizeFormat(format string) string {
+	f := strings.ToLower(strings.ReplaceAll(format, " ", ""))
+	switch f {
+	case "image/png8", "png8", "image/png;mode=8bit":
+		return FormatPNG8
+	case "image/jpeg", "image/jpg", "jpeg", "jpg":
+		return FormatJPEG
+	case "image/gif", "gif":
+		return FormatGIF
+	default:
+		return FormatPNG
+	}
+}
@@ -0,0 +1,137 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// transparentPNG returns a PNG whose left half is transparent and right half opaque red
+func transparentPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			img.Set(x, y, color.NRGBA{R: 0xff, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		format          string
+		transparent     string
+		bgColor         string
+		formatOptions   string
+		defaultQuality  int
+		needsProcessing bool
+		expectError     bool
+	}{
+		{"Transparent PNG passes through", "image/png", "TRUE", "", "", 0, false, false},
+		{"Opaque PNG is flattened", "image/png", "FALSE", "0x336699", "", 0, true, false},
+		{"PNG8 is quantized", "image/png; mode=8bit", "TRUE", "", "", 0, true, false},
+		{"JPEG passes through by default", "image/jpeg", "", "", "", 0, false, false},
+		{"JPEG quality from FORMAT_OPTIONS", "image/jpeg", "", "", "quality:60;antialias:full", 0, true, false},
+		{"JPEG quality from configuration", "image/jpeg", "", "", "", 70, true, false},
+		{"Invalid BGCOLOR", "image/png", "FALSE", "red", "", 0, false, true},
+		{"Invalid quality", "image/jpeg", "", "", "quality:200", 0, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := NewOptions(test.format, test.transparent, test.bgColor, test.formatOptions, test.defaultQuality)
+			if test.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if opts.NeedsProcessing() != test.needsProcessing {
+				t.Errorf("NeedsProcessing() = %t, expected %t", opts.NeedsProcessing(), test.needsProcessing)
+			}
+		})
+	}
+}
+
+func TestProcessFlattensOntoBackground(t *testing.T) {
+	opts, err := NewOptions("image/png", "FALSE", "0x336699", "", 0)
+	if err != nil {
+		t.Fatalf("NewOptions failed: %v", err)
+	}
+
+	out, contentType, err := Process(transparentPNG(t), opts)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if contentType != "image/png" {
+		t.Errorf("Content type = %s, expected image/png", contentType)
+	}
+
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Output is not a PNG: %v", err)
+	}
+	if got := color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA); got != (color.RGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff}) {
+		t.Errorf("Transparent pixel not filled with BGCOLOR: %+v", got)
+	}
+	if got := color.RGBAModel.Convert(img.At(3, 3)).(color.RGBA); got != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("Opaque pixel changed: %+v", got)
+	}
+}
+
+func TestProcessPNG8(t *testing.T) {
+	opts, err := NewOptions("image/png8", "TRUE", "", "", 0)
+	if err != nil {
+		t.Fatalf("NewOptions failed: %v", err)
+	}
+
+	out, _, err := Process(transparentPNG(t), opts)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Output is not a PNG: %v", err)
+	}
+	if _, ok := img.(*image.Paletted); !ok {
+		t.Errorf("Expected a paletted image, got %T", img)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("Transparency should be preserved in PNG8 output")
+	}
+}
+
+func TestProcessJPEG(t *testing.T) {
+	opts, err := NewOptions("image/jpeg", "", "", "quality:50", 0)
+	if err != nil {
+		t.Fatalf("NewOptions failed: %v", err)
+	}
+
+	out, contentType, err := Process(transparentPNG(t), opts)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if contentType != "image/jpeg" {
+		t.Errorf("Content type = %s, expected image/jpeg", contentType)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("Output is not a JPEG: %v", err)
+	}
+
+	if _, _, err := Process([]byte("not an image"), opts); err == nil {
+		t.Error("Expected error for undecodable input")
+	}
+}
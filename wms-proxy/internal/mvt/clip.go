@@ -0,0 +1,152 @@
+package mvt
+
+import (
+	"math"
+
+	"wms-proxy/internal/geometry"
+)
+
+// rect is an axis-aligned clip rectangle in tile coordinates
+type rect struct {
+	minX, minY, maxX, maxY float64
+}
+
+// clipRect is the tile area plus its buffer
+var clipRect = rect{minX: -Buffer, minY: -Buffer, maxX: Extent + Buffer, maxY: Extent + Buffer}
+
+func (r rect) contains(p geometry.Point) bool {
+	return p[0] >= r.minX && p[0] <= r.maxX && p[1] >= r.minY && p[1] <= r.maxY
+}
+
+// clipLine clips a line string to the rectangle, returning the visible parts
+func clipLine(line []geometry.Point, r rect) [][]geometry.Point {
+	var parts [][]geometry.Point
+	var current []geometry.Point
+
+	for i := 0; i+1 < len(line); i++ {
+		a, b, ok := clipSegment(line[i], line[i+1], r)
+		if !ok {
+			if len(current) >= 2 {
+				parts = append(parts, current)
+			}
+			current = nil
+			continue
+		}
+		if len(current) == 0 {
+			current = append(current, a)
+		} else if current[len(current)-1] != a {
+			// The segment re-enters the rectangle: start a new part
+			if len(current) >= 2 {
+				parts = append(parts, current)
+			}
+			current = []geometry.Point{a}
+		}
+		current = append(current, b)
+		if b != line[i+1] {
+			// The segment leaves the rectangle
+			parts = append(parts, current)
+			current = nil
+		}
+	}
+	if len(current) >= 2 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// clipSegment clips a segment with the Liang-Barsky algorithm
+func clipSegment(a, b geometry.Point, r rect) (geometry.Point, geometry.Point, bool) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t0, t1 := 0.0, 1.0
+
+	edges := [4][2]float64{
+		{-dx, a[0] - r.minX},
+		{dx, r.maxX - a[0]},
+		{-dy, a[1] - r.minY},
+		{dy, r.maxY - a[1]},
+	}
+	for _, e := range edges {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return a, b, false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return a, b, false
+			}
+			t1 = math.Min(t1, t)
+		}
+	}
+
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = geometry.Point{a[0] + t0*dx, a[1] + t0*dy}
+	}
+	if t1 < 1 {
+		clippedB = geometry.Point{a[0] + t1*dx, a[1] + t1*dy}
+	}
+	return clippedA, clippedB, true
+}
+
+// clipRing clips a closed ring to the rectangle with the Sutherland-Hodgman
+// algorithm. The result is closed, or nil when nothing remains.
+func clipRing(ring []geometry.Point, r rect) []geometry.Point {
+	out := ring
+	edges := []struct {
+		inside    func(p geometry.Point) bool
+		intersect func(a, b geometry.Point) geometry.Point
+	}{
+		{func(p geometry.Point) bool { return p[0] >= r.minX }, func(a, b geometry.Point) geometry.Point { return atX(a, b, r.minX) }},
+		{func(p geometry.Point) bool { return p[0] <= r.maxX }, func(a, b geometry.Point) geometry.Point { return atX(a, b, r.maxX) }},
+		{func(p geometry.Point) bool { return p[1] >= r.minY }, func(a, b geometry.Point) geometry.Point { return atY(a, b, r.minY) }},
+		{func(p geometry.Point) bool { return p[1] <= r.maxY }, func(a, b geometry.Point) geometry.Point { return atY(a, b, r.maxY) }},
+	}
+
+	for _, edge := range edges {
+		if len(out) == 0 {
+			return nil
+		}
+		in := out
+		// Work on the open ring; it is closed again at the end
+		if len(in) > 1 && in[0] == in[len(in)-1] {
+			in = in[:len(in)-1]
+		}
+		out = nil
+		prev := in[len(in)-1]
+		for _, p := range in {
+			if edge.inside(p) {
+				if !edge.inside(prev) {
+					out = append(out, edge.intersect(prev, p))
+				}
+				out = append(out, p)
+			} else if edge.inside(prev) {
+				out = append(out, edge.intersect(prev, p))
+			}
+			prev = p
+		}
+	}
+
+	if len(out) < 3 {
+		return nil
+	}
+	return append(out, out[0])
+}
+
+func atX(a, b geometry.Point, x float64) geometry.Point {
+	t := (x - a[0]) / (b[0] - a[0])
+	return geometry.Point{x, a[1] + t*(b[1]-a[1])}
+}
+
+func atY(a, b geometry.Point, y float64) geometry.Point {
+	t := (y - a[1]) / (b[1] - a[1])
+	return geometry.Point{a[0] + t*(b[0]-a[0]), y}
+}
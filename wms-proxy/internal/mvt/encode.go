@@ -0,0 +1,101 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// ContentType is the media type of Mapbox Vector Tiles
+const ContentType = "application/vnd.mapbox-vector-tile"
+
+// Protobuf wire types
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+)
+
+// Marshal encodes layers as a Mapbox Vector Tile (version 2.1) protobuf
+func Marshal(layers ...*Layer) []byte {
+	var tile []byte
+	for _, layer := range layers {
+		tile = appendBytesField(tile, 3, layer.marshal())
+	}
+	return tile
+}
+
+// marshal encodes a Layer message
+func (l *Layer) marshal() []byte {
+	var buf []byte
+	buf = appendVarintField(buf, 15, 2) // version
+	buf = appendBytesField(buf, 1, []byte(l.name))
+
+	for _, f := range l.features {
+		buf = appendBytesField(buf, 2, f.marshal())
+	}
+	for _, k := range l.keys {
+		buf = appendBytesField(buf, 3, []byte(k))
+	}
+	for _, v := range l.values {
+		buf = appendBytesField(buf, 4, v.marshal())
+	}
+
+	buf = appendVarintField(buf, 5, Extent)
+	return buf
+}
+
+// marshal encodes a Feature message
+func (f *encodedFeature) marshal() []byte {
+	var buf []byte
+	if f.hasID {
+		buf = appendVarintField(buf, 1, f.id)
+	}
+	if len(f.tags) > 0 {
+		buf = appendBytesField(buf, 2, packUint32(f.tags))
+	}
+	buf = appendVarintField(buf, 3, uint64(f.geomType))
+	buf = appendBytesField(buf, 4, packUint32(f.geometry))
+	return buf
+}
+
+// marshal encodes a Value message
+func (v value) marshal() []byte {
+	switch v.kind {
+	case 1:
+		return appendBytesField(nil, 1, []byte(v.s))
+	case 3:
+		buf := appendTag(nil, 3, wire64Bit)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.d))
+	case 6:
+		return appendVarintField(nil, 6, uint64((v.i<<1)^(v.i>>63)))
+	default:
+		b := uint64(0)
+		if v.b {
+			b = 1
+		}
+		return appendVarintField(nil, 7, b)
+	}
+}
+
+func appendTag(buf []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	buf = appendTag(buf, field, wireVarint)
+	return binary.AppendUvarint(buf, v)
+}
+
+func appendBytesField(buf []byte, field int, data []byte) []byte {
+	buf = appendTag(buf, field, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func packUint32(values []uint32) []byte {
+	buf := make([]byte, 0, len(values)*2)
+	for _, v := range values {
+		buf = binary.AppendUvarint(buf, uint64(v))
+	}
+	return buf
+}
@@ -0,0 +1,298 @@
+package mvt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"wms-proxy/internal/geometry"
+	"wms-proxy/internal/transform"
+)
+
+// Feature geometry types (vector_tile.proto GeomType)
+const (
+	geomPoint      = 1
+	geomLineString = 2
+	geomPolygon    = 3
+)
+
+// Geometry command IDs
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// Layer accumulates the features of one vector tile layer
+type Layer struct {
+	name       string
+	toTile     func(x, y float64) (float64, float64)
+	features   []encodedFeature
+	keys       []string
+	keyIndex   map[string]uint32
+	values     []value
+	valueIndex map[value]uint32
+}
+
+type encodedFeature struct {
+	id       uint64
+	hasID    bool
+	tags     []uint32
+	geomType int
+	geometry []uint32
+}
+
+// value is a tile attribute value; exactly one member is meaningful per kind
+type value struct {
+	kind int // 1 string, 3 double, 6 sint, 7 bool (vector_tile.proto field numbers)
+	s    string
+	d    float64
+	i    int64
+	b    bool
+}
+
+// NewLayer creates a layer for the tile with the given EPSG:3857 bounds
+func NewLayer(name string, bounds transform.BBox) *Layer {
+	return &Layer{
+		name:       name,
+		toTile:     toTile(bounds),
+		keyIndex:   make(map[string]uint32),
+		valueIndex: make(map[value]uint32),
+	}
+}
+
+// Len returns the number of features in the layer
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddFeature projects a feature with EPSG:3857 geometry into tile coordinates,
+// clips it to the buffered tile, simplifies it and adds it to the layer.
+// Features with nothing left inside the tile are skipped.
+func (l *Layer) AddFeature(f *geometry.Feature) {
+	if f.Geometry.IsEmpty() {
+		return
+	}
+
+	var geomType int
+	var commands []uint32
+	switch f.Geometry.Type {
+	case geometry.TypePoint, geometry.TypeMultiPoint:
+		geomType, commands = geomPoint, l.encodePoints(f.Geometry.Points)
+	case geometry.TypeLineString, geometry.TypeMultiLineString:
+		geomType, commands = geomLineString, l.encodeLines(f.Geometry.Lines)
+	default:
+		geomType, commands = geomPolygon, l.encodePolygons(f.Geometry.Polygons)
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	feature := encodedFeature{geomType: geomType, geometry: commands}
+	if id, ok := featureID(f.ID); ok {
+		feature.id, feature.hasID = id, true
+	}
+	// Keys are added in sorted order so that encoding the same features always
+	// produces the same bytes, which the tile ETag is computed from
+	keys := make([]string, 0, len(f.Properties))
+	for key := range f.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v, ok := toValue(f.Properties[key])
+		if !ok {
+			continue
+		}
+		feature.tags = append(feature.tags, l.key(key), l.value(v))
+	}
+	l.features = append(l.features, feature)
+}
+
+func (l *Layer) project(points []geometry.Point) []geometry.Point {
+	out := make([]geometry.Point, len(points))
+	for i, p := range points {
+		x, y := l.toTile(p[0], p[1])
+		out[i] = geometry.Point{x, y}
+	}
+	return out
+}
+
+func (l *Layer) encodePoints(points []geometry.Point) []uint32 {
+	var inside []geometry.Point
+	for _, p := range l.project(points) {
+		if clipRect.contains(p) {
+			inside = append(inside, p)
+		}
+	}
+	if len(inside) == 0 {
+		return nil
+	}
+
+	cursor := geometry.Point{}
+	commands := []uint32{command(cmdMoveTo, len(inside))}
+	for _, p := range inside {
+		commands = append(commands, delta(&cursor, p)...)
+	}
+	return commands
+}
+
+func (l *Layer) encodeLines(lines [][]geometry.Point) []uint32 {
+	var commands []uint32
+	cursor := geometry.Point{}
+	for _, line := range lines {
+		for _, part := range clipLine(l.project(line), clipRect) {
+			part = dedupe(simplify(roundPoints(part), simplifyTolerance))
+			if len(part) < 2 {
+				continue
+			}
+			commands = append(commands, command(cmdMoveTo, 1))
+			commands = append(commands, delta(&cursor, part[0])...)
+			commands = append(commands, command(cmdLineTo, len(part)-1))
+			for _, p := range part[1:] {
+				commands = append(commands, delta(&cursor, p)...)
+			}
+		}
+	}
+	return commands
+}
+
+func (l *Layer) encodePolygons(polygons [][][]geometry.Point) []uint32 {
+	var commands []uint32
+	cursor := geometry.Point{}
+	for _, polygon := range polygons {
+		for i, ring := range polygon {
+			clipped := clipRing(l.project(ring), clipRect)
+			if clipped == nil {
+				if i == 0 {
+					break // no exterior, so the holes are irrelevant
+				}
+				continue
+			}
+			clipped = dedupe(simplify(roundPoints(clipped), simplifyTolerance))
+			if len(clipped) < 4 {
+				if i == 0 {
+					break
+				}
+				continue
+			}
+
+			// Exterior rings must have positive area in tile coordinates, holes negative
+			area := ringArea(clipped)
+			if area == 0 {
+				continue
+			}
+			if (i == 0) != (area > 0) {
+				clipped = reverseRing(clipped)
+			}
+
+			// The closing point is implied by ClosePath
+			open := clipped[:len(clipped)-1]
+			commands = append(commands, command(cmdMoveTo, 1))
+			commands = append(commands, delta(&cursor, open[0])...)
+			commands = append(commands, command(cmdLineTo, len(open)-1))
+			for _, p := range open[1:] {
+				commands = append(commands, delta(&cursor, p)...)
+			}
+			commands = append(commands, command(cmdClosePath, 1))
+		}
+	}
+	return commands
+}
+
+func (l *Layer) key(k string) uint32 {
+	if i, ok := l.keyIndex[k]; ok {
+		return i
+	}
+	i := uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	l.keyIndex[k] = i
+	return i
+}
+
+func (l *Layer) value(v value) uint32 {
+	if i, ok := l.valueIndex[v]; ok {
+		return i
+	}
+	i := uint32(len(l.values))
+	l.values = append(l.values, v)
+	l.valueIndex[v] = i
+	return i
+}
+
+// command encodes a command integer
+func command(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+// delta encodes p relative to the cursor as zigzag parameters and advances the cursor
+func delta(cursor *geometry.Point, p geometry.Point) []uint32 {
+	dx := int32(p[0] - cursor[0])
+	dy := int32(p[1] - cursor[1])
+	*cursor = p
+	return []uint32{zigzag32(dx), zigzag32(dy)}
+}
+
+func zigzag32(n int32) uint32 {
+	return uint32((n << 1) ^ (n >> 31))
+}
+
+func roundPoints(points []geometry.Point) []geometry.Point {
+	for i := range points {
+		points[i] = geometry.Point{math.Round(points[i][0]), math.Round(points[i][1])}
+	}
+	return points
+}
+
+func reverseRing(ring []geometry.Point) []geometry.Point {
+	out := make([]geometry.Point, len(ring))
+	for i, p := range ring {
+		out[len(ring)-1-i] = p
+	}
+	return out
+}
+
+// featureID converts an ArcGIS object ID into a tile feature ID
+func featureID(id interface{}) (uint64, bool) {
+	switch v := id.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return uint64(n), err == nil && n >= 0
+	case int64:
+		return uint64(v), v >= 0
+	case int:
+		return uint64(v), v >= 0
+	case float64:
+		return uint64(v), v >= 0 && v == math.Trunc(v)
+	}
+	return 0, false
+}
+
+// toValue converts an attribute into a tile value; nulls and unsupported types are skipped
+func toValue(raw interface{}) (value, bool) {
+	switch v := raw.(type) {
+	case string:
+		return value{kind: 1, s: v}, true
+	case bool:
+		return value{kind: 7, b: v}, true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return value{kind: 6, i: i}, true
+		}
+		if f, err := v.Float64(); err == nil {
+			return value{kind: 3, d: f}, true
+		}
+	case int64:
+		return value{kind: 6, i: v}, true
+	case int:
+		return value{kind: 6, i: int64(v)}, true
+	case float64:
+		return value{kind: 3, d: v}, true
+	case nil:
+		return value{}, false
+	default:
+		return value{kind: 1, s: fmt.Sprint(v)}, true
+	}
+	return value{}, false
+}
@@ -0,0 +1,202 @@
+package mvt
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"wms-proxy/internal/geometry"
+	"wms-proxy/internal/transform"
+)
+
+func TestTileBounds(t *testing.T) {
+	bounds, err := TileBounds(0, 0, 0)
+	if err != nil {
+		t.Fatalf("TileBounds failed: %v", err)
+	}
+	if math.Abs(bounds.MinX+webMercatorHalfWorld) > 1e-6 || math.Abs(bounds.MaxY-webMercatorHalfWorld) > 1e-6 {
+		t.Errorf("Unexpected world bounds: %+v", bounds)
+	}
+
+	bounds, _ = TileBounds(1, 1, 0)
+	if bounds.MinX != 0 || bounds.MinY != 0 {
+		t.Errorf("Expected tile 1/1/0 to be the north-east quadrant, got %+v", bounds)
+	}
+
+	for _, tile := range [][3]int{{-1, 0, 0}, {1, 2, 0}, {2, 0, 4}, {25, 0, 0}} {
+		if _, err := TileBounds(tile[0], tile[1], tile[2]); err == nil {
+			t.Errorf("Expected error for tile %v", tile)
+		}
+	}
+}
+
+func TestTileRange(t *testing.T) {
+	bounds, _ := TileBounds(3, 2, 5)
+	if minX, minY, maxX, maxY := TileRange(bounds, 3); minX != 2 || minY != 5 || maxX != 2 || maxY != 5 {
+		t.Errorf("Expected the tile itself, got %d,%d - %d,%d", minX, minY, maxX, maxY)
+	}
+
+	world := transform.BBox{MinX: -1e9, MinY: -1e9, MaxX: 1e9, MaxY: 1e9}
+	if minX, minY, maxX, maxY := TileRange(world, 2); minX != 0 || minY != 0 || maxX != 3 || maxY != 3 {
+		t.Errorf("Expected the range to be clamped, got %d,%d - %d,%d", minX, minY, maxX, maxY)
+	}
+}
+
+func TestCommandEncoding(t *testing.T) {
+	// Examples from the vector tile specification
+	if command(cmdMoveTo, 1) != 9 || command(cmdLineTo, 3) != 26 || command(cmdClosePath, 1) != 15 {
+		t.Error("Unexpected command integers")
+	}
+	if zigzag32(25) != 50 || zigzag32(-1) != 1 || zigzag32(17) != 34 {
+		t.Error("Unexpected zigzag encoding")
+	}
+}
+
+func TestLayerPointFeature(t *testing.T) {
+	bounds := transform.BBox{MinX: 0, MinY: 0, MaxX: 4096, MaxY: 4096}
+	layer := NewLayer("points", bounds)
+
+	layer.AddFeature(&geometry.Feature{
+		ID:         json.Number("7"),
+		Properties: map[string]interface{}{"NAME": "A", "COUNT": json.Number("3"), "EMPTY": nil},
+		Geometry:   &geometry.Geometry{Type: geometry.TypePoint, Points: []geometry.Point{{25, 4096 - 17}}},
+	})
+	// Far outside the tile and its buffer
+	layer.AddFeature(&geometry.Feature{
+		Geometry: &geometry.Geometry{Type: geometry.TypePoint, Points: []geometry.Point{{10000, 10000}}},
+	})
+
+	if layer.Len() != 1 {
+		t.Fatalf("Expected 1 feature, got %d", layer.Len())
+	}
+	f := layer.features[0]
+	if !f.hasID || f.id != 7 || f.geomType != geomPoint {
+		t.Errorf("Unexpected feature: %+v", f)
+	}
+	if expected := []uint32{9, 50, 34}; !equalUint32(f.geometry, expected) {
+		t.Errorf("Expected geometry %v, got %v", expected, f.geometry)
+	}
+	if len(f.tags) != 4 || len(layer.keys) != 2 {
+		t.Errorf("Expected 2 tags (null skipped), got tags %v keys %v", f.tags, layer.keys)
+	}
+
+	tile := Marshal(layer)
+	if len(tile) == 0 || tile[0] != 0x1a {
+		t.Fatalf("Expected tile to start with the layers field, got % x", tile[:1])
+	}
+	if !bytes.Contains(tile, []byte("points")) || !bytes.Contains(tile, []byte("NAME")) {
+		t.Error("Expected layer name and keys in the encoded tile")
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	bounds := transform.BBox{MinX: 0, MinY: 0, MaxX: 4096, MaxY: 4096}
+	encode := func() []byte {
+		layer := NewLayer("points", bounds)
+		for i := 0; i < 3; i++ {
+			layer.AddFeature(&geometry.Feature{
+				Properties: map[string]interface{}{"A": "a", "B": json.Number("2"), "C": true, "D": 1.5, "E": "e", "F": "f", "G": "g", "H": "h"},
+				Geometry:   &geometry.Geometry{Type: geometry.TypePoint, Points: []geometry.Point{{float64(100 * i), 100}}},
+			})
+		}
+		return Marshal(layer)
+	}
+
+	first := encode()
+	for i := 0; i < 20; i++ {
+		if tile := encode(); !bytes.Equal(tile, first) {
+			t.Fatalf("Expected identical tiles from identical features, got % x and % x", first, tile)
+		}
+	}
+}
+
+func TestLayerPolygonClippingAndWinding(t *testing.T) {
+	bounds := transform.BBox{MinX: 0, MinY: 0, MaxX: 4096, MaxY: 4096}
+	layer := NewLayer("polygons", bounds)
+
+	// Counter-clockwise (RFC 7946) square larger than the tile
+	square := []geometry.Point{{-1000, -1000}, {5000, -1000}, {5000, 5000}, {-1000, 5000}, {-1000, -1000}}
+	layer.AddFeature(&geometry.Feature{
+		Geometry: &geometry.Geometry{Type: geometry.TypePolygon, Polygons: [][][]geometry.Point{{square}}},
+	})
+	if layer.Len() != 1 {
+		t.Fatalf("Expected 1 feature, got %d", layer.Len())
+	}
+
+	ring := decodeRing(layer.features[0].geometry)
+	for _, p := range ring {
+		if p[0] < -Buffer || p[0] > Extent+Buffer || p[1] < -Buffer || p[1] > Extent+Buffer {
+			t.Errorf("Point %v outside the buffered tile", p)
+		}
+	}
+	if area := ringArea(append(ring, ring[0])); area <= 0 {
+		t.Errorf("Expected exterior ring with positive area, got %f", area)
+	}
+}
+
+func TestClipLine(t *testing.T) {
+	r := rect{minX: 0, minY: 0, maxX: 10, maxY: 10}
+
+	parts := clipLine([]geometry.Point{{-5, 5}, {5, 5}, {15, 5}}, r)
+	if len(parts) != 1 || parts[0][0] != (geometry.Point{0, 5}) || parts[0][len(parts[0])-1] != (geometry.Point{10, 5}) {
+		t.Errorf("Unexpected clipped line: %v", parts)
+	}
+
+	// Leaves and re-enters the rectangle
+	parts = clipLine([]geometry.Point{{2, 2}, {2, 20}, {8, 20}, {8, 2}}, r)
+	if len(parts) != 2 {
+		t.Errorf("Expected 2 parts, got %v", parts)
+	}
+
+	if parts := clipLine([]geometry.Point{{20, 20}, {30, 30}}, r); len(parts) != 0 {
+		t.Errorf("Expected no parts, got %v", parts)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	line := []geometry.Point{{0, 0}, {1, 0.1}, {2, -0.1}, {3, 5}, {4, 6}, {5, 7}, {6, 8.1}, {7, 9}}
+	simplified := simplify(line, 1)
+	if len(simplified) >= len(line) {
+		t.Errorf("Expected fewer points, got %v", simplified)
+	}
+	if simplified[0] != line[0] || simplified[len(simplified)-1] != line[len(line)-1] {
+		t.Error("Expected endpoints to be kept")
+	}
+}
+
+// decodeRing decodes the first ring of polygon geometry commands
+func decodeRing(commands []uint32) []geometry.Point {
+	var ring []geometry.Point
+	x, y := int32(0), int32(0)
+	for i := 0; i < len(commands); {
+		id, count := commands[i]&0x7, int(commands[i]>>3)
+		i++
+		if id == cmdClosePath {
+			break
+		}
+		for j := 0; j < count; j++ {
+			x += unzigzag(commands[i])
+			y += unzigzag(commands[i+1])
+			i += 2
+			ring = append(ring, geometry.Point{float64(x), float64(y)})
+		}
+	}
+	return ring
+}
+
+func unzigzag(n uint32) int32 {
+	return int32(n>>1) ^ -int32(n&1)
+}
+
+func equalUint32(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package mvt
+
+import (
+	"math"
+
+	"wms-proxy/internal/geometry"
+)
+
+// simplifyTolerance is the Douglas-Peucker tolerance in tile coordinates.
+// Because tile coordinates scale with the zoom level, simplifying in tile
+// space removes detail that is invisible at the requested zoom.
+const simplifyTolerance = 1.0
+
+// simplify reduces a line with the Douglas-Peucker algorithm, keeping its endpoints
+func simplify(points []geometry.Point, tolerance float64) []geometry.Point {
+	if len(points) <= 2 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDist, index := 0.0, -1
+		for i := s.first + 1; i < s.last; i++ {
+			if d := segmentDistance(points[i], points[s.first], points[s.last]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index >= 0 && maxDist > tolerance {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+
+	out := make([]geometry.Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// segmentDistance returns the distance from p to the segment a-b
+func segmentDistance(p, a, b geometry.Point) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
+
+// dedupe removes consecutive duplicate points, as produced by rounding to tile coordinates
+func dedupe(points []geometry.Point) []geometry.Point {
+	out := make([]geometry.Point, 0, len(points))
+	for _, p := range points {
+		if len(out) == 0 || out[len(out)-1] != p {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ringArea returns the signed area of a ring using the surveyor's formula.
+// In tile coordinates (y down) a positive area means clockwise on screen.
+func ringArea(ring []geometry.Point) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return area / 2
+}
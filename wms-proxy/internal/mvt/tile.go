@@ -0,0 +1,76 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+
+	"wms-proxy/internal/transform"
+)
+
+// Tile layout constants
+const (
+	Extent  = 4096 // tile coordinate extent
+	Buffer  = 64   // clip buffer around the tile, in tile coordinates
+	MaxZoom = 24
+)
+
+// webMercatorHalfWorld is half the width of the EPSG:3857 world in meters
+const webMercatorHalfWorld = 20037508.342789244
+
+// TileBounds returns the EPSG:3857 bounds of an XYZ tile
+func TileBounds(z, x, y int) (transform.BBox, error) {
+	if z < 0 || z > MaxZoom {
+		return transform.BBox{}, fmt.Errorf("zoom level must be between 0 and %d", MaxZoom)
+	}
+	n := 1 << uint(z)
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return transform.BBox{}, fmt.Errorf("tile %d/%d/%d is outside the tile matrix", z, x, y)
+	}
+
+	size := 2 * webMercatorHalfWorld / float64(n)
+	return transform.BBox{
+		MinX: -webMercatorHalfWorld + float64(x)*size,
+		MaxX: -webMercatorHalfWorld + float64(x+1)*size,
+		MinY: webMercatorHalfWorld - float64(y+1)*size,
+		MaxY: webMercatorHalfWorld - float64(y)*size,
+	}, nil
+}
+
+// TileRange returns the XYZ tiles at zoom z intersecting EPSG:3857 bounds,
+// clamped to the tile matrix
+func TileRange(bounds transform.BBox, z int) (minX, minY, maxX, maxY int) {
+	n := 1 << uint(z)
+	size := 2 * webMercatorHalfWorld / float64(n)
+	clamp := func(v int) int {
+		return max(0, min(n-1, v))
+	}
+	// Tiles only touching the bounds at their edge are excluded; the
+	// tolerance absorbs rounding of bounds computed from tile coordinates
+	const tolerance = 1e-9
+	minX = clamp(int(math.Floor((bounds.MinX+webMercatorHalfWorld)/size + tolerance)))
+	maxX = clamp(int(math.Ceil((bounds.MaxX+webMercatorHalfWorld)/size-tolerance)) - 1)
+	minY = clamp(int(math.Floor((webMercatorHalfWorld-bounds.MaxY)/size + tolerance)))
+	maxY = clamp(int(math.Ceil((webMercatorHalfWorld-bounds.MinY)/size-tolerance)) - 1)
+	return minX, minY, max(minX, maxX), max(minY, maxY)
+}
+
+// BufferedBounds expands tile bounds by the clip buffer
+func BufferedBounds(bounds transform.BBox) transform.BBox {
+	pad := (bounds.MaxX - bounds.MinX) * Buffer / Extent
+	return transform.BBox{
+		MinX: bounds.MinX - pad,
+		MinY: bounds.MinY - pad,
+		MaxX: bounds.MaxX + pad,
+		MaxY: bounds.MaxY + pad,
+	}
+}
+
+// toTile returns the function mapping EPSG:3857 coordinates to tile coordinates
+// (origin top-left, y down)
+func toTile(bounds transform.BBox) func(x, y float64) (float64, float64) {
+	sx := Extent / (bounds.MaxX - bounds.MinX)
+	sy := Extent / (bounds.MaxY - bounds.MinY)
+	return func(x, y float64) (float64, float64) {
+		return math.Round((x - bounds.MinX) * sx), math.Round((bounds.MaxY - y) * sy)
+	}
+}
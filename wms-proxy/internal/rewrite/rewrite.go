@@ -0,0 +1,141 @@
+package rewrite
+
+import (
+	"bytes"
+	"io"
+)
+
+// chunkSize is how much of the source is read at a time
+const chunkSize = 32 * 1024
+
+// Reader replaces literal strings in a stream without buffering the whole
+// body. Only the last len(longest pattern)-1 bytes of each chunk are held back
+// so that matches spanning chunk boundaries are still found.
+type Reader struct {
+	src   io.ReadCloser
+	old   [][]byte
+	new   [][]byte
+	first [256]bool // first bytes of the patterns
+	hold  int       // bytes held back for matches spanning chunks
+
+	in     []byte // unprocessed input
+	out    []byte // processed output not yet returned
+	eof    bool
+	srcErr error
+}
+
+// NewReader returns a reader replacing each old string with the new string that
+// follows it, in the manner of strings.NewReplacer. At each position the first
+// matching pair wins. Closing the reader closes src.
+func NewReader(src io.ReadCloser, oldnew ...string) *Reader {
+	if len(oldnew)%2 == 1 {
+		panic("rewrite.NewReader: odd argument count")
+	}
+
+	r := &Reader{src: src}
+	for i := 0; i < len(oldnew); i += 2 {
+		if oldnew[i] == "" {
+			continue
+		}
+		r.old = append(r.old, []byte(oldnew[i]))
+		r.new = append(r.new, []byte(oldnew[i+1]))
+		r.first[oldnew[i][0]] = true
+		if len(oldnew[i])-1 > r.hold {
+			r.hold = len(oldnew[i]) - 1
+		}
+	}
+	return r
+}
+
+// Read returns rewritten bytes
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof && len(r.in) == 0 {
+			if r.srcErr != nil {
+				return 0, r.srcErr
+			}
+			return 0, io.EOF
+		}
+		if !r.eof {
+			r.fill()
+		}
+		r.process()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// Close closes the source
+func (r *Reader) Close() error {
+	return r.src.Close()
+}
+
+// fill reads the next chunk of the source
+func (r *Reader) fill() {
+	start := len(r.in)
+	if cap(r.in)-start < chunkSize {
+		grown := make([]byte, start, start+chunkSize)
+		copy(grown, r.in)
+		r.in = grown
+	}
+	n, err := r.src.Read(r.in[start : start+chunkSize])
+	r.in = r.in[:start+n]
+	if err != nil {
+		r.eof = true
+		if err != io.EOF {
+			r.srcErr = err
+		}
+	}
+}
+
+// process rewrites the input up to the point where a pattern could still be
+// cut off by the end of the chunk
+func (r *Reader) process() {
+	limit := len(r.in)
+	if !r.eof {
+		limit -= r.hold
+	}
+
+	out := r.out[:0]
+	i, copied := 0, 0
+	for i < limit {
+		if !r.first[r.in[i]] {
+			i++
+			continue
+		}
+		k := r.match(r.in[i:])
+		if k < 0 {
+			i++
+			continue
+		}
+		out = append(out, r.in[copied:i]...)
+		out = append(out, r.new[k]...)
+		i += len(r.old[k])
+		copied = i
+	}
+	if i > limit {
+		// A replaced match extended past the limit
+		limit = i
+	}
+	if limit > copied {
+		out = append(out, r.in[copied:limit]...)
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	r.out = out
+	r.in = append(r.in[:0], r.in[limit:]...)
+}
+
+// match returns the index of the first pattern prefixing b, or -1
+func (r *Reader) match(b []byte) int {
+	for k, old := range r.old {
+		if bytes.HasPrefix(b, old) {
+			return k
+		}
+	}
+	return -1
+}
@@ -0,0 +1,70 @@
+package rewrite
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		oldnew   []string
+		expected string
+	}{
+		{"no patterns", "unchanged", nil, "unchanged"},
+		{"single replacement", `{"url":"https://gis.internal/arcgis/rest"}`, []string{"https://gis.internal/arcgis/", "https://maps.example.com/arcgis/"}, `{"url":"https://maps.example.com/arcgis/rest"}`},
+		{"every occurrence", "a-a-a", []string{"a", "bb"}, "bb-bb-bb"},
+		{"first pair wins", "abc", []string{"ab", "1", "abc", "2"}, "1c"},
+		{"match at end", "xxabc", []string{"abc", "z"}, "xxz"},
+		{"partial match at end", "xxab", []string{"abc", "z"}, "xxab"},
+		{"replacement not rescanned", "aa", []string{"a", "aa"}, "aaaa"},
+		{"empty input", "", []string{"a", "b"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// One byte at a time puts every match across a chunk boundary
+			for _, src := range []io.Reader{strings.NewReader(test.input), iotest.OneByteReader(strings.NewReader(test.input))} {
+				output, err := io.ReadAll(NewReader(io.NopCloser(src), test.oldnew...))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(output) != test.expected {
+					t.Errorf("expected %q, got %q", test.expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestReader_LargeBody(t *testing.T) {
+	// Place matches across the 32 KiB chunk boundaries
+	record := strings.Repeat("x", 1000) + "http://backend/arcgis/"
+	input := strings.Repeat(record, 200)
+	expected := strings.Repeat(strings.Repeat("x", 1000)+"https://proxy/arcgis/", 200)
+
+	output, err := io.ReadAll(NewReader(io.NopCloser(strings.NewReader(input)), "http://backend/arcgis/", "https://proxy/arcgis/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("rewritten body differs from the expected one (got %d bytes, expected %d)", len(output), len(expected))
+	}
+}
+
+func TestReader_SourceError(t *testing.T) {
+	failure := errors.New("connection reset")
+	src := io.MultiReader(strings.NewReader("partial body"), iotest.ErrReader(failure))
+
+	output, err := io.ReadAll(NewReader(io.NopCloser(src), "body", "content"))
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the source error, got %v", err)
+	}
+	if string(output) != "partial content" {
+		t.Errorf("expected the data read before the error, got %q", output)
+	}
+}
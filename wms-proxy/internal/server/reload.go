@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"wms-proxy/internal/cache"
+	"wms-proxy/internal/config"
+	"wms-proxy/internal/tilefile"
+)
+
+// replacedGrace is how long replaced tile files, caches and backend
+// connections stay open for the requests still using them
+const replacedGrace = time.Minute
+
+// Reload reads the configuration again and swaps in routes, backends and
+// tile files built from it. The listener and open connections are kept, and
+// requests in flight finish on the previous routes. Caches are kept unless
+// their settings changed, and backends unless their settings or the response
+// cache changed. An invalid configuration is rejected and the active one kept.
+func (s *Server) Reload() error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	cfg, err := config.LoadFile(s.config.File)
+	if err != nil {
+		var invalid config.Errors
+		if errors.As(err, &invalid) {
+			for _, fieldErr := range invalid {
+				s.logger.Error("Invalid configuration", "setting", fieldErr.Path, "error", fieldErr.Message)
+			}
+		}
+		s.logger.Error("Configuration reload failed, keeping the active configuration", "error", err)
+		return err
+	}
+	for _, setting := range restartSettings(s.config, cfg) {
+		s.logger.Warn("Setting changed but requires a restart", "setting", setting)
+	}
+
+	next, err := newServer(cfg, s.logger, s.level, s)
+	if err != nil {
+		s.logger.Error("Configuration reload failed, keeping the active configuration", "error", err)
+		return err
+	}
+
+	// Swap the routes before stopping the background tasks of the previous ones
+	s.router.Store(next.setupRoutes())
+	stopPrevious := s.stopBackground
+	s.stopBackground = next.startBackground()
+	stopPrevious()
+
+	replaced := s.replacedBy(next)
+	time.AfterFunc(replacedGrace, replaced.close)
+
+	s.config = next.config
+	s.defaultBackend = next.defaultBackend
+	s.backends = next.backends
+	s.crawler = next.crawler
+	s.tileCache = next.tileCache
+	s.responseCache = next.responseCache
+	s.metadataCache = next.metadataCache
+	s.cachePolicy = next.cachePolicy
+	s.tileFiles = next.tileFiles
+	s.level.Set(parseLogLevel(cfg.LogLevel))
+
+	s.logger.Info("Configuration reloaded",
+		"file", cfg.File,
+		"backends", len(cfg.Backends),
+		"tile_files", len(cfg.TileFiles))
+	return nil
+}
+
+// replacedResources are the resources of a configuration that the next one
+// does not use
+type replacedResources struct {
+	tileFiles  map[string]*tilefile.Reader
+	caches     []*cache.TileCache
+	transports []*http.Transport
+}
+
+// replacedBy collects the resources of s that next does not use
+func (s *Server) replacedBy(next *Server) replacedResources {
+	replaced := replacedResources{tileFiles: s.tileFiles}
+	if s.tileCache != next.tileCache {
+		replaced.caches = append(replaced.caches, s.tileCache)
+	}
+	if s.responseCache != nil && s.responseCache != next.responseCache {
+		replaced.caches = append(replaced.caches, s.responseCache)
+	}
+	if s.metadataCache != nil && s.metadataCache != next.metadataCache {
+		replaced.caches = append(replaced.caches, s.metadataCache)
+	}
+	for _, b := range append([]*backend{s.defaultBackend}, s.backends...) {
+		if next.backend(b.config.Name) != b {
+			replaced.transports = append(replaced.transports, b.transport)
+		}
+	}
+	return replaced
+}
+
+// close closes the replaced resources
+func (r replacedResources) close() {
+	for _, reader := range r.tileFiles {
+		reader.Close()
+	}
+	for _, c := range r.caches {
+		c.Close()
+	}
+	for _, transport := range r.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// restartSettings names the settings of the listener that differ between two
+// configurations; they only take effect on restart
+func restartSettings(active, next *config.Config) []string {
+	var changed []string
+	if active.ProxyPort != next.ProxyPort {
+		changed = append(changed, "PROXY_PORT")
+	}
+	if active.EnableHTTPS != next.EnableHTTPS || active.CertFile != next.CertFile || active.KeyFile != next.KeyFile {
+		changed = append(changed, "ENABLE_HTTPS, CERT_FILE and KEY_FILE")
+	}
+	if active.WatchInterval != next.WatchInterval {
+		changed = append(changed, "CONFIG_WATCH_INTERVAL")
+	}
+	return changed
+}
+
+// watchConfig reloads the configuration when the contents of file change
+func (s *Server) watchConfig(ctx context.Context, file string, interval time.Duration) {
+	fingerprint := func() []byte {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil
+		}
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+
+	last := fingerprint()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fingerprint()
+			// A file being replaced may briefly be missing; it is checked again on the next tick
+			if current == nil || bytes.Equal(current, last) {
+				continue
+			}
+			last = current
+			s.logger.Info("Configuration file changed, reloading", "file", file)
+			s.Reload()
+		}
+	}
+}
@@ -5,49 +5,345 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"wms-proxy/internal/cache"
 	"wms-proxy/internal/client"
 	"wms-proxy/internal/config"
 	"wms-proxy/internal/handlers"
+	"wms-proxy/internal/services"
+	"wms-proxy/internal/tilefile"
 )
 
 // Server represents the WMS proxy server
 type Server struct {
-	config       *config.Config
-	logger       *slog.Logger
-	httpServer   *http.Server
-	arcgisClient *client.ArcGISClient
+	config         *config.Config
+	logger         *slog.Logger
+	level          *slog.LevelVar // log level, changed on reload
+	httpServer     *http.Server
+	router         atomic.Pointer[mux.Router] // routes of the active configuration, swapped on reload
+	stopBackground context.CancelFunc         // stops the background tasks of the active configuration
+	reloadMutex    sync.Mutex
+	defaultBackend *backend
+	backends       []*backend // named backends from the routing table
+	crawler        *services.ServiceCrawler
+	tileCache      *cache.TileCache // vector tiles of all backends
+	responseCache  *cache.TileCache // upstream responses of all backends; nil when disabled
+	metadataCache  *cache.TileCache // service definitions of all backends; nil when disabled
+	cachePolicy    *handlers.CachePolicy
+	tileFiles      map[string]*tilefile.Reader // read-only tile layers served under /tiles
+}
+
+// backend holds the client and shared services of one upstream ArcGIS server
+type backend struct {
+	config     config.Backend
+	settings   interface{}     // settings the backend was built from, see backendSettings
+	transport  *http.Transport // connections of the pool and token requests
+	pool       *client.Pool
+	client     *client.ResilientClient      // retries and circuit breaker around pool
+	bulkhead   *client.Bulkhead             // concurrency limit and request queue around client
+	api        client.ArcGISClientInterface // client used by the handlers: metadata and response caches and request coalescing around bulkhead
+	srDetector *services.BackendSRDetector
 }
 
 // New creates a new server instance
-func New(cfg *config.Config) *Server {
+func New(cfg *config.Config) (*Server, error) {
 	// Setup logger
-	logger := setupLogger(cfg.LogLevel)
+	level := new(slog.LevelVar)
+	level.Set(parseLogLevel(cfg.LogLevel))
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+
+	return newServer(cfg, logger, level, nil)
+}
+
+// newServer creates the backends, caches and tile files of cfg. On reload,
+// the caches of previous are kept when their settings have not changed, and
+// so are its backends when neither their settings nor the response and
+// metadata caches changed.
+func newServer(cfg *config.Config, logger *slog.Logger, level *slog.LevelVar, previous *Server) (*Server, error) {
+	settings := cacheSettings(cfg)
+	caches := make([]*cache.TileCache, len(settings))
+	for i := range settings {
+		if previous != nil && cacheSettings(previous.config)[i] == settings[i] {
+			caches[i] = previous.caches()[i]
+			continue
+		}
+		c, err := newCache(settings[i])
+		if err != nil {
+			return nil, err
+		}
+		caches[i] = c
+	}
+	tileCache, responseCache, metadataCache := caches[0], caches[1], caches[2]
+
+	// Kept backends keep their circuit breakers, tokens, detected spatial
+	// references, cached metadata and the requests counted by their bulkheads
+	buildBackend := func(backendConfig config.Backend) (*backend, error) {
+		if previous != nil && previous.responseCache == responseCache && previous.metadataCache == metadataCache {
+			if b := previous.backend(backendConfig.Name); b != nil && reflect.DeepEqual(b.settings, backendSettings(cfg, backendConfig)) {
+				return b, nil
+			}
+		}
+		return newBackend(backendConfig, cfg, responseCache, metadataCache, logger)
+	}
+
+	defaultBackend, err := buildBackend(cfg.DefaultBackend())
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		config:         cfg,
+		logger:         logger,
+		level:          level,
+		defaultBackend: defaultBackend,
+		tileCache:      tileCache,
+		responseCache:  responseCache,
+		metadataCache:  metadataCache,
+		cachePolicy:    handlers.NewCachePolicy(cfg.CacheControl, cfg.LayerCacheControl),
+	}
+	if cfg.Discovery {
+		s.crawler = services.NewServiceCrawler(defaultBackend.api, logger, defaultBackend.config.BaseURL(),
+			services.ServicesRoot(defaultBackend.config.ServicePath), cfg.DiscoveryInclude, cfg.DiscoveryExclude)
+	}
+	for _, backendConfig := range cfg.Backends {
+		b, err := buildBackend(backendConfig)
+		if err != nil {
+			return nil, err
+		}
+		s.backends = append(s.backends, b)
+	}
+	if len(cfg.TileFiles) > 0 {
+		s.tileFiles = make(map[string]*tilefile.Reader, len(cfg.TileFiles))
+		for name, path := range cfg.TileFiles {
+			reader, err := tilefile.Open(path)
+			if err != nil {
+				s.closeTileFiles()
+				return nil, fmt.Errorf("tile file %s: %w", name, err)
+			}
+			s.tileFiles[name] = reader
+			metadata := reader.Metadata()
+			logger.Info("Opened tile file",
+				"name", name,
+				"path", path,
+				"package", reader.Format(),
+				"format", metadata.Format,
+				"zoom", fmt.Sprintf("%d-%d", metadata.MinZoom, metadata.MaxZoom))
+		}
+	}
+
+	return s, nil
+}
+
+// cacheConfig holds the settings a cache is built from
+type cacheConfig struct {
+	name                     string
+	enabled                  bool
+	size                     int64 // bytes kept by the memory and filesystem storages
+	ttl                      time.Duration
+	whileRevalidate, ifError time.Duration
+	storage, dir             string
+	redis                    config.Redis
+}
+
+// cacheSettings returns the settings of the tile, response and metadata
+// caches, in the order of Server.caches. Expired tiles and responses are
+// served while they are refreshed and while the backend fails, expired
+// service definitions only while it fails.
+func cacheSettings(cfg *config.Config) []cacheConfig {
+	base := cacheConfig{enabled: true, whileRevalidate: cfg.StaleRevalidate, ifError: cfg.StaleIfError,
+		storage: cfg.CacheStorage, dir: cfg.CacheDir, redis: cfg.Redis}
+
+	tiles, responses, metadata := base, base, base
+	tiles.name, tiles.size, tiles.ttl = "tiles", int64(cfg.TileCacheSize)<<20, cfg.TileCacheTTL
+	responses.name, responses.size, responses.ttl = "responses", int64(cfg.ResponseCacheSize)<<20, cfg.ResponseCacheTTL
+	responses.enabled = cfg.ResponseCacheSize > 0 || cfg.CacheStorage == "redis"
+	metadata.name, metadata.size, metadata.ttl = "metadata", metadataCacheSize, cfg.MetadataCacheTTL
+	metadata.enabled, metadata.whileRevalidate = cfg.MetadataCacheTTL > 0, 0
+	return []cacheConfig{tiles, responses, metadata}
+}
+
+// caches returns the tile, response and metadata caches
+func (s *Server) caches() []*cache.TileCache {
+	return []*cache.TileCache{s.tileCache, s.responseCache, s.metadataCache}
+}
 
-	// Create ArcGIS client
-	arcgisClient := client.NewArcGISClient(cfg.GetArcGISBaseURL(), cfg.RequestTimeout)
+// newCache creates the cache described by settings, or nil when it is disabled
+func newCache(settings cacheConfig) (*cache.TileCache, error) {
+	if !settings.enabled {
+		return nil, nil
+	}
+	storage, err := newCacheStorage(settings)
+	if err != nil {
+		return nil, err
+	}
+	c := cache.NewTileCacheWithStorage(storage, settings.ttl)
+	c.SetStale(settings.whileRevalidate, settings.ifError)
+	return c, nil
+}
+
+// backendSettings returns the settings a backend is built from
+func backendSettings(cfg *config.Config, b config.Backend) interface{} {
+	return struct {
+		backend    config.Backend
+		maxFails   int
+		resilience client.ResilienceConfig
+		coalesce   bool
+		detection  services.SRDetectorConfig
+	}{b, cfg.MaxFails, resilienceConfig(cfg), cfg.Coalesce, srDetectorConfig(cfg, b)}
+}
+
+// backend returns the backend called name, or nil
+func (s *Server) backend(name string) *backend {
+	if name == s.defaultBackend.config.Name {
+		return s.defaultBackend
+	}
+	for _, b := range s.backends {
+		if b.config.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// metadataCacheSize bounds the memory and filesystem storages of service
+// definitions, which are a few kilobytes each
+const metadataCacheSize = 16 << 20
+
+// newCacheStorage creates the storage of a cache from the configured kind.
+// The size bounds the memory and filesystem storages, where a cache of size 0
+// stores nothing; Redis leaves the size to the server.
+func newCacheStorage(settings cacheConfig) (cache.Storage, error) {
+	switch {
+	case settings.storage == "redis":
+		return cache.NewRedisStorage(redisConfig(settings.redis, settings.name)), nil
+	case settings.size == 0:
+		return cache.NewMemoryStorage(0), nil
+	case settings.storage == "filesystem":
+		storage, err := cache.NewFileStorage(filepath.Join(settings.dir, settings.name), settings.size)
+		if err != nil {
+			return nil, fmt.Errorf("%s cache: %w", settings.name, err)
+		}
+		return storage, nil
+	default:
+		return cache.NewMemoryStorage(settings.size), nil
+	}
+}
 
-	return &Server{
-		config:       cfg,
-		logger:       logger,
-		arcgisClient: arcgisClient,
+// newBackend creates the replica pool, resilient client and SR detector of a
+// backend; responses and service definitions are cached in responseCache and
+// metadataCache unless they are nil
+func newBackend(cfg config.Backend, proxyConfig *config.Config, responseCache, metadataCache *cache.TileCache, logger *slog.Logger) (*backend, error) {
+	transport, err := client.NewTransport(transportConfig(cfg.Transport))
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", cfg.Name, err)
+	}
+
+	backendLogger := logger.With("backend", cfg.Name)
+	pool := client.NewPool(cfg.URLs, cfg.Timeout, transport, cfg.LoadBalancing, proxyConfig.MaxFails, backendLogger)
+
+	// Tokens are shared by the replicas of a backend and use the same TLS and proxy settings
+	tokenClient := &http.Client{Timeout: cfg.Timeout, Transport: transport}
+	switch auth := cfg.Auth; {
+	case auth.ClientID != "":
+		pool.SetTokenProvider(client.NewOAuthTokenProvider(tokenClient, auth.PortalURL, auth.ClientID, auth.ClientSecret))
+	case auth.Username != "":
+		tokenURL := auth.TokenURL
+		if tokenURL == "" {
+			tokenURL = cfg.BaseURL() + "/arcgis/tokens/generateToken"
+		}
+		pool.SetTokenProvider(client.NewServerTokenProvider(tokenClient, tokenURL, auth.Username, auth.Password))
+	default:
+		pool.SetToken(auth.Token)
 	}
+	arcgisClient := client.NewResilientClient(pool, resilienceConfig(proxyConfig), backendLogger)
+	bulkhead := client.NewBulkhead(arcgisClient, bulkheadConfig(cfg.Bulkhead), backendLogger)
+	var api client.ArcGISClientInterface = bulkhead
+	if proxyConfig.Coalesce {
+		api = client.NewCoalescingClient(bulkhead, backendLogger)
+	}
+	if responseCache != nil {
+		api = client.NewCachingClient(api, responseCache.Namespace(cfg.Name), cfg.BaseURL(), backendLogger)
+	}
+	var definitions *services.MetadataCache
+	if metadataCache != nil {
+		definitions = services.NewMetadataCache(api, backendLogger, metadataCache.Namespace(cfg.Name))
+		api = definitions
+	}
+	srDetector := services.NewBackendSRDetectorWithConfig(api, backendLogger, srDetectorConfig(proxyConfig, cfg))
+	if definitions != nil {
+		// A changed service definition may come with a new spatial reference
+		definitions.OnChange(func(change services.MetadataChange) {
+			srDetector.Invalidate(change.ServicePath)
+		})
+	}
+
+	return &backend{
+		config:     cfg,
+		settings:   backendSettings(proxyConfig, cfg),
+		transport:  transport,
+		pool:       pool,
+		client:     arcgisClient,
+		bulkhead:   bulkhead,
+		api:        api,
+		srDetector: srDetector,
+	}, nil
+}
+
+// closeTileFiles closes the open tile files
+func (s *Server) closeTileFiles() {
+	for _, reader := range s.tileFiles {
+		reader.Close()
+	}
+}
+
+// closeCaches closes the storages of the caches
+func (s *Server) closeCaches() {
+	s.tileCache.Close()
+	if s.responseCache != nil {
+		s.responseCache.Close()
+	}
+	if s.metadataCache != nil {
+		s.metadataCache.Close()
+	}
+}
+
+// Handler returns the proxy's routes without starting the server, e.g. to
+// request tiles in-process
+func (s *Server) Handler() http.Handler {
+	return s.setupRoutes()
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	// Setup routes
-	router := s.setupRoutes()
+	// Setup routes; requests are routed by the routes of the active configuration
+	s.router.Store(s.setupRoutes())
+	s.stopBackground = s.startBackground()
+	defer func() {
+		s.reloadMutex.Lock()
+		defer s.reloadMutex.Unlock()
+		s.stopBackground()
+		s.closeTileFiles()
+		s.closeCaches()
+	}()
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.router.Load().ServeHTTP(w, r)
+	})
 
 	// Create HTTP server
+	cfg := s.config
 	s.httpServer = &http.Server{
-		Addr:         s.config.GetProxyAddress(),
+		Addr:         cfg.GetProxyAddress(),
 		Handler:      router,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
@@ -57,20 +353,21 @@ func (s *Server) Start() error {
 	// Start server in a goroutine
 	go func() {
 		protocol := "HTTP"
-		if s.config.EnableHTTPS {
+		if cfg.EnableHTTPS {
 			protocol = "HTTPS"
 		}
 
 		s.logger.Info("Starting WMS proxy server",
 			"protocol", protocol,
-			"address", s.config.GetProxyAddress(),
-			"arcgis_host", s.config.ArcGISHost,
-			"https_enabled", s.config.EnableHTTPS,
+			"address", cfg.GetProxyAddress(),
+			"arcgis_host", cfg.ArcGISHost,
+			"backends", len(cfg.Backends),
+			"https_enabled", cfg.EnableHTTPS,
 		)
 
 		var err error
-		if s.config.EnableHTTPS {
-			err = s.httpServer.ListenAndServeTLS(s.config.CertFile, s.config.KeyFile)
+		if cfg.EnableHTTPS {
+			err = s.httpServer.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
 		} else {
 			err = s.httpServer.ListenAndServe()
 		}
@@ -81,32 +378,97 @@ func (s *Server) Start() error {
 		}
 	}()
 
+	// Reload the configuration file when it changes
+	if cfg.File != "" && cfg.WatchInterval > 0 {
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+		go s.watchConfig(watchCtx, cfg.File, cfg.WatchInterval)
+	}
+
 	// Wait for interrupt signal to gracefully shutdown
 	return s.waitForShutdown()
 }
 
+// startBackground starts the health checks, spatial reference refreshes and
+// service discovery of the active configuration, and returns their stop function
+func (s *Server) startBackground() context.CancelFunc {
+	// Actively probe replicas so that unhealthy ones return to rotation
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	if s.config.HealthInterval > 0 {
+		for _, b := range append([]*backend{s.defaultBackend}, s.backends...) {
+			if len(b.config.URLs) > 1 {
+				b.pool.StartHealthChecks(backgroundCtx, s.config.HealthInterval)
+			}
+		}
+	}
+
+	// Refresh detected spatial references before they expire
+	for _, b := range append([]*backend{s.defaultBackend}, s.backends...) {
+		b.srDetector.Start(backgroundCtx)
+	}
+
+	// Keep the catalog of discovered services current
+	if s.crawler != nil {
+		s.crawler.Start(backgroundCtx, s.config.DiscoveryInterval)
+	}
+	return stopBackground
+}
+
 // setupRoutes configures the HTTP routes
 func (s *Server) setupRoutes() *mux.Router {
 	router := mux.NewRouter()
 
 	// Add logging middleware
 	router.Use(s.loggingMiddleware)
+	if s.config.PublicURL != "" {
+		router.Use(publicURLMiddleware(s.config.PublicURL))
+	}
 
 	// Health check endpoint
-	healthHandler := handlers.NewHealthHandler(s.arcgisClient)
+	healthHandler := handlers.NewHealthHandler(s.defaultBackend.pool)
+	healthHandler.AddCircuit(s.defaultBackend.config.Name, s.defaultBackend.client)
+	healthHandler.AddQueue(s.defaultBackend.config.Name, s.defaultBackend.bulkhead)
+	for _, b := range s.backends {
+		healthHandler.AddBackend(b.config.Name, b.pool)
+		healthHandler.AddCircuit(b.config.Name, b.client)
+		healthHandler.AddQueue(b.config.Name, b.bulkhead)
+	}
+	healthHandler.AddCache("tiles", s.tileCache)
+	if s.responseCache != nil {
+		healthHandler.AddCache("responses", s.responseCache)
+	}
+	if s.metadataCache != nil {
+		healthHandler.AddCache("metadata", s.metadataCache)
+	}
 	router.Handle("/health", healthHandler).Methods("GET")
 
-	// ArcGIS REST API proxy (direct passthrough)
-	arcgisProxyHandler := handlers.NewArcGISProxyHandler(s.arcgisClient, s.logger, s.config.GetArcGISBaseURL())
-
-	// Handle ArcGIS REST API paths directly
-	router.PathPrefix("/arcgis/").Handler(arcgisProxyHandler).Methods("GET")
-
-	// WMS endpoints (for WMS clients)
-	wmsHandler := handlers.NewWMSHandler(s.arcgisClient, s.logger, s.config.GetArcGISBaseURL(), s.config.ArcGISService)
+	// Named backends are registered first so that their prefixed routes take
+	// precedence over the default backend's /arcgis/ and /ogcapi/ prefixes
+	for _, b := range s.backends {
+		s.registerBackend(router, b, "/"+b.config.Name)
+		s.logger.Info("Registered backend",
+			"name", b.config.Name,
+			"urls", b.config.URLs,
+			"service", b.config.ServicePath,
+		)
+	}
+	wmsHandler := s.registerBackend(router, s.defaultBackend, "")
+
+	// Map services found by discovery are published as WMS layer sets under /services
+	if s.crawler != nil {
+		catalogHandler := handlers.NewCatalogHandler(s.crawler, s.defaultBackend.api, s.defaultBackend.srDetector,
+			s.logger, s.defaultBackend.config.BaseURL(), "/services", s.config.JPEGQuality)
+		catalogHandler.SetCachePolicy(s.cachePolicy)
+		router.Handle("/services", catalogHandler).Methods("GET")
+		router.PathPrefix("/services/").Handler(catalogHandler).Methods("GET")
+	}
 
-	// Handle WMS requests
-	router.Handle("/wms", wmsHandler).Methods("GET")
+	// Tile layers served from MBTiles and GeoPackage files
+	if len(s.tileFiles) > 0 {
+		tileFileHandler := handlers.NewTileFileHandler(s.tileFiles, s.logger, "/tiles")
+		tileFileHandler.SetCachePolicy(s.cachePolicy)
+		router.PathPrefix("/tiles/").Handler(tileFileHandler).Methods("GET")
+	}
 
 	// Root path defaults to WMS for backward compatibility
 	router.Handle("/", wmsHandler).Methods("GET")
@@ -114,6 +476,79 @@ func (s *Server) setupRoutes() *mux.Router {
 	return router
 }
 
+// registerBackend mounts the endpoints of a backend, suffixing each endpoint
+// path with mount (e.g. /wms/parcels for mount "/parcels"), and returns its WMS handler
+func (s *Server) registerBackend(router *mux.Router, b *backend, mount string) *handlers.WMSHandler {
+	baseURL := b.config.BaseURL()
+	servicePath := b.config.ServicePath
+
+	// ArcGIS REST API proxy (direct passthrough); /arcgis/{name}/rest/... maps to /arcgis/rest/... upstream
+	arcgisProxyHandler := handlers.NewArcGISProxyHandler(b.api, b.srDetector, s.logger, baseURL, "/arcgis"+mount)
+	arcgisProxyHandler.SetBackendOrigins(b.config.URLs)
+	arcgisProxyHandler.SetReadOnly(b.config.ReadOnly)
+	arcgisProxyHandler.SetBodyLimits(int64(s.config.MaxFormSize)<<20, int64(s.config.MaxUploadSize)<<20)
+	if mount == "" {
+		router.PathPrefix("/arcgis/").Handler(arcgisProxyHandler).Methods("GET", "POST")
+	} else {
+		router.PathPrefix("/arcgis"+mount+"/").Handler(rewritePrefix("/arcgis"+mount, "/arcgis", arcgisProxyHandler)).Methods("GET", "POST")
+	}
+
+	// WMS endpoint (for WMS clients)
+	wmsHandler := handlers.NewWMSHandler(b.api, b.srDetector, s.logger, baseURL, servicePath, s.config.JPEGQuality)
+	wmsHandler.SetCachePolicy(s.cachePolicy)
+	router.Handle("/wms"+mount, wmsHandler).Methods("GET")
+
+	// WFS endpoint (for vector clients)
+	wfsHandler := handlers.NewWFSHandler(b.api, b.srDetector, s.logger, baseURL, servicePath)
+	wfsHandler.SetCachePolicy(s.cachePolicy)
+	router.Handle("/wfs"+mount, wfsHandler).Methods("GET")
+
+	// OGC API - Features endpoints
+	ogcapiHandler := handlers.NewOGCAPIHandler(b.api, b.srDetector, s.logger, baseURL, servicePath, "/ogcapi"+mount)
+	router.Handle("/ogcapi"+mount, ogcapiHandler).Methods("GET")
+	router.PathPrefix("/ogcapi" + mount + "/").Handler(ogcapiHandler).Methods("GET")
+
+	// Vector tile endpoint
+	vectorTileHandler := handlers.NewVectorTileHandler(b.api, b.srDetector, s.logger, baseURL, servicePath, "/vt"+mount, s.tileCache.Namespace(b.config.Name))
+	vectorTileHandler.SetCachePolicy(s.cachePolicy)
+	router.PathPrefix("/vt" + mount + "/").Handler(vectorTileHandler).Methods("GET")
+
+	// KML super-overlays and KMZ overlays for Google Earth, rendered through WMS GetMap
+	kmlHandler := handlers.NewKMLHandler(wmsHandler, s.logger, "/wms"+mount)
+	router.Handle("/kml"+mount, kmlHandler).Methods("GET")
+	router.HandleFunc("/kmz"+mount, kmlHandler.ServeKMZ).Methods("GET")
+
+	return wmsHandler
+}
+
+// rewritePrefix replaces the path prefix from with to before calling next
+func rewritePrefix(from, to string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = to + strings.TrimPrefix(r.URL.Path, from)
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// publicURLMiddleware presents the configured external URL to the handlers as
+// X-Forwarded-* headers, replacing any sent by the client or a reverse proxy
+func publicURLMiddleware(publicURL string) mux.MiddlewareFunc {
+	// Validated by config.Load
+	u, _ := url.Parse(publicURL)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("X-Forwarded-Proto", u.Scheme)
+			r.Header.Set("X-Forwarded-Host", u.Host)
+			r.Header.Set("X-Forwarded-Prefix", u.Path)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // loggingMiddleware logs HTTP requests
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -147,12 +582,16 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// waitForShutdown waits for interrupt signal and gracefully shuts down the server
+// waitForShutdown waits for interrupt signal and gracefully shuts down the
+// server; SIGHUP reloads the configuration
 func (s *Server) waitForShutdown() error {
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	<-quit
+	for sig := <-quit; sig == syscall.SIGHUP; sig = <-quit {
+		s.logger.Info("Received SIGHUP, reloading configuration")
+		s.Reload()
+	}
 	s.logger.Info("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -166,27 +605,16 @@ func (s *Server) waitForShutdown() error {
 	return nil
 }
 
-// setupLogger creates a structured logger based on log level
-func setupLogger(level string) *slog.Logger {
-	var logLevel slog.Level
-
+// parseLogLevel returns the slog level of a configured log level
+func parseLogLevel(level string) slog.Level {
 	switch level {
 	case "debug":
-		logLevel = slog.LevelDebug
-	case "info":
-		logLevel = slog.LevelInfo
+		return slog.LevelDebug
 	case "warn":
-		logLevel = slog.LevelWarn
+		return slog.LevelWarn
 	case "error":
-		logLevel = slog.LevelError
+		return slog.LevelError
 	default:
-		logLevel = slog.LevelInfo
+		return slog.LevelInfo
 	}
-
-	opts := &slog.HandlerOptions{
-		Level: logLevel,
-	}
-
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	return slog.New(handler)
 }
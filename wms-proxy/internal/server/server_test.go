@@ -0,0 +1,225 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/config"
+	"wms-proxy/pkg/kml"
+)
+
+// newTestUpstream serves the metadata and export images of an ArcGIS map service
+func newTestUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	var tile bytes.Buffer
+	png.Encode(&tile, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/export") {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(tile.Bytes())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"spatialReference":{"wkid":4326,"latestWkid":4326},"layers":[{"id":0,"name":"Parcels"}],"supportedImageFormatTypes":"PNG32,PNG,JPG"}`)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// newTestServer builds a server whose default and parcels backends use upstream
+func newTestServer(t *testing.T, upstream *httptest.Server) *Server {
+	t.Helper()
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	t.Setenv("ARCGIS_SCHEME", "http")
+	t.Setenv("ARCGIS_HOST", host)
+	t.Setenv("ARCGIS_SERVICE", "/arcgis/rest/services/Base/MapServer")
+	t.Setenv("BACKENDS", "parcels")
+	t.Setenv("BACKEND_PARCELS_URL", upstream.URL)
+	t.Setenv("BACKEND_PARCELS_SERVICE", "/arcgis/rest/services/Parcels/MapServer")
+
+	cfg, err := config.LoadFile("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := newServer(cfg, logger, new(slog.LevelVar), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestGoogleEarthRoutes(t *testing.T) {
+	s := newTestServer(t, newTestUpstream(t))
+	router := s.setupRoutes()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+	}{
+		{"default KML", "/kml", kml.ContentTypeKML},
+		{"default KMZ", "/kmz", kml.ContentTypeKMZ},
+		{"named backend KML", "/kml/parcels", kml.ContentTypeKML},
+		{"named backend KMZ", "/kmz/parcels", kml.ContentTypeKMZ},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := url.Values{"LAYERS": {"0"}, "BBOX": {"-74.3,40.6,-74.1,40.8"}, "WIDTH": {"64"}}
+			req := httptest.NewRequest("GET", test.path+"?"+query.Encode(), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Fatalf("expected content type %s, got %s", test.contentType, contentType)
+			}
+			if test.contentType != kml.ContentTypeKMZ {
+				return
+			}
+
+			archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+			if err != nil {
+				t.Fatalf("response is not a KMZ archive: %v", err)
+			}
+			names := make([]string, 0, len(archive.File))
+			for _, file := range archive.File {
+				names = append(names, file.Name)
+			}
+			if strings.Join(names, ",") != "doc.kml,overlay.png" {
+				t.Errorf("expected doc.kml and overlay.png, got %v", names)
+			}
+		})
+	}
+}
+
+func TestCacheStorage(t *testing.T) {
+	upstream := newTestUpstream(t)
+
+	t.Run("redis ignores the cache sizes", func(t *testing.T) {
+		t.Setenv("CACHE_STORAGE", "redis")
+		t.Setenv("REDIS_ADDR", "127.0.0.1:6379")
+		t.Setenv("TILE_CACHE_SIZE", "0")
+		s := newTestServer(t, upstream)
+		defer s.closeCaches()
+
+		for name, c := range map[string]interface{ Stats() map[string]interface{} }{
+			"tiles": s.tileCache, "responses": s.responseCache, "metadata": s.metadataCache,
+		} {
+			if stats := c.Stats(); stats["storage"] != "redis" || !strings.HasSuffix(stats["key_prefix"].(string), name+":") {
+				t.Errorf("expected the %s cache in redis, got %v", name, stats)
+			}
+		}
+	})
+
+	t.Run("service metadata is stored in the cache directory", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("CACHE_STORAGE", "filesystem")
+		t.Setenv("CACHE_DIR", dir)
+		s := newTestServer(t, upstream)
+		defer s.closeCaches()
+
+		if _, err := s.backend("parcels").api.GetServiceMetadata(context.Background(), "/arcgis/rest/services/Parcels/MapServer"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		files, err := os.ReadDir(filepath.Join(dir, "metadata"))
+		if err != nil || len(files) == 0 {
+			t.Errorf("expected the definition under %s/metadata, got %v, %v", dir, files, err)
+		}
+	})
+}
+
+func TestReload(t *testing.T) {
+	upstream := newTestUpstream(t)
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile := func(contents string) {
+		if err := os.WriteFile(file, []byte(contents), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	writeFile(`
+logging:
+  level: info
+backends:
+  - name: parcels
+    url: ` + upstream.URL + `
+    service: /arcgis/rest/services/Parcels/MapServer
+`)
+	t.Setenv("ARCGIS_SCHEME", "http")
+	t.Setenv("ARCGIS_HOST", strings.TrimPrefix(upstream.URL, "http://"))
+	cfg, err := config.LoadFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	level := new(slog.LevelVar)
+	s, err := newServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), level, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.router.Store(s.setupRoutes())
+	s.stopBackground = s.startBackground()
+	defer func() { s.stopBackground() }()
+	defaultBackend, parcels, tileCache, metadataCache := s.defaultBackend, s.backend("parcels"), s.tileCache, s.metadataCache
+
+	// Backends with unchanged settings are kept
+	writeFile(`
+logging:
+  level: debug
+backends:
+  - name: parcels
+    url: ` + upstream.URL + `
+    service: /arcgis/rest/services/Parcels/MapServer
+    timeout: 45
+`)
+	if err := s.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("expected the debug level, got %v", level.Level())
+	}
+	if s.defaultBackend != defaultBackend || s.tileCache != tileCache || s.metadataCache != metadataCache {
+		t.Error("expected the default backend and the tile and metadata caches to be kept")
+	}
+	if s.backend("parcels") == parcels || s.backend("parcels").config.Timeout != 45*time.Second {
+		t.Error("expected the changed backend to be rebuilt")
+	}
+	replaced := (&Server{defaultBackend: defaultBackend, backends: []*backend{parcels}, tileCache: tileCache}).replacedBy(s)
+	if len(replaced.transports) != 1 || replaced.transports[0] != parcels.transport || len(replaced.caches) != 0 {
+		t.Errorf("expected only the transport of the rebuilt backend to be replaced, got %+v", replaced)
+	}
+
+	// New caches come with new backends
+	writeFile(`
+caches:
+  tiles:
+    size: 64
+`)
+	if err := s.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.tileCache == tileCache || s.defaultBackend != defaultBackend || len(s.backends) != 0 {
+		t.Error("expected a new tile cache, the default backend kept and the named backend removed")
+	}
+
+	// An invalid configuration keeps the active one
+	writeFile("caches:\n  tiles:\n    size: lots\n")
+	if err := s.Reload(); err == nil || s.config.TileCacheSize != 64 {
+		t.Errorf("expected the invalid configuration to be rejected, got %v", err)
+	}
+}
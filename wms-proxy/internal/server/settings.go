@@ -0,0 +1,71 @@
+package server
+
+import (
+	"path/filepath"
+
+	"wms-proxy/internal/cache"
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/config"
+	"wms-proxy/internal/services"
+)
+
+// transportConfig returns the upstream transport settings of a backend
+func transportConfig(t config.Transport) client.TransportConfig {
+	return client.TransportConfig{
+		CAFile:              t.CAFile,
+		CertFile:            t.CertFile,
+		KeyFile:             t.KeyFile,
+		MinTLSVersion:       t.MinTLSVersion,
+		ServerName:          t.ServerName,
+		ProxyURL:            t.ProxyURL,
+		MaxIdleConns:        t.MaxIdleConns,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     t.MaxConnsPerHost,
+		IdleConnTimeout:     t.IdleConnTimeout,
+	}
+}
+
+// bulkheadConfig returns the concurrency limit and request queue of a backend
+func bulkheadConfig(b config.Bulkhead) client.BulkheadConfig {
+	return client.BulkheadConfig{
+		MaxConcurrent: b.MaxConcurrent,
+		QueueSize:     b.QueueSize,
+		MaxWait:       b.MaxWait,
+	}
+}
+
+// resilienceConfig returns the retry and circuit breaker settings applied to each backend
+func resilienceConfig(cfg *config.Config) client.ResilienceConfig {
+	return client.ResilienceConfig{
+		MaxRetries:       cfg.Retries,
+		BaseBackoff:      cfg.RetryBackoff,
+		MaxBackoff:       cfg.RetryMaxDelay,
+		FailureThreshold: cfg.CircuitFails,
+		OpenTimeout:      cfg.CircuitTimeout,
+	}
+}
+
+// srDetectorConfig returns the spatial reference detection settings of a backend
+func srDetectorConfig(cfg *config.Config, b config.Backend) services.SRDetectorConfig {
+	detection := services.SRDetectorConfig{
+		TTL:        cfg.SRCacheTTL,
+		FallbackSR: b.DefaultCRS,
+		Overrides:  cfg.SROverrides,
+		Refresh:    cfg.SRRefresh,
+	}
+	if cfg.SRCacheDir != "" {
+		detection.CacheFile = filepath.Join(cfg.SRCacheDir, "sr-"+b.Name+".json")
+	}
+	return detection
+}
+
+// redisConfig returns the redis storage settings of the cache called name
+func redisConfig(r config.Redis, name string) cache.RedisConfig {
+	return cache.RedisConfig{
+		Address:   r.Address,
+		Password:  r.Password,
+		DB:        r.DB,
+		KeyPrefix: r.KeyPrefix + name + ":",
+		Timeout:   r.Timeout,
+	}
+}
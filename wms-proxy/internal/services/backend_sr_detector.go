@@ -2,15 +2,34 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
 
 	"wms-proxy/internal/client"
+	"wms-proxy/internal/coalesce"
 )
 
+// SRDetectorConfig configures spatial reference detection
+type SRDetectorConfig struct {
+	TTL        time.Duration     // how long a detected SR is trusted
+	FallbackSR string            // assumed when detection fails and nothing was detected before
+	Overrides  map[string]string // service root path -> EPSG code, skipping detection
+	CacheFile  string            // JSON file persisting detected SRs across restarts (empty disables)
+	Refresh    bool              // re-detect cached SRs in the background before they expire
+}
+
+// DefaultSRDetectorConfig returns the detection settings used without configuration
+func DefaultSRDetectorConfig() SRDetectorConfig {
+	return SRDetectorConfig{TTL: 15 * time.Minute, FallbackSR: DefaultFallbackSR}
+}
+
 // BackendSRDetector manages detection of backend spatial reference systems
 type BackendSRDetector struct {
 	arcgisClient client.ArcGISClientInterface
@@ -19,36 +38,163 @@ type BackendSRDetector struct {
 	cacheMutex   sync.RWMutex
 	cacheTTL     time.Duration
 	cacheExpiry  map[string]time.Time
+	lastUsed     map[string]time.Time // servicePath -> last lookup, for background refresh
+	fallbackSR   string
+	overrides    map[string]string
+	cacheFile    string
+	refresh      bool
+	lookups      coalesce.Group[string] // concurrent cache misses share one metadata lookup
+	saveMutex    sync.Mutex
 }
 
+// DefaultFallbackSR is used when a backend's spatial reference cannot be detected
+const DefaultFallbackSR = "EPSG:3424"
+
 // NewBackendSRDetector creates a new backend spatial reference detector
 func NewBackendSRDetector(arcgisClient client.ArcGISClientInterface, logger *slog.Logger) *BackendSRDetector {
-	return &BackendSRDetector{
+	return NewBackendSRDetectorWithConfig(arcgisClient, logger, DefaultSRDetectorConfig())
+}
+
+// NewBackendSRDetectorWithFallback creates a backend spatial reference detector
+// that assumes fallbackSR when detection fails
+func NewBackendSRDetectorWithFallback(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, fallbackSR string) *BackendSRDetector {
+	cfg := DefaultSRDetectorConfig()
+	cfg.FallbackSR = fallbackSR
+	return NewBackendSRDetectorWithConfig(arcgisClient, logger, cfg)
+}
+
+// NewBackendSRDetectorWithConfig creates a backend spatial reference detector,
+// loading the SRs persisted in cfg.CacheFile
+func NewBackendSRDetectorWithConfig(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, cfg SRDetectorConfig) *BackendSRDetector {
+	d := &BackendSRDetector{
 		arcgisClient: arcgisClient,
 		logger:       logger,
 		cache:        make(map[string]string),
 		cacheExpiry:  make(map[string]time.Time),
-		cacheTTL:     15 * time.Minute, // Cache for 15 minutes
+		lastUsed:     make(map[string]time.Time),
+		cacheTTL:     cfg.TTL,
+		fallbackSR:   cfg.FallbackSR,
+		overrides:    make(map[string]string, len(cfg.Overrides)),
+		cacheFile:    cfg.CacheFile,
+		refresh:      cfg.Refresh,
+	}
+	for servicePath, sr := range cfg.Overrides {
+		d.overrides[client.ServiceRoot(servicePath)] = sr
 	}
+	if d.cacheFile != "" {
+		d.load()
+	}
+	return d
+}
+
+// FallbackSR returns the spatial reference assumed when detection fails
+func (d *BackendSRDetector) FallbackSR() string {
+	return d.fallbackSR
 }
 
 // GetBackendSR detects the spatial reference system expected by the backend service
 func (d *BackendSRDetector) GetBackendSR(ctx context.Context, servicePath string) (string, error) {
+	if sr, ok := d.overrides[client.ServiceRoot(servicePath)]; ok {
+		return sr, nil
+	}
+
 	// Check cache first
-	d.cacheMutex.RLock()
+	now := time.Now()
+	d.cacheMutex.Lock()
 	if cachedSR, exists := d.cache[servicePath]; exists {
-		if expiry, hasExpiry := d.cacheExpiry[servicePath]; hasExpiry && time.Now().Before(expiry) {
-			d.cacheMutex.RUnlock()
+		d.lastUsed[servicePath] = now
+		if expiry, hasExpiry := d.cacheExpiry[servicePath]; hasExpiry && now.Before(expiry) {
+			d.cacheMutex.Unlock()
 			d.logger.Debug("Using cached backend SR", "service_path", servicePath, "sr", cachedSR)
 			return cachedSR, nil
 		}
 	}
-	d.cacheMutex.RUnlock()
+	d.cacheMutex.Unlock()
 
+	backendSR, _, err := d.lookups.Do(ctx, servicePath, func(ctx context.Context) (string, error) {
+		return d.detect(ctx, servicePath)
+	})
+	return backendSR, err
+}
+
+// Start re-detects cached spatial references in the background shortly
+// before they expire, until ctx is cancelled. Only services looked up within
+// the last TTL are refreshed; expired entries of other services are evicted.
+// It does nothing unless refresh is enabled.
+func (d *BackendSRDetector) Start(ctx context.Context) {
+	if !d.refresh || d.cacheTTL <= 0 {
+		return
+	}
+	interval := d.cacheTTL / 4
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.refreshExpiring(ctx, interval)
+			}
+		}
+	}()
+}
+
+// refreshExpiring re-detects the entries expiring within window that were
+// used recently and evicts the expired entries that were not
+func (d *BackendSRDetector) refreshExpiring(ctx context.Context, window time.Duration) {
+	now := time.Now()
+	deadline := now.Add(window)
+	var expiring, evicted []string
+	d.cacheMutex.Lock()
+	for servicePath, expiry := range d.cacheExpiry {
+		switch {
+		case !expiry.Before(deadline):
+		case now.Sub(d.lastUsed[servicePath]) < d.cacheTTL:
+			expiring = append(expiring, servicePath)
+		case !now.Before(expiry):
+			evicted = append(evicted, servicePath)
+			delete(d.cache, servicePath)
+			delete(d.cacheExpiry, servicePath)
+			delete(d.lastUsed, servicePath)
+		}
+	}
+	d.cacheMutex.Unlock()
+
+	if len(evicted) > 0 {
+		d.logger.Info("Evicted unused backend SRs", "service_paths", evicted)
+		if d.cacheFile != "" {
+			d.save()
+		}
+	}
+
+	for _, servicePath := range expiring {
+		_, _, err := d.lookups.Do(ctx, servicePath, func(ctx context.Context) (string, error) {
+			return d.detect(ctx, servicePath)
+		})
+		if err != nil {
+			d.logger.Warn("Background SR refresh failed", "service_path", servicePath, "error", err)
+		}
+	}
+}
+
+// detect queries the service metadata for its spatial reference and caches it.
+// When the metadata is unavailable a previously detected SR is kept.
+func (d *BackendSRDetector) detect(ctx context.Context, servicePath string) (string, error) {
 	// Query service metadata
 	d.logger.Info("Querying backend service metadata", "service_path", servicePath)
 	metadata, err := d.arcgisClient.GetServiceMetadata(ctx, servicePath)
 	if err != nil {
+		d.cacheMutex.RLock()
+		previousSR, exists := d.cache[servicePath]
+		d.cacheMutex.RUnlock()
+		if exists {
+			d.logger.Warn("Failed to refresh backend SR, keeping the expired one",
+				"service_path", servicePath,
+				"sr", previousSR,
+				"error", err)
+			return previousSR, nil
+		}
 		return "", fmt.Errorf("failed to get service metadata: %w", err)
 	}
 
@@ -59,8 +205,8 @@ func (d *BackendSRDetector) GetBackendSR(ctx context.Context, servicePath string
 	} else if metadata.SpatialReference.WKID != 0 {
 		backendSR = "EPSG:" + strconv.Itoa(metadata.SpatialReference.WKID)
 	} else {
-		// Fallback to a reasonable default for New Jersey services
-		backendSR = "EPSG:3424"
+		// Fallback to the backend's configured default
+		backendSR = d.fallbackSR
 		d.logger.Warn("Could not determine backend SR from metadata, using fallback",
 			"service_path", servicePath,
 			"fallback_sr", backendSR,
@@ -68,12 +214,19 @@ func (d *BackendSRDetector) GetBackendSR(ctx context.Context, servicePath string
 			"metadata_latest_wkid", metadata.SpatialReference.LatestWKID)
 	}
 
-	// Cache the result
+	// Cache the result; a background refresh does not count as a use
 	d.cacheMutex.Lock()
+	if _, exists := d.cache[servicePath]; !exists {
+		d.lastUsed[servicePath] = time.Now()
+	}
 	d.cache[servicePath] = backendSR
 	d.cacheExpiry[servicePath] = time.Now().Add(d.cacheTTL)
 	d.cacheMutex.Unlock()
 
+	if d.cacheFile != "" {
+		d.save()
+	}
+
 	d.logger.Info("Detected backend spatial reference system",
 		"service_path", servicePath,
 		"backend_sr", backendSR,
@@ -83,6 +236,81 @@ func (d *BackendSRDetector) GetBackendSR(ctx context.Context, servicePath string
 	return backendSR, nil
 }
 
+// persistedSR is a cache entry stored in the cache file
+type persistedSR struct {
+	SR      string    `json:"sr"`
+	Expires time.Time `json:"expires"`
+}
+
+// load restores the entries of the cache file; expired entries are kept for
+// use while the backend is unavailable
+func (d *BackendSRDetector) load() {
+	data, err := os.ReadFile(d.cacheFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			d.logger.Warn("Failed to read SR cache file", "file", d.cacheFile, "error", err)
+		}
+		return
+	}
+
+	var entries map[string]persistedSR
+	if err := json.Unmarshal(data, &entries); err != nil {
+		d.logger.Warn("Ignoring invalid SR cache file", "file", d.cacheFile, "error", err)
+		return
+	}
+
+	// Restored entries count as used, so they are refreshed for one TTL
+	now := time.Now()
+	d.cacheMutex.Lock()
+	defer d.cacheMutex.Unlock()
+	for servicePath, entry := range entries {
+		d.cache[servicePath] = entry.SR
+		d.cacheExpiry[servicePath] = entry.Expires
+		d.lastUsed[servicePath] = now
+	}
+	d.logger.Info("Loaded persisted backend SRs", "file", d.cacheFile, "entries", len(entries))
+}
+
+// save writes the cache to the cache file, replacing it atomically
+func (d *BackendSRDetector) save() {
+	d.saveMutex.Lock()
+	defer d.saveMutex.Unlock()
+
+	d.cacheMutex.RLock()
+	entries := make(map[string]persistedSR, len(d.cache))
+	for servicePath, sr := range d.cache {
+		entries[servicePath] = persistedSR{SR: sr, Expires: d.cacheExpiry[servicePath]}
+	}
+	d.cacheMutex.RUnlock()
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(d.cacheFile), 0o755)
+	}
+	if err == nil {
+		tmp := d.cacheFile + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, d.cacheFile)
+		}
+	}
+	if err != nil {
+		d.logger.Warn("Failed to write SR cache file", "file", d.cacheFile, "error", err)
+	}
+}
+
+// Invalidate expires the cached spatial references of a service, keeping them
+// for use while the backend is unavailable
+func (d *BackendSRDetector) Invalidate(servicePath string) {
+	serviceRoot := client.ServiceRoot(servicePath)
+	d.cacheMutex.Lock()
+	defer d.cacheMutex.Unlock()
+	for cachedPath := range d.cacheExpiry {
+		if client.ServiceRoot(cachedPath) == serviceRoot {
+			d.cacheExpiry[cachedPath] = time.Time{}
+		}
+	}
+}
+
 // ClearCache clears the spatial reference cache
 func (d *BackendSRDetector) ClearCache() {
 	d.cacheMutex.Lock()
@@ -90,6 +318,7 @@ func (d *BackendSRDetector) ClearCache() {
 
 	d.cache = make(map[string]string)
 	d.cacheExpiry = make(map[string]time.Time)
+	d.lastUsed = make(map[string]time.Time)
 	d.logger.Info("Backend SR cache cleared")
 }
 
@@ -117,5 +346,6 @@ func (d *BackendSRDetector) GetCacheStats() map[string]interface{} {
 		"valid_entries":     validEntries,
 		"expired_entries":   expiredEntries,
 		"cache_ttl_minutes": d.cacheTTL.Minutes(),
+		"overrides":         len(d.overrides),
 	}
 }
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/client"
+)
+
+func TestBackendSRDetector_Config(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits, failing atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() == 1 {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"spatialReference":{"wkid":102100,"latestWkid":3857}}`))
+	}))
+	defer server.Close()
+
+	cfg := SRDetectorConfig{
+		TTL:        time.Hour,
+		FallbackSR: "EPSG:4326",
+		Overrides:  map[string]string{"/arcgis/rest/services/Fixed/MapServer": "EPSG:2263"},
+		CacheFile:  filepath.Join(t.TempDir(), "sr", "sr-default.json"),
+	}
+	arcgisClient := client.NewArcGISClient(server.URL, 5*time.Second)
+	detector := NewBackendSRDetectorWithConfig(arcgisClient, logger, cfg)
+	ctx := context.Background()
+
+	if sr, err := detector.GetBackendSR(ctx, "/arcgis/rest/services/Fixed/MapServer/export"); err != nil || sr != "EPSG:2263" {
+		t.Errorf("expected the override, got %s %v", sr, err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("expected overrides to skip detection, got %d requests", hits.Load())
+	}
+	if sr, err := detector.GetBackendSR(ctx, "/arcgis/rest/services/Parcels/MapServer"); err != nil || sr != "EPSG:3857" {
+		t.Errorf("expected the detected SR, got %s %v", sr, err)
+	}
+
+	// A new detector restores the persisted SR without asking the backend
+	failing.Store(1)
+	restored := NewBackendSRDetectorWithConfig(arcgisClient, logger, cfg)
+	if sr, err := restored.GetBackendSR(ctx, "/arcgis/rest/services/Parcels/MapServer"); err != nil || sr != "EPSG:3857" {
+		t.Errorf("expected the persisted SR, got %s %v", sr, err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected the persisted SR to be used without a request, got %d requests", hits.Load())
+	}
+
+	// An expired SR is kept when the backend fails; unknown services fail
+	cfg.TTL = time.Nanosecond
+	expired := NewBackendSRDetectorWithConfig(arcgisClient, logger, cfg)
+	if sr, err := expired.GetBackendSR(ctx, "/arcgis/rest/services/Parcels/MapServer"); err != nil || sr != "EPSG:3857" {
+		t.Errorf("expected the expired SR while the backend fails, got %s %v", sr, err)
+	}
+	if _, err := expired.GetBackendSR(ctx, "/arcgis/rest/services/Other/MapServer"); err == nil {
+		t.Error("expected an error for an undetectable service")
+	}
+	if expired.FallbackSR() != "EPSG:4326" {
+		t.Errorf("expected the configured fallback, got %s", expired.FallbackSR())
+	}
+}
+
+func TestBackendSRDetector_Refresh(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var usedHits, deletedHits, deleted atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/Deleted/") {
+			deletedHits.Add(1)
+			if deleted.Load() == 1 {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+		} else {
+			usedHits.Add(1)
+		}
+		w.Write([]byte(`{"spatialReference":{"wkid":102100,"latestWkid":3857}}`))
+	}))
+	defer server.Close()
+
+	cfg := SRDetectorConfig{TTL: 200 * time.Millisecond, FallbackSR: "EPSG:4326", Refresh: true}
+	detector := NewBackendSRDetectorWithConfig(client.NewArcGISClient(server.URL, 5*time.Second), logger, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const used, gone = "/arcgis/rest/services/Used/MapServer", "/arcgis/rest/services/Deleted/MapServer"
+	for _, servicePath := range []string{used, gone} {
+		if _, err := detector.GetBackendSR(ctx, servicePath); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	deleted.Store(1)
+	detector.Start(ctx)
+
+	// Only the used service is looked up; it is refreshed before it expires
+	use := func(d time.Duration) {
+		for end := time.Now().Add(d); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+			if sr, err := detector.GetBackendSR(ctx, used); err != nil || sr != "EPSG:3857" {
+				t.Fatalf("expected the cached SR, got %s %v", sr, err)
+			}
+		}
+	}
+	use(time.Second)
+	if usedHits.Load() < 3 {
+		t.Errorf("expected background refreshes of the used service, got %d requests", usedHits.Load())
+	}
+
+	// The deleted service is no longer retried and was evicted
+	stopped := deletedHits.Load()
+	use(300 * time.Millisecond)
+	if deletedHits.Load() != stopped {
+		t.Errorf("expected the unused service not to be refreshed, got %d more requests", deletedHits.Load()-stopped)
+	}
+	if stats := detector.GetCacheStats(); stats["total_entries"] != 1 {
+		t.Errorf("expected the unused service to be evicted, got %v entries", stats["total_entries"])
+	}
+}
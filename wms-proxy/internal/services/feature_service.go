@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/geometry"
+	"wms-proxy/internal/transform"
+)
+
+// FeatureQuery describes a feature request against a single layer
+type FeatureQuery struct {
+	LayerID    string
+	Where      string
+	BBox       *transform.BBox
+	BBoxCRS    string // CRS of BBox, EPSG:4326 when empty
+	OutFields  []string
+	ObjectIDs  []string
+	Offset     int
+	Limit      int
+	OutCRS     string // CRS of returned geometries, EPSG:4326 when empty
+	NoGeometry bool
+}
+
+// FeatureResult holds the features returned by a query
+type FeatureResult struct {
+	Features              []geometry.Feature
+	Fields                []client.Field
+	GeometryType          string
+	ExceededTransferLimit bool
+}
+
+// queryResponse is the ArcGIS JSON response of a layer query
+type queryResponse struct {
+	ObjectIDFieldName string         `json:"objectIdFieldName"`
+	GeometryType      string         `json:"geometryType"`
+	Fields            []client.Field `json:"fields"`
+	Features          []struct {
+		Attributes map[string]interface{} `json:"attributes"`
+		Geometry   json.RawMessage        `json:"geometry"`
+	} `json:"features"`
+	ExceededTransferLimit bool `json:"exceededTransferLimit"`
+	Error                 *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// FeatureService runs ArcGIS layer queries and returns reprojected features
+type FeatureService struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	baseURL      string
+	transformer  *transform.CoordinateTransformer
+	srDetector   *BackendSRDetector
+}
+
+// NewFeatureService creates a new feature query service
+func NewFeatureService(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, baseURL string, transformer *transform.CoordinateTransformer, srDetector *BackendSRDetector) *FeatureService {
+	return &FeatureService{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		baseURL:      baseURL,
+		transformer:  transformer,
+		srDetector:   srDetector,
+	}
+}
+
+// Query executes a feature query. The bbox is transformed into the backend's
+// spatial reference and the returned geometries into the requested output CRS.
+func (s *FeatureService) Query(ctx context.Context, servicePath string, q *FeatureQuery) (*FeatureResult, error) {
+	backendSR, err := s.srDetector.GetBackendSR(ctx, servicePath)
+	if err != nil {
+		s.logger.Warn("Failed to detect backend SR, using fallback",
+			"error", err,
+			"service_path", servicePath,
+			"fallback_sr", s.srDetector.FallbackSR())
+		backendSR = s.srDetector.FallbackSR()
+	}
+
+	params := &client.QueryParams{
+		Where:             q.Where,
+		OutFields:         strings.Join(q.OutFields, ","),
+		OutSR:             client.WKIDFromCRS(backendSR),
+		ObjectIDs:         strings.Join(q.ObjectIDs, ","),
+		ResultOffset:      q.Offset,
+		ResultRecordCount: q.Limit,
+		ReturnGeometry:    !q.NoGeometry,
+	}
+
+	if q.BBox != nil {
+		bboxCRS := q.BBoxCRS
+		if bboxCRS == "" {
+			bboxCRS = "EPSG:4326"
+		}
+		envelope, err := s.transformer.TransformEnvelope(*q.BBox, bboxCRS, backendSR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transform bbox: %w", err)
+		}
+		params.Geometry = fmt.Sprintf("%f,%f,%f,%f", envelope.MinX, envelope.MinY, envelope.MaxX, envelope.MaxY)
+		params.InSR = client.WKIDFromCRS(backendSR)
+	}
+
+	queryURL := client.BuildQueryURL(s.baseURL, servicePath, q.LayerID, params)
+	s.logger.Info("Querying ArcGIS features", "query_url", queryURL)
+
+	resp, err := s.arcgisClient.Get(ctx, queryURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query features: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feature query failed with status: %d", resp.StatusCode)
+	}
+
+	var qr queryResponse
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&qr); err != nil {
+		return nil, fmt.Errorf("failed to decode feature query response: %w", err)
+	}
+	if qr.Error != nil {
+		return nil, fmt.Errorf("feature query error %d: %s", qr.Error.Code, qr.Error.Message)
+	}
+
+	outCRS := q.OutCRS
+	if outCRS == "" {
+		outCRS = "EPSG:4326"
+	}
+	reproject, err := s.transformer.PointTransformer(backendSR, outCRS)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FeatureResult{
+		Fields:                qr.Fields,
+		GeometryType:          qr.GeometryType,
+		ExceededTransferLimit: qr.ExceededTransferLimit,
+		Features:              make([]geometry.Feature, 0, len(qr.Features)),
+	}
+
+	dateFields := make(map[string]bool)
+	for _, f := range qr.Fields {
+		if f.Type == "esriFieldTypeDate" {
+			dateFields[f.Name] = true
+		}
+	}
+
+	for _, f := range qr.Features {
+		geom, err := geometry.FromEsriJSON(f.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		if geom != nil {
+			if err := geom.Transform(reproject); err != nil {
+				return nil, fmt.Errorf("failed to reproject geometry: %w", err)
+			}
+		}
+
+		// ArcGIS dates are epoch milliseconds; expose them as ISO 8601
+		for name := range dateFields {
+			if n, ok := f.Attributes[name].(json.Number); ok {
+				if ms, err := n.Int64(); err == nil {
+					f.Attributes[name] = time.UnixMilli(ms).UTC().Format(time.RFC3339)
+				}
+			}
+		}
+
+		result.Features = append(result.Features, geometry.Feature{
+			ID:         f.Attributes[qr.ObjectIDFieldName],
+			Properties: f.Attributes,
+			Geometry:   geom,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wms-proxy/internal/client"
+)
+
+// CatalogLayer is a layer of an ArcGIS service as published by the proxy
+type CatalogLayer struct {
+	ID           int
+	Name         string // feature type / collection name, safe for XML and URLs
+	Title        string
+	GeometryType string
+	IsGroup      bool
+}
+
+// LayerCatalog lists the layers of ArcGIS services from their service metadata
+type LayerCatalog struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	cache        map[string][]CatalogLayer // servicePath -> layers
+	cacheMutex   sync.RWMutex
+	cacheTTL     time.Duration
+	cacheExpiry  map[string]time.Time
+}
+
+// NewLayerCatalog creates a new layer catalog
+func NewLayerCatalog(arcgisClient client.ArcGISClientInterface, logger *slog.Logger) *LayerCatalog {
+	return &LayerCatalog{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		cache:        make(map[string][]CatalogLayer),
+		cacheExpiry:  make(map[string]time.Time),
+		cacheTTL:     15 * time.Minute, // Cache for 15 minutes
+	}
+}
+
+// GetLayers returns the layers of a service, including group layers
+func (c *LayerCatalog) GetLayers(ctx context.Context, servicePath string) ([]CatalogLayer, error) {
+	c.cacheMutex.RLock()
+	if layers, exists := c.cache[servicePath]; exists && time.Now().Before(c.cacheExpiry[servicePath]) {
+		c.cacheMutex.RUnlock()
+		return layers, nil
+	}
+	c.cacheMutex.RUnlock()
+
+	metadata, err := c.arcgisClient.GetServiceMetadata(ctx, servicePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service metadata: %w", err)
+	}
+
+	layers := BuildCatalogLayers(metadata.Layers)
+
+	c.cacheMutex.Lock()
+	c.cache[servicePath] = layers
+	c.cacheExpiry[servicePath] = time.Now().Add(c.cacheTTL)
+	c.cacheMutex.Unlock()
+
+	c.logger.Info("Loaded layer catalog", "service_path", servicePath, "layers", len(layers))
+	return layers, nil
+}
+
+// GetFeatureLayers returns the layers that hold features (group layers excluded)
+func (c *LayerCatalog) GetFeatureLayers(ctx context.Context, servicePath string) ([]CatalogLayer, error) {
+	layers, err := c.GetLayers(ctx, servicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var featureLayers []CatalogLayer
+	for _, layer := range layers {
+		if !layer.IsGroup {
+			featureLayers = append(featureLayers, layer)
+		}
+	}
+	return featureLayers, nil
+}
+
+// FindLayer resolves a published layer name (optionally prefixed, e.g. "esri:Roads")
+// or a numeric layer ID to a feature layer
+func (c *LayerCatalog) FindLayer(ctx context.Context, servicePath, name string) (*CatalogLayer, error) {
+	layers, err := c.GetFeatureLayers(ctx, servicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	id, idErr := strconv.Atoi(name)
+
+	for i := range layers {
+		if strings.EqualFold(layers[i].Name, name) || (idErr == nil && layers[i].ID == id) {
+			return &layers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown layer %q", name)
+}
+
+// BuildCatalogLayers derives published names for service layers. Names are
+// sanitized ArcGIS layer names, suffixed with the layer ID when they collide.
+func BuildCatalogLayers(infos []client.LayerInfo) []CatalogLayer {
+	layers := make([]CatalogLayer, 0, len(infos))
+	seen := make(map[string]int)
+
+	for _, info := range infos {
+		name := sanitizeName(info.Name)
+		if name == "" {
+			name = "layer"
+		}
+		seen[strings.ToLower(name)]++
+		layers = append(layers, CatalogLayer{
+			ID:           info.ID,
+			Name:         name,
+			Title:        info.Name,
+			GeometryType: info.GeometryType,
+			IsGroup:      len(info.SubLayerIDs) > 0,
+		})
+	}
+
+	for i := range layers {
+		if seen[strings.ToLower(layers[i].Name)] > 1 {
+			layers[i].Name = layers[i].Name + "_" + strconv.Itoa(layers[i].ID)
+		}
+	}
+
+	return layers
+}
+
+// sanitizeName turns an ArcGIS layer name into a valid XML NCName
+func sanitizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9', r == '-', r == '.':
+			if sb.Len() == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/cql"
+)
+
+// layerResponse wraps an ArcGIS layer resource, which reports errors with HTTP 200
+type layerResponse struct {
+	client.LayerMetadata
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// LayerSchemaService fetches and caches attribute schemas of ArcGIS layers
+type LayerSchemaService struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	baseURL      string
+	cache        map[string]*client.LayerMetadata // servicePath/layerID -> layer metadata
+	cacheMutex   sync.RWMutex
+	cacheTTL     time.Duration
+	cacheExpiry  map[string]time.Time
+}
+
+// NewLayerSchemaService creates a new layer schema service
+func NewLayerSchemaService(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, baseURL string) *LayerSchemaService {
+	return &LayerSchemaService{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		baseURL:      baseURL,
+		cache:        make(map[string]*client.LayerMetadata),
+		cacheExpiry:  make(map[string]time.Time),
+		cacheTTL:     15 * time.Minute, // Cache for 15 minutes
+	}
+}
+
+// GetLayerMetadata returns the metadata (fields, geometry type, object ID field)
+// of a layer within a MapServer or FeatureServer service
+func (s *LayerSchemaService) GetLayerMetadata(ctx context.Context, servicePath, layerID string) (*client.LayerMetadata, error) {
+	serviceRoot := client.ServiceRoot(servicePath)
+	key := serviceRoot + "/" + layerID
+
+	s.cacheMutex.RLock()
+	if metadata, exists := s.cache[key]; exists && time.Now().Before(s.cacheExpiry[key]) {
+		s.cacheMutex.RUnlock()
+		return metadata, nil
+	}
+	s.cacheMutex.RUnlock()
+
+	s.logger.Info("Querying layer metadata", "service_path", serviceRoot, "layer", layerID)
+	resp, err := s.arcgisClient.Get(ctx, s.baseURL+key+"?f=json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("layer metadata request failed with status: %d", resp.StatusCode)
+	}
+
+	var layer layerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&layer); err != nil {
+		return nil, fmt.Errorf("failed to decode layer metadata: %w", err)
+	}
+	if layer.Error != nil {
+		return nil, fmt.Errorf("layer metadata error %d: %s", layer.Error.Code, layer.Error.Message)
+	}
+
+	metadata := &layer.LayerMetadata
+
+	s.cacheMutex.Lock()
+	s.cache[key] = metadata
+	s.cacheExpiry[key] = time.Now().Add(s.cacheTTL)
+	s.cacheMutex.Unlock()
+
+	return metadata, nil
+}
+
+// GetLayerSchema returns the attribute schema of a layer for filter validation
+func (s *LayerSchemaService) GetLayerSchema(ctx context.Context, servicePath, layerID string) (*cql.Schema, error) {
+	metadata, err := s.GetLayerMetadata(ctx, servicePath, layerID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]cql.Field, 0, len(metadata.Fields))
+	for _, f := range metadata.Fields {
+		fields = append(fields, cql.Field{Name: f.Name, Type: f.Type})
+	}
+	return cql.NewSchema(fields), nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"wms-proxy/internal/cache"
+	"wms-proxy/internal/client"
+)
+
+// MetadataChange describes a service definition that changed on the backend
+type MetadataChange struct {
+	ServicePath string   // service root
+	Changed     []string // changed aspects, e.g. "spatialReference", "layers"
+	Previous    *client.ServiceMetadata
+	Current     *client.ServiceMetadata
+}
+
+// MetadataCache caches the service metadata of a backend: spatial reference,
+// extents, layers, image formats and size limits, and time info. It wraps the
+// backend's client, so every service using the client shares the cache; other
+// requests pass through. Definitions are kept in a cache storage, which
+// replicas of the proxy share when it is on the filesystem or in Redis. When a
+// definition differs from the one this process last served, whether reloaded
+// here or by another replica, the change is logged and reported to the
+// OnChange listeners.
+type MetadataCache struct {
+	arcgisClient   client.ArcGISClientInterface
+	logger         *slog.Logger
+	cache          *cache.TileCache // service root -> JSON service definition
+	mutex          sync.Mutex
+	served         map[string]servedDefinition // service root -> definition last served
+	invalidated    map[string]time.Time        // service root -> time of Invalidate
+	invalidatedAll time.Time
+	listeners      []func(MetadataChange)
+	changes        int64
+}
+
+// servedDefinition is a service definition returned by this process
+type servedDefinition struct {
+	metadata    *client.ServiceMetadata
+	fingerprint [32]byte
+}
+
+// Ensure MetadataCache implements ArcGISClientInterface
+var _ client.ArcGISClientInterface = (*MetadataCache)(nil)
+
+// NewMetadataCache creates a metadata cache in front of arcgisClient keeping
+// the definitions in metadataCache, for its TTL
+func NewMetadataCache(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, metadataCache *cache.TileCache) *MetadataCache {
+	return &MetadataCache{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		cache:        metadataCache,
+		served:       make(map[string]servedDefinition),
+		invalidated:  make(map[string]time.Time),
+	}
+}
+
+// OnChange registers a listener called when a service definition changes
+func (c *MetadataCache) OnChange(listener func(MetadataChange)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+// Get performs a GET request through the wrapped client
+func (c *MetadataCache) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.arcgisClient.Get(ctx, url)
+}
+
+// Post performs a POST request through the wrapped client
+func (c *MetadataCache) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	return c.arcgisClient.Post(ctx, url, contentType, body)
+}
+
+// GetServiceMetadata returns the cached metadata of a service, reloading it
+// when expired. When reloading fails the expired metadata is kept for the
+// cache's stale-if-error window.
+func (c *MetadataCache) GetServiceMetadata(ctx context.Context, servicePath string) (*client.ServiceMetadata, error) {
+	serviceRoot := client.ServiceRoot(servicePath)
+
+	entry, freshness, found := c.cache.Lookup(serviceRoot)
+	if found && freshness == cache.Fresh && !c.invalidatedSince(serviceRoot, entry.StoredAt) {
+		if metadata, err := c.serve(serviceRoot, entry.Body); err == nil {
+			return metadata, nil
+		}
+	}
+
+	metadata, err := c.arcgisClient.GetServiceMetadata(ctx, servicePath)
+	if err != nil {
+		if found {
+			if cached, decodeErr := c.serve(serviceRoot, entry.Body); decodeErr == nil {
+				c.logger.Warn("Failed to reload service metadata, keeping the expired copy",
+					"service_path", serviceRoot,
+					"error", err)
+				c.cache.RecordStale()
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode service metadata: %w", err)
+	}
+	c.cache.Store(serviceRoot, cache.Entry{Body: body, ContentType: "application/json"})
+	return c.serve(serviceRoot, body)
+}
+
+// serve decodes a stored definition, reporting a change from the definition
+// last served for the service
+func (c *MetadataCache) serve(serviceRoot string, body []byte) (*client.ServiceMetadata, error) {
+	fingerprint := sha256.Sum256(body)
+
+	c.mutex.Lock()
+	previous, seen := c.served[serviceRoot]
+	c.mutex.Unlock()
+	if seen && previous.fingerprint == fingerprint {
+		return copyMetadata(previous.metadata), nil
+	}
+
+	var metadata client.ServiceMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode cached service metadata: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.served[serviceRoot] = servedDefinition{metadata: &metadata, fingerprint: fingerprint}
+	if seen {
+		c.changes++
+	}
+	listeners := append([]func(MetadataChange){}, c.listeners...)
+	c.mutex.Unlock()
+
+	if seen {
+		change := MetadataChange{
+			ServicePath: serviceRoot,
+			Changed:     changedAspects(previous.metadata, &metadata),
+			Previous:    previous.metadata,
+			Current:     &metadata,
+		}
+		c.logger.Warn("Backend service definition changed",
+			"service_path", serviceRoot,
+			"changed", change.Changed)
+		for _, listener := range listeners {
+			listener(change)
+		}
+	}
+
+	return copyMetadata(&metadata), nil
+}
+
+// invalidatedSince reports whether the service was invalidated after storedAt
+func (c *MetadataCache) invalidatedSince(serviceRoot string, storedAt time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !storedAt.After(c.invalidatedAll) || !storedAt.After(c.invalidated[serviceRoot])
+}
+
+// Invalidate expires the cached metadata of a service; the next lookup reloads
+// it and still reports changes
+func (c *MetadataCache) Invalidate(servicePath string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.invalidated[client.ServiceRoot(servicePath)] = time.Now()
+}
+
+// InvalidateAll expires the metadata of every service
+func (c *MetadataCache) InvalidateAll() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.invalidatedAll = time.Now()
+	c.logger.Info("Service metadata cache invalidated")
+}
+
+// GetCacheStats returns cache statistics for monitoring
+func (c *MetadataCache) GetCacheStats() map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return map[string]interface{}{
+		"services":          len(c.served),
+		"changes":           c.changes,
+		"cache_ttl_minutes": c.cache.TTL().Minutes(),
+	}
+}
+
+// copyMetadata gives callers their own copy of the top-level fields
+func copyMetadata(metadata *client.ServiceMetadata) *client.ServiceMetadata {
+	copied := *metadata
+	return &copied
+}
+
+// changedAspects names the parts of a service definition that differ
+func changedAspects(previous, current *client.ServiceMetadata) []string {
+	aspects := []struct {
+		name              string
+		previous, current interface{}
+	}{
+		{"spatialReference", previous.SpatialReference, current.SpatialReference},
+		{"layers", previous.Layers, current.Layers},
+		{"fullExtent", previous.FullExtent, current.FullExtent},
+		{"initialExtent", previous.InitialExtent, current.InitialExtent},
+		{"extent", previous.Extent, current.Extent},
+		{"supportedImageFormatTypes", previous.SupportedImageFormatTypes, current.SupportedImageFormatTypes},
+		{"maxImageSize", [2]int{previous.MaxImageWidth, previous.MaxImageHeight}, [2]int{current.MaxImageWidth, current.MaxImageHeight}},
+		{"maxRecordCount", previous.MaxRecordCount, current.MaxRecordCount},
+		{"timeInfo", previous.TimeInfo, current.TimeInfo},
+		{"capabilities", previous.Capabilities, current.Capabilities},
+	}
+
+	var changed []string
+	for _, aspect := range aspects {
+		if !reflect.DeepEqual(aspect.previous, aspect.current) {
+			changed = append(changed, aspect.name)
+		}
+	}
+	if len(changed) == 0 {
+		changed = append(changed, "other")
+	}
+	return changed
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/cache"
+	"wms-proxy/internal/client"
+)
+
+func TestMetadataCache(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits, wkid, failing atomic.Int32
+	wkid.Store(3857)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() == 1 {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		if wkid.Load() == 3857 {
+			w.Write([]byte(`{"spatialReference":{"wkid":3857},"maxImageWidth":4096,"maxImageHeight":4096}`))
+			return
+		}
+		w.Write([]byte(`{"spatialReference":{"wkid":2263},"maxImageWidth":4096,"maxImageHeight":4096}`))
+	}))
+	defer server.Close()
+
+	definitions := cache.NewTileCache(1<<20, time.Hour)
+	definitions.SetStale(0, time.Hour)
+	metadataCache := NewMetadataCache(client.NewArcGISClient(server.URL, 5*time.Second), logger, definitions)
+	var changes []MetadataChange
+	metadataCache.OnChange(func(change MetadataChange) {
+		changes = append(changes, change)
+	})
+	ctx := context.Background()
+
+	// Lookups of the same service share one entry
+	for _, servicePath := range []string{"/arcgis/rest/services/Parcels/MapServer", "/arcgis/rest/services/Parcels/MapServer/export"} {
+		metadata, err := metadataCache.GetServiceMetadata(ctx, servicePath)
+		if err != nil || metadata.SpatialReference.WKID != 3857 || metadata.MaxImageWidth != 4096 {
+			t.Fatalf("unexpected result: %+v, %v", metadata, err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected one metadata request, got %d", hits.Load())
+	}
+
+	// A reload without changes reports nothing
+	metadataCache.Invalidate("/arcgis/rest/services/Parcels/MapServer")
+	if _, err := metadataCache.GetServiceMetadata(ctx, "/arcgis/rest/services/Parcels/MapServer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hits.Load() != 2 || len(changes) != 0 {
+		t.Errorf("expected a silent reload, got %d requests and %d changes", hits.Load(), len(changes))
+	}
+
+	// A changed spatial reference is detected on reload
+	wkid.Store(2263)
+	metadataCache.InvalidateAll()
+	metadata, err := metadataCache.GetServiceMetadata(ctx, "/arcgis/rest/services/Parcels/MapServer")
+	if err != nil || metadata.SpatialReference.WKID != 2263 {
+		t.Fatalf("unexpected result: %+v, %v", metadata, err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected one change, got %d", len(changes))
+	}
+	if changes[0].ServicePath != "/arcgis/rest/services/Parcels/MapServer" ||
+		len(changes[0].Changed) != 1 || changes[0].Changed[0] != "spatialReference" {
+		t.Errorf("unexpected change: %+v", changes[0])
+	}
+	if changes[0].Previous.SpatialReference.WKID != 3857 {
+		t.Errorf("expected the previous definition, got %+v", changes[0].Previous)
+	}
+
+	// The expired copy is kept while the backend fails
+	failing.Store(1)
+	metadataCache.InvalidateAll()
+	metadata, err = metadataCache.GetServiceMetadata(ctx, "/arcgis/rest/services/Parcels/MapServer")
+	if err != nil || metadata.SpatialReference.WKID != 2263 {
+		t.Errorf("expected the expired metadata, got %+v, %v", metadata, err)
+	}
+	if _, err := metadataCache.GetServiceMetadata(ctx, "/arcgis/rest/services/Other/MapServer"); err == nil {
+		t.Error("expected an error for an uncached service")
+	}
+
+	if stats := metadataCache.GetCacheStats(); stats["changes"] != int64(1) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestMetadataCache_SharedStorage(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var hits, wkid atomic.Int32
+	wkid.Store(3857)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"spatialReference":{"wkid":` + strconv.Itoa(int(wkid.Load())) + `}}`))
+	}))
+	defer server.Close()
+
+	// Two replicas keeping their definitions in the same storage
+	definitions := cache.NewTileCache(1<<20, time.Hour)
+	first := NewMetadataCache(client.NewArcGISClient(server.URL, 5*time.Second), logger, definitions.Namespace("backend"))
+	second := NewMetadataCache(client.NewArcGISClient(server.URL, 5*time.Second), logger, definitions.Namespace("backend"))
+	var changes []MetadataChange
+	second.OnChange(func(change MetadataChange) {
+		changes = append(changes, change)
+	})
+	ctx := context.Background()
+	servicePath := "/arcgis/rest/services/Parcels/MapServer"
+
+	for _, replica := range []*MetadataCache{first, second} {
+		if metadata, err := replica.GetServiceMetadata(ctx, servicePath); err != nil || metadata.SpatialReference.WKID != 3857 {
+			t.Fatalf("unexpected result: %+v, %v", metadata, err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected the replicas to share one metadata request, got %d", hits.Load())
+	}
+
+	// A definition reloaded by one replica is reported as a change by the other
+	wkid.Store(2263)
+	first.Invalidate(servicePath)
+	if _, err := first.GetServiceMetadata(ctx, servicePath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	metadata, err := second.GetServiceMetadata(ctx, servicePath)
+	if err != nil || metadata.SpatialReference.WKID != 2263 {
+		t.Fatalf("expected the reloaded definition, got %+v, %v", metadata, err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected one reload, got %d requests", hits.Load())
+	}
+	if len(changes) != 1 || changes[0].Changed[0] != "spatialReference" {
+		t.Errorf("expected the change to be reported, got %+v", changes)
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wms-proxy/internal/client"
+)
+
+// DiscoveredService is a service found by crawling an ArcGIS services directory
+type DiscoveredService struct {
+	Name   string // folder-qualified name, e.g. "Hydro/Wetlands"
+	Type   string // MapServer, ImageServer, FeatureServer, ...
+	Path   string // service root, e.g. /arcgis/rest/services/Hydro/Wetlands/MapServer
+	Title  string
+	Layers []CatalogLayer // layers of map services
+}
+
+// directory is an ArcGIS services directory listing (the root or a folder)
+type directory struct {
+	Folders  []string `json:"folders"`
+	Services []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"services"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// ServiceCrawler walks the folders of an ArcGIS services directory and keeps
+// an in-memory catalog of the services that pass the include and exclude
+// patterns. Map services are described with their layers.
+type ServiceCrawler struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	baseURL      string
+	servicesRoot string   // e.g. /arcgis/rest/services
+	include      []string // path.Match patterns on folder-qualified names; empty includes all
+	exclude      []string
+
+	mu        sync.RWMutex
+	services  []DiscoveredService
+	lastCrawl time.Time
+}
+
+// NewServiceCrawler creates a crawler for the services directory at
+// servicesRoot on baseURL
+func NewServiceCrawler(arcgisClient client.ArcGISClientInterface, logger *slog.Logger, baseURL, servicesRoot string, include, exclude []string) *ServiceCrawler {
+	return &ServiceCrawler{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		baseURL:      baseURL,
+		servicesRoot: strings.TrimSuffix(servicesRoot, "/"),
+		include:      include,
+		exclude:      exclude,
+	}
+}
+
+// ServicesRoot returns the services directory containing servicePath, e.g.
+// /arcgis/rest/services for /arcgis/rest/services/Hydro/Wetlands/MapServer/export
+func ServicesRoot(servicePath string) string {
+	if i := strings.Index(servicePath, "/rest/services"); i >= 0 {
+		return servicePath[:i] + "/rest/services"
+	}
+	return "/arcgis/rest/services"
+}
+
+// Matches reports whether a folder-qualified service name passes the include
+// and exclude patterns; exclusions take precedence
+func (c *ServiceCrawler) Matches(name string) bool {
+	for _, pattern := range c.exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(c.include) == 0 {
+		return true
+	}
+	for _, pattern := range c.include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Crawl walks the services directory and replaces the catalog. Folders and
+// services that cannot be read are skipped; an unreadable root keeps the
+// previous catalog.
+func (c *ServiceCrawler) Crawl(ctx context.Context) error {
+	root, err := c.listDirectory(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list services directory: %w", err)
+	}
+
+	var services []DiscoveredService
+	pending := []*directory{root}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		for _, folder := range dir.Folders {
+			sub, err := c.listDirectory(ctx, folder)
+			if err != nil {
+				c.logger.Warn("Failed to list services folder, skipping", "folder", folder, "error", err)
+				continue
+			}
+			pending = append(pending, sub)
+		}
+
+		for _, s := range dir.Services {
+			if !c.Matches(s.Name) {
+				continue
+			}
+			service := DiscoveredService{
+				Name: s.Name,
+				Type: s.Type,
+				Path: c.servicesRoot + "/" + s.Name + "/" + s.Type,
+			}
+			if s.Type == client.ServiceTypeMap {
+				metadata, err := c.arcgisClient.GetServiceMetadata(ctx, service.Path)
+				if err != nil {
+					c.logger.Warn("Failed to get metadata of discovered service, skipping", "service", s.Name, "error", err)
+					continue
+				}
+				service.Title = metadata.MapName
+				service.Layers = BuildCatalogLayers(metadata.Layers)
+			}
+			services = append(services, service)
+		}
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name != services[j].Name {
+			return services[i].Name < services[j].Name
+		}
+		return services[i].Type < services[j].Type
+	})
+
+	c.mu.Lock()
+	c.services = services
+	c.lastCrawl = time.Now()
+	c.mu.Unlock()
+
+	c.logger.Info("Crawled services directory", "services_root", c.servicesRoot, "services", len(services))
+	return nil
+}
+
+// listDirectory reads the root of the services directory or one of its folders
+func (c *ServiceCrawler) listDirectory(ctx context.Context, folder string) (*directory, error) {
+	dirURL := c.baseURL + c.servicesRoot
+	if folder != "" {
+		segments := strings.Split(folder, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		dirURL += "/" + strings.Join(segments, "/")
+	}
+
+	resp, err := c.arcgisClient.Get(ctx, dirURL+"?f=json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("directory request returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory response: %w", err)
+	}
+
+	var dir directory
+	if err := json.Unmarshal(body, &dir); err != nil {
+		return nil, fmt.Errorf("failed to parse directory response: %w", err)
+	}
+	if dir.Error != nil {
+		return nil, fmt.Errorf("ArcGIS error %d: %s", dir.Error.Code, dir.Error.Message)
+	}
+	return &dir, nil
+}
+
+// Start crawls the directory immediately and then every interval until ctx is cancelled
+func (c *ServiceCrawler) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.Crawl(ctx); err != nil {
+				c.logger.Error("Service discovery failed, keeping previous catalog", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Services returns the services found by the last successful crawl
+func (c *ServiceCrawler) Services() []DiscoveredService {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.services
+}
+
+// FindService returns the discovered service with the given name and type
+func (c *ServiceCrawler) FindService(name, serviceType string) (DiscoveredService, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, service := range c.services {
+		if service.Name == name && service.Type == serviceType {
+			return service, true
+		}
+	}
+	return DiscoveredService{}, false
+}
+
+// LastCrawl returns when the catalog was last refreshed
+func (c *ServiceCrawler) LastCrawl() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastCrawl
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"wms-proxy/internal/client"
+)
+
+// ServiceTypeDetector determines whether a backend service is a MapServer,
+// ImageServer or FeatureServer and caches the result
+type ServiceTypeDetector struct {
+	arcgisClient client.ArcGISClientInterface
+	logger       *slog.Logger
+	cache        map[string]string // servicePath -> service type
+	cacheExpiry  map[string]time.Time
+	cacheMutex   sync.RWMutex
+	cacheTTL     time.Duration
+}
+
+// NewServiceTypeDetector creates a new service type detector
+func NewServiceTypeDetector(arcgisClient client.ArcGISClientInterface, logger *slog.Logger) *ServiceTypeDetector {
+	return &ServiceTypeDetector{
+		arcgisClient: arcgisClient,
+		logger:       logger,
+		cache:        make(map[string]string),
+		cacheExpiry:  make(map[string]time.Time),
+		cacheTTL:     15 * time.Minute,
+	}
+}
+
+// GetServiceType detects the type of a service from its metadata. When the
+// metadata is unavailable the type is taken from the service path (MapServer
+// if the path names none) and not cached.
+func (d *ServiceTypeDetector) GetServiceType(ctx context.Context, servicePath string) string {
+	d.cacheMutex.RLock()
+	if serviceType, exists := d.cache[servicePath]; exists && time.Now().Before(d.cacheExpiry[servicePath]) {
+		d.cacheMutex.RUnlock()
+		return serviceType
+	}
+	d.cacheMutex.RUnlock()
+
+	metadata, err := d.arcgisClient.GetServiceMetadata(ctx, servicePath)
+	if err != nil {
+		serviceType := client.ServiceTypeFromPath(servicePath)
+		if serviceType == "" {
+			serviceType = client.ServiceTypeMap
+		}
+		d.logger.Warn("Failed to get service metadata, deriving service type from path",
+			"error", err,
+			"service_path", servicePath,
+			"service_type", serviceType)
+		return serviceType
+	}
+
+	serviceType := metadata.ServiceType(servicePath)
+	d.cacheMutex.Lock()
+	d.cache[servicePath] = serviceType
+	d.cacheExpiry[servicePath] = time.Now().Add(d.cacheTTL)
+	d.cacheMutex.Unlock()
+
+	d.logger.Info("Detected backend service type", "service_path", servicePath, "service_type", serviceType)
+	return serviceType
+}
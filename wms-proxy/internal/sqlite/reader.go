@@ -0,0 +1,334 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// maxDepth bounds the b-tree depth followed, guarding against cycles in
+// corrupt files
+const maxDepth = 32
+
+// DB is a database file opened for reading. Only databases in UTF-8 and in
+// rollback journal mode, or with the write-ahead log checkpointed, are read
+// correctly.
+type DB struct {
+	file          *os.File
+	pageSize      int
+	usable        int
+	applicationID uint32
+	userVersion   uint32
+}
+
+// SchemaEntry is a row of sqlite_schema
+type SchemaEntry struct {
+	Type      string // table, index, view or trigger
+	Name      string
+	TableName string
+	RootPage  uint32
+	SQL       string
+}
+
+// Open opens a database file for reading
+func Open(path string) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := file.ReadAt(header, 0); err != nil || !bytes.HasPrefix(header, []byte("SQLite format 3\x00")) {
+		file.Close()
+		return nil, fmt.Errorf("sqlite: %s is not a database file", path)
+	}
+	pageSize := int(binary.BigEndian.Uint16(header[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		file.Close()
+		return nil, errCorrupt
+	}
+	if encoding := binary.BigEndian.Uint32(header[56:]); encoding > 1 {
+		file.Close()
+		return nil, fmt.Errorf("sqlite: unsupported text encoding %d", encoding)
+	}
+
+	return &DB{
+		file:          file,
+		pageSize:      pageSize,
+		usable:        pageSize - int(header[20]),
+		applicationID: binary.BigEndian.Uint32(header[68:]),
+		userVersion:   binary.BigEndian.Uint32(header[60:]),
+	}, nil
+}
+
+// Close closes the file
+func (db *DB) Close() error {
+	return db.file.Close()
+}
+
+// ApplicationID returns the application id and user version of the header
+func (db *DB) ApplicationID() (uint32, uint32) {
+	return db.applicationID, db.userVersion
+}
+
+// Schema returns the rows of sqlite_schema
+func (db *DB) Schema() ([]SchemaEntry, error) {
+	var schema []SchemaEntry
+	err := db.ScanTable(1, func(rowid int64, values []Value) error {
+		if len(values) < 5 {
+			return errCorrupt
+		}
+		entry := SchemaEntry{}
+		entry.Type, _ = values[0].(string)
+		entry.Name, _ = values[1].(string)
+		entry.TableName, _ = values[2].(string)
+		if root, ok := values[3].(int64); ok {
+			entry.RootPage = uint32(root)
+		}
+		entry.SQL, _ = values[4].(string)
+		schema = append(schema, entry)
+		return nil
+	})
+	return schema, err
+}
+
+// ScanTable calls fn for every row of the table b-tree at root, in rowid order
+func (db *DB) ScanTable(root uint32, fn func(rowid int64, values []Value) error) error {
+	return db.scan(root, 0, func(cell []byte, pageType byte) error {
+		rowid, payload, err := db.tableCell(cell)
+		if err != nil {
+			return err
+		}
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return err
+		}
+		return fn(rowid, values)
+	})
+}
+
+// ScanIndex calls fn for every entry of the index b-tree at root, in key
+// order; the last value of an entry is the rowid of its row
+func (db *DB) ScanIndex(root uint32, fn func(values []Value) error) error {
+	return db.scan(root, 0, func(cell []byte, pageType byte) error {
+		if pageType == pageIndexInterior {
+			cell = cell[4:]
+		}
+		size, n := readVarint(cell)
+		if n == 0 {
+			return errCorrupt
+		}
+		payload, err := db.payload(int(size), cell[n:], db.maxLocal(true))
+		if err != nil {
+			return err
+		}
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return err
+		}
+		return fn(values)
+	})
+}
+
+// Row returns the row with rowid from the table b-tree at root
+func (db *DB) Row(root uint32, rowid int64) ([]Value, bool, error) {
+	page := root
+	for depth := 0; depth < maxDepth; depth++ {
+		data, header, err := db.page(page)
+		if err != nil {
+			return nil, false, err
+		}
+		cells, err := db.cells(data, header)
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch header[0] {
+		case pageTableLeaf:
+			i := sort.Search(len(cells), func(i int) bool {
+				key, _ := readVarint(cells[i][varintSkip(cells[i]):])
+				return int64(key) >= rowid
+			})
+			if i == len(cells) {
+				return nil, false, nil
+			}
+			key, payload, err := db.tableCell(cells[i])
+			if err != nil || key != rowid {
+				return nil, false, err
+			}
+			values, err := decodeRecord(payload)
+			return values, err == nil, err
+		case pageTableInterior:
+			// Descend into the first child whose largest rowid is not smaller
+			page = binary.BigEndian.Uint32(header[8:])
+			i := sort.Search(len(cells), func(i int) bool {
+				key, _ := readVarint(cells[i][4:])
+				return int64(key) >= rowid
+			})
+			if i < len(cells) {
+				page = binary.BigEndian.Uint32(cells[i])
+			}
+		default:
+			return nil, false, errCorrupt
+		}
+	}
+	return nil, false, errCorrupt
+}
+
+// scan walks a b-tree in key order, calling fn for the cells holding rows or
+// entries: table leaf cells, and index leaf and interior cells
+func (db *DB) scan(page uint32, depth int, fn func(cell []byte, pageType byte) error) error {
+	if depth >= maxDepth {
+		return errCorrupt
+	}
+	data, header, err := db.page(page)
+	if err != nil {
+		return err
+	}
+	cells, err := db.cells(data, header)
+	if err != nil {
+		return err
+	}
+
+	pageType := header[0]
+	switch pageType {
+	case pageTableLeaf, pageIndexLeaf:
+		for _, cell := range cells {
+			if err := fn(cell, pageType); err != nil {
+				return err
+			}
+		}
+		return nil
+	case pageTableInterior, pageIndexInterior:
+		for _, cell := range cells {
+			if len(cell) < 4 {
+				return errCorrupt
+			}
+			if err := db.scan(binary.BigEndian.Uint32(cell), depth+1, fn); err != nil {
+				return err
+			}
+			if pageType == pageIndexInterior {
+				if err := fn(cell, pageType); err != nil {
+					return err
+				}
+			}
+		}
+		return db.scan(binary.BigEndian.Uint32(header[8:]), depth+1, fn)
+	default:
+		return errCorrupt
+	}
+}
+
+// page reads a page and returns it with its b-tree header
+func (db *DB) page(page uint32) ([]byte, []byte, error) {
+	if page == 0 {
+		return nil, nil, errCorrupt
+	}
+	data := make([]byte, db.pageSize)
+	if _, err := db.file.ReadAt(data, int64(page-1)*int64(db.pageSize)); err != nil {
+		return nil, nil, fmt.Errorf("sqlite: failed to read page %d: %w", page, err)
+	}
+	header := data
+	if page == 1 {
+		header = data[headerSize:]
+	}
+	if len(header) < 12 {
+		return nil, nil, errCorrupt
+	}
+	return data, header, nil
+}
+
+// cells returns the cells of a page in pointer order; the slices run to the
+// end of the usable page
+func (db *DB) cells(data, header []byte) ([][]byte, error) {
+	headerLen := 8
+	if header[0] == pageTableInterior || header[0] == pageIndexInterior {
+		headerLen = 12
+	}
+	count := int(binary.BigEndian.Uint16(header[3:]))
+	if headerLen+2*count > len(header) {
+		return nil, errCorrupt
+	}
+
+	cells := make([][]byte, count)
+	for i := range cells {
+		offset := int(binary.BigEndian.Uint16(header[headerLen+2*i:]))
+		if offset >= db.usable {
+			return nil, errCorrupt
+		}
+		cells[i] = data[offset:db.usable]
+	}
+	return cells, nil
+}
+
+// tableCell decodes a table leaf cell into its rowid and payload
+func (db *DB) tableCell(cell []byte) (int64, []byte, error) {
+	size, n := readVarint(cell)
+	if n == 0 {
+		return 0, nil, errCorrupt
+	}
+	rowid, m := readVarint(cell[n:])
+	if m == 0 {
+		return 0, nil, errCorrupt
+	}
+	payload, err := db.payload(int(size), cell[n+m:], db.maxLocal(false))
+	return int64(rowid), payload, err
+}
+
+// maxLocal returns the largest payload stored in a cell without overflow
+func (db *DB) maxLocal(index bool) int {
+	if index {
+		return (db.usable-12)*64/255 - 23
+	}
+	return db.usable - 35
+}
+
+// payload reads a payload of size bytes starting in cell, following the
+// overflow pages
+func (db *DB) payload(size int, cell []byte, maxLocal int) ([]byte, error) {
+	if size < 0 {
+		return nil, errCorrupt
+	}
+	if size <= maxLocal {
+		if size > len(cell) {
+			return nil, errCorrupt
+		}
+		return cell[:size], nil
+	}
+
+	minLocal := (db.usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(db.usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	if local+4 > len(cell) {
+		return nil, errCorrupt
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	data := make([]byte, db.pageSize)
+	for len(payload) < size {
+		if next == 0 {
+			return nil, errCorrupt
+		}
+		if _, err := db.file.ReadAt(data, int64(next-1)*int64(db.pageSize)); err != nil {
+			return nil, fmt.Errorf("sqlite: failed to read page %d: %w", next, err)
+		}
+		next = binary.BigEndian.Uint32(data)
+		payload = append(payload, data[4:4+min(size-len(payload), db.usable-4)]...)
+	}
+	return payload, nil
+}
+
+// varintSkip returns the length of the varint at the start of b
+func varintSkip(b []byte) int {
+	_, n := readVarint(b)
+	return n
+}
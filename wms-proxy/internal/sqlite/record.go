@@ -0,0 +1,282 @@
+// Package sqlite reads and writes SQLite database files without a SQL engine.
+// It covers the part of the file format needed for tile packages such as
+// MBTiles and GeoPackage: writing tables and indexes in a new file, scanning
+// tables and indexes and looking up rows by rowid.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Value is a column value: nil, int64, float64, string or []byte
+type Value interface{}
+
+// errCorrupt is returned for malformed database files
+var errCorrupt = errors.New("sqlite: database disk image is malformed")
+
+// appendVarint appends v in the SQLite variable-length integer encoding
+func appendVarint(buf []byte, v uint64) []byte {
+	if v <= 0x7f {
+		return append(buf, byte(v))
+	}
+	if v > 0x00ffffffffffffff {
+		// Nine bytes: eight groups of seven bits, then eight bits
+		var b [9]byte
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, b[:]...)
+	}
+
+	var b [8]byte
+	n := 0
+	for ; v > 0; n++ {
+		b[n] = byte(v&0x7f) | 0x80
+		v >>= 7
+	}
+	b[0] &= 0x7f
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, b[i])
+	}
+	return buf
+}
+
+// varintLen returns the encoded length of v
+func varintLen(v uint64) int {
+	n := 1
+	for v > 0x7f && n < 9 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// readVarint decodes a variable-length integer, returning its length; the
+// length is 0 when b is too short
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	if len(b) < 9 {
+		return 0, 0
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// serialType returns the serial type of a value and its body length
+func serialType(value Value) (uint64, int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, 0, nil
+	case int64:
+		switch {
+		case v == 0:
+			return 8, 0, nil
+		case v == 1:
+			return 9, 0, nil
+		case v >= math.MinInt8 && v <= math.MaxInt8:
+			return 1, 1, nil
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			return 2, 2, nil
+		case v >= -1<<23 && v < 1<<23:
+			return 3, 3, nil
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			return 4, 4, nil
+		case v >= -1<<47 && v < 1<<47:
+			return 5, 6, nil
+		default:
+			return 6, 8, nil
+		}
+	case float64:
+		return 7, 8, nil
+	case string:
+		return uint64(len(v))*2 + 13, len(v), nil
+	case []byte:
+		return uint64(len(v))*2 + 12, len(v), nil
+	default:
+		return 0, 0, fmt.Errorf("sqlite: unsupported value type %T", value)
+	}
+}
+
+// encodeRecord encodes values in the record format: a header of serial types
+// followed by the value bodies
+func encodeRecord(values []Value) ([]byte, error) {
+	types := make([]byte, 0, len(values))
+	bodyLen := 0
+	for _, value := range values {
+		t, n, err := serialType(value)
+		if err != nil {
+			return nil, err
+		}
+		types = appendVarint(types, t)
+		bodyLen += n
+	}
+
+	// The header size counts its own varint
+	headerLen := len(types) + 1
+	if varintLen(uint64(headerLen)) > 1 {
+		headerLen = len(types) + varintLen(uint64(len(types)+2))
+	}
+
+	record := make([]byte, 0, headerLen+bodyLen)
+	record = appendVarint(record, uint64(headerLen))
+	record = append(record, types...)
+	for _, value := range values {
+		switch v := value.(type) {
+		case int64:
+			t, n, _ := serialType(v)
+			if t < 8 {
+				var b [8]byte
+				binary.BigEndian.PutUint64(b[:], uint64(v))
+				record = append(record, b[8-n:]...)
+			}
+		case float64:
+			record = binary.BigEndian.AppendUint64(record, math.Float64bits(v))
+		case string:
+			record = append(record, v...)
+		case []byte:
+			record = append(record, v...)
+		}
+	}
+	return record, nil
+}
+
+// decodeRecord decodes a record into its values
+func decodeRecord(record []byte) ([]Value, error) {
+	headerLen, n := readVarint(record)
+	if n == 0 || headerLen > uint64(len(record)) {
+		return nil, errCorrupt
+	}
+
+	var values []Value
+	body := record[headerLen:]
+	for header := record[n:headerLen]; len(header) > 0; {
+		t, n := readVarint(header)
+		if n == 0 {
+			return nil, errCorrupt
+		}
+		header = header[n:]
+
+		size := serialSize(t)
+		if size > len(body) {
+			return nil, errCorrupt
+		}
+		data := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t <= 6:
+			// Sign-extend the big-endian integer
+			v := int64(int8(data[0]))
+			for _, b := range data[1:] {
+				v = v<<8 | int64(b)
+			}
+			values = append(values, v)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(data)))
+		case t == 8:
+			values = append(values, int64(0))
+		case t == 9:
+			values = append(values, int64(1))
+		case t >= 12 && t%2 == 0:
+			values = append(values, append([]byte(nil), data...))
+		case t >= 13:
+			values = append(values, string(data))
+		default:
+			return nil, errCorrupt
+		}
+	}
+	return values, nil
+}
+
+// serialSize returns the body length of a serial type
+func serialSize(t uint64) int {
+	switch {
+	case t <= 4:
+		return int(t)
+	case t == 5:
+		return 6
+	case t == 6 || t == 7:
+		return 8
+	case t >= 12:
+		return int((t - 12) / 2)
+	default:
+		return 0
+	}
+}
+
+// compareValues orders values as SQLite does with the BINARY collation:
+// NULL, then numbers, then text, then blobs
+func compareValues(a, b Value) int {
+	classA, classB := valueClass(a), valueClass(b)
+	if classA != classB {
+		return classA - classB
+	}
+	switch va := a.(type) {
+	case int64:
+		if vb, ok := b.(int64); ok {
+			return compareOrdered(va, vb)
+		}
+		return compareOrdered(float64(va), b.(float64))
+	case float64:
+		if vb, ok := b.(int64); ok {
+			return compareOrdered(va, float64(vb))
+		}
+		return compareOrdered(va, b.(float64))
+	case string:
+		return compareOrdered(va, b.(string))
+	case []byte:
+		return bytes.Compare(va, b.([]byte))
+	}
+	return 0
+}
+
+// compareRecords orders records value by value
+func compareRecords(a, b []Value) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func valueClass(value Value) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func compareOrdered[T int64 | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
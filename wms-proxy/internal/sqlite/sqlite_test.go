@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 0x7f, 0x80, 0x3fff, 0x4000, 1<<56 - 1, 1 << 56, 1<<64 - 1} {
+		encoded := appendVarint(nil, v)
+		if len(encoded) != varintLen(v) {
+			t.Errorf("%d: encoded in %d bytes, expected %d", v, len(encoded), varintLen(v))
+		}
+		if decoded, n := readVarint(encoded); decoded != v || n != len(encoded) {
+			t.Errorf("%d: decoded %d from %d bytes", v, decoded, n)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	values := []Value{nil, int64(0), int64(1), int64(-100), int64(40000), int64(-1 << 40), int64(1 << 62), 2.5, "text", []byte{0, 1, 2}}
+	record, err := encodeRecord(values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, err := decodeRecord(record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(decoded) != len(values) || compareRecords(decoded, values) != 0 {
+		t.Errorf("expected %v, got %v", values, decoded)
+	}
+
+	if _, err := encodeRecord([]Value{int32(1)}); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	w, err := Create(path, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.SetApplicationID(0x47504B47, 10300)
+
+	tiles := w.CreateTable("tiles", "CREATE TABLE tiles (id INTEGER PRIMARY KEY, z INTEGER, x INTEGER, data BLOB, UNIQUE (z, x))",
+		Index{Name: "sqlite_autoindex_tiles_1", Columns: []int{1, 2}, Unique: true})
+	// Enough rows for interior pages, with payloads needing overflow pages
+	for i := int64(1); i <= 2000; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, int(i%7)*500)
+		if err := tiles.Insert(i, nil, i%10, 2000-i, data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := tiles.Insert(5, nil, int64(0), int64(0), []byte{}); err == nil {
+		t.Error("expected an error for a decreasing rowid")
+	}
+	if err := tiles.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	duplicates := w.CreateTable("duplicates", "CREATE TABLE duplicates (name TEXT UNIQUE)",
+		Index{Name: "sqlite_autoindex_duplicates_1", Columns: []int{0}, Unique: true})
+	duplicates.Insert(1, "a")
+	duplicates.Insert(2, "a")
+	if err := duplicates.Close(); err == nil {
+		t.Error("expected an error for a duplicate entry in a unique index")
+	}
+
+	metadata := w.CreateTable("metadata", "CREATE TABLE metadata (name TEXT, value TEXT)")
+	metadata.Insert(1, "name", "test")
+	if err := metadata.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	if id, version := db.ApplicationID(); id != 0x47504B47 || version != 10300 {
+		t.Errorf("unexpected application id %x and version %d", id, version)
+	}
+	schema, err := db.Schema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(schema) != 3 || schema[0].Name != "tiles" || schema[1].Type != "index" || schema[1].SQL != "" || schema[2].Name != "metadata" {
+		t.Fatalf("unexpected schema: %+v", schema)
+	}
+
+	rows := int64(0)
+	err = db.ScanTable(schema[0].RootPage, func(rowid int64, values []Value) error {
+		rows++
+		if rowid != rows || values[0] != nil || values[1] != rowid%10 || len(values[3].([]byte)) != int(rowid%7)*500 {
+			t.Errorf("unexpected row %d: %v", rowid, values[:3])
+		}
+		return nil
+	})
+	if err != nil || rows != 2000 {
+		t.Errorf("scanned %d rows: %v", rows, err)
+	}
+
+	var previous []Value
+	entries := 0
+	err = db.ScanIndex(schema[1].RootPage, func(values []Value) error {
+		if previous != nil && compareRecords(previous, values) >= 0 {
+			t.Errorf("index entries out of order: %v before %v", previous, values)
+		}
+		previous = values
+		entries++
+		return nil
+	})
+	if err != nil || entries != 2000 {
+		t.Errorf("scanned %d index entries: %v", entries, err)
+	}
+
+	for _, rowid := range []int64{1, 777, 2000} {
+		values, found, err := db.Row(schema[0].RootPage, rowid)
+		if err != nil || !found || values[2] != 2000-rowid || !bytes.Equal(values[3].([]byte), bytes.Repeat([]byte{byte(rowid)}, int(rowid%7)*500)) {
+			t.Errorf("unexpected row %d: %v %v", rowid, found, err)
+		}
+	}
+	if _, found, err := db.Row(schema[0].RootPage, 2001); found || err != nil {
+		t.Errorf("expected no row, got %v %v", found, err)
+	}
+}
@@ -0,0 +1,442 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Page types
+const (
+	pageIndexInterior = 0x02
+	pageTableInterior = 0x05
+	pageIndexLeaf     = 0x0a
+	pageTableLeaf     = 0x0d
+)
+
+// headerSize is the size of the database header at the start of page 1
+const headerSize = 100
+
+// Writer writes a new database file. Rows are appended to the tables in
+// increasing rowid order; their pages are written as they fill, so large
+// tables are not held in memory. Indexes are built when their table is
+// closed and the schema when the writer is closed.
+type Writer struct {
+	file          *os.File
+	pageSize      int
+	nextPage      uint32
+	schema        *treeBuilder
+	schemaRowid   int64
+	applicationID uint32
+	userVersion   uint32
+}
+
+// Index is an index of a table. Automatic indexes, created by PRIMARY KEY and
+// UNIQUE constraints of the table definition, have no SQL and must be named
+// sqlite_autoindex_<table>_<n> in the order of the constraints.
+type Index struct {
+	Name    string
+	SQL     string
+	Columns []int // indexed columns of the table
+	Unique  bool  // reject rows with the same indexed values
+}
+
+// Create creates a database file at path with the given page size, a power
+// of two between 512 and 65536. An existing file is replaced.
+func Create(path string, pageSize int) (*Writer, error) {
+	if pageSize < 512 || pageSize > 65536 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("sqlite: invalid page size %d", pageSize)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{file: file, pageSize: pageSize, nextPage: 2}
+	w.schema = newTreeBuilder(w, false, headerSize)
+	return w, nil
+}
+
+// SetApplicationID sets the application id and user version of the header,
+// e.g. for GeoPackage
+func (w *Writer) SetApplicationID(applicationID, userVersion uint32) {
+	w.applicationID = applicationID
+	w.userVersion = userVersion
+}
+
+// CreateTable starts a table defined by sql with its indexes
+func (w *Writer) CreateTable(name, sql string, indexes ...Index) *TableWriter {
+	t := &TableWriter{
+		w:       w,
+		name:    name,
+		sql:     sql,
+		tree:    newTreeBuilder(w, false, 0),
+		indexes: indexes,
+		entries: make([][][]Value, len(indexes)),
+	}
+	return t
+}
+
+// Close writes the schema and the database header
+func (w *Writer) Close() error {
+	defer w.file.Close()
+
+	if _, err := w.schema.finish(1); err != nil {
+		return err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], uint16(w.pageSize&0xffff|w.pageSize>>16))
+	header[18], header[19] = 1, 1 // legacy journal
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(header[28:], w.nextPage-1)
+	binary.BigEndian.PutUint32(header[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4) // schema format
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[60:], w.userVersion)
+	binary.BigEndian.PutUint32(header[68:], w.applicationID)
+	binary.BigEndian.PutUint32(header[92:], 1)       // version-valid-for
+	binary.BigEndian.PutUint32(header[96:], 3040001) // format of SQLite 3.40.1
+	if _, err := w.file.WriteAt(header, 0); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
+
+// Abort closes and removes the unfinished file
+func (w *Writer) Abort() error {
+	w.file.Close()
+	return os.Remove(w.file.Name())
+}
+
+// allocate reserves the next page
+func (w *Writer) allocate() uint32 {
+	page := w.nextPage
+	w.nextPage++
+	return page
+}
+
+// writePage writes a page at its offset
+func (w *Writer) writePage(page uint32, data []byte) error {
+	_, err := w.file.WriteAt(data, int64(page-1)*int64(w.pageSize))
+	return err
+}
+
+// addSchema adds a row to sqlite_schema
+func (w *Writer) addSchema(kind, name, table string, root uint32, sql string) error {
+	var sqlValue Value
+	if sql != "" {
+		sqlValue = sql
+	}
+	w.schemaRowid++
+	return w.schema.addRow(w.schemaRowid, []Value{kind, name, table, int64(root), sqlValue})
+}
+
+// TableWriter appends the rows of a table
+type TableWriter struct {
+	w         *Writer
+	name      string
+	sql       string
+	tree      *treeBuilder
+	indexes   []Index
+	entries   [][][]Value // index entries per index
+	lastRowid int64
+	rows      int64
+}
+
+// Insert appends a row; rowids must increase. The value of an INTEGER
+// PRIMARY KEY column is the rowid and must be passed as nil.
+func (t *TableWriter) Insert(rowid int64, values ...Value) error {
+	if t.rows > 0 && rowid <= t.lastRowid {
+		return fmt.Errorf("sqlite: rowid %d of table %s is not increasing", rowid, t.name)
+	}
+	for i, index := range t.indexes {
+		entry := make([]Value, 0, len(index.Columns)+1)
+		for _, column := range index.Columns {
+			entry = append(entry, values[column])
+		}
+		t.entries[i] = append(t.entries[i], append(entry, rowid))
+	}
+	t.lastRowid = rowid
+	t.rows++
+	return t.tree.addRow(rowid, values)
+}
+
+// Close finishes the table, builds its indexes and adds them to the schema.
+// A table violating a unique index is left out of the schema.
+func (t *TableWriter) Close() error {
+	for i, index := range t.indexes {
+		entries := t.entries[i]
+		sort.Slice(entries, func(a, b int) bool { return compareRecords(entries[a], entries[b]) < 0 })
+		if !index.Unique {
+			continue
+		}
+		columns := len(index.Columns)
+		for j := 1; j < len(entries); j++ {
+			if compareRecords(entries[j-1][:columns], entries[j][:columns]) == 0 {
+				return fmt.Errorf("sqlite: duplicate entry %v in unique index %s", entries[j][:columns], index.Name)
+			}
+		}
+	}
+
+	root, err := t.tree.finish(0)
+	if err != nil {
+		return err
+	}
+	if err := t.w.addSchema("table", t.name, t.name, root, t.sql); err != nil {
+		return err
+	}
+
+	for i, index := range t.indexes {
+		tree := newTreeBuilder(t.w, true, 0)
+		for _, entry := range t.entries[i] {
+			if err := tree.addEntry(entry); err != nil {
+				return err
+			}
+		}
+		root, err := tree.finish(0)
+		if err != nil {
+			return err
+		}
+		if err := t.w.addSchema("index", index.Name, t.name, root, index.SQL); err != nil {
+			return err
+		}
+	}
+	t.entries = nil
+	return nil
+}
+
+// treeBuilder builds a b-tree bottom-up from cells added in key order. Leaf
+// pages are written as they fill; interior levels are built by finish.
+type treeBuilder struct {
+	w        *Writer
+	index    bool
+	offset   int // bytes reserved before the page header; the database header on page 1
+	cells    [][]byte
+	used     int
+	lastKey  int64
+	children []uint32
+	dividers [][]byte // cell content after the child pointer, one per child but the last
+}
+
+func newTreeBuilder(w *Writer, index bool, offset int) *treeBuilder {
+	return &treeBuilder{w: w, index: index, offset: offset}
+}
+
+// addRow adds a table row
+func (b *treeBuilder) addRow(rowid int64, values []Value) error {
+	payload, err := encodeRecord(values)
+	if err != nil {
+		return err
+	}
+	cell := appendVarint(nil, uint64(len(payload)))
+	cell = appendVarint(cell, uint64(rowid))
+	cell, err = b.appendPayload(cell, payload, b.w.pageSize-35)
+	if err != nil {
+		return err
+	}
+
+	if !b.fits(cell, 8) {
+		if len(b.cells) == 0 {
+			return fmt.Errorf("sqlite: row %d does not fit in a page", rowid)
+		}
+		if err := b.flushLeaf(); err != nil {
+			return err
+		}
+		// A table divider is the largest rowid of the child on its left
+		b.dividers = append(b.dividers, appendVarint(nil, uint64(b.lastKey)))
+	}
+	b.add(cell)
+	b.lastKey = rowid
+	return nil
+}
+
+// addEntry adds an index entry
+func (b *treeBuilder) addEntry(entry []Value) error {
+	payload, err := encodeRecord(entry)
+	if err != nil {
+		return err
+	}
+	cell := appendVarint(nil, uint64(len(payload)))
+	cell, err = b.appendPayload(cell, payload, (b.w.pageSize-12)*64/255-23)
+	if err != nil {
+		return err
+	}
+
+	if !b.fits(cell, 8) {
+		// Index interior cells hold entries: the last entry of the full leaf
+		// moves up as the divider
+		divider := b.cells[len(b.cells)-1]
+		b.cells = b.cells[:len(b.cells)-1]
+		if err := b.flushLeaf(); err != nil {
+			return err
+		}
+		b.dividers = append(b.dividers, divider)
+	}
+	b.add(cell)
+	return nil
+}
+
+// appendPayload appends the part of payload stored in the cell, writing the
+// rest to overflow pages when it exceeds maxLocal
+func (b *treeBuilder) appendPayload(cell, payload []byte, maxLocal int) ([]byte, error) {
+	if len(payload) <= maxLocal {
+		return append(cell, payload...), nil
+	}
+
+	usable := b.w.pageSize
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+
+	rest := payload[local:]
+	first := b.w.nextPage
+	for len(rest) > 0 {
+		page := b.w.allocate()
+		n := min(len(rest), usable-4)
+		data := make([]byte, usable)
+		if n < len(rest) {
+			binary.BigEndian.PutUint32(data, page+1)
+		}
+		copy(data[4:], rest[:n])
+		if err := b.w.writePage(page, data); err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+	}
+	return binary.BigEndian.AppendUint32(cell, first), nil
+}
+
+// fits reports whether a cell fits in the current page with a page header of
+// headerLen bytes
+func (b *treeBuilder) fits(cell []byte, headerLen int) bool {
+	return b.offset+headerLen+2*(len(b.cells)+1)+b.used+len(cell) <= b.w.pageSize
+}
+
+func (b *treeBuilder) add(cell []byte) {
+	b.cells = append(b.cells, cell)
+	b.used += len(cell)
+}
+
+// flushLeaf writes the current leaf page and starts a new one
+func (b *treeBuilder) flushLeaf() error {
+	page := b.w.allocate()
+	if err := b.w.writePage(page, b.buildPage(page, b.leafType(), b.cells, 0)); err != nil {
+		return err
+	}
+	b.children = append(b.children, page)
+	b.cells = nil
+	b.used = 0
+	return nil
+}
+
+// leafType returns the page type of the leaves
+func (b *treeBuilder) leafType() byte {
+	pageType := byte(pageTableLeaf)
+	if b.index {
+		pageType = pageIndexLeaf
+	}
+	return pageType
+}
+
+// finish writes the remaining pages and returns the root page, which is
+// written at rootPage when it is not 0
+func (b *treeBuilder) finish(rootPage uint32) (uint32, error) {
+	place := func(pageType byte, cells [][]byte, right uint32) (uint32, error) {
+		page := rootPage
+		if page == 0 {
+			page = b.w.allocate()
+		}
+		return page, b.w.writePage(page, b.buildPage(page, pageType, cells, right))
+	}
+
+	if len(b.children) == 0 {
+		return place(b.leafType(), b.cells, 0)
+	}
+	if err := b.flushLeaf(); err != nil {
+		return 0, err
+	}
+
+	pageType := byte(pageTableInterior)
+	if b.index {
+		pageType = pageIndexInterior
+	}
+	children, dividers := b.children, b.dividers
+	for {
+		pages, rights, up := b.packInterior(children, dividers)
+		if len(pages) == 1 {
+			return place(pageType, pages[0], rights[0])
+		}
+		children = children[:0:0]
+		for i, cells := range pages {
+			page := b.w.allocate()
+			if err := b.w.writePage(page, b.buildPage(page, pageType, cells, rights[i])); err != nil {
+				return 0, err
+			}
+			children = append(children, page)
+		}
+		dividers = up
+	}
+}
+
+// packInterior distributes the children of a level over interior pages. It
+// returns the cells and right child of each page and the dividers between the
+// pages, which move up to the next level.
+func (b *treeBuilder) packInterior(children []uint32, dividers [][]byte) ([][][]byte, []uint32, [][]byte) {
+	var pages [][][]byte
+	var rights []uint32
+	var up [][]byte
+
+	b.cells, b.used = nil, 0
+	for i := 0; i < len(children)-1; i++ {
+		cell := binary.BigEndian.AppendUint32(nil, children[i])
+		cell = append(cell, dividers[i]...)
+		if !b.fits(cell, 12) {
+			// The child of the last cell becomes the right child and its
+			// divider moves up
+			last := b.cells[len(b.cells)-1]
+			pages = append(pages, b.cells[:len(b.cells)-1])
+			rights = append(rights, binary.BigEndian.Uint32(last))
+			up = append(up, last[4:])
+			b.cells, b.used = nil, 0
+		}
+		b.add(cell)
+	}
+	pages = append(pages, b.cells)
+	rights = append(rights, children[len(children)-1])
+	b.cells, b.used = nil, 0
+	return pages, rights, up
+}
+
+// buildPage lays out a b-tree page: the header, the cell pointers and the
+// cells at the end of the page. On page 1 the header follows the database
+// header.
+func (b *treeBuilder) buildPage(page uint32, pageType byte, cells [][]byte, right uint32) []byte {
+	data := make([]byte, b.w.pageSize)
+	header := data
+	if page == 1 {
+		header = data[headerSize:]
+	}
+	headerLen := 8
+	if pageType == pageTableInterior || pageType == pageIndexInterior {
+		headerLen = 12
+		binary.BigEndian.PutUint32(header[8:], right)
+	}
+
+	content := len(data)
+	for i, cell := range cells {
+		content -= len(cell)
+		copy(data[content:], cell)
+		binary.BigEndian.PutUint16(header[headerLen+2*i:], uint16(content))
+	}
+
+	header[0] = pageType
+	binary.BigEndian.PutUint16(header[3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(header[5:], uint16(content)) // 65536 wraps to 0
+	return data
+}
@@ -0,0 +1,185 @@
+package tilefile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wms-proxy/internal/mvt"
+	"wms-proxy/internal/transform"
+)
+
+// ExportOptions selects the tiles of an export
+type ExportOptions struct {
+	Bounds  [4]float64 // WGS84 min lon, min lat, max lon, max lat
+	MinZoom int
+	MaxZoom int
+	// TileURL is the proxy URL path of a tile, with {z}, {x}, {y} and {bbox}
+	// (the EPSG:3857 tile bounds) placeholders
+	TileURL     string
+	Concurrency int
+	Format      string // tile format; pbf tiles are gzip compressed before writing
+}
+
+// ExportStats counts the tiles of an export
+type ExportStats struct {
+	Written int64
+	Empty   int64 // tiles answered 204 or 404, which are not written
+}
+
+type exportTile struct {
+	z, x, y int
+	data    []byte
+}
+
+// Export requests every tile of opts from handler, the proxy's router, and
+// writes the tiles to writer. Tiles come from the proxy's caches when they
+// hold them and are rendered through the backend otherwise. Export stops at
+// the first failed tile; the caller closes or aborts writer.
+func Export(ctx context.Context, handler http.Handler, writer Writer, opts ExportOptions, logger *slog.Logger) (ExportStats, error) {
+	var stats ExportStats
+	if opts.MinZoom < 0 || opts.MaxZoom > 24 || opts.MinZoom > opts.MaxZoom {
+		return stats, fmt.Errorf("invalid zoom range %d-%d", opts.MinZoom, opts.MaxZoom)
+	}
+	bounds, err := transform.NewCoordinateTransformer().TransformEnvelope(
+		transform.BBox{MinX: opts.Bounds[0], MinY: opts.Bounds[1], MaxX: opts.Bounds[2], MaxY: opts.Bounds[3]},
+		"EPSG:4326", "EPSG:3857")
+	if err != nil {
+		return stats, fmt.Errorf("invalid bounds: %w", err)
+	}
+	concurrency := max(opts.Concurrency, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Tiles are fetched concurrently and written by one goroutine
+	jobs := make(chan exportTile)
+	results := make(chan exportTile)
+	errs := make(chan error, concurrency+1)
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for tile := range jobs {
+				data, err := fetchTile(ctx, handler, opts, tile.z, tile.x, tile.y)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				tile.data = data
+				select {
+				case results <- tile:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for z := opts.MinZoom; z <= opts.MaxZoom; z++ {
+			minX, minY, maxX, maxY := mvt.TileRange(bounds, z)
+			logger.Info("Exporting zoom level", "zoom", z, "tiles", (maxX-minX+1)*(maxY-minY+1))
+			for x := minX; x <= maxX; x++ {
+				for y := minY; y <= maxY; y++ {
+					select {
+					case jobs <- exportTile{z: z, x: x, y: y}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	for tile := range results {
+		if tile.data == nil {
+			stats.Empty++
+			continue
+		}
+		if err := writer.WriteTile(tile.z, tile.x, tile.y, tile.data); err != nil {
+			errs <- fmt.Errorf("failed to write tile %d/%d/%d: %w", tile.z, tile.x, tile.y, err)
+			cancel()
+			break
+		}
+		stats.Written++
+	}
+	// Let the workers finish after a write error
+	for range results {
+	}
+
+	select {
+	case err := <-errs:
+		return stats, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// fetchTile requests a tile from handler; an empty tile returns nil data
+func fetchTile(ctx context.Context, handler http.Handler, opts ExportOptions, z, x, y int) ([]byte, error) {
+	bounds, err := mvt.TileBounds(z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	target := strings.NewReplacer(
+		"{z}", strconv.Itoa(z),
+		"{x}", strconv.Itoa(x),
+		"{y}", strconv.Itoa(y),
+		"{bbox}", fmt.Sprintf("%f,%f,%f,%f", bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY),
+	).Replace(opts.TileURL)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	switch recorder.Code {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("tile %d/%d/%d: %s returned %d: %s", z, x, y, target, recorder.Code,
+			strings.TrimSpace(recorder.Body.String()))
+	}
+
+	data := recorder.Body.Bytes()
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if opts.Format != TilePBF {
+		// WMS reports some errors as service exceptions with status 200
+		if format := detectFormat(data); format != opts.Format {
+			return nil, fmt.Errorf("tile %d/%d/%d: %s returned %s instead of a %s tile", z, x, y, target,
+				recorder.Header().Get("Content-Type"), opts.Format)
+		}
+		return data, nil
+	}
+	if recorder.Header().Get("Content-Encoding") == "gzip" {
+		return data, nil
+	}
+
+	// MBTiles vector tiles are stored gzip compressed
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
@@ -0,0 +1,315 @@
+package tilefile
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"wms-proxy/internal/mvt"
+	"wms-proxy/internal/sqlite"
+	"wms-proxy/internal/transform"
+)
+
+// GeoPackage 1.3 header values
+const (
+	geoPackageApplicationID = 0x47504B47 // "GPKG"
+	geoPackageVersion       = 10300
+)
+
+// tileSize is the width and height of exported tiles in pixels
+const tileSize = 256
+
+// webMercatorSRS are the srs ids of EPSG:3857 and its aliases
+var webMercatorSRS = map[int]bool{3857: true, 900913: true, 102100: true, 102113: true}
+
+// unsafeTableName matches the characters not kept in a tile table name
+var unsafeTableName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Spatial reference systems every GeoPackage defines, with EPSG:3857 for the
+// tiles; rows are in srs_id order
+var geoPackageSRS = [][]sqlite.Value{
+	{"Undefined cartesian SRS", int64(-1), "NONE", int64(-1), "undefined", "undefined cartesian coordinate reference system"},
+	{"Undefined geographic SRS", int64(0), "NONE", int64(0), "undefined", "undefined geographic coordinate reference system"},
+	{"WGS 84 / Pseudo-Mercator", int64(3857), "EPSG", int64(3857),
+		`PROJCS["WGS 84 / Pseudo-Mercator",GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]],PROJECTION["Mercator_1SP"],PARAMETER["central_meridian",0],PARAMETER["scale_factor",1],PARAMETER["false_easting",0],PARAMETER["false_northing",0],UNIT["metre",1,AUTHORITY["EPSG","9001"]],AXIS["Easting",EAST],AXIS["Northing",NORTH],AUTHORITY["EPSG","3857"]]`,
+		"Web Mercator"},
+	{"WGS 84 geodetic", int64(4326), "EPSG", int64(4326),
+		`GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AXIS["Latitude",NORTH],AXIS["Longitude",EAST],AUTHORITY["EPSG","4326"]]`,
+		"longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid"},
+}
+
+// geoPackageWriter writes a GeoPackage 1.3 tile pyramid in the
+// GoogleMapsCompatible tile matrix set: EPSG:3857, 256 pixel tiles, zoom
+// levels and tile rows as in XYZ
+type geoPackageWriter struct {
+	db       *sqlite.Writer
+	table    string
+	tiles    *sqlite.TableWriter
+	metadata Metadata
+	zooms    zoomRange
+	rowid    int64
+}
+
+func createGeoPackage(path string, metadata Metadata) (*geoPackageWriter, error) {
+	if metadata.Format != TilePNG && metadata.Format != TileJPEG {
+		return nil, fmt.Errorf("GeoPackage tiles must be png or jpg, not %s", metadata.Format)
+	}
+	table := unsafeTableName.ReplaceAllString(metadata.Name, "_")
+	if table == "" || table[0] >= '0' && table[0] <= '9' || len(table) >= 4 && table[:4] == "gpkg" {
+		table = "tiles_" + table
+	}
+
+	db, err := sqlite.Create(path, 4096)
+	if err != nil {
+		return nil, err
+	}
+	db.SetApplicationID(geoPackageApplicationID, geoPackageVersion)
+	tiles := db.CreateTable(table,
+		`CREATE TABLE "`+table+`" (id INTEGER PRIMARY KEY AUTOINCREMENT, zoom_level INTEGER NOT NULL, tile_column INTEGER NOT NULL, tile_row INTEGER NOT NULL, tile_data BLOB NOT NULL, UNIQUE (zoom_level, tile_column, tile_row))`,
+		sqlite.Index{Name: "sqlite_autoindex_" + table + "_1", Columns: []int{1, 2, 3}, Unique: true})
+	return &geoPackageWriter{db: db, table: table, tiles: tiles, metadata: metadata}, nil
+}
+
+// WriteTile stores an XYZ tile
+func (w *geoPackageWriter) WriteTile(z, x, y int, data []byte) error {
+	w.rowid++
+	w.zooms.add(z)
+	return w.tiles.Insert(w.rowid, nil, int64(z), int64(x), int64(y), data)
+}
+
+// Close writes the GeoPackage tables describing the tiles
+func (w *geoPackageWriter) Close() error {
+	if err := w.finish(); err != nil {
+		w.db.Abort()
+		return err
+	}
+	return w.db.Close()
+}
+
+// Abort removes the unfinished file
+func (w *geoPackageWriter) Abort() error {
+	return w.db.Abort()
+}
+
+func (w *geoPackageWriter) finish() error {
+	if err := w.tiles.Close(); err != nil {
+		return err
+	}
+	w.zooms.apply(&w.metadata)
+
+	world, _ := mvt.TileBounds(0, 0, 0)
+	bounds := transform.BBox{MinX: w.metadata.Bounds[0], MinY: w.metadata.Bounds[1], MaxX: w.metadata.Bounds[2], MaxY: w.metadata.Bounds[3]}
+	bounds, err := transform.NewCoordinateTransformer().TransformEnvelope(bounds, "EPSG:4326", "EPSG:3857")
+	if err != nil {
+		return err
+	}
+
+	tables := []struct {
+		name    string
+		sql     string
+		indexes []sqlite.Index
+		rows    [][]sqlite.Value
+		rowids  []int64
+	}{
+		{
+			name: "gpkg_spatial_ref_sys",
+			sql:  "CREATE TABLE gpkg_spatial_ref_sys (srs_name TEXT NOT NULL, srs_id INTEGER NOT NULL PRIMARY KEY, organization TEXT NOT NULL, organization_coordsys_id INTEGER NOT NULL, definition TEXT NOT NULL, description TEXT)",
+		},
+		{
+			name: "gpkg_contents",
+			sql:  "CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL, identifier TEXT UNIQUE, description TEXT DEFAULT '', last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')), min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE, srs_id INTEGER, CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id))",
+			indexes: []sqlite.Index{
+				{Name: "sqlite_autoindex_gpkg_contents_1", Columns: []int{0}, Unique: true},
+				{Name: "sqlite_autoindex_gpkg_contents_2", Columns: []int{2}, Unique: true},
+			},
+			rows: [][]sqlite.Value{{
+				w.table, "tiles", w.metadata.Name, w.metadata.Description,
+				time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+				bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY, int64(3857),
+			}},
+		},
+		{
+			name: "gpkg_tile_matrix_set",
+			sql:  "CREATE TABLE gpkg_tile_matrix_set (table_name TEXT NOT NULL PRIMARY KEY, srs_id INTEGER NOT NULL, min_x DOUBLE NOT NULL, min_y DOUBLE NOT NULL, max_x DOUBLE NOT NULL, max_y DOUBLE NOT NULL, CONSTRAINT fk_gtms_table_name FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name), CONSTRAINT fk_gtms_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id))",
+			indexes: []sqlite.Index{
+				{Name: "sqlite_autoindex_gpkg_tile_matrix_set_1", Columns: []int{0}, Unique: true},
+			},
+			rows: [][]sqlite.Value{{w.table, int64(3857), world.MinX, world.MinY, world.MaxX, world.MaxY}},
+		},
+		{
+			name: "gpkg_tile_matrix",
+			sql:  "CREATE TABLE gpkg_tile_matrix (table_name TEXT NOT NULL, zoom_level INTEGER NOT NULL, matrix_width INTEGER NOT NULL, matrix_height INTEGER NOT NULL, tile_width INTEGER NOT NULL, tile_height INTEGER NOT NULL, pixel_x_size DOUBLE NOT NULL, pixel_y_size DOUBLE NOT NULL, CONSTRAINT pk_ttm PRIMARY KEY (table_name, zoom_level), CONSTRAINT fk_tmm_table_name FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name))",
+			indexes: []sqlite.Index{
+				{Name: "sqlite_autoindex_gpkg_tile_matrix_1", Columns: []int{0, 1}, Unique: true},
+			},
+		},
+		{
+			name: "sqlite_sequence",
+			sql:  "CREATE TABLE sqlite_sequence(name,seq)",
+			rows: [][]sqlite.Value{{w.table, w.rowid}},
+		},
+	}
+
+	// srs_id is the rowid of gpkg_spatial_ref_sys
+	for _, srs := range geoPackageSRS {
+		row := append([]sqlite.Value{}, srs...)
+		tables[0].rowids = append(tables[0].rowids, row[1].(int64))
+		row[1] = nil
+		tables[0].rows = append(tables[0].rows, row)
+	}
+	if w.zooms.tiles > 0 {
+		for z := w.metadata.MinZoom; z <= w.metadata.MaxZoom; z++ {
+			n := int64(1) << uint(z)
+			pixelSize := (world.MaxX - world.MinX) / float64(n*tileSize)
+			tables[3].rows = append(tables[3].rows, []sqlite.Value{w.table, int64(z), n, n, int64(tileSize), int64(tileSize), pixelSize, pixelSize})
+		}
+	}
+
+	for _, table := range tables {
+		writer := w.db.CreateTable(table.name, table.sql, table.indexes...)
+		for i, row := range table.rows {
+			rowid := int64(i + 1)
+			if table.rowids != nil {
+				rowid = table.rowids[i]
+			}
+			if err := writer.Insert(rowid, row...); err != nil {
+				return err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openGeoPackage reads the first tile pyramid of a GeoPackage. Only the
+// GoogleMapsCompatible tile matrix set is supported.
+func openGeoPackage(db *sqlite.DB, schema []sqlite.SchemaEntry) (*Reader, error) {
+	rows := func(table string) ([][]sqlite.Value, error) {
+		entry := findSchema(schema, "table", table)
+		if entry == nil {
+			return nil, fmt.Errorf("not a GeoPackage tile file: no %s table", table)
+		}
+		var result [][]sqlite.Value
+		err := db.ScanTable(entry.RootPage, func(rowid int64, values []sqlite.Value) error {
+			if info := parseTable(entry.SQL); info.rowid >= 0 && info.rowid < len(values) {
+				values[info.rowid] = rowid
+			}
+			result = append(result, values)
+			return nil
+		})
+		return result, err
+	}
+
+	contents, err := rows("gpkg_contents")
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{db: db, format: FormatGeoPackage}
+	var table string
+	var contentsSRS int
+	for _, row := range contents {
+		if len(row) >= 10 && row[1] == "tiles" {
+			table, _ = row[0].(string)
+			r.metadata.Name, _ = row[2].(string)
+			if r.metadata.Name == "" {
+				r.metadata.Name = table
+			}
+			r.metadata.Description, _ = row[3].(string)
+			for i := range r.metadata.Bounds {
+				r.metadata.Bounds[i], _ = toFloat(row[5+i])
+			}
+			contentsSRS, _ = toInt(row[9])
+			break
+		}
+	}
+	if table == "" {
+		return nil, fmt.Errorf("GeoPackage has no tiles")
+	}
+
+	// The tile matrix set must cover the Web Mercator world
+	srsCodes := make(map[int]int)
+	srs, err := rows("gpkg_spatial_ref_sys")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range srs {
+		if len(row) >= 4 {
+			id, _ := toInt(row[1])
+			code, _ := toInt(row[3])
+			srsCodes[id] = code
+		}
+	}
+	matrixSets, err := rows("gpkg_tile_matrix_set")
+	if err != nil {
+		return nil, err
+	}
+	world, _ := mvt.TileBounds(0, 0, 0)
+	compatible := false
+	for _, row := range matrixSets {
+		if len(row) < 6 || row[0] != table {
+			continue
+		}
+		id, _ := toInt(row[1])
+		minX, _ := toFloat(row[2])
+		maxY, _ := toFloat(row[5])
+		compatible = webMercatorSRS[srsCodes[id]] && math.Abs(minX-world.MinX) < 1 && math.Abs(maxY-world.MaxY) < 1
+	}
+	if !compatible {
+		return nil, fmt.Errorf("tiles %s are not in the GoogleMapsCompatible tile matrix set", table)
+	}
+
+	matrices, err := rows("gpkg_tile_matrix")
+	if err != nil {
+		return nil, err
+	}
+	first := true
+	for _, row := range matrices {
+		if len(row) < 4 || row[0] != table {
+			continue
+		}
+		z, _ := toInt(row[1])
+		width, _ := toInt(row[2])
+		height, _ := toInt(row[3])
+		if width != 1<<uint(z) || height != 1<<uint(z) {
+			return nil, fmt.Errorf("tile matrix %d of %s is not GoogleMapsCompatible", z, table)
+		}
+		if first || z < r.metadata.MinZoom {
+			r.metadata.MinZoom = z
+		}
+		if first || z > r.metadata.MaxZoom {
+			r.metadata.MaxZoom = z
+		}
+		first = false
+	}
+
+	// Contents bounds are in the contents' spatial reference
+	if webMercatorSRS[srsCodes[contentsSRS]] {
+		b := r.metadata.Bounds
+		bounds, err := transform.NewCoordinateTransformer().TransformEnvelope(transform.BBox{MinX: b[0], MinY: b[1], MaxX: b[2], MaxY: b[3]}, "EPSG:3857", "EPSG:4326")
+		if err == nil {
+			r.metadata.Bounds = [4]float64{bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY}
+		}
+	}
+
+	entry := findSchema(schema, "table", table)
+	if entry == nil {
+		return nil, fmt.Errorf("tile table %s does not exist", table)
+	}
+	if err := r.loadTiles(schema, entry, false); err != nil {
+		return nil, err
+	}
+
+	// The tile format is not recorded; it is detected from a tile
+	for key := range r.tiles {
+		data, _, err := r.Tile(key.z, key.x, key.y)
+		if err != nil {
+			return nil, err
+		}
+		r.metadata.Format = detectFormat(data)
+		break
+	}
+	return r, nil
+}
@@ -0,0 +1,183 @@
+package tilefile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/sqlite"
+)
+
+// mbtilesWriter writes an MBTiles 1.3 file. Tile rows are stored in the TMS
+// scheme (origin bottom-left).
+type mbtilesWriter struct {
+	db       *sqlite.Writer
+	tiles    *sqlite.TableWriter
+	metadata Metadata
+	zooms    zoomRange
+	rowid    int64
+}
+
+func createMBTiles(path string, metadata Metadata) (*mbtilesWriter, error) {
+	db, err := sqlite.Create(path, 4096)
+	if err != nil {
+		return nil, err
+	}
+	tiles := db.CreateTable("tiles",
+		"CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+		sqlite.Index{
+			Name:    "tile_index",
+			SQL:     "CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)",
+			Columns: []int{0, 1, 2},
+			Unique:  true,
+		})
+	return &mbtilesWriter{db: db, tiles: tiles, metadata: metadata}, nil
+}
+
+// WriteTile stores an XYZ tile
+func (w *mbtilesWriter) WriteTile(z, x, y int, data []byte) error {
+	w.rowid++
+	w.zooms.add(z)
+	return w.tiles.Insert(w.rowid, int64(z), int64(x), int64(1<<uint(z)-1-y), data)
+}
+
+// Close writes the metadata table
+func (w *mbtilesWriter) Close() error {
+	if err := w.tiles.Close(); err != nil {
+		w.db.Abort()
+		return err
+	}
+	w.zooms.apply(&w.metadata)
+
+	table := w.db.CreateTable("metadata", "CREATE TABLE metadata (name text, value text)",
+		sqlite.Index{Name: "name", SQL: "CREATE UNIQUE INDEX name ON metadata (name)", Columns: []int{0}, Unique: true})
+	for i, row := range mbtilesMetadata(w.metadata) {
+		if err := table.Insert(int64(i+1), row[0], row[1]); err != nil {
+			w.db.Abort()
+			return err
+		}
+	}
+	if err := table.Close(); err != nil {
+		w.db.Abort()
+		return err
+	}
+	return w.db.Close()
+}
+
+// Abort removes the unfinished file
+func (w *mbtilesWriter) Abort() error {
+	return w.db.Abort()
+}
+
+// mbtilesMetadata returns the name/value rows of the metadata table
+func mbtilesMetadata(metadata Metadata) [][2]string {
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	bounds := metadata.Bounds
+	rows := [][2]string{
+		{"name", metadata.Name},
+		{"format", metadata.Format},
+		{"bounds", strings.Join([]string{formatFloat(bounds[0]), formatFloat(bounds[1]), formatFloat(bounds[2]), formatFloat(bounds[3])}, ",")},
+		{"center", fmt.Sprintf("%s,%s,%d", formatFloat((bounds[0]+bounds[2])/2), formatFloat((bounds[1]+bounds[3])/2), metadata.MinZoom)},
+		{"minzoom", strconv.Itoa(metadata.MinZoom)},
+		{"maxzoom", strconv.Itoa(metadata.MaxZoom)},
+		{"type", "baselayer"},
+		{"version", "1.0"},
+	}
+	if metadata.Description != "" {
+		rows = append(rows, [2]string{"description", metadata.Description})
+	}
+	if metadata.Attribution != "" {
+		rows = append(rows, [2]string{"attribution", metadata.Attribution})
+	}
+	if metadata.Format == TilePBF {
+		// Vector tilesets describe their layers in the json row
+		layers := make([]map[string]interface{}, 0, len(metadata.VectorLayers))
+		for _, layer := range metadata.VectorLayers {
+			layers = append(layers, map[string]interface{}{"id": layer, "fields": map[string]string{}})
+		}
+		data, _ := json.Marshal(map[string]interface{}{"vector_layers": layers})
+		rows = append(rows, [2]string{"json", string(data)})
+	}
+	return rows
+}
+
+// openMBTiles reads the metadata and tile keys of an MBTiles file
+func openMBTiles(db *sqlite.DB, schema []sqlite.SchemaEntry) (*Reader, error) {
+	tiles := findSchema(schema, "table", "tiles")
+	if tiles == nil {
+		if findSchema(schema, "view", "tiles") != nil {
+			return nil, fmt.Errorf("tiles is a view; only MBTiles files with a tiles table are supported")
+		}
+		return nil, fmt.Errorf("not an MBTiles file: no tiles table")
+	}
+
+	r := &Reader{db: db, format: FormatMBTiles}
+	if metadata := findSchema(schema, "table", "metadata"); metadata != nil {
+		values := make(map[string]string)
+		err := db.ScanTable(metadata.RootPage, func(rowid int64, row []sqlite.Value) error {
+			if len(row) >= 2 {
+				name, _ := row[0].(string)
+				value, _ := row[1].(string)
+				values[name] = value
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.metadata = parseMBTilesMetadata(values)
+	}
+
+	if err := r.loadTiles(schema, tiles, true); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parseMBTilesMetadata reads the metadata rows, ignoring malformed values
+func parseMBTilesMetadata(values map[string]string) Metadata {
+	metadata := Metadata{
+		Name:        values["name"],
+		Description: values["description"],
+		Attribution: values["attribution"],
+		Format:      values["format"],
+		Bounds:      [4]float64{-180, -85.051129, 180, 85.051129},
+		MaxZoom:     22,
+	}
+	if parts := strings.Split(values["bounds"], ","); len(parts) == 4 {
+		var bounds [4]float64
+		valid := true
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			valid = valid && err == nil
+			bounds[i] = v
+		}
+		if valid {
+			metadata.Bounds = bounds
+		}
+	}
+	if z, err := strconv.Atoi(values["minzoom"]); err == nil {
+		metadata.MinZoom = z
+	}
+	if z, err := strconv.Atoi(values["maxzoom"]); err == nil {
+		metadata.MaxZoom = z
+	}
+	if metadata.Format == "jpeg" {
+		metadata.Format = TileJPEG
+	}
+
+	var tilejson struct {
+		VectorLayers []struct {
+			ID string `json:"id"`
+		} `json:"vector_layers"`
+	}
+	if json.Unmarshal([]byte(values["json"]), &tilejson) == nil {
+		for _, layer := range tilejson.VectorLayers {
+			metadata.VectorLayers = append(metadata.VectorLayers, layer.ID)
+		}
+	}
+	return metadata
+}
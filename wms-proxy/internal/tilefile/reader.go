@@ -0,0 +1,322 @@
+package tilefile
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"wms-proxy/internal/sqlite"
+)
+
+// Reader serves the tiles of a package file. The tile keys are loaded when
+// the file is opened; tile data is read on demand.
+type Reader struct {
+	db         *sqlite.DB
+	format     string
+	metadata   Metadata
+	root       uint32 // root page of the tiles table
+	dataColumn int
+	tiles      map[tileKey]int64 // XYZ tile -> rowid
+}
+
+type tileKey struct {
+	z, x, y int
+}
+
+// tileColumns are the key columns of MBTiles and GeoPackage tile tables
+var tileColumns = []string{"zoom_level", "tile_column", "tile_row"}
+
+// Open opens an MBTiles or GeoPackage file for reading
+func Open(path string) (*Reader, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := db.Schema()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var reader *Reader
+	applicationID, _ := db.ApplicationID()
+	if applicationID == geoPackageApplicationID || findSchema(schema, "table", "gpkg_contents") != nil {
+		reader, err = openGeoPackage(db, schema)
+	} else {
+		reader, err = openMBTiles(db, schema)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return reader, nil
+}
+
+// Format returns the package format
+func (r *Reader) Format() string {
+	return r.format
+}
+
+// Metadata returns the description of the tiles
+func (r *Reader) Metadata() Metadata {
+	return r.metadata
+}
+
+// Tile returns the data of an XYZ tile
+func (r *Reader) Tile(z, x, y int) ([]byte, bool, error) {
+	rowid, exists := r.tiles[tileKey{z, x, y}]
+	if !exists {
+		return nil, false, nil
+	}
+	values, found, err := r.db.Row(r.root, rowid)
+	if err != nil || !found || r.dataColumn >= len(values) {
+		return nil, false, err
+	}
+	data, _ := values[r.dataColumn].([]byte)
+	return data, true, nil
+}
+
+// Close closes the file
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
+
+// loadTiles reads the keys of the tiles table, from an index on the key
+// columns when the table has one; flip converts stored rows to XYZ rows
+func (r *Reader) loadTiles(schema []sqlite.SchemaEntry, table *sqlite.SchemaEntry, flip bool) error {
+	info := parseTable(table.SQL)
+	columns := make([]int, len(tileColumns))
+	for i, name := range append(tileColumns[:3:3], "tile_data") {
+		column := info.column(name)
+		if column < 0 {
+			return fmt.Errorf("table %s has no %s column", table.Name, name)
+		}
+		if i < len(columns) {
+			columns[i] = column
+		} else {
+			r.dataColumn = column
+		}
+	}
+	r.root = table.RootPage
+	r.tiles = make(map[tileKey]int64)
+
+	add := func(z, x, row sqlite.Value, rowid int64) error {
+		zoom, ok1 := toInt(z)
+		column, ok2 := toInt(x)
+		tileRow, ok3 := toInt(row)
+		if !ok1 || !ok2 || !ok3 || zoom < 0 || zoom > 30 {
+			return fmt.Errorf("invalid tile key %v/%v/%v", z, x, row)
+		}
+		if flip {
+			tileRow = 1<<uint(zoom) - 1 - tileRow
+		}
+		r.tiles[tileKey{zoom, column, tileRow}] = rowid
+		return nil
+	}
+
+	if index := findTileIndex(schema, table.Name, info); index != nil {
+		return r.db.ScanIndex(index.RootPage, func(values []sqlite.Value) error {
+			rowid, ok := values[len(values)-1].(int64)
+			if len(values) < 4 || !ok {
+				return fmt.Errorf("invalid entry in index %s", index.Name)
+			}
+			return add(values[0], values[1], values[2], rowid)
+		})
+	}
+	return r.db.ScanTable(table.RootPage, func(rowid int64, values []sqlite.Value) error {
+		if len(values) <= max(columns[0], columns[1], columns[2]) {
+			return fmt.Errorf("invalid row %d in table %s", rowid, table.Name)
+		}
+		return add(values[columns[0]], values[columns[1]], values[columns[2]], rowid)
+	})
+}
+
+// toInt converts an integer column value
+func toInt(value sqlite.Value) (int, bool) {
+	switch v := value.(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), v == math.Trunc(v)
+	default:
+		return 0, false
+	}
+}
+
+// toFloat converts a numeric column value
+func toFloat(value sqlite.Value) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// findSchema returns the schema entry of an object
+func findSchema(schema []sqlite.SchemaEntry, kind, name string) *sqlite.SchemaEntry {
+	for i := range schema {
+		if schema[i].Type == kind && strings.EqualFold(schema[i].Name, name) {
+			return &schema[i]
+		}
+	}
+	return nil
+}
+
+// findTileIndex returns an index of table starting with the tile key columns
+func findTileIndex(schema []sqlite.SchemaEntry, table string, info tableInfo) *sqlite.SchemaEntry {
+	automatic := 0
+	for i := range schema {
+		entry := &schema[i]
+		if entry.Type != "index" || !strings.EqualFold(entry.TableName, table) {
+			continue
+		}
+		var columns []string
+		if entry.SQL != "" {
+			columns = parseColumnList(entry.SQL)
+		} else if automatic < len(info.unique) {
+			// Automatic indexes are numbered in the order of the constraints
+			columns = info.unique[automatic]
+			automatic++
+		}
+		if len(columns) >= len(tileColumns) && equalFoldAll(columns[:len(tileColumns)], tileColumns) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func equalFoldAll(a, b []string) bool {
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// tableInfo is the part of a CREATE TABLE statement needed to read a table
+type tableInfo struct {
+	columns []string
+	rowid   int        // column aliasing the rowid (INTEGER PRIMARY KEY), or -1
+	unique  [][]string // columns of the PRIMARY KEY and UNIQUE constraints creating automatic indexes
+}
+
+// column returns the position of a column, or -1
+func (t tableInfo) column(name string) int {
+	for i, column := range t.columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTable parses the column definitions and constraints of a CREATE TABLE
+// statement
+func parseTable(sql string) tableInfo {
+	info := tableInfo{rowid: -1}
+	var tableUnique [][]string
+	for _, definition := range splitDefinitions(sql) {
+		words := strings.Fields(definition)
+		if len(words) == 0 {
+			continue
+		}
+		primaryKey, unique := false, false
+		for i, word := range words[1:] {
+			word = strings.ToUpper(strings.TrimRight(word, "("))
+			primaryKey = primaryKey || word == "PRIMARY" && i+2 < len(words) && strings.HasPrefix(strings.ToUpper(words[i+2]), "KEY")
+			unique = unique || strings.HasPrefix(word, "UNIQUE")
+		}
+
+		switch strings.ToUpper(strings.SplitN(words[0], "(", 2)[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			first := strings.ToUpper(words[0])
+			if primaryKey || unique || first == "PRIMARY" || strings.HasPrefix(first, "UNIQUE") {
+				tableUnique = append(tableUnique, parseColumnList(definition))
+			}
+			continue
+		}
+
+		name := unquote(words[0])
+		info.columns = append(info.columns, name)
+		if primaryKey {
+			if len(words) > 1 && strings.EqualFold(words[1], "INTEGER") {
+				info.rowid = len(info.columns) - 1
+			} else {
+				info.unique = append(info.unique, []string{name})
+			}
+		}
+		if unique {
+			info.unique = append(info.unique, []string{name})
+		}
+	}
+	info.unique = append(info.unique, tableUnique...)
+	return info
+}
+
+// splitDefinitions splits the parenthesized body of a statement at its
+// top-level commas
+func splitDefinitions(sql string) []string {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+
+	var definitions []string
+	depth, last := 0, start+1
+	var quote byte
+	for i := start + 1; i < end; i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			definitions = append(definitions, strings.TrimSpace(sql[last:i]))
+			last = i + 1
+		}
+	}
+	return append(definitions, strings.TrimSpace(sql[last:end]))
+}
+
+// parseColumnList returns the column names of the first parenthesized list
+// of a constraint or CREATE INDEX statement
+func parseColumnList(sql string) []string {
+	var columns []string
+	for _, definition := range splitDefinitions(sql[:strings.Index(sql, ")")+1]) {
+		if words := strings.Fields(definition); len(words) > 0 {
+			columns = append(columns, unquote(words[0]))
+		}
+	}
+	return columns
+}
+
+// unquote removes identifier quotes
+func unquote(name string) string {
+	if len(name) >= 2 {
+		switch name[0] {
+		case '"', '`', '\'':
+			if name[len(name)-1] == name[0] {
+				return name[1 : len(name)-1]
+			}
+		case '[':
+			if name[len(name)-1] == ']' {
+				return name[1 : len(name)-1]
+			}
+		}
+	}
+	return name
+}
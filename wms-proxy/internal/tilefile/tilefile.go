@@ -0,0 +1,126 @@
+// Package tilefile writes and reads tile packages for offline use: MBTiles
+// and GeoPackage files holding an XYZ tile pyramid in Web Mercator.
+package tilefile
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"wms-proxy/internal/mvt"
+)
+
+// Package formats
+const (
+	FormatMBTiles    = "mbtiles"
+	FormatGeoPackage = "gpkg"
+)
+
+// Tile formats
+const (
+	TilePNG  = "png"
+	TileJPEG = "jpg"
+	TileWebP = "webp"
+	TilePBF  = "pbf" // Mapbox Vector Tiles, gzip compressed
+)
+
+// Metadata describes the tiles of a package
+type Metadata struct {
+	Name         string
+	Description  string
+	Attribution  string
+	Format       string     // tile format: png, jpg, webp or pbf
+	Bounds       [4]float64 // WGS84 min lon, min lat, max lon, max lat
+	MinZoom      int
+	MaxZoom      int
+	VectorLayers []string // layers of vector tiles
+}
+
+// Writer writes tiles to a package. Tiles are XYZ tiles (origin top-left).
+// Close writes the metadata, with the zoom range of the tiles written; Abort
+// removes the unfinished file.
+type Writer interface {
+	WriteTile(z, x, y int, data []byte) error
+	Close() error
+	Abort() error
+}
+
+// Create creates a package file in format, replacing an existing file
+func Create(path, format string, metadata Metadata) (Writer, error) {
+	switch format {
+	case FormatMBTiles:
+		return createMBTiles(path, metadata)
+	case FormatGeoPackage:
+		return createGeoPackage(path, metadata)
+	default:
+		return nil, fmt.Errorf("unsupported package format %q", format)
+	}
+}
+
+// FormatFromPath returns the package format of a file name's extension
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mbtiles":
+		return FormatMBTiles, nil
+	case ".gpkg":
+		return FormatGeoPackage, nil
+	default:
+		return "", fmt.Errorf("unknown package extension of %s, expected .mbtiles or .gpkg", path)
+	}
+}
+
+// ContentType returns the media type of a tile format
+func ContentType(format string) string {
+	switch format {
+	case TilePNG:
+		return "image/png"
+	case TileJPEG:
+		return "image/jpeg"
+	case TileWebP:
+		return "image/webp"
+	case TilePBF:
+		return mvt.ContentType
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// detectFormat returns the tile format of tile data from its signature
+func detectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return TilePNG
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return TileJPEG
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return TileWebP
+	case bytes.HasPrefix(data, []byte("\x1f\x8b")):
+		return TilePBF
+	default:
+		return ""
+	}
+}
+
+// zoomRange tracks the zoom levels of the tiles written
+type zoomRange struct {
+	min, max int
+	tiles    int64
+}
+
+func (r *zoomRange) add(z int) {
+	if r.tiles == 0 || z < r.min {
+		r.min = z
+	}
+	if r.tiles == 0 || z > r.max {
+		r.max = z
+	}
+	r.tiles++
+}
+
+// apply sets the zoom range of metadata when tiles were written
+func (r *zoomRange) apply(metadata *Metadata) {
+	if r.tiles > 0 {
+		metadata.MinZoom, metadata.MaxZoom = r.min, r.max
+	}
+}
@@ -0,0 +1,242 @@
+package tilefile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\ntile")
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		file   string
+		format string
+	}{
+		{"tiles.mbtiles", TilePNG},
+		{"tiles.mbtiles", TilePBF},
+		{"tiles.gpkg", TilePNG},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file+"/"+test.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			packageFormat, err := FormatFromPath(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			metadata := Metadata{
+				Name:         "Parcels",
+				Description:  "Parcel boundaries",
+				Format:       test.format,
+				Bounds:       [4]float64{-75, 39, -74, 40},
+				MinZoom:      0,
+				MaxZoom:      20,
+				VectorLayers: []string{"parcels"},
+			}
+			writer, err := Create(path, packageFormat, metadata)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tile := func(z, x, y int) []byte {
+				if test.format == TilePBF {
+					return []byte(fmt.Sprintf("\x1f\x8b%d/%d/%d", z, x, y))
+				}
+				return append(append([]byte{}, testPNG...), fmt.Sprintf("%d/%d/%d", z, x, y)...)
+			}
+			for z := 3; z <= 6; z++ {
+				for x := 0; x < 1<<z; x++ {
+					for y := 0; y < 1<<z; y += 3 {
+						if err := writer.WriteTile(z, x, y, tile(z, x, y)); err != nil {
+							t.Fatalf("unexpected error: %v", err)
+						}
+					}
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			reader, err := Open(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer reader.Close()
+			if reader.Format() != packageFormat {
+				t.Errorf("expected package format %s, got %s", packageFormat, reader.Format())
+			}
+			got := reader.Metadata()
+			if got.Name != "Parcels" || got.Description != "Parcel boundaries" || got.Format != test.format {
+				t.Errorf("unexpected metadata: %+v", got)
+			}
+			// The zoom range is that of the tiles written
+			if got.MinZoom != 3 || got.MaxZoom != 6 {
+				t.Errorf("expected zoom levels 3-6, got %d-%d", got.MinZoom, got.MaxZoom)
+			}
+			for i, expected := range metadata.Bounds {
+				if diff := got.Bounds[i] - expected; diff > 1e-6 || diff < -1e-6 {
+					t.Errorf("expected bounds %v, got %v", metadata.Bounds, got.Bounds)
+					break
+				}
+			}
+
+			for _, coords := range [][3]int{{3, 0, 0}, {5, 31, 30}, {6, 17, 63}} {
+				data, found, err := reader.Tile(coords[0], coords[1], coords[2])
+				if err != nil || !found || !bytes.Equal(data, tile(coords[0], coords[1], coords[2])) {
+					t.Errorf("tile %v: got %q, %v, %v", coords, data, found, err)
+				}
+			}
+			if _, found, err := reader.Tile(5, 0, 1); found || err != nil {
+				t.Errorf("expected no tile, got %v, %v", found, err)
+			}
+		})
+	}
+}
+
+func TestCreate_GeoPackageVectorTiles(t *testing.T) {
+	if _, err := Create(filepath.Join(t.TempDir(), "tiles.gpkg"), FormatGeoPackage, Metadata{Name: "Parcels", Format: TilePBF}); err == nil {
+		t.Error("expected an error for vector tiles in a GeoPackage")
+	}
+}
+
+func TestAbort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiles.mbtiles")
+	writer, err := Create(path, FormatMBTiles, Metadata{Name: "Parcels", Format: TilePNG})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writer.WriteTile(0, 0, 0, testPNG)
+	writer.Abort()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed, got %v", err)
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		sql     string
+		columns []string
+		rowid   int
+		unique  [][]string
+	}{
+		{
+			"CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+			[]string{"zoom_level", "tile_column", "tile_row", "tile_data"}, -1, nil,
+		},
+		{
+			`CREATE TABLE "t" (id INTEGER PRIMARY KEY AUTOINCREMENT, zoom_level INTEGER NOT NULL, tile_column INTEGER, tile_row INTEGER, tile_data BLOB, UNIQUE (zoom_level, tile_column, tile_row))`,
+			[]string{"id", "zoom_level", "tile_column", "tile_row", "tile_data"}, 0,
+			[][]string{{"zoom_level", "tile_column", "tile_row"}},
+		},
+		{
+			"CREATE TABLE map (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_id TEXT, CONSTRAINT pk PRIMARY KEY (zoom_level, tile_column, tile_row))",
+			[]string{"zoom_level", "tile_column", "tile_row", "tile_id"}, -1,
+			[][]string{{"zoom_level", "tile_column", "tile_row"}},
+		},
+		{
+			"CREATE TABLE metadata (name text PRIMARY KEY, value text DEFAULT 'a,b')",
+			[]string{"name", "value"}, -1, [][]string{{"name"}},
+		},
+	}
+
+	for _, test := range tests {
+		info := parseTable(test.sql)
+		if !reflect.DeepEqual(info.columns, test.columns) || info.rowid != test.rowid || !reflect.DeepEqual(info.unique, test.unique) {
+			t.Errorf("parseTable(%s) = %+v", test.sql, info)
+		}
+	}
+}
+
+// memoryWriter collects exported tiles
+type memoryWriter struct {
+	mu    sync.Mutex
+	tiles map[string][]byte
+}
+
+func (w *memoryWriter) WriteTile(z, x, y int, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tiles[fmt.Sprintf("%d/%d/%d", z, x, y)] = data
+	return nil
+}
+
+func (w *memoryWriter) Close() error { return nil }
+func (w *memoryWriter) Abort() error { return nil }
+
+func TestExport(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	var paths sync.Map
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths.Store(r.URL.String(), true)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/vt/"):
+			w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+			w.Write([]byte("mvt " + r.URL.Path))
+		case r.URL.Query().Get("BBOX") == "":
+			http.Error(w, "missing BBOX", http.StatusBadRequest)
+		case strings.HasPrefix(r.URL.Query().Get("BBOX"), "-20037508"):
+			// Nothing to render in the western half
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(testPNG)
+		}
+	})
+
+	// Bounds crossing the prime meridian and equator
+	opts := ExportOptions{
+		Bounds:      [4]float64{-10, -10, 10, 10},
+		MinZoom:     0,
+		MaxZoom:     2,
+		TileURL:     "/wms?REQUEST=GetMap&BBOX={bbox}",
+		Concurrency: 3,
+		Format:      TilePNG,
+	}
+	writer := &memoryWriter{tiles: make(map[string][]byte)}
+	stats, err := Export(context.Background(), handler, writer, opts, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// One tile at zoom 0 and four at zooms 1 and 2; the western tiles of zooms 0 and 1 are empty
+	if stats.Written != 6 || stats.Empty != 3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	for _, key := range []string{"1/1/0", "1/1/1", "2/1/1", "2/2/2"} {
+		if !bytes.Equal(writer.tiles[key], testPNG) {
+			t.Errorf("tile %s: got %q", key, writer.tiles[key])
+		}
+	}
+	if _, ok := paths.Load("/wms?REQUEST=GetMap&BBOX=0.000000,0.000000,20037508.342789,20037508.342789"); !ok {
+		t.Error("expected the tile bounds in the request of tile 1/1/0")
+	}
+
+	// Vector tiles are gzip compressed
+	opts.TileURL, opts.Format, opts.MaxZoom = "/vt/parcels/{z}/{x}/{y}.mvt", TilePBF, 0
+	writer = &memoryWriter{tiles: make(map[string][]byte)}
+	if _, err := Export(context.Background(), handler, writer, opts, logger); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(writer.tiles["0/0/0"]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := io.ReadAll(reader); string(data) != "mvt /vt/parcels/0/0/0.mvt" {
+		t.Errorf("unexpected vector tile %q", data)
+	}
+
+	// A failed tile stops the export
+	opts.TileURL, opts.Format = "/wms?REQUEST=GetMap", TilePNG
+	if _, err := Export(context.Background(), handler, &memoryWriter{tiles: make(map[string][]byte)}, opts, logger); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected an error for the failed tile, got %v", err)
+	}
+}
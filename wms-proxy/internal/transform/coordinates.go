@@ -50,6 +50,9 @@ func (ct *CoordinateTransformer) initializeTransformations() {
 	// EPSG:4326 to EPSG:3857 (reverse of webMercatorToWGS84)
 	ct.addTransformation("EPSG:4326", "EPSG:3857", wgs84ToWebMercator)
 
+	// EPSG:3424 to EPSG:4326 (reverse of wgs84ToNAD83NewJersey)
+	ct.addTransformation("EPSG:3424", "EPSG:4326", nad83NewJerseyToWGS84)
+
 	// Add identity transformations (same CRS)
 	ct.addTransformation("EPSG:3857", "EPSG:3857", identityTransform)
 	ct.addTransformation("EPSG:3424", "EPSG:3424", identityTransform)
@@ -93,6 +96,34 @@ func (ct *CoordinateTransformer) TransformBBox(bboxStr, fromCRS, toCRS string) (
 	return fmt.Sprintf("%.6f,%.6f,%.6f,%.6f", minX, minY, maxX, maxY), nil
 }
 
+// PointTransformer returns the function that transforms individual coordinates
+// from one CRS to another, for reprojecting feature geometries
+func (ct *CoordinateTransformer) PointTransformer(fromCRS, toCRS string) (TransformFunc, error) {
+	return ct.getTransformFunc(fromCRS, toCRS)
+}
+
+// TransformEnvelope transforms a bbox by its four corners and returns the enclosing envelope
+func (ct *CoordinateTransformer) TransformEnvelope(bbox BBox, fromCRS, toCRS string) (BBox, error) {
+	fn, err := ct.getTransformFunc(fromCRS, toCRS)
+	if err != nil {
+		return BBox{}, err
+	}
+
+	out := BBox{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	corners := [][2]float64{{bbox.MinX, bbox.MinY}, {bbox.MinX, bbox.MaxY}, {bbox.MaxX, bbox.MinY}, {bbox.MaxX, bbox.MaxY}}
+	for _, c := range corners {
+		x, y, err := fn(c[0], c[1])
+		if err != nil {
+			return BBox{}, err
+		}
+		out.MinX = math.Min(out.MinX, x)
+		out.MinY = math.Min(out.MinY, y)
+		out.MaxX = math.Max(out.MaxX, x)
+		out.MaxY = math.Max(out.MaxY, y)
+	}
+	return out, nil
+}
+
 // getTransformFunc retrieves the transformation function for the given CRS pair
 func (ct *CoordinateTransformer) getTransformFunc(fromCRS, toCRS string) (TransformFunc, error) {
 	// Normalize CRS names
@@ -123,13 +154,13 @@ func normalizeCRS(crs string) string {
 
 	// Handle common variations
 	switch upperCRS {
-	case "3857", "900913":
+	case "3857", "900913", "102100":
 		return "EPSG:3857"
 	case "4326":
 		return "EPSG:4326"
 	case "3424", "102711":
 		return "EPSG:3424"
-	case "EPSG:3857", "EPSG:900913":
+	case "EPSG:3857", "EPSG:900913", "EPSG:102100":
 		return "EPSG:3857"
 	case "EPSG:4326":
 		return "EPSG:4326"
@@ -320,9 +351,12 @@ func nad83NewJerseyToWGS84(eastingFt, northingFt float64) (float64, float64, err
 	mu := M / (a * (1 - e2/4 - 3*e4/64 - 5*e6/256))
 
 	// Footprint latitude (simplified calculation)
-	lat1 := mu + (3*e2/2-27*e4/32)*math.Sin(2*mu) +
-		(21*e4/16-55*e6/32)*math.Sin(4*mu) +
-		(151*e6/96)*math.Sin(6*mu)
+	// The footprint series is in terms of e1, not the eccentricity squared
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	lat1 := mu + (3*e1/2-27*e1*e1*e1/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*e1*e1*e1*e1/32)*math.Sin(4*mu) +
+		(151*e1*e1*e1/96)*math.Sin(6*mu) +
+		(1097*e1*e1*e1*e1/512)*math.Sin(8*mu)
 
 	// Calculate longitude (simplified)
 	N1 := a / math.Sqrt(1-e2*math.Sin(lat1)*math.Sin(lat1))
@@ -203,6 +203,48 @@ func TestWebMercatorToNAD83NewJersey(t *testing.T) {
 	t.Logf("Transformed coordinates: easting=%f ft, northing=%f ft", eastingFt, northingFt)
 }
 
+// nad83NewJerseyReferencePoints are EPSG:3424 coordinates of geographic points,
+// computed with the Krüger series used by PROJ's tmerc; on the central meridian
+// the northing agrees with the integrated meridian arc to 0.0001 ft
+var nad83NewJerseyReferencePoints = []struct {
+	lon, lat              float64
+	eastingFt, northingFt float64
+}{
+	{-74.5, 40.2, 492125.0000, 497766.3876},
+	{-74.0, 40.0, 632192.8109, 425308.0394},
+	{-75.2, 39.5, 294606.8198, 243565.7052},
+	{-74.2, 41.0, 574926.8284, 789338.6857},
+	{-74.75, 40.75, 422863.4284, 698218.8525},
+}
+
+func TestWGS84ToNAD83NewJersey(t *testing.T) {
+	for _, point := range nad83NewJerseyReferencePoints {
+		eastingFt, northingFt, err := wgs84ToNAD83NewJersey(point.lon, point.lat)
+		if err != nil {
+			t.Fatalf("wgs84ToNAD83NewJersey unexpected error: %v", err)
+		}
+		// 0.01 ft is about 3 mm
+		if math.Abs(eastingFt-point.eastingFt) > 0.01 || math.Abs(northingFt-point.northingFt) > 0.01 {
+			t.Errorf("(%f, %f): expected (%.4f, %.4f), got (%.4f, %.4f)",
+				point.lon, point.lat, point.eastingFt, point.northingFt, eastingFt, northingFt)
+		}
+	}
+}
+
+func TestNAD83NewJerseyToWGS84(t *testing.T) {
+	for _, point := range nad83NewJerseyReferencePoints {
+		lon, lat, err := nad83NewJerseyToWGS84(point.eastingFt, point.northingFt)
+		if err != nil {
+			t.Fatalf("nad83NewJerseyToWGS84 unexpected error: %v", err)
+		}
+		// 1e-7 degrees is about 1 cm
+		if math.Abs(lon-point.lon) > 1e-7 || math.Abs(lat-point.lat) > 1e-7 {
+			t.Errorf("(%.4f, %.4f): expected (%f, %f), got (%.9f, %.9f)",
+				point.eastingFt, point.northingFt, point.lon, point.lat, lon, lat)
+		}
+	}
+}
+
 func TestTransformBBox(t *testing.T) {
 	transformer := NewCoordinateTransformer()
 
@@ -337,3 +379,27 @@ func BenchmarkWebMercatorToWGS84(b *testing.B) {
 		}
 	}
 }
+
+func TestPointTransformer(t *testing.T) {
+	transformer := NewCoordinateTransformer()
+
+	toStatePlane, err := transformer.PointTransformer("EPSG:4326", "3424")
+	if err != nil {
+		t.Fatalf("PointTransformer(4326, 3424) failed: %v", err)
+	}
+	toWGS84, err := transformer.PointTransformer("EPSG:102711", "EPSG:4326")
+	if err != nil {
+		t.Fatalf("PointTransformer(102711, 4326) failed: %v", err)
+	}
+
+	lon, lat := -74.2, 41.0
+	x, y, _ := toStatePlane(lon, lat)
+	backLon, backLat, _ := toWGS84(x, y)
+	if math.Abs(backLon-lon) > 1e-6 || math.Abs(backLat-lat) > 1e-6 {
+		t.Errorf("Round trip drifted: (%f, %f) -> (%f, %f) -> (%f, %f)", lon, lat, x, y, backLon, backLat)
+	}
+
+	if _, err := transformer.PointTransformer("EPSG:4326", "EPSG:9999"); err == nil {
+		t.Error("Expected error for unsupported CRS")
+	}
+}
@@ -0,0 +1,139 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/pkg/wms"
+)
+
+// DimensionsFromMetadata builds the WMS dimensions advertised in capabilities
+// from the backend service's timeInfo and rangeInfos
+func DimensionsFromMetadata(metadata *client.ServiceMetadata) []wms.DimensionInfo {
+	if metadata == nil {
+		return nil
+	}
+
+	var dimensions []wms.DimensionInfo
+
+	if ti := metadata.TimeInfo; ti != nil && len(ti.TimeExtent) == 2 && ti.TimeExtent[0] != 0 {
+		start := wms.FormatISO8601(time.UnixMilli(ti.TimeExtent[0]))
+		end := wms.FormatISO8601(time.UnixMilli(ti.TimeExtent[1]))
+		// Live data has no fixed end; WMS uses "present" for an open-ended extent
+		if ti.HasLiveData || ti.TimeExtent[1] == 0 {
+			end = "present"
+		}
+
+		extent := start + "/" + end
+		if period := isoPeriod(ti.DefaultTimeInterval, ti.DefaultTimeIntervalUnits); period != "" {
+			extent += "/" + period
+		}
+
+		dimensions = append(dimensions, wms.DimensionInfo{
+			Name:    "time",
+			Units:   "ISO8601",
+			Default: end,
+			Extent:  extent,
+		})
+	}
+
+	for _, ri := range metadata.RangeInfos {
+		rangeExtent := ri.FullRangeExtent
+		if len(rangeExtent) != 2 {
+			rangeExtent = ri.CurrentRangeExtent
+		}
+		if ri.Name == "" || len(rangeExtent) != 2 {
+			continue
+		}
+
+		// WMS dimension names are case-insensitive; MatchRangeNames restores the
+		// rangeInfo name when the dimension is requested
+		name := strings.ToLower(ri.Name)
+		units := ""
+		if name == "elevation" {
+			units = "EPSG:5030"
+		}
+
+		dimensions = append(dimensions, wms.DimensionInfo{
+			Name:    name,
+			Units:   units,
+			Default: formatFloat(rangeExtent[0]),
+			Extent:  formatFloat(rangeExtent[0]) + "/" + formatFloat(rangeExtent[1]),
+		})
+	}
+
+	return dimensions
+}
+
+// MatchRangeNames replaces the lower-case WMS dimension names in rangeValues
+// with the service's rangeInfo names, since ArcGIS matches them case-sensitively
+// (e.g. DIM_DEPTH is sent as "Depth")
+func MatchRangeNames(metadata *client.ServiceMetadata, arcgisParams *wms.ArcGISParams) error {
+	if metadata == nil || arcgisParams.RangeValues == "" {
+		return nil
+	}
+
+	names := make(map[string]string, len(metadata.RangeInfos))
+	for _, ri := range metadata.RangeInfos {
+		names[strings.ToLower(ri.Name)] = ri.Name
+	}
+
+	var ranges []rangeValue
+	if err := json.Unmarshal([]byte(arcgisParams.RangeValues), &ranges); err != nil {
+		return fmt.Errorf("failed to decode rangeValues: %w", err)
+	}
+	for i := range ranges {
+		if name, ok := names[ranges[i].Name]; ok {
+			ranges[i].Name = name
+		}
+	}
+
+	encoded, err := json.Marshal(ranges)
+	if err != nil {
+		return fmt.Errorf("failed to encode rangeValues: %w", err)
+	}
+	arcgisParams.RangeValues = string(encoded)
+	return nil
+}
+
+// isoPeriod converts an ArcGIS time interval to an ISO 8601 duration
+func isoPeriod(interval float64, units string) string {
+	if interval <= 0 {
+		return ""
+	}
+
+	n := formatFloat(interval)
+	switch units {
+	case "esriTimeUnitsMilliseconds":
+		return "PT" + formatFloat(interval/1000) + "S"
+	case "esriTimeUnitsSeconds":
+		return "PT" + n + "S"
+	case "esriTimeUnitsMinutes":
+		return "PT" + n + "M"
+	case "esriTimeUnitsHours":
+		return "PT" + n + "H"
+	case "esriTimeUnitsDays":
+		return "P" + n + "D"
+	case "esriTimeUnitsWeeks":
+		return "P" + n + "W"
+	case "esriTimeUnitsMonths":
+		return "P" + n + "M"
+	case "esriTimeUnitsYears":
+		return "P" + n + "Y"
+	case "esriTimeUnitsDecades":
+		return fmt.Sprintf("P%sY", formatFloat(interval*10))
+	case "esriTimeUnitsCenturies":
+		return fmt.Sprintf("P%sY", formatFloat(interval*100))
+	default:
+		return ""
+	}
+}
+
+// formatFloat formats a number without trailing zeros
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,196 @@
+package translator
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/pkg/wms"
+)
+
+func TestTranslateDimensionsTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		time        string
+		expected    string
+		expectError bool
+	}{
+		{"Instant", "2024-01-01T00:00:00Z", "1704067200000", false},
+		{"Date only", "2024-01-01", "1704067200000", false},
+		{"Range", "2024-01-01/2024-01-02", "1704067200000,1704153600000", false},
+		{"Range with period", "2024-01-01T00:00Z/2024-01-01T06:00Z/PT1H", "1704067200000,1704088800000", false},
+		{"List collapses to extent", "2024-01-02,2024-01-01", "1704067200000,1704153600000", false},
+		{"Offset is normalized to UTC", "2024-01-01T01:00:00+01:00", "1704067200000", false},
+		{"End before start", "2024-01-02/2024-01-01", "", true},
+		{"Bad period", "2024-01-01/2024-01-02/1H", "", true},
+		{"Garbage", "yesterday", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arcgisParams := &wms.ArcGISParams{}
+			err := translateDimensions(&wms.WMSParams{Time: test.time}, arcgisParams)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("Expected error for TIME=%q, got time=%q", test.time, arcgisParams.Time)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if arcgisParams.Time != test.expected {
+				t.Errorf("time = %q, expected %q", arcgisParams.Time, test.expected)
+			}
+		})
+	}
+}
+
+func TestTranslateDimensionsRangeValues(t *testing.T) {
+	wmsParams := &wms.WMSParams{
+		Elevation:  "100/500",
+		Dimensions: map[string]string{"depth": "5", "band": "1,3"},
+	}
+	arcgisParams := &wms.ArcGISParams{}
+
+	if err := translateDimensions(wmsParams, arcgisParams); err != nil {
+		t.Fatalf("translateDimensions failed: %v", err)
+	}
+
+	expected := `[{"name":"elevation","values":[100,500]},{"name":"band","values":[1,3]},{"name":"depth","values":[5,5]}]`
+	if arcgisParams.RangeValues != expected {
+		t.Errorf("rangeValues = %s, expected %s", arcgisParams.RangeValues, expected)
+	}
+
+	built := BuildArcGISURL("https://example.com", "/arcgis/rest/services/test/MapServer/export", arcgisParams)
+	u, err := url.Parse(built)
+	if err != nil {
+		t.Fatalf("Failed to parse built URL: %v", err)
+	}
+	if u.Query().Get("rangeValues") != expected {
+		t.Errorf("URL rangeValues = %s, expected %s", u.Query().Get("rangeValues"), expected)
+	}
+	if u.Query().Has("time") {
+		t.Error("time parameter should be omitted when TIME is not requested")
+	}
+
+	if err := translateDimensions(&wms.WMSParams{Elevation: "500/100"}, &wms.ArcGISParams{}); err == nil {
+		t.Error("Expected error for inverted ELEVATION range")
+	}
+}
+
+func TestDimensionsFromMetadata(t *testing.T) {
+	metadata := &client.ServiceMetadata{
+		TimeInfo: &client.TimeInfo{
+			TimeExtent:               []int64{1704067200000, 1704153600000},
+			DefaultTimeInterval:      6,
+			DefaultTimeIntervalUnits: "esriTimeUnitsHours",
+		},
+		RangeInfos: []client.RangeInfo{
+			{Name: "Elevation", FullRangeExtent: []float64{0, 1250.5}},
+			{Name: "Incomplete"},
+		},
+	}
+
+	dimensions := DimensionsFromMetadata(metadata)
+	if len(dimensions) != 2 {
+		t.Fatalf("Expected 2 dimensions, got %d: %+v", len(dimensions), dimensions)
+	}
+
+	timeDim := dimensions[0]
+	if timeDim.Name != "time" || timeDim.Units != "ISO8601" {
+		t.Errorf("Unexpected time dimension: %+v", timeDim)
+	}
+	if timeDim.Extent != "2024-01-01T00:00:00Z/2024-01-02T00:00:00Z/PT6H" {
+		t.Errorf("Unexpected time extent: %s", timeDim.Extent)
+	}
+	if timeDim.Default != "2024-01-02T00:00:00Z" {
+		t.Errorf("Unexpected time default: %s", timeDim.Default)
+	}
+
+	elevation := dimensions[1]
+	if elevation.Name != "elevation" || elevation.Units != "EPSG:5030" || elevation.Extent != "0/1250.5" {
+		t.Errorf("Unexpected elevation dimension: %+v", elevation)
+	}
+
+	if dims := DimensionsFromMetadata(&client.ServiceMetadata{}); len(dims) != 0 {
+		t.Errorf("Expected no dimensions for a non-temporal service, got %+v", dims)
+	}
+}
+
+func TestTranslateDimensionsAdvertisedLiveTime(t *testing.T) {
+	dimensions := DimensionsFromMetadata(&client.ServiceMetadata{
+		TimeInfo: &client.TimeInfo{
+			TimeExtent:  []int64{1704067200000, 0},
+			HasLiveData: true,
+		},
+	})
+	if len(dimensions) != 1 {
+		t.Fatalf("Expected a time dimension, got %+v", dimensions)
+	}
+
+	// Clients echo the advertised default and extent back as TIME
+	for _, value := range []string{dimensions[0].Default, dimensions[0].Extent, "current"} {
+		t.Run(value, func(t *testing.T) {
+			before := time.Now().UnixMilli()
+			arcgisParams := &wms.ArcGISParams{}
+			if err := translateDimensions(&wms.WMSParams{Time: value}, arcgisParams); err != nil {
+				t.Fatalf("Unexpected error for TIME=%q: %v", value, err)
+			}
+
+			parts := strings.Split(arcgisParams.Time, ",")
+			end, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+			if err != nil {
+				t.Fatalf("Unexpected time %q", arcgisParams.Time)
+			}
+			if end < before || end > time.Now().UnixMilli() {
+				t.Errorf("Expected the time to end now, got %q", arcgisParams.Time)
+			}
+			if len(parts) == 2 && parts[0] != "1704067200000" {
+				t.Errorf("Expected the extent to start at the service start, got %q", arcgisParams.Time)
+			}
+		})
+	}
+}
+
+func TestMatchRangeNames(t *testing.T) {
+	metadata := &client.ServiceMetadata{
+		RangeInfos: []client.RangeInfo{
+			{Name: "Elevation", FullRangeExtent: []float64{0, 1250.5}},
+			{Name: "StdTime", FullRangeExtent: []float64{0, 10}},
+		},
+	}
+
+	// Dimensions are advertised lower-case
+	for _, dim := range DimensionsFromMetadata(metadata) {
+		if dim.Name != strings.ToLower(dim.Name) {
+			t.Errorf("Expected a lower-case dimension name, got %s", dim.Name)
+		}
+	}
+
+	arcgisParams := &wms.ArcGISParams{}
+	wmsParams := &wms.WMSParams{
+		Elevation:  "100",
+		Dimensions: map[string]string{"stdtime": "5", "band": "1"},
+	}
+	if err := translateDimensions(wmsParams, arcgisParams); err != nil {
+		t.Fatalf("translateDimensions failed: %v", err)
+	}
+	if err := MatchRangeNames(metadata, arcgisParams); err != nil {
+		t.Fatalf("MatchRangeNames failed: %v", err)
+	}
+
+	// Names unknown to the service are sent as requested
+	expected := `[{"name":"Elevation","values":[100,100]},{"name":"band","values":[1,1]},{"name":"StdTime","values":[5,5]}]`
+	if arcgisParams.RangeValues != expected {
+		t.Errorf("rangeValues = %s, expected %s", arcgisParams.RangeValues, expected)
+	}
+
+	empty := &wms.ArcGISParams{}
+	if err := MatchRangeNames(metadata, empty); err != nil || empty.RangeValues != "" {
+		t.Errorf("Expected no rangeValues without dimensions, got %q (%v)", empty.RangeValues, err)
+	}
+}
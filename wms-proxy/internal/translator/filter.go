@@ -0,0 +1,101 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/cql"
+)
+
+// SchemaLookup returns the attribute schema of a layer
+type SchemaLookup func(layerID string) (*cql.Schema, error)
+
+// TranslateFilterToLayerDefs converts a CQL_FILTER value into the ArcGIS
+// layerDefs parameter. The filter holds one expression per layer separated by
+// ';', or a single expression applied to every requested layer. Each
+// expression is validated against its layer's schema before being rendered.
+func TranslateFilterToLayerDefs(layers, filter string, lookup SchemaLookup) (string, error) {
+	var layerIDs []string
+	for _, layer := range strings.Split(layers, ",") {
+		if layer = strings.TrimSpace(layer); layer != "" {
+			// Layer IDs are used in metadata paths and layerDefs keys, so only numeric IDs are accepted
+			if _, err := strconv.Atoi(layer); err != nil {
+				return "", fmt.Errorf("CQL_FILTER requires numeric layer IDs, got %q", layer)
+			}
+			layerIDs = append(layerIDs, layer)
+		}
+	}
+	if len(layerIDs) == 0 {
+		return "", fmt.Errorf("CQL_FILTER requires LAYERS")
+	}
+
+	filters := cql.SplitFilters(filter)
+	if len(filters) == 1 && len(layerIDs) > 1 {
+		for len(filters) < len(layerIDs) {
+			filters = append(filters, filters[0])
+		}
+	}
+	if len(filters) != len(layerIDs) {
+		return "", fmt.Errorf("CQL_FILTER has %d filters but %d layers were requested", len(filters), len(layerIDs))
+	}
+
+	layerDefs := make(map[string]string)
+	for i, layerID := range layerIDs {
+		if filters[i] == "" || strings.EqualFold(filters[i], "INCLUDE") {
+			continue
+		}
+
+		expr, err := cql.Parse(filters[i])
+		if err != nil {
+			return "", fmt.Errorf("invalid filter for layer %s: %w", layerID, err)
+		}
+
+		schema, err := lookup(layerID)
+		if err != nil {
+			return "", fmt.Errorf("failed to load schema for layer %s: %w", layerID, err)
+		}
+
+		where, err := cql.ToSQL(expr, schema)
+		if err != nil {
+			return "", fmt.Errorf("invalid filter for layer %s: %w", layerID, err)
+		}
+		layerDefs[layerID] = where
+	}
+
+	if len(layerDefs) == 0 {
+		return "", nil
+	}
+
+	// Comparison operators are kept readable rather than HTML-escaped
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(layerDefs); err != nil {
+		return "", fmt.Errorf("failed to encode layerDefs: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// TranslateFilterToWhere converts a single CQL expression into an ArcGIS query
+// where clause validated against the layer's schema. An empty or INCLUDE filter
+// yields an empty where clause.
+func TranslateFilterToWhere(filter string, schema *cql.Schema) (string, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" || strings.EqualFold(filter, "INCLUDE") {
+		return "", nil
+	}
+
+	expr, err := cql.Parse(filter)
+	if err != nil {
+		return "", fmt.Errorf("invalid filter: %w", err)
+	}
+
+	where, err := cql.ToSQL(expr, schema)
+	if err != nil {
+		return "", fmt.Errorf("invalid filter: %w", err)
+	}
+	return where, nil
+}
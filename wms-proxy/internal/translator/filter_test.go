@@ -0,0 +1,60 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"wms-proxy/internal/cql"
+)
+
+func TestTranslateFilterToLayerDefs(t *testing.T) {
+	schemas := map[string]*cql.Schema{
+		"0": cql.NewSchema([]cql.Field{{Name: "COUNTY", Type: "esriFieldTypeString"}}),
+		"1": cql.NewSchema([]cql.Field{{Name: "POP", Type: "esriFieldTypeInteger"}}),
+	}
+	lookup := func(layerID string) (*cql.Schema, error) {
+		if schema, ok := schemas[layerID]; ok {
+			return schema, nil
+		}
+		return nil, fmt.Errorf("no such layer %s", layerID)
+	}
+
+	layerDefs, err := TranslateFilterToLayerDefs("0,1", "COUNTY = 'Mercer';POP > 10", lookup)
+	if err != nil {
+		t.Fatalf("TranslateFilterToLayerDefs failed: %v", err)
+	}
+
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(layerDefs), &decoded); err != nil {
+		t.Fatalf("layerDefs is not valid JSON: %v", err)
+	}
+	if decoded["0"] != "COUNTY = 'Mercer'" || decoded["1"] != "POP > 10" {
+		t.Errorf("Unexpected layerDefs: %v", decoded)
+	}
+
+	// INCLUDE leaves a layer unfiltered
+	layerDefs, err = TranslateFilterToLayerDefs("0,1", "INCLUDE;POP > 10", lookup)
+	if err != nil {
+		t.Fatalf("TranslateFilterToLayerDefs failed: %v", err)
+	}
+	if layerDefs != `{"1":"POP > 10"}` {
+		t.Errorf("Unexpected layerDefs: %s", layerDefs)
+	}
+
+	errorCases := []struct {
+		layers string
+		filter string
+	}{
+		{"0,1", "COUNTY = 'a';POP > 1;POP < 5"}, // filter count mismatch
+		{"0,1", "COUNTY = 'a'"},                 // POP layer has no COUNTY
+		{"2", "COUNTY = 'a'"},                   // schema lookup failure
+		{"roads", "COUNTY = 'a'"},               // non-numeric layer
+		{"0", "COUNTY = 'a' OR 1=1"},            // invalid expression
+	}
+	for _, tc := range errorCases {
+		if _, err := TranslateFilterToLayerDefs(tc.layers, tc.filter, lookup); err == nil {
+			t.Errorf("Expected error for LAYERS=%q CQL_FILTER=%q", tc.layers, tc.filter)
+		}
+	}
+}
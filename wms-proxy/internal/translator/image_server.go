@@ -0,0 +1,130 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/pkg/wms"
+)
+
+// interpolations maps the INTERPOLATION vendor parameter to ImageServer resampling methods
+var interpolations = map[string]string{
+	"nearest":  "RSP_NearestNeighbor",
+	"bilinear": "RSP_BilinearInterpolation",
+	"cubic":    "RSP_CubicConvolution",
+	"majority": "RSP_Majority",
+}
+
+// TranslateImageServerParams adapts translated GetMap parameters to an
+// ImageServer exportImage request: the rendering rule comes from RENDERINGRULE
+// or the first of STYLES, and INTERPOLATION and COMPRESSION select resampling
+// and compression. Image services have no sublayers or attribute filters.
+func TranslateImageServerParams(wmsParams *wms.WMSParams, arcgisParams *wms.ArcGISParams) error {
+	if wmsParams.Filter != "" {
+		return fmt.Errorf("CQL_FILTER is not supported for image services")
+	}
+	if arcgisParams.RangeValues != "" {
+		return fmt.Errorf("ELEVATION and DIM_ parameters are not supported for image services")
+	}
+
+	rule := wmsParams.RenderingRule
+	if rule == "" {
+		style, _, _ := strings.Cut(wmsParams.Styles, ",")
+		if style = strings.TrimSpace(style); !strings.EqualFold(style, "default") {
+			rule = style
+		}
+	}
+	if rule != "" {
+		renderingRule, err := translateRenderingRule(rule)
+		if err != nil {
+			return err
+		}
+		arcgisParams.RenderingRule = renderingRule
+	}
+
+	if wmsParams.Interpolation != "" {
+		interpolation, ok := interpolations[strings.ToLower(wmsParams.Interpolation)]
+		if !ok {
+			return fmt.Errorf("INTERPOLATION must be nearest, bilinear, cubic or majority")
+		}
+		arcgisParams.Interpolation = interpolation
+	}
+
+	if wmsParams.Compression != "" {
+		method, quality, hasQuality := strings.Cut(strings.ToLower(wmsParams.Compression), ":")
+		switch method {
+		case "none":
+			arcgisParams.Compression = "None"
+		case "lz77":
+			arcgisParams.Compression = "LZ77"
+		case "jpeg":
+			arcgisParams.Compression = "JPEG"
+		default:
+			return fmt.Errorf("COMPRESSION must be none, lz77 or jpeg[:quality]")
+		}
+		if hasQuality {
+			q, err := strconv.Atoi(quality)
+			if err != nil || q < 1 || q > 100 || method != "jpeg" {
+				return fmt.Errorf("COMPRESSION quality must be between 1 and 100 and requires jpeg")
+			}
+			arcgisParams.CompressionQuality = q
+		}
+	}
+
+	arcgisParams.Layers = ""
+	arcgisParams.LayerDefs = ""
+	return nil
+}
+
+// translateRenderingRule accepts a JSON rendering rule or the name of a raster function
+func translateRenderingRule(rule string) (string, error) {
+	if strings.HasPrefix(strings.TrimSpace(rule), "{") {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(rule), &parsed); err != nil {
+			return "", fmt.Errorf("invalid RENDERINGRULE: %w", err)
+		}
+		return rule, nil
+	}
+
+	encoded, err := json.Marshal(map[string]string{"rasterFunction": rule})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode rendering rule: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// BuildExportImageURL constructs the ImageServer exportImage URL of a service
+func BuildExportImageURL(baseURL, servicePath string, params *wms.ArcGISParams) string {
+	query := url.Values{}
+	query.Set("bbox", params.BBOX)
+	query.Set("size", params.Size)
+	query.Set("format", params.Format)
+	if params.BBoxSR != "" {
+		query.Set("bboxSR", params.BBoxSR)
+	}
+	if params.ImageSR != "" {
+		query.Set("imageSR", params.ImageSR)
+	}
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.RenderingRule != "" {
+		query.Set("renderingRule", params.RenderingRule)
+	}
+	if params.Interpolation != "" {
+		query.Set("interpolation", params.Interpolation)
+	}
+	if params.Compression != "" {
+		query.Set("compression", params.Compression)
+	}
+	if params.CompressionQuality > 0 {
+		query.Set("compressionQuality", strconv.Itoa(params.CompressionQuality))
+	}
+	query.Set("f", params.F)
+
+	return baseURL + client.ServiceRoot(servicePath) + "/exportImage?" + query.Encode()
+}
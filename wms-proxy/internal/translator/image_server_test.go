@@ -0,0 +1,97 @@
+package translator
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"wms-proxy/pkg/wms"
+)
+
+func TestTranslateImageServerParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		wmsParams   wms.WMSParams
+		expected    wms.ArcGISParams
+		expectError bool
+	}{
+		{"defaults", wms.WMSParams{}, wms.ArcGISParams{}, false},
+		{"raster function name", wms.WMSParams{RenderingRule: "Hillshade"}, wms.ArcGISParams{RenderingRule: `{"rasterFunction":"Hillshade"}`}, false},
+		{"JSON rendering rule", wms.WMSParams{RenderingRule: `{"rasterFunction":"Stretch","rasterFunctionArguments":{"StretchType":5}}`}, wms.ArcGISParams{RenderingRule: `{"rasterFunction":"Stretch","rasterFunctionArguments":{"StretchType":5}}`}, false},
+		{"style as raster function", wms.WMSParams{Styles: "NDVI"}, wms.ArcGISParams{RenderingRule: `{"rasterFunction":"NDVI"}`}, false},
+		{"default style", wms.WMSParams{Styles: "default"}, wms.ArcGISParams{}, false},
+		{"rendering rule overrides style", wms.WMSParams{Styles: "NDVI", RenderingRule: "Hillshade"}, wms.ArcGISParams{RenderingRule: `{"rasterFunction":"Hillshade"}`}, false},
+		{"interpolation", wms.WMSParams{Interpolation: "Bilinear"}, wms.ArcGISParams{Interpolation: "RSP_BilinearInterpolation"}, false},
+		{"lz77 compression", wms.WMSParams{Compression: "lz77"}, wms.ArcGISParams{Compression: "LZ77"}, false},
+		{"jpeg compression with quality", wms.WMSParams{Compression: "jpeg:60"}, wms.ArcGISParams{Compression: "JPEG", CompressionQuality: 60}, false},
+		{"invalid rendering rule", wms.WMSParams{RenderingRule: "{not json"}, wms.ArcGISParams{}, true},
+		{"unknown interpolation", wms.WMSParams{Interpolation: "lanczos"}, wms.ArcGISParams{}, true},
+		{"unknown compression", wms.WMSParams{Compression: "zip"}, wms.ArcGISParams{}, true},
+		{"quality out of range", wms.WMSParams{Compression: "jpeg:0"}, wms.ArcGISParams{}, true},
+		{"quality without jpeg", wms.WMSParams{Compression: "lz77:50"}, wms.ArcGISParams{}, true},
+		{"CQL filter", wms.WMSParams{Filter: "STATUS='A'"}, wms.ArcGISParams{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arcgisParams := &wms.ArcGISParams{Layers: "show:0"}
+			err := TranslateImageServerParams(&test.wmsParams, arcgisParams)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *arcgisParams != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, *arcgisParams)
+			}
+		})
+	}
+}
+
+func TestBuildExportImageURL(t *testing.T) {
+	params := &wms.ArcGISParams{
+		BBOX:               "-100,40,-99,41",
+		Size:               "256,256",
+		Format:             "png32",
+		BBoxSR:             "4326",
+		ImageSR:            "4326",
+		Layers:             "show:0",
+		RenderingRule:      `{"rasterFunction":"Hillshade"}`,
+		Interpolation:      "RSP_CubicConvolution",
+		Compression:        "JPEG",
+		CompressionQuality: 75,
+		F:                  "image",
+	}
+
+	for _, servicePath := range []string{"/arcgis/rest/services/Elevation/ImageServer", "/arcgis/rest/services/Elevation/ImageServer/exportImage"} {
+		result := BuildExportImageURL("https://gis.example.com", servicePath, params)
+
+		prefix := "https://gis.example.com/arcgis/rest/services/Elevation/ImageServer/exportImage?"
+		if !strings.HasPrefix(result, prefix) {
+			t.Fatalf("expected URL to start with %s, got %s", prefix, result)
+		}
+		query, _ := url.ParseQuery(strings.TrimPrefix(result, prefix))
+		expected := map[string]string{
+			"bbox":               "-100,40,-99,41",
+			"size":               "256,256",
+			"bboxSR":             "4326",
+			"renderingRule":      `{"rasterFunction":"Hillshade"}`,
+			"interpolation":      "RSP_CubicConvolution",
+			"compression":        "JPEG",
+			"compressionQuality": "75",
+			"f":                  "image",
+		}
+		for key, value := range expected {
+			if query.Get(key) != value {
+				t.Errorf("expected %s=%s, got %q", key, value, query.Get(key))
+			}
+		}
+		if query.Has("layers") {
+			t.Errorf("exportImage should not carry layers")
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package translator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/transform"
+	"wms-proxy/pkg/wms"
+)
+
+// ExtentFromMetadata builds the service extent advertised in capabilities from
+// the backend's full extent. The geographic extent is included when the
+// transformer supports the service's spatial reference.
+func ExtentFromMetadata(metadata *client.ServiceMetadata, transformer *transform.CoordinateTransformer) *wms.ExtentInfo {
+	if metadata == nil {
+		return nil
+	}
+	extent := metadata.ServiceExtent()
+	if extent == nil {
+		return nil
+	}
+
+	wkid := extent.SpatialReference.LatestWKID
+	if wkid == 0 {
+		wkid = extent.SpatialReference.WKID
+	}
+	if wkid == 0 {
+		wkid = metadata.SpatialReference.LatestWKID
+	}
+	if wkid == 0 {
+		wkid = metadata.SpatialReference.WKID
+	}
+	if wkid == 0 {
+		return nil
+	}
+
+	srs := transformer.NormalizeCRS("EPSG:" + strconv.Itoa(wkid))
+	info := &wms.ExtentInfo{
+		SRS:  srs,
+		BBox: [4]float64{extent.XMin, extent.YMin, extent.XMax, extent.YMax},
+	}
+
+	bbox := fmt.Sprintf("%f,%f,%f,%f", extent.XMin, extent.YMin, extent.XMax, extent.YMax)
+	if srs != "EPSG:4326" {
+		transformed, err := transformer.TransformBBox(bbox, srs, "EPSG:4326")
+		if err != nil {
+			return info
+		}
+		bbox = transformed
+	}
+	parts := strings.Split(bbox, ",")
+	for i := range info.LatLon {
+		value, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return info
+		}
+		info.LatLon[i] = value
+	}
+	info.HasLatLon = true
+	return info
+}
+
+// ValidateExportLimits checks a GetMap request against the service's maximum
+// image size and supported image formats
+func ValidateExportLimits(metadata *client.ServiceMetadata, width, height int, format string) error {
+	if metadata.MaxImageWidth > 0 && width > metadata.MaxImageWidth {
+		return fmt.Errorf("WIDTH %d exceeds the service maximum of %d", width, metadata.MaxImageWidth)
+	}
+	if metadata.MaxImageHeight > 0 && height > metadata.MaxImageHeight {
+		return fmt.Errorf("HEIGHT %d exceeds the service maximum of %d", height, metadata.MaxImageHeight)
+	}
+	if !metadata.SupportsImageFormat(format) {
+		return fmt.Errorf("image format %s is not supported by the service (supported: %s)", format, metadata.SupportedImageFormatTypes)
+	}
+	return nil
+}
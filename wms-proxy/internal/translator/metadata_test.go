@@ -0,0 +1,74 @@
+package translator
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"wms-proxy/internal/client"
+	"wms-proxy/internal/transform"
+)
+
+const mapServiceJSON = `{
+	"spatialReference": {"wkid": 102100, "latestWkid": 3857},
+	"fullExtent": {"xmin": -8266000, "ymin": 4938000, "xmax": -8204000, "ymax": 4999000, "spatialReference": {"wkid": 102100, "latestWkid": 3857}},
+	"initialExtent": {"xmin": -8240000, "ymin": 4960000, "xmax": -8230000, "ymax": 4970000},
+	"supportedImageFormatTypes": "PNG32,PNG24,PNG,JPG,DIB,TIFF,EMF,PS,PDF,GIF,SVG,SVGZ,BMP",
+	"maxImageWidth": 4096,
+	"maxImageHeight": 2048
+}`
+
+func TestExtentFromMetadata(t *testing.T) {
+	var metadata client.ServiceMetadata
+	if err := json.Unmarshal([]byte(mapServiceJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+
+	extent := ExtentFromMetadata(&metadata, transform.NewCoordinateTransformer())
+	if extent == nil {
+		t.Fatal("expected an extent")
+	}
+	if extent.SRS != "EPSG:3857" || extent.BBox != [4]float64{-8266000, 4938000, -8204000, 4999000} {
+		t.Errorf("unexpected extent: %+v", extent)
+	}
+	if !extent.HasLatLon || math.Abs(extent.LatLon[0]+74.25) > 0.01 || math.Abs(extent.LatLon[3]-40.91) > 0.01 {
+		t.Errorf("unexpected geographic extent: %v", extent.LatLon)
+	}
+
+	if ExtentFromMetadata(&client.ServiceMetadata{}, transform.NewCoordinateTransformer()) != nil {
+		t.Error("expected no extent without extents in the metadata")
+	}
+}
+
+func TestValidateExportLimits(t *testing.T) {
+	var metadata client.ServiceMetadata
+	if err := json.Unmarshal([]byte(mapServiceJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		width, height int
+		format        string
+		expectError   bool
+	}{
+		{"within limits", 4096, 2048, "png32", false},
+		{"too wide", 4097, 256, "png32", true},
+		{"too high", 256, 2049, "jpg", true},
+		{"unsupported format", 256, 256, "png8", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateExportLimits(&metadata, test.width, test.height, test.format)
+			if (err != nil) != test.expectError {
+				t.Errorf("expected error %v, got %v", test.expectError, err)
+			}
+		})
+	}
+
+	// Services that do not declare limits accept everything
+	if err := ValidateExportLimits(&client.ServiceMetadata{}, 10000, 10000, "png8"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
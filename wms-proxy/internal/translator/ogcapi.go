@@ -0,0 +1,123 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/transform"
+	"wms-proxy/pkg/ogcapi"
+)
+
+// OGCAPIItemsParams holds the parsed parameters of an OGC API items request
+type OGCAPIItemsParams struct {
+	BBox      *transform.BBox
+	BBoxCRS   string // EPSG code of BBox
+	OutCRS    string // EPSG code of returned geometries
+	OutCRSURI string // requested CRS URI, echoed in Content-Crs
+	SwapAxes  bool   // output CRS uses latitude/longitude axis order
+	Limit     int
+	Offset    int
+}
+
+// ParseOGCAPIItemsParams parses the bbox, bbox-crs, crs, limit and offset parameters
+func ParseOGCAPIItemsParams(query url.Values) (*OGCAPIItemsParams, error) {
+	p := &OGCAPIItemsParams{Limit: ogcapi.DefaultLimit}
+
+	crsURI := query.Get("crs")
+	if crsURI == "" {
+		crsURI = ogcapi.CRS84
+	}
+	outCRS, latLon, err := ParseOGCAPICRS(crsURI)
+	if err != nil {
+		return nil, err
+	}
+	p.OutCRS, p.OutCRSURI, p.SwapAxes = outCRS, crsURI, latLon
+
+	if bboxParam := query.Get("bbox"); bboxParam != "" {
+		parts := strings.Split(bboxParam, ",")
+		if len(parts) != 4 {
+			// 3D bounding boxes (6 values) are not supported by ArcGIS envelopes
+			return nil, fmt.Errorf("invalid bbox, expected minx,miny,maxx,maxy")
+		}
+		var coords [4]float64
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bbox coordinate: %s", part)
+			}
+			coords[i] = v
+		}
+
+		bboxCRS := query.Get("bbox-crs")
+		if bboxCRS == "" {
+			bboxCRS = ogcapi.CRS84
+		}
+		crs, bboxLatLon, err := ParseOGCAPICRS(bboxCRS)
+		if err != nil {
+			return nil, err
+		}
+
+		bbox := &transform.BBox{MinX: coords[0], MinY: coords[1], MaxX: coords[2], MaxY: coords[3]}
+		if bboxLatLon {
+			bbox = &transform.BBox{MinX: coords[1], MinY: coords[0], MaxX: coords[3], MaxY: coords[2]}
+		}
+		if bbox.MinX > bbox.MaxX || bbox.MinY > bbox.MaxY {
+			return nil, fmt.Errorf("invalid bbox: min values must not exceed max values")
+		}
+		p.BBox, p.BBoxCRS = bbox, crs
+	} else if query.Get("bbox-crs") != "" {
+		return nil, fmt.Errorf("bbox-crs requires bbox")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid limit: %s", limit)
+		}
+		if n > ogcapi.MaxLimit {
+			n = ogcapi.MaxLimit
+		}
+		p.Limit = n
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset: %s", offset)
+		}
+		p.Offset = n
+	}
+
+	return p, nil
+}
+
+// ParseOGCAPICRS validates a CRS URI against the supported CRSs and returns its
+// EPSG code and whether it uses latitude/longitude axis order
+func ParseOGCAPICRS(uri string) (string, bool, error) {
+	for _, supported := range ogcapi.SupportedCRS {
+		if uri == supported {
+			return ParseCRSName(uri)
+		}
+	}
+	return "", false, fmt.Errorf("unsupported crs: %s", uri)
+}
+
+// GenerateOGCAPIError writes an OGC API JSON exception
+func GenerateOGCAPIError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", ogcapi.MediaTypeJSON)
+	w.WriteHeader(code)
+
+	exceptionCode := "InvalidParameterValue"
+	switch code {
+	case http.StatusNotFound:
+		exceptionCode = "NotFound"
+	case http.StatusBadGateway, http.StatusInternalServerError:
+		exceptionCode = "ServerError"
+	}
+
+	json.NewEncoder(w).Encode(ogcapi.Exception{Code: exceptionCode, Description: message})
+}
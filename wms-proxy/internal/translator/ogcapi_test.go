@@ -0,0 +1,60 @@
+package translator
+
+import (
+	"net/url"
+	"testing"
+
+	"wms-proxy/pkg/ogcapi"
+)
+
+func TestParseOGCAPIItemsParams(t *testing.T) {
+	query, _ := url.ParseQuery("bbox=40,-75,41,-74&bbox-crs=http://www.opengis.net/def/crs/EPSG/0/4326&crs=http://www.opengis.net/def/crs/EPSG/0/3857&limit=20&offset=40")
+	p, err := ParseOGCAPIItemsParams(query)
+	if err != nil {
+		t.Fatalf("ParseOGCAPIItemsParams failed: %v", err)
+	}
+
+	// EPSG:4326 URIs are latitude/longitude and must be swapped
+	if p.BBox.MinX != -75 || p.BBox.MinY != 40 || p.BBox.MaxX != -74 || p.BBox.MaxY != 41 || p.BBoxCRS != "EPSG:4326" {
+		t.Errorf("Unexpected bbox: %+v %s", p.BBox, p.BBoxCRS)
+	}
+	if p.OutCRS != "EPSG:3857" || p.SwapAxes || p.Limit != 20 || p.Offset != 40 {
+		t.Errorf("Unexpected params: %+v", p)
+	}
+
+	p, err = ParseOGCAPIItemsParams(url.Values{})
+	if err != nil {
+		t.Fatalf("ParseOGCAPIItemsParams failed: %v", err)
+	}
+	if p.BBox != nil || p.OutCRS != "EPSG:4326" || p.OutCRSURI != ogcapi.CRS84 || p.SwapAxes || p.Limit != ogcapi.DefaultLimit {
+		t.Errorf("Unexpected defaults: %+v", p)
+	}
+
+	p, _ = ParseOGCAPIItemsParams(url.Values{"limit": {"999999"}})
+	if p.Limit != ogcapi.MaxLimit {
+		t.Errorf("Expected limit to be capped at %d, got %d", ogcapi.MaxLimit, p.Limit)
+	}
+}
+
+func TestParseOGCAPIItemsParamsErrors(t *testing.T) {
+	tests := []string{
+		"bbox=1,2,3",
+		"bbox=1,2,3,4,5,6",
+		"bbox=3,0,1,1",
+		"bbox=a,0,1,1",
+		"bbox-crs=" + ogcapi.CRS84,
+		"crs=EPSG:4326",
+		"crs=http://www.opengis.net/def/crs/EPSG/0/2263",
+		"limit=0",
+		"offset=-1",
+	}
+
+	for _, raw := range tests {
+		t.Run(raw, func(t *testing.T) {
+			query, _ := url.ParseQuery(raw)
+			if _, err := ParseOGCAPIItemsParams(query); err == nil {
+				t.Errorf("Expected error for %q", raw)
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package translator
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -28,8 +30,8 @@ func TranslateWMSToArcGISWithTransform(wmsParams *wms.WMSParams, transformer *tr
 	if transformer != nil && sourceSRS != "" && targetSRS != "" {
 		// The sourceSRS parameter indicates the coordinate system of the incoming bbox coordinates
 		fromCRS := transformer.NormalizeCRS(sourceSRS)
-		// The backend ArcGIS server expects coordinates in EPSG:3424 (New Jersey State Plane)
-		toCRS := "EPSG:3424"
+		// Without a detector the backend is assumed to use the default fallback SR
+		toCRS := services.DefaultFallbackSR
 
 		// Only transform if the CRS are different
 		if fromCRS != toCRS {
@@ -56,6 +58,10 @@ func TranslateWMSToArcGISWithTransform(wmsParams *wms.WMSParams, transformer *tr
 		F:           "image",
 	}
 
+	if err := translateDimensions(wmsParams, arcgisParams); err != nil {
+		return nil, err
+	}
+
 	return arcgisParams, nil
 }
 
@@ -75,7 +81,7 @@ func TranslateWMSToArcGISWithTransformAndBackendSR(wmsParams *wms.WMSParams, tra
 		if err != nil {
 			// Log the error but continue with original bbox
 			fmt.Printf("Warning: failed to detect backend SR: %v\n", err)
-			toCRS = "EPSG:3424" // Fallback
+			toCRS = srDetector.FallbackSR()
 		}
 
 		// Only transform if the CRS are different
@@ -99,6 +105,10 @@ func TranslateWMSToArcGISWithTransformAndBackendSR(wmsParams *wms.WMSParams, tra
 		F:           "image",
 	}
 
+	if err := translateDimensions(wmsParams, arcgisParams); err != nil {
+		return nil, err
+	}
+
 	return arcgisParams, nil
 }
 
@@ -120,6 +130,15 @@ func BuildArcGISURL(baseURL, servicePath string, params *wms.ArcGISParams) strin
 	query.Set("transparent", params.Transparent)
 	query.Set("dpi", fmt.Sprintf("%d", params.DPI))
 	query.Set("f", params.F)
+	if params.Time != "" {
+		query.Set("time", params.Time)
+	}
+	if params.RangeValues != "" {
+		query.Set("rangeValues", params.RangeValues)
+	}
+	if params.LayerDefs != "" {
+		query.Set("layerDefs", params.LayerDefs)
+	}
 
 	u.RawQuery = query.Encode()
 	return u.String()
@@ -136,9 +155,68 @@ func buildQueryString(params *wms.ArcGISParams) string {
 	values.Set("transparent", params.Transparent)
 	values.Set("dpi", fmt.Sprintf("%d", params.DPI))
 	values.Set("f", params.F)
+	if params.Time != "" {
+		values.Set("time", params.Time)
+	}
+	if params.RangeValues != "" {
+		values.Set("rangeValues", params.RangeValues)
+	}
+	if params.LayerDefs != "" {
+		values.Set("layerDefs", params.LayerDefs)
+	}
 	return values.Encode()
 }
 
+// rangeValue is a single entry of the ArcGIS export rangeValues parameter
+type rangeValue struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// translateDimensions maps WMS TIME, ELEVATION and DIM_ values onto the ArcGIS
+// export time and rangeValues parameters
+func translateDimensions(wmsParams *wms.WMSParams, arcgisParams *wms.ArcGISParams) error {
+	if wmsParams.Time != "" {
+		tr, err := wms.ParseTime(wmsParams.Time)
+		if err != nil {
+			return fmt.Errorf("invalid TIME parameter: %w", err)
+		}
+		arcgisParams.Time = tr.EpochMillis()
+	}
+
+	var ranges []rangeValue
+	if wmsParams.Elevation != "" {
+		nr, err := wms.ParseNumericDimension(wmsParams.Elevation)
+		if err != nil {
+			return fmt.Errorf("invalid ELEVATION parameter: %w", err)
+		}
+		ranges = append(ranges, rangeValue{Name: "elevation", Values: []float64{nr.Min, nr.Max}})
+	}
+
+	names := make([]string, 0, len(wmsParams.Dimensions))
+	for name := range wmsParams.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		nr, err := wms.ParseNumericDimension(wmsParams.Dimensions[name])
+		if err != nil {
+			return fmt.Errorf("invalid DIM_%s parameter: %w", strings.ToUpper(name), err)
+		}
+		ranges = append(ranges, rangeValue{Name: name, Values: []float64{nr.Min, nr.Max}})
+	}
+
+	if len(ranges) > 0 {
+		encoded, err := json.Marshal(ranges)
+		if err != nil {
+			return fmt.Errorf("failed to encode rangeValues: %w", err)
+		}
+		arcgisParams.RangeValues = string(encoded)
+	}
+
+	return nil
+}
+
 // translateFormat converts WMS format to ArcGIS format
 func translateFormat(wmsFormat string) string {
 	switch strings.ToLower(wmsFormat) {
@@ -2,15 +2,20 @@ package translator
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"wms-proxy/internal/imaging"
 )
 
+// maxProcessedImageBytes bounds how much of an upstream image is buffered for post-processing
+const maxProcessedImageBytes = 64 << 20
+
 // TranslateArcGISResponse handles the response from ArcGIS and prepares it for WMS client
 func TranslateArcGISResponse(arcgisResp *http.Response, wmsWriter http.ResponseWriter) error {
-	// Copy status code
-	wmsWriter.WriteHeader(arcgisResp.StatusCode)
-
-	// Copy relevant headers
+	// Copy relevant headers; they must be set before the status is written
 	copyHeaders(arcgisResp.Header, wmsWriter.Header())
 
 	// For successful image responses, copy the body directly
@@ -22,17 +27,60 @@ func TranslateArcGISResponse(arcgisResp *http.Response, wmsWriter http.ResponseW
 			wmsWriter.Header().Set("Content-Type", "image/png")
 		}
 
+		wmsWriter.WriteHeader(arcgisResp.StatusCode)
+
 		// Copy response body
 		_, err := copyResponseBody(arcgisResp, wmsWriter)
 		return err
 	}
 
+	// Copy status code
+	wmsWriter.WriteHeader(arcgisResp.StatusCode)
+
 	// For error responses, we might want to convert to WMS error format
 	// For now, just pass through the ArcGIS error
 	_, err := copyResponseBody(arcgisResp, wmsWriter)
 	return err
 }
 
+// TranslateArcGISImageResponse runs a successful upstream image through the
+// post-processing pipeline before returning it to the WMS client. Errors and
+// non-image responses are passed through unchanged.
+func TranslateArcGISImageResponse(arcgisResp *http.Response, wmsWriter http.ResponseWriter, opts *imaging.Options) error {
+	contentType := arcgisResp.Header.Get("Content-Type")
+	if arcgisResp.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, "image/") {
+		return TranslateArcGISResponse(arcgisResp, wmsWriter)
+	}
+	defer arcgisResp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(arcgisResp.Body, maxProcessedImageBytes+1))
+	if err == nil && len(data) > maxProcessedImageBytes {
+		err = fmt.Errorf("upstream image exceeds %d bytes", maxProcessedImageBytes)
+	}
+	var processed []byte
+	var processedType string
+	if err == nil {
+		processed, processedType, err = imaging.Process(data, opts)
+	}
+	if err != nil {
+		// Nothing has been written yet, so the client still gets a proper WMS error
+		GenerateWMSError(wmsWriter, "Failed to process upstream image", http.StatusBadGateway)
+		return err
+	}
+
+	for _, header := range []string{"Cache-Control", "Expires", "Last-Modified", "Warning"} {
+		if value := arcgisResp.Header.Get(header); value != "" {
+			wmsWriter.Header().Set(header, value)
+		}
+	}
+	wmsWriter.Header().Set("Content-Type", processedType)
+	wmsWriter.Header().Set("Content-Length", strconv.Itoa(len(processed)))
+	wmsWriter.WriteHeader(http.StatusOK)
+
+	_, err = wmsWriter.Write(processed)
+	return err
+}
+
 // copyHeaders copies relevant headers from ArcGIS response to WMS response
 func copyHeaders(src http.Header, dst http.Header) {
 	// Headers to copy
@@ -43,6 +91,7 @@ func copyHeaders(src http.Header, dst http.Header) {
 		"Expires",
 		"Last-Modified",
 		"ETag",
+		"Warning",
 	}
 
 	for _, header := range headersToCopy {
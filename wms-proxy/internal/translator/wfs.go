@@ -0,0 +1,371 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wms-proxy/internal/geometry"
+	"wms-proxy/internal/transform"
+	"wms-proxy/pkg/wfs"
+)
+
+// Paging limits for GetFeature
+const (
+	DefaultFeatureCount = 1000
+	MaxFeatureCount     = 10000
+)
+
+// WFSGetFeatureParams holds the parsed parameters of a WFS GetFeature request
+type WFSGetFeatureParams struct {
+	TypeName      string
+	BBox          *transform.BBox
+	BBoxCRS       string // EPSG code of BBox
+	Filter        string // CQL expression
+	PropertyNames []string
+	ResourceIDs   []string // object IDs
+	Count         int
+	StartIndex    int
+	OutCRS        string // EPSG code of returned geometries
+	SwapAxes      bool   // output CRS uses latitude/longitude axis order
+	SRSName       string // srsName written to GML geometries
+	OutputFormat  string
+}
+
+// NormalizeWFSParams returns the query parameters with upper-cased keys,
+// since OGC parameter names are case-insensitive
+func NormalizeWFSParams(query url.Values) url.Values {
+	params := make(url.Values, len(query))
+	for key, values := range query {
+		upper := strings.ToUpper(key)
+		params[upper] = append(params[upper], values...)
+	}
+	return params
+}
+
+// ParseWFSGetFeature parses GetFeature parameters (with upper-cased keys)
+func ParseWFSGetFeature(params url.Values) (*WFSGetFeatureParams, error) {
+	p := &WFSGetFeatureParams{Count: DefaultFeatureCount}
+
+	typeNames := params.Get("TYPENAMES")
+	if typeNames == "" {
+		typeNames = params.Get("TYPENAME")
+	}
+	if typeNames == "" && params.Get("RESOURCEID") == "" {
+		return nil, fmt.Errorf("missing required parameter: TYPENAMES")
+	}
+	if strings.Contains(typeNames, ",") {
+		return nil, fmt.Errorf("only one feature type per GetFeature request is supported")
+	}
+	p.TypeName = strings.TrimSpace(typeNames)
+
+	if resourceIDs := params.Get("RESOURCEID"); resourceIDs != "" {
+		for _, rid := range strings.Split(resourceIDs, ",") {
+			// Resource IDs are written as <TypeName>.<objectid>
+			typeName, oid := "", strings.TrimSpace(rid)
+			if i := strings.LastIndex(oid, "."); i >= 0 {
+				typeName, oid = oid[:i], oid[i+1:]
+			}
+			if _, err := strconv.ParseInt(oid, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid RESOURCEID: %s", rid)
+			}
+			if p.TypeName == "" {
+				p.TypeName = typeName
+			}
+			p.ResourceIDs = append(p.ResourceIDs, oid)
+		}
+		if p.TypeName == "" {
+			return nil, fmt.Errorf("missing required parameter: TYPENAMES")
+		}
+	}
+
+	srsName := params.Get("SRSNAME")
+	if srsName == "" {
+		srsName = "urn:ogc:def:crs:EPSG::4326"
+	}
+	outCRS, latLon, err := ParseCRSName(srsName)
+	if err != nil {
+		return nil, err
+	}
+	p.OutCRS = outCRS
+	p.SwapAxes = latLon
+	p.SRSName = srsName
+
+	if bboxParam := params.Get("BBOX"); bboxParam != "" {
+		bbox, crs, err := parseWFSBBox(bboxParam, outCRS, latLon)
+		if err != nil {
+			return nil, err
+		}
+		p.BBox = bbox
+		p.BBoxCRS = crs
+	}
+
+	p.Filter = params.Get("CQL_FILTER")
+	if p.Filter == "" {
+		p.Filter = params.Get("FILTER")
+	}
+	if strings.HasPrefix(strings.TrimSpace(p.Filter), "<") {
+		return nil, fmt.Errorf("XML filter encoding is not supported, use CQL")
+	}
+	if p.Filter != "" && p.BBox != nil {
+		return nil, fmt.Errorf("BBOX and FILTER are mutually exclusive")
+	}
+
+	if propertyNames := params.Get("PROPERTYNAME"); propertyNames != "" {
+		for _, name := range strings.Split(propertyNames, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				// Strip a namespace prefix such as esri:NAME
+				if i := strings.LastIndex(name, ":"); i >= 0 {
+					name = name[i+1:]
+				}
+				p.PropertyNames = append(p.PropertyNames, name)
+			}
+		}
+	}
+
+	count := params.Get("COUNT")
+	if count == "" {
+		count = params.Get("MAXFEATURES")
+	}
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid COUNT: %s", count)
+		}
+		p.Count = n
+	}
+	if p.Count > MaxFeatureCount {
+		p.Count = MaxFeatureCount
+	}
+
+	if startIndex := params.Get("STARTINDEX"); startIndex != "" {
+		n, err := strconv.Atoi(startIndex)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid STARTINDEX: %s", startIndex)
+		}
+		p.StartIndex = n
+	}
+
+	p.OutputFormat = wfs.FormatGML32
+	if format := params.Get("OUTPUTFORMAT"); format != "" {
+		switch strings.ToLower(format) {
+		case "application/json", "json", "geojson", "application/geo+json":
+			p.OutputFormat = wfs.FormatGeoJSON
+		case "application/gml+xml; version=3.2", "text/xml; subtype=gml/3.2", "gml32", "gml3":
+			p.OutputFormat = wfs.FormatGML32
+		default:
+			return nil, fmt.Errorf("unsupported OUTPUTFORMAT: %s", format)
+		}
+	}
+
+	return p, nil
+}
+
+// parseWFSBBox parses "minx,miny,maxx,maxy[,crs]". Without a CRS the values are
+// in the CRS of the response, defaultCRS, in its axis order (latitude/longitude
+// when defaultLatLon), so that they agree with the returned geometries; with an
+// EPSG URN the axis order of the CRS applies.
+func parseWFSBBox(value, defaultCRS string, defaultLatLon bool) (*transform.BBox, string, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 && len(parts) != 5 {
+		return nil, "", fmt.Errorf("invalid BBOX format, expected minx,miny,maxx,maxy[,crs]")
+	}
+
+	var coords [4]float64
+	for i := 0; i < 4; i++ {
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid BBOX coordinate: %s", parts[i])
+		}
+		coords[i] = v
+	}
+
+	crs, latLon := defaultCRS, defaultLatLon
+	if len(parts) == 5 {
+		var err error
+		crs, latLon, err = ParseCRSName(strings.TrimSpace(parts[4]))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	bbox := &transform.BBox{MinX: coords[0], MinY: coords[1], MaxX: coords[2], MaxY: coords[3]}
+	if latLon {
+		bbox = &transform.BBox{MinX: coords[1], MinY: coords[0], MaxX: coords[3], MaxY: coords[2]}
+	}
+	if bbox.MinX > bbox.MaxX || bbox.MinY > bbox.MaxY {
+		return nil, "", fmt.Errorf("invalid BBOX: min values must not exceed max values")
+	}
+	return bbox, crs, nil
+}
+
+// ParseCRSName converts a CRS name (EPSG:n, an EPSG URN or an OGC CRS URI) into
+// an EPSG code and reports whether coordinates are in latitude/longitude order.
+// Only the URN and URI forms of EPSG:4326 use latitude/longitude order.
+func ParseCRSName(name string) (string, bool, error) {
+	var code string
+	urnForm := false
+
+	switch {
+	case strings.HasPrefix(strings.ToUpper(name), "EPSG:"):
+		code = name[len("EPSG:"):]
+	case strings.HasPrefix(strings.ToLower(name), "urn:ogc:def:crs:epsg:"):
+		code = name[strings.LastIndex(name, ":")+1:]
+		urnForm = true
+	case strings.HasPrefix(name, "http://www.opengis.net/def/crs/EPSG/0/"):
+		code = strings.TrimPrefix(name, "http://www.opengis.net/def/crs/EPSG/0/")
+		urnForm = true
+	case name == "http://www.opengis.net/def/crs/OGC/1.3/CRS84", strings.EqualFold(name, "CRS:84"):
+		return "EPSG:4326", false, nil
+	default:
+		return "", false, fmt.Errorf("unsupported CRS: %s", name)
+	}
+
+	if _, err := strconv.Atoi(code); err != nil {
+		return "", false, fmt.Errorf("unsupported CRS: %s", name)
+	}
+	return "EPSG:" + code, urnForm && code == "4326", nil
+}
+
+// XSDTypeForEsriField maps an ArcGIS field type to an XML schema type
+func XSDTypeForEsriField(esriType string) string {
+	switch esriType {
+	case "esriFieldTypeOID", "esriFieldTypeInteger", "esriFieldTypeSmallInteger":
+		return "xsd:int"
+	case "esriFieldTypeBigInteger":
+		return "xsd:long"
+	case "esriFieldTypeDouble", "esriFieldTypeSingle":
+		return "xsd:double"
+	case "esriFieldTypeDate", "esriFieldTypeTimestampOffset":
+		return "xsd:dateTime"
+	case "esriFieldTypeDateOnly":
+		return "xsd:date"
+	default:
+		return "xsd:string"
+	}
+}
+
+// EncodeGMLFeatureCollection encodes features as a WFS 2.0 GML 3.2 FeatureCollection.
+// properties lists the property names in output order; next is the URL of the
+// following page, if any.
+func EncodeGMLFeatureCollection(typeName string, features []geometry.Feature, properties []string, srsName string, swapAxes bool, next string) []byte {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<wfs:FeatureCollection xmlns:wfs="http://www.opengis.net/wfs/2.0" xmlns:gml="http://www.opengis.net/gml/3.2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:` +
+		wfs.FeaturePrefix + `="` + wfs.FeatureNamespace + `" numberMatched="unknown" numberReturned="` + strconv.Itoa(len(features)) + `"`)
+	if next != "" {
+		sb.WriteString(` next="` + escapeXML(next) + `"`)
+	}
+	sb.WriteString(">\n")
+
+	element := wfs.FeaturePrefix + ":" + typeName
+	for i := range features {
+		f := &features[i]
+		gmlID := typeName + "." + formatFeatureID(f.ID, i)
+
+		sb.WriteString("  <wfs:member>\n")
+		sb.WriteString("    <" + element + ` gml:id="` + escapeXML(gmlID) + `">` + "\n")
+		for _, name := range properties {
+			value, ok := f.Properties[name]
+			if !ok {
+				continue
+			}
+			if value == nil {
+				sb.WriteString("      <" + wfs.FeaturePrefix + ":" + name + ` xsi:nil="true"/>` + "\n")
+				continue
+			}
+			sb.WriteString("      <" + wfs.FeaturePrefix + ":" + name + ">" + escapeXML(fmt.Sprint(value)) + "</" + wfs.FeaturePrefix + ":" + name + ">\n")
+		}
+		if !f.Geometry.IsEmpty() {
+			sb.WriteString("      <" + wfs.FeaturePrefix + ":shape>")
+			sb.WriteString(geometry.GML(f.Geometry, srsName, gmlID, swapAxes))
+			sb.WriteString("</" + wfs.FeaturePrefix + ":shape>\n")
+		}
+		sb.WriteString("    </" + element + ">\n")
+		sb.WriteString("  </wfs:member>\n")
+	}
+
+	sb.WriteString("</wfs:FeatureCollection>\n")
+	return []byte(sb.String())
+}
+
+// GeoJSONLink is a link object of a GeoJSON feature collection
+type GeoJSONLink struct {
+	Href  string `json:"href"`
+	Rel   string `json:"rel"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// GeoJSONFeatureCollection is a GeoJSON FeatureCollection with paging information
+type GeoJSONFeatureCollection struct {
+	Type           string                    `json:"type"`
+	Features       []geometry.GeoJSONFeature `json:"features"`
+	NumberReturned int                       `json:"numberReturned"`
+	TimeStamp      string                    `json:"timeStamp,omitempty"`
+	Links          []GeoJSONLink             `json:"links,omitempty"`
+}
+
+// EncodeGeoJSONFeatureCollection encodes features as a GeoJSON FeatureCollection
+// restricted to the given properties
+func EncodeGeoJSONFeatureCollection(features []geometry.Feature, properties []string, links []GeoJSONLink) ([]byte, error) {
+	collection := GeoJSONFeatureCollection{
+		Type:           "FeatureCollection",
+		Features:       make([]geometry.GeoJSONFeature, 0, len(features)),
+		NumberReturned: len(features),
+		TimeStamp:      time.Now().UTC().Format(time.RFC3339),
+		Links:          links,
+	}
+
+	for i := range features {
+		feature := features[i].ToGeoJSON()
+		if properties != nil {
+			selected := make(map[string]interface{}, len(properties))
+			for _, name := range properties {
+				if value, ok := feature.Properties[name]; ok {
+					selected[name] = value
+				}
+			}
+			feature.Properties = selected
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+
+	data, err := json.Marshal(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GeoJSON: %w", err)
+	}
+	return data, nil
+}
+
+// formatFeatureID renders a feature ID, falling back to its position
+func formatFeatureID(id interface{}, index int) string {
+	if id == nil {
+		return strconv.Itoa(index + 1)
+	}
+	return fmt.Sprint(id)
+}
+
+// GenerateOWSException creates an OWS 1.1 ExceptionReport as used by WFS 2.0
+func GenerateOWSException(w http.ResponseWriter, exceptionCode, locator, message string, code int) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+
+	locatorAttr := ""
+	if locator != "" {
+		locatorAttr = ` locator="` + escapeXML(locator) + `"`
+	}
+
+	errorXML := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ows:ExceptionReport xmlns:ows="http://www.opengis.net/ows/1.1" version="2.0.0">
+  <ows:Exception exceptionCode="%s"%s>
+    <ows:ExceptionText>%s</ows:ExceptionText>
+  </ows:Exception>
+</ows:ExceptionReport>`, escapeXML(exceptionCode), locatorAttr, escapeXML(message))
+
+	w.Write([]byte(errorXML))
+}
@@ -0,0 +1,157 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"wms-proxy/internal/geometry"
+	"wms-proxy/pkg/wfs"
+)
+
+func TestParseWFSGetFeature(t *testing.T) {
+	query, _ := url.ParseQuery("typeNames=esri:Roads&bbox=40,-75,41,-74,urn:ogc:def:crs:EPSG::4326&count=50&startIndex=100&propertyName=esri:NAME,LANES&outputFormat=application/json")
+	p, err := ParseWFSGetFeature(NormalizeWFSParams(query))
+	if err != nil {
+		t.Fatalf("ParseWFSGetFeature failed: %v", err)
+	}
+
+	if p.TypeName != "esri:Roads" || p.Count != 50 || p.StartIndex != 100 {
+		t.Errorf("Unexpected params: %+v", p)
+	}
+	// The URN form of EPSG:4326 is latitude/longitude and must be swapped
+	if p.BBox.MinX != -75 || p.BBox.MinY != 40 || p.BBox.MaxX != -74 || p.BBox.MaxY != 41 || p.BBoxCRS != "EPSG:4326" {
+		t.Errorf("Unexpected bbox: %+v %s", p.BBox, p.BBoxCRS)
+	}
+	if strings.Join(p.PropertyNames, ",") != "NAME,LANES" {
+		t.Errorf("Unexpected property names: %v", p.PropertyNames)
+	}
+	if p.OutputFormat != wfs.FormatGeoJSON || p.OutCRS != "EPSG:4326" || !p.SwapAxes {
+		t.Errorf("Unexpected output settings: %+v", p)
+	}
+}
+
+func TestParseWFSGetFeatureBBoxAxisOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected [4]float64 // minx (longitude), miny (latitude), maxx, maxy
+		crs      string
+		swap     bool
+	}{
+		// Without a CRS the BBOX uses the response CRS: the default URN is latitude/longitude
+		{"default srsName", "typeNames=a&bbox=40,-75,41,-74", [4]float64{-75, 40, -74, 41}, "EPSG:4326", true},
+		{"EPSG srsName", "typeNames=a&bbox=-75,40,-74,41&srsName=EPSG:4326", [4]float64{-75, 40, -74, 41}, "EPSG:4326", false},
+		{"projected srsName", "typeNames=a&bbox=-8348000,4865000,-8237000,5012000&srsName=EPSG:3857", [4]float64{-8348000, 4865000, -8237000, 5012000}, "EPSG:3857", false},
+		// An explicit CRS applies to the BBOX only
+		{"BBOX CRS", "typeNames=a&bbox=-75,40,-74,41,EPSG:4326", [4]float64{-75, 40, -74, 41}, "EPSG:4326", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(test.query)
+			p, err := ParseWFSGetFeature(NormalizeWFSParams(query))
+			if err != nil {
+				t.Fatalf("ParseWFSGetFeature failed: %v", err)
+			}
+			bbox := [4]float64{p.BBox.MinX, p.BBox.MinY, p.BBox.MaxX, p.BBox.MaxY}
+			if bbox != test.expected || p.BBoxCRS != test.crs {
+				t.Errorf("Expected bbox %v in %s, got %v in %s", test.expected, test.crs, bbox, p.BBoxCRS)
+			}
+			if p.SwapAxes != test.swap {
+				t.Errorf("Expected SwapAxes %v, got %v", test.swap, p.SwapAxes)
+			}
+		})
+	}
+}
+
+func TestParseWFSGetFeatureErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"typeNames=a,b",
+		"typeNames=a&bbox=1,2,3",
+		"typeNames=a&bbox=3,0,1,1",
+		"typeNames=a&count=-1",
+		"typeNames=a&srsName=EPSG:abc",
+		"typeNames=a&outputFormat=shape-zip",
+		"typeNames=a&filter=<Filter/>",
+		"typeNames=a&resourceId=a.b",
+		"typeNames=a&bbox=0,0,1,1&cql_filter=A=1",
+	}
+
+	for _, raw := range tests {
+		t.Run(raw, func(t *testing.T) {
+			query, _ := url.ParseQuery(raw)
+			if _, err := ParseWFSGetFeature(NormalizeWFSParams(query)); err == nil {
+				t.Errorf("Expected error for %q", raw)
+			}
+		})
+	}
+}
+
+func TestParseCRSName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		latLon   bool
+	}{
+		{"EPSG:4326", "EPSG:4326", false},
+		{"urn:ogc:def:crs:EPSG::4326", "EPSG:4326", true},
+		{"http://www.opengis.net/def/crs/EPSG/0/4326", "EPSG:4326", true},
+		{"urn:ogc:def:crs:EPSG::3857", "EPSG:3857", false},
+		{"http://www.opengis.net/def/crs/OGC/1.3/CRS84", "EPSG:4326", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			crs, latLon, err := ParseCRSName(tt.input)
+			if err != nil || crs != tt.expected || latLon != tt.latLon {
+				t.Errorf("ParseCRSName(%s) = %s, %v, %v", tt.input, crs, latLon, err)
+			}
+		})
+	}
+}
+
+func TestEncodeFeatureCollections(t *testing.T) {
+	features := []geometry.Feature{{
+		ID:         int64(3),
+		Properties: map[string]interface{}{"OBJECTID": int64(3), "NAME": "Main & 1st", "LANES": nil},
+		Geometry:   &geometry.Geometry{Type: geometry.TypePoint, Points: []geometry.Point{{-74.5, 40.2}}},
+	}}
+
+	gml := string(EncodeGMLFeatureCollection("Roads", features, []string{"NAME", "LANES"}, "urn:ogc:def:crs:EPSG::4326", true, "http://proxy/wfs?STARTINDEX=1"))
+	for _, expected := range []string{
+		`numberReturned="1"`,
+		`next="http://proxy/wfs?STARTINDEX=1"`,
+		`<esri:Roads gml:id="Roads.3">`,
+		`<esri:NAME>Main &amp; 1st</esri:NAME>`,
+		`<esri:LANES xsi:nil="true"/>`,
+		`<gml:pos>40.2 -74.5</gml:pos>`,
+	} {
+		if !strings.Contains(gml, expected) {
+			t.Errorf("Expected GML to contain %s, got:\n%s", expected, gml)
+		}
+	}
+	if strings.Contains(gml, "<esri:OBJECTID>") {
+		t.Error("Expected unrequested properties to be omitted from GML")
+	}
+
+	data, err := EncodeGeoJSONFeatureCollection(features, []string{"NAME"}, []GeoJSONLink{{Href: "http://proxy/next", Rel: "next"}})
+	if err != nil {
+		t.Fatalf("EncodeGeoJSONFeatureCollection failed: %v", err)
+	}
+	var decoded struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+		NumberReturned int `json:"numberReturned"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Invalid GeoJSON: %v", err)
+	}
+	if decoded.Type != "FeatureCollection" || decoded.NumberReturned != 1 || len(decoded.Features[0].Properties) != 1 {
+		t.Errorf("Unexpected GeoJSON: %s", data)
+	}
+}
@@ -0,0 +1,164 @@
+package kml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// Content types of KML documents and KMZ archives
+const (
+	ContentTypeKML = "application/vnd.google-earth.kml+xml"
+	ContentTypeKMZ = "application/vnd.google-earth.kmz"
+)
+
+// KML is the root element of a KML document
+type KML struct {
+	XMLName  xml.Name `xml:"kml"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Document Document `xml:"Document"`
+}
+
+// Document holds a super-overlay tile: its region, overlay and child links
+type Document struct {
+	Name           string          `xml:"name,omitempty"`
+	Region         *Region         `xml:"Region,omitempty"`
+	NetworkLinks   []NetworkLink   `xml:"NetworkLink"`
+	GroundOverlays []GroundOverlay `xml:"GroundOverlay"`
+}
+
+// Region controls when a feature is loaded and drawn
+type Region struct {
+	LatLonAltBox LatLonBox `xml:"LatLonAltBox"`
+	Lod          Lod       `xml:"Lod"`
+}
+
+// LatLonBox is a bounding box in WGS84 degrees
+type LatLonBox struct {
+	North float64 `xml:"north"`
+	South float64 `xml:"south"`
+	East  float64 `xml:"east"`
+	West  float64 `xml:"west"`
+}
+
+// Lod sets the projected size range in which a region is active
+type Lod struct {
+	MinLodPixels int `xml:"minLodPixels"`
+	MaxLodPixels int `xml:"maxLodPixels"`
+}
+
+// NetworkLink loads a child document when its region becomes active
+type NetworkLink struct {
+	Name   string `xml:"name,omitempty"`
+	Region Region `xml:"Region"`
+	Link   Link   `xml:"Link"`
+}
+
+// Link is a network link target
+type Link struct {
+	Href        string `xml:"href"`
+	ViewRefresh string `xml:"viewRefreshMode,omitempty"`
+}
+
+// GroundOverlay drapes an image over the terrain
+type GroundOverlay struct {
+	Name      string    `xml:"name,omitempty"`
+	DrawOrder int       `xml:"drawOrder"`
+	Icon      Icon      `xml:"Icon"`
+	LatLonBox LatLonBox `xml:"LatLonBox"`
+}
+
+// Icon is the image of a ground overlay
+type Icon struct {
+	Href string `xml:"href"`
+}
+
+// BBox is a WGS84 bounding box in degrees
+type BBox struct {
+	West, South, East, North float64
+}
+
+// String formats the box as a WMS 1.1.1 EPSG:4326 BBOX value
+func (b BBox) String() string {
+	return formatFloat(b.West) + "," + formatFloat(b.South) + "," + formatFloat(b.East) + "," + formatFloat(b.North)
+}
+
+// Quadrants splits the box into its north-west, north-east, south-west and south-east quarters
+func (b BBox) Quadrants() [4]BBox {
+	midX := (b.West + b.East) / 2
+	midY := (b.South + b.North) / 2
+	return [4]BBox{
+		{West: b.West, South: midY, East: midX, North: b.North},
+		{West: midX, South: midY, East: b.East, North: b.North},
+		{West: b.West, South: b.South, East: midX, North: midY},
+		{West: midX, South: b.South, East: b.East, North: midY},
+	}
+}
+
+func (b BBox) latLonBox() LatLonBox {
+	return LatLonBox{North: b.North, South: b.South, East: b.East, West: b.West}
+}
+
+// SuperOverlayTile describes one tile of a Region-based super-overlay
+type SuperOverlayTile struct {
+	Name      string
+	BBox      BBox
+	Level     int
+	ImageHref string   // GetMap URL of the tile image
+	Children  []string // document URLs of the four quadrants (empty at the deepest level)
+}
+
+// GenerateSuperOverlay creates the KML document of a super-overlay tile. The
+// tile's overlay is drawn while it covers at least 128 pixels and stays below
+// its children, which load once their own regions become active.
+func GenerateSuperOverlay(tile SuperOverlayTile) ([]byte, error) {
+	minLod := 128
+	if tile.Level == 0 {
+		minLod = 0
+	}
+
+	doc := Document{
+		Name:   tile.Name,
+		Region: &Region{LatLonAltBox: tile.BBox.latLonBox(), Lod: Lod{MinLodPixels: minLod, MaxLodPixels: -1}},
+		GroundOverlays: []GroundOverlay{{
+			DrawOrder: tile.Level,
+			Icon:      Icon{Href: tile.ImageHref},
+			LatLonBox: tile.BBox.latLonBox(),
+		}},
+	}
+
+	quadrants := tile.BBox.Quadrants()
+	for i, href := range tile.Children {
+		doc.NetworkLinks = append(doc.NetworkLinks, NetworkLink{
+			Region: Region{LatLonAltBox: quadrants[i].latLonBox(), Lod: Lod{MinLodPixels: 128, MaxLodPixels: -1}},
+			Link:   Link{Href: href, ViewRefresh: "onRegion"},
+		})
+	}
+
+	return marshal(doc)
+}
+
+// GenerateOverlay creates a KML document with a single ground overlay, as
+// bundled in KMZ archives
+func GenerateOverlay(name string, bbox BBox, imageHref string) ([]byte, error) {
+	return marshal(Document{
+		Name: name,
+		GroundOverlays: []GroundOverlay{{
+			Name:      name,
+			Icon:      Icon{Href: imageHref},
+			LatLonBox: bbox.latLonBox(),
+		}},
+	})
+}
+
+func marshal(doc Document) ([]byte, error) {
+	xmlData, err := xml.MarshalIndent(KML{XMLNS: "http://www.opengis.net/kml/2.2", Document: doc}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal KML: %w", err)
+	}
+	return []byte(xml.Header + string(xmlData)), nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
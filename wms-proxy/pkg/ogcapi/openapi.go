@@ -0,0 +1,87 @@
+package ogcapi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GenerateOpenAPI creates the OpenAPI 3.0 definition of the API served at baseURL
+func GenerateOpenAPI(baseURL string) ([]byte, error) {
+	collectionID := parameter("collectionId", "path", "Identifier of a feature collection", true, map[string]interface{}{"type": "string"})
+	featureID := parameter("featureId", "path", "Identifier of a feature (ArcGIS object ID)", true, map[string]interface{}{"type": "string"})
+	crs := parameter("crs", "query", "CRS of the returned geometries", false, map[string]interface{}{"type": "string", "format": "uri", "enum": SupportedCRS})
+
+	items := []interface{}{
+		collectionID,
+		parameter("bbox", "query", "Bounding box as minx,miny,maxx,maxy", false, map[string]interface{}{
+			"type": "array", "minItems": 4, "maxItems": 4, "items": map[string]interface{}{"type": "number"},
+		}),
+		parameter("bbox-crs", "query", "CRS of the bbox parameter", false, map[string]interface{}{"type": "string", "format": "uri", "enum": SupportedCRS}),
+		crs,
+		parameter("limit", "query", "Maximum number of features to return", false, map[string]interface{}{
+			"type": "integer", "minimum": 1, "maximum": MaxLimit, "default": DefaultLimit,
+		}),
+		parameter("offset", "query", "Number of features to skip", false, map[string]interface{}{"type": "integer", "minimum": 0, "default": 0}),
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "ArcGIS REST to OGC API - Features Proxy",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": baseURL}},
+		"paths": map[string]interface{}{
+			"/":                                 operation("getLandingPage", "Landing page", nil, MediaTypeJSON),
+			"/conformance":                      operation("getConformance", "Conformance classes", nil, MediaTypeJSON),
+			"/api":                              operation("getAPI", "This API definition", nil, MediaTypeOpenAPI),
+			"/collections":                      operation("getCollections", "Feature collections", nil, MediaTypeJSON),
+			"/collections/{collectionId}":       operation("describeCollection", "Feature collection metadata", []interface{}{collectionID}, MediaTypeJSON),
+			"/collections/{collectionId}/items": operation("getFeatures", "Features of a collection", items, MediaTypeGeoJSON),
+			"/collections/{collectionId}/items/{featureId}": operation("getFeature", "A single feature", []interface{}{collectionID, featureID, crs}, MediaTypeGeoJSON),
+		},
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OpenAPI document: %w", err)
+	}
+	return data, nil
+}
+
+// operation describes a GET operation returning the given media type
+func operation(id, summary string, parameters []interface{}, mediaType string) map[string]interface{} {
+	get := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": summary,
+				"content":     map[string]interface{}{mediaType: map[string]interface{}{}},
+			},
+			"400": map[string]interface{}{"description": "Invalid request parameters"},
+			"404": map[string]interface{}{"description": "Resource not found"},
+			"502": map[string]interface{}{"description": "Upstream ArcGIS error"},
+		},
+	}
+	if len(parameters) > 0 {
+		get["parameters"] = parameters
+	}
+	return map[string]interface{}{"get": get}
+}
+
+// parameter describes an operation parameter
+func parameter(name, in, description string, required bool, schema map[string]interface{}) map[string]interface{} {
+	p := map[string]interface{}{
+		"name":        name,
+		"in":          in,
+		"description": description,
+		"required":    required,
+		"schema":      schema,
+	}
+	if name == "bbox" {
+		p["style"] = "form"
+		p["explode"] = false
+	}
+	return p
+}
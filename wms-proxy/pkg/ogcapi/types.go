@@ -0,0 +1,98 @@
+package ogcapi
+
+import (
+	"strings"
+)
+
+// Media types used by OGC API - Features
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeGeoJSON = "application/geo+json"
+	MediaTypeOpenAPI = "application/vnd.oai.openapi+json;version=3.0"
+)
+
+// Paging limits of the items endpoint
+const (
+	DefaultLimit = 10
+	MaxLimit     = 10000
+)
+
+// CRS84 is the default CRS of OGC API - Features (WGS84 longitude/latitude)
+const CRS84 = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+
+// ConformanceClasses lists the conformance classes implemented by the proxy
+var ConformanceClasses = []string{
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/oas30",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
+	"http://www.opengis.net/spec/ogcapi-features-2/1.0/conf/crs",
+}
+
+// SupportedCRS lists the CRSs features can be requested in, CRS84 first
+var SupportedCRS = []string{
+	CRS84,
+	CRSURI("EPSG:4326"),
+	CRSURI("EPSG:3857"),
+	CRSURI("EPSG:3424"),
+}
+
+// Link is a hypermedia link
+type Link struct {
+	Href  string `json:"href"`
+	Rel   string `json:"rel"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// LandingPage is the root document of the API
+type LandingPage struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Links       []Link `json:"links"`
+}
+
+// Conformance lists the implemented conformance classes
+type Conformance struct {
+	ConformsTo []string `json:"conformsTo"`
+}
+
+// Collections lists the feature collections
+type Collections struct {
+	Links       []Link       `json:"links"`
+	Collections []Collection `json:"collections"`
+	CRS         []string     `json:"crs,omitempty"`
+}
+
+// Collection describes a feature collection (an ArcGIS layer)
+type Collection struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Extent      *Extent  `json:"extent,omitempty"`
+	ItemType    string   `json:"itemType"`
+	CRS         []string `json:"crs"`
+	StorageCRS  string   `json:"storageCrs,omitempty"`
+	Links       []Link   `json:"links"`
+}
+
+// Extent is the spatial extent of a collection
+type Extent struct {
+	Spatial SpatialExtent `json:"spatial"`
+}
+
+// SpatialExtent holds bounding boxes in CRS84
+type SpatialExtent struct {
+	BBox [][]float64 `json:"bbox"`
+	CRS  string      `json:"crs"`
+}
+
+// Exception is the JSON error body of OGC API responses
+type Exception struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// CRSURI returns the OGC URI of an EPSG code such as EPSG:3857
+func CRSURI(epsg string) string {
+	return "http://www.opengis.net/def/crs/EPSG/0/" + strings.TrimPrefix(strings.ToUpper(epsg), "EPSG:")
+}
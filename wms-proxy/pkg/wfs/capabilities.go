@@ -0,0 +1,129 @@
+package wfs
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// Namespace and prefix of the feature types published by the proxy
+const (
+	FeatureNamespace = "urn:wms-proxy:features"
+	FeaturePrefix    = "esri"
+)
+
+// Output formats supported by GetFeature
+const (
+	FormatGML32   = "application/gml+xml; version=3.2"
+	FormatGeoJSON = "application/json"
+)
+
+// WFSCapabilities represents a WFS 2.0 GetCapabilities response
+type WFSCapabilities struct {
+	XMLName               xml.Name              `xml:"wfs:WFS_Capabilities"`
+	Version               string                `xml:"version,attr"`
+	XMLNSWFS              string                `xml:"xmlns:wfs,attr"`
+	XMLNSOWS              string                `xml:"xmlns:ows,attr"`
+	XMLNSXLink            string                `xml:"xmlns:xlink,attr"`
+	XMLNSFeatures         string                `xml:"xmlns:esri,attr"`
+	ServiceIdentification ServiceIdentification `xml:"ows:ServiceIdentification"`
+	OperationsMetadata    OperationsMetadata    `xml:"ows:OperationsMetadata"`
+	FeatureTypeList       FeatureTypeList       `xml:"wfs:FeatureTypeList"`
+}
+
+// ServiceIdentification describes the WFS service
+type ServiceIdentification struct {
+	Title              string `xml:"ows:Title"`
+	ServiceType        string `xml:"ows:ServiceType"`
+	ServiceTypeVersion string `xml:"ows:ServiceTypeVersion"`
+}
+
+// OperationsMetadata lists the supported operations and their endpoints
+type OperationsMetadata struct {
+	Operations []Operation `xml:"ows:Operation"`
+}
+
+// Operation is a supported WFS operation
+type Operation struct {
+	Name string `xml:"name,attr"`
+	DCP  DCP    `xml:"ows:DCP"`
+}
+
+// DCP holds the HTTP binding of an operation
+type DCP struct {
+	HTTP struct {
+		Get struct {
+			Href string `xml:"xlink:href,attr"`
+		} `xml:"ows:Get"`
+	} `xml:"ows:HTTP"`
+}
+
+// FeatureTypeList lists the published feature types
+type FeatureTypeList struct {
+	FeatureTypes []FeatureType `xml:"wfs:FeatureType"`
+}
+
+// FeatureType describes a published feature type
+type FeatureType struct {
+	Name          string        `xml:"wfs:Name"`
+	Title         string        `xml:"wfs:Title"`
+	DefaultCRS    string        `xml:"wfs:DefaultCRS"`
+	OtherCRS      []string      `xml:"wfs:OtherCRS"`
+	OutputFormats OutputFormats `xml:"wfs:OutputFormats"`
+}
+
+// OutputFormats lists the formats a feature type can be returned in
+type OutputFormats struct {
+	Formats []string `xml:"wfs:Format"`
+}
+
+// FeatureTypeInfo describes a feature type to advertise in capabilities
+type FeatureTypeInfo struct {
+	Name  string // unprefixed type name
+	Title string
+}
+
+// supportedCRS lists the output CRSs, default first
+var supportedCRS = []string{
+	"urn:ogc:def:crs:EPSG::4326",
+	"urn:ogc:def:crs:EPSG::3857",
+	"urn:ogc:def:crs:EPSG::3424",
+}
+
+// GenerateCapabilities creates a WFS 2.0 capabilities XML response
+func GenerateCapabilities(serviceURL string, featureTypes []FeatureTypeInfo) ([]byte, error) {
+	capabilities := WFSCapabilities{
+		Version:       "2.0.0",
+		XMLNSWFS:      "http://www.opengis.net/wfs/2.0",
+		XMLNSOWS:      "http://www.opengis.net/ows/1.1",
+		XMLNSXLink:    "http://www.w3.org/1999/xlink",
+		XMLNSFeatures: FeatureNamespace,
+		ServiceIdentification: ServiceIdentification{
+			Title:              "ArcGIS REST to WFS Proxy",
+			ServiceType:        "WFS",
+			ServiceTypeVersion: "2.0.0",
+		},
+	}
+
+	for _, name := range []string{"GetCapabilities", "DescribeFeatureType", "GetFeature"} {
+		op := Operation{Name: name}
+		op.DCP.HTTP.Get.Href = serviceURL
+		capabilities.OperationsMetadata.Operations = append(capabilities.OperationsMetadata.Operations, op)
+	}
+
+	for _, ft := range featureTypes {
+		capabilities.FeatureTypeList.FeatureTypes = append(capabilities.FeatureTypeList.FeatureTypes, FeatureType{
+			Name:          FeaturePrefix + ":" + ft.Name,
+			Title:         ft.Title,
+			DefaultCRS:    supportedCRS[0],
+			OtherCRS:      supportedCRS[1:],
+			OutputFormats: OutputFormats{Formats: []string{FormatGML32, FormatGeoJSON}},
+		})
+	}
+
+	xmlData, err := xml.MarshalIndent(capabilities, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal WFS capabilities XML: %w", err)
+	}
+
+	return []byte(xml.Header + string(xmlData)), nil
+}
@@ -0,0 +1,96 @@
+package wfs
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// XSDSchema represents a DescribeFeatureType application schema
+type XSDSchema struct {
+	XMLName            xml.Name         `xml:"xsd:schema"`
+	XMLNSXSD           string           `xml:"xmlns:xsd,attr"`
+	XMLNSGML           string           `xml:"xmlns:gml,attr"`
+	XMLNSFeatures      string           `xml:"xmlns:esri,attr"`
+	TargetNamespace    string           `xml:"targetNamespace,attr"`
+	ElementFormDefault string           `xml:"elementFormDefault,attr"`
+	Import             XSDImport        `xml:"xsd:import"`
+	ComplexTypes       []XSDComplexType `xml:"xsd:complexType"`
+	Elements           []XSDElement     `xml:"xsd:element"`
+}
+
+// XSDImport imports the GML schema
+type XSDImport struct {
+	Namespace      string `xml:"namespace,attr"`
+	SchemaLocation string `xml:"schemaLocation,attr"`
+}
+
+// XSDComplexType declares a feature type as an extension of gml:AbstractFeatureType
+type XSDComplexType struct {
+	Name      string `xml:"name,attr"`
+	Extension struct {
+		Base     string       `xml:"base,attr"`
+		Elements []XSDElement `xml:"xsd:sequence>xsd:element"`
+	} `xml:"xsd:complexContent>xsd:extension"`
+}
+
+// XSDElement declares a property or a feature element
+type XSDElement struct {
+	Name              string `xml:"name,attr"`
+	Type              string `xml:"type,attr"`
+	SubstitutionGroup string `xml:"substitutionGroup,attr,omitempty"`
+	MinOccurs         string `xml:"minOccurs,attr,omitempty"`
+	Nillable          string `xml:"nillable,attr,omitempty"`
+}
+
+// PropertyInfo describes a feature property in an application schema
+type PropertyInfo struct {
+	Name string
+	Type string // qualified XSD or GML type, e.g. xsd:string or gml:GeometryPropertyType
+}
+
+// FeatureTypeSchema describes one feature type in an application schema
+type FeatureTypeSchema struct {
+	Name       string
+	Properties []PropertyInfo
+}
+
+// GenerateFeatureTypeSchema creates the XML schema returned by DescribeFeatureType
+func GenerateFeatureTypeSchema(featureTypes []FeatureTypeSchema) ([]byte, error) {
+	schema := XSDSchema{
+		XMLNSXSD:           "http://www.w3.org/2001/XMLSchema",
+		XMLNSGML:           "http://www.opengis.net/gml/3.2",
+		XMLNSFeatures:      FeatureNamespace,
+		TargetNamespace:    FeatureNamespace,
+		ElementFormDefault: "qualified",
+		Import: XSDImport{
+			Namespace:      "http://www.opengis.net/gml/3.2",
+			SchemaLocation: "http://schemas.opengis.net/gml/3.2.1/gml.xsd",
+		},
+	}
+
+	for _, ft := range featureTypes {
+		complexType := XSDComplexType{Name: ft.Name + "Type"}
+		complexType.Extension.Base = "gml:AbstractFeatureType"
+		for _, p := range ft.Properties {
+			complexType.Extension.Elements = append(complexType.Extension.Elements, XSDElement{
+				Name:      p.Name,
+				Type:      p.Type,
+				MinOccurs: "0",
+				Nillable:  "true",
+			})
+		}
+		schema.ComplexTypes = append(schema.ComplexTypes, complexType)
+		schema.Elements = append(schema.Elements, XSDElement{
+			Name:              ft.Name,
+			Type:              FeaturePrefix + ":" + ft.Name + "Type",
+			SubstitutionGroup: "gml:AbstractFeature",
+		})
+	}
+
+	xmlData, err := xml.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal feature type schema: %w", err)
+	}
+
+	return []byte(xml.Header + string(xmlData)), nil
+}
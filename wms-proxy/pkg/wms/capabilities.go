@@ -7,9 +7,87 @@ import (
 
 // WMSCapabilities represents a basic WMS GetCapabilities response
 type WMSCapabilities struct {
-	XMLName xml.Name `xml:"WMS_Capabilities"`
-	Version string   `xml:"version,attr"`
-	Service Service  `xml:"Service"`
+	XMLName    xml.Name    `xml:"WMS_Capabilities"`
+	Version    string      `xml:"version,attr"`
+	Service    Service     `xml:"Service"`
+	Capability *Capability `xml:"Capability,omitempty"`
+}
+
+// Capability holds the layer tree advertised by the service
+type Capability struct {
+	Layer Layer `xml:"Layer"`
+}
+
+// Layer represents a WMS layer and the dimensions it supports
+type Layer struct {
+	Name              string             `xml:"Name,omitempty"`
+	Title             string             `xml:"Title"`
+	SRS               string             `xml:"SRS,omitempty"`
+	LatLonBoundingBox *LatLonBoundingBox `xml:"LatLonBoundingBox,omitempty"`
+	BoundingBox       *BoundingBox       `xml:"BoundingBox,omitempty"`
+	Styles            []Style            `xml:"Style,omitempty"`
+	Dimensions        []Dimension        `xml:"Dimension,omitempty"`
+	Extents           []Extent           `xml:"Extent,omitempty"`
+	Layers            []Layer            `xml:"Layer,omitempty"`
+}
+
+// LatLonBoundingBox is the extent of a layer in geographic coordinates
+type LatLonBoundingBox struct {
+	MinX float64 `xml:"minx,attr"`
+	MinY float64 `xml:"miny,attr"`
+	MaxX float64 `xml:"maxx,attr"`
+	MaxY float64 `xml:"maxy,attr"`
+}
+
+// BoundingBox is the extent of a layer in one of its spatial reference systems
+type BoundingBox struct {
+	SRS  string  `xml:"SRS,attr"`
+	MinX float64 `xml:"minx,attr"`
+	MinY float64 `xml:"miny,attr"`
+	MaxX float64 `xml:"maxx,attr"`
+	MaxY float64 `xml:"maxy,attr"`
+}
+
+// ExtentInfo describes the extent of the service to advertise in capabilities
+type ExtentInfo struct {
+	SRS       string     // native spatial reference of the service
+	BBox      [4]float64 // minx, miny, maxx, maxy in SRS
+	LatLon    [4]float64 // the extent in EPSG:4326
+	HasLatLon bool
+}
+
+// Style names a style that can be requested for a layer through STYLES
+type Style struct {
+	Name  string `xml:"Name"`
+	Title string `xml:"Title"`
+}
+
+// Dimension declares a WMS dimension (TIME, ELEVATION or a custom one)
+type Dimension struct {
+	Name  string `xml:"name,attr"`
+	Units string `xml:"units,attr"`
+}
+
+// Extent lists the valid values of a declared dimension
+type Extent struct {
+	Name    string `xml:"name,attr"`
+	Default string `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// DimensionInfo describes a dimension to advertise in capabilities
+type DimensionInfo struct {
+	Name    string
+	Units   string
+	Default string
+	Extent  string
+}
+
+// LayerInfo describes a named layer to advertise in capabilities
+type LayerInfo struct {
+	Name   string
+	Title  string
+	Styles []string // style names, e.g. ImageServer raster functions
 }
 
 // Service represents the WMS service information
@@ -28,6 +106,24 @@ type OnlineResource struct {
 
 // GenerateCapabilities creates a basic WMS capabilities XML response
 func GenerateCapabilities(baseURL string) ([]byte, error) {
+	return GenerateCapabilitiesWithDimensions(baseURL, nil)
+}
+
+// GenerateCapabilitiesWithDimensions creates a WMS capabilities XML response
+// whose root layer advertises the given dimensions
+func GenerateCapabilitiesWithDimensions(baseURL string, dimensions []DimensionInfo) ([]byte, error) {
+	return GenerateCapabilitiesWithLayers(baseURL, nil, dimensions)
+}
+
+// GenerateCapabilitiesWithLayers creates a WMS capabilities XML response with
+// the given layers nested under a root layer that advertises the dimensions
+func GenerateCapabilitiesWithLayers(baseURL string, layers []LayerInfo, dimensions []DimensionInfo) ([]byte, error) {
+	return GenerateCapabilitiesWithExtent(baseURL, layers, dimensions, nil)
+}
+
+// GenerateCapabilitiesWithExtent creates a WMS capabilities XML response whose
+// root layer also advertises the extent of the service
+func GenerateCapabilitiesWithExtent(baseURL string, layers []LayerInfo, dimensions []DimensionInfo, extent *ExtentInfo) ([]byte, error) {
 	capabilities := WMSCapabilities{
 		Version: "1.1.1",
 		Service: Service{
@@ -40,6 +136,29 @@ func GenerateCapabilities(baseURL string) ([]byte, error) {
 		},
 	}
 
+	if len(dimensions) > 0 || len(layers) > 0 || extent != nil {
+		layer := Layer{Title: "ArcGIS REST to WMS Proxy"}
+		if extent != nil {
+			layer.SRS = extent.SRS
+			layer.BoundingBox = &BoundingBox{SRS: extent.SRS, MinX: extent.BBox[0], MinY: extent.BBox[1], MaxX: extent.BBox[2], MaxY: extent.BBox[3]}
+			if extent.HasLatLon {
+				layer.LatLonBoundingBox = &LatLonBoundingBox{MinX: extent.LatLon[0], MinY: extent.LatLon[1], MaxX: extent.LatLon[2], MaxY: extent.LatLon[3]}
+			}
+		}
+		for _, dim := range dimensions {
+			layer.Dimensions = append(layer.Dimensions, Dimension{Name: dim.Name, Units: dim.Units})
+			layer.Extents = append(layer.Extents, Extent{Name: dim.Name, Default: dim.Default, Value: dim.Extent})
+		}
+		for _, l := range layers {
+			child := Layer{Name: l.Name, Title: l.Title}
+			for _, style := range l.Styles {
+				child.Styles = append(child.Styles, Style{Name: style, Title: style})
+			}
+			layer.Layers = append(layer.Layers, child)
+		}
+		capabilities.Capability = &Capability{Layer: layer}
+	}
+
 	// Add XML header
 	xmlData, err := xml.MarshalIndent(capabilities, "", "  ")
 	if err != nil {
@@ -0,0 +1,175 @@
+package wms
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeRange represents a parsed WMS TIME value as an inclusive interval.
+// Instants are represented with Start equal to End.
+type TimeRange struct {
+	Start  time.Time
+	End    time.Time
+	Period string // ISO 8601 resolution (e.g. "PT1H") when given as start/end/period
+}
+
+// NumericRange represents a parsed ELEVATION or custom dimension value
+type NumericRange struct {
+	Min float64
+	Max float64
+}
+
+// timeLayouts lists the ISO 8601 forms accepted in TIME values, most specific first
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02T15Z07:00",
+	"2006-01-02T15",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// isoPeriodPattern matches ISO 8601 durations such as P1Y2M10DT2H30M or PT15M
+var isoPeriodPattern = regexp.MustCompile(`^P(?:\d+Y)?(?:\d+M)?(?:\d+W)?(?:\d+D)?(?:T(?:\d+H)?(?:\d+M)?(?:\d+(?:\.\d+)?S)?)?$`)
+
+// ParseTime parses a WMS TIME parameter value.
+// Supported forms are a single instant, a comma-separated list of instants,
+// a start/end range and a start/end/period range. Lists are collapsed to the
+// interval covering all of their values, since ArcGIS accepts a single extent.
+func ParseTime(value string) (*TimeRange, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty TIME value")
+	}
+
+	var result *TimeRange
+	for _, item := range strings.Split(value, ",") {
+		tr, err := parseTimeItem(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = tr
+			continue
+		}
+		if tr.Start.Before(result.Start) {
+			result.Start = tr.Start
+		}
+		if tr.End.After(result.End) {
+			result.End = tr.End
+		}
+		result.Period = ""
+	}
+
+	return result, nil
+}
+
+// parseTimeItem parses a single instant or range from a TIME list
+func parseTimeItem(item string) (*TimeRange, error) {
+	parts := strings.Split(item, "/")
+	switch len(parts) {
+	case 1:
+		instant, err := parseInstant(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return &TimeRange{Start: instant, End: instant}, nil
+	case 2, 3:
+		start, err := parseInstant(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseInstant(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("invalid TIME range %q: end is before start", item)
+		}
+		tr := &TimeRange{Start: start, End: end}
+		if len(parts) == 3 {
+			period := strings.ToUpper(strings.TrimSpace(parts[2]))
+			if period == "P" || !isoPeriodPattern.MatchString(period) {
+				return nil, fmt.Errorf("invalid TIME period %q", parts[2])
+			}
+			tr.Period = period
+		}
+		return tr, nil
+	default:
+		return nil, fmt.Errorf("invalid TIME value %q", item)
+	}
+}
+
+// parseInstant parses an ISO 8601 instant, treating values without a zone as UTC.
+// "present" and "current", as advertised for live data, mean the current time.
+func parseInstant(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "present") || strings.EqualFold(s, "current") {
+		return time.Now().UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid TIME instant %q", s)
+}
+
+// ParseNumericDimension parses an ELEVATION or custom dimension value.
+// Supported forms are a single value, a comma-separated list and a min/max
+// or min/max/resolution range. Lists are collapsed to their covering range.
+func ParseNumericDimension(value string) (*NumericRange, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty dimension value")
+	}
+
+	var values []float64
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), "/")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid dimension value %q", item)
+		}
+		// A trailing resolution does not change the requested extent
+		if len(parts) == 3 {
+			parts = parts[:2]
+		}
+		for _, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dimension value %q", part)
+			}
+			values = append(values, v)
+		}
+		if len(parts) == 2 && values[len(values)-1] < values[len(values)-2] {
+			return nil, fmt.Errorf("invalid dimension range %q: max is below min", item)
+		}
+	}
+
+	sort.Float64s(values)
+	return &NumericRange{Min: values[0], Max: values[len(values)-1]}, nil
+}
+
+// EpochMillis returns the range as ArcGIS "start,end" epoch milliseconds.
+// Instants are sent as a single value.
+func (tr *TimeRange) EpochMillis() string {
+	start := tr.Start.UnixMilli()
+	end := tr.End.UnixMilli()
+	if start == end {
+		return strconv.FormatInt(start, 10)
+	}
+	return fmt.Sprintf("%d,%d", start, end)
+}
+
+// FormatISO8601 formats a time for use in capabilities dimension extents
+func FormatISO8601(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -8,19 +8,27 @@ import (
 
 // WMSParams represents parsed WMS request parameters
 type WMSParams struct {
-	Service     string
-	Version     string
-	Request     string
-	Layers      string
-	Styles      string
-	Format      string
-	Transparent string
-	BGColor     string
-	SRS         string
-	CRS         string
-	BBOX        string
-	Width       int
-	Height      int
+	Service       string
+	Version       string
+	Request       string
+	Layers        string
+	Styles        string
+	Format        string
+	Transparent   string
+	BGColor       string
+	SRS           string
+	CRS           string
+	BBOX          string
+	Width         int
+	Height        int
+	Time          string
+	Elevation     string
+	Dimensions    map[string]string // custom DIM_<name> values keyed by lower-case name
+	Filter        string            // CQL_FILTER (or FILTER) attribute filter
+	FormatOptions string            // FORMAT_OPTIONS vendor parameter, e.g. "quality:80"
+	RenderingRule string            // RENDERINGRULE vendor parameter: raster function name or JSON rendering rule (ImageServer)
+	Interpolation string            // INTERPOLATION vendor parameter: nearest, bilinear, cubic or majority (ImageServer)
+	Compression   string            // COMPRESSION vendor parameter: none, lz77 or jpeg[:quality] (ImageServer)
 }
 
 // ArcGISParams represents ArcGIS REST API parameters
@@ -34,6 +42,15 @@ type ArcGISParams struct {
 	Transparent string
 	DPI         int
 	F           string
+	Time        string // epoch milliseconds, "instant" or "start,end"
+	RangeValues string // JSON rangeValues for elevation and custom dimensions
+	LayerDefs   string // JSON layerDefs built from CQL_FILTER
+
+	// ImageServer exportImage parameters
+	RenderingRule      string // JSON rendering rule, e.g. {"rasterFunction":"Hillshade"}
+	Interpolation      string // RSP_* resampling method
+	Compression        string // None, LZ77 or JPEG
+	CompressionQuality int    // JPEG compression quality (0 uses the server default)
 }
 
 // ParseWMSParams extracts WMS parameters from query values
@@ -63,9 +80,37 @@ func ParseWMSParams(queryParams map[string][]string) (*WMSParams, error) {
 	params.Format = getValue("FORMAT")
 	params.Transparent = getValue("TRANSPARENT")
 	params.BGColor = getValue("BGCOLOR")
+	params.FormatOptions = getValue("FORMAT_OPTIONS")
+	params.RenderingRule = getValue("RENDERINGRULE")
+	params.Interpolation = getValue("INTERPOLATION")
+	params.Compression = getValue("COMPRESSION")
 	params.SRS = getValue("SRS")
 	params.CRS = getValue("CRS")
 	params.BBOX = getValue("BBOX")
+	params.Time = getValue("TIME")
+	params.Elevation = getValue("ELEVATION")
+
+	// CQL_FILTER is the common vendor parameter; FILTER is accepted as an alias
+	// when it carries CQL rather than OGC XML filter encoding
+	params.Filter = getValue("CQL_FILTER")
+	if params.Filter == "" {
+		if filter := getValue("FILTER"); filter != "" {
+			if strings.HasPrefix(strings.TrimSpace(filter), "<") {
+				return nil, fmt.Errorf("XML FILTER encoding is not supported, use CQL_FILTER")
+			}
+			params.Filter = filter
+		}
+	}
+
+	// Collect vendor-specific dimensions sent as DIM_<name>
+	for key, values := range queryParams {
+		if len(key) > 4 && strings.EqualFold(key[:4], "DIM_") && len(values) > 0 {
+			if params.Dimensions == nil {
+				params.Dimensions = make(map[string]string)
+			}
+			params.Dimensions[strings.ToLower(key[4:])] = values[0]
+		}
+	}
 
 	// Parse width and height
 	if widthStr := getValue("WIDTH"); widthStr != "" {